@@ -14,7 +14,7 @@ func main() {
 
 	// Cria a camada de serviço para publicação de eventos e tratamento de subscrições
 	eventSvc := services.NewEventService(appState)
-	subSvc := services.NewSubscriptionService(appState)
+	subSvc := services.NewSubscriptionService(appState, eventSvc)
 
 	// Configura o gerenciador de comandos para lidar com comandos do usuário com injeção de dependências
 	cmdManager := commands.NewManager(eventSvc, appState)
@@ -26,11 +26,20 @@ func main() {
 	})
 	mux.Register("chat", cmdManager.ExecChat)
 	mux.Register("login", cmdManager.ExecLogin)
+	mux.Register("logout", cmdManager.ExecLogout)
+	mux.Register("passwd", cmdManager.ExecPasswd)
+	mux.Register("profile", cmdManager.ExecProfile)
+	mux.Register("deleteaccount", cmdManager.ExecDeleteAccount)
 	mux.Register("register", cmdManager.ExecRegister)
 	mux.Register("start", cmdManager.ExecStart)
 	mux.Register("play", cmdManager.ExecPlay)
 	mux.Register("surrender", cmdManager.ExecSurrender)
 	mux.Register("join", cmdManager.ExecJoin)
+	mux.Register("mute", cmdManager.ExecMute)
+	mux.Register("ban", cmdManager.ExecBan)
+	mux.Register("unban", cmdManager.ExecUnban)
+	mux.Register("grant", cmdManager.ExecGrant)
+	mux.Register("cancelmatch", cmdManager.ExecCancelMatch)
 	mux.Register("clear", cmdManager.ExecClear)
 	mux.Register("help", cmdManager.ExecHelp)
 	mux.Register("exit", cmdManager.ExecExit)
@@ -6,6 +6,7 @@ import (
 
 	// "fmt"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -30,6 +31,10 @@ func (m *Manager) ExecChat(args []string) error {
 		m.appState.Chat.Write("You must be logged in to chat. Use /login <user> <pass>")
 		return nil // Retornar nil previne mensagem "Error: ..." na UI
 	}
+	if m.appState.Sanctions.Blocked(m.appState.UserID) {
+		m.appState.Chat.Write("You are muted or banned in this room.")
+		return nil
+	}
 	event := m.eventSvc.CreateChatEvent(args)
 	return m.eventSvc.Publish(event)
 }
@@ -54,6 +59,51 @@ func (m *Manager) ExecRegister(args []string) error {
 	return m.eventSvc.Publish(event)
 }
 
+// ExecLogout encerra a sessão atual, revogando os tokens no servidor.
+func (m *Manager) ExecLogout(args []string) error {
+	if m.appState.UserID == "" {
+		m.appState.Chat.Write("You are not logged in.")
+		return nil
+	}
+	event := m.eventSvc.CreateLogoutEvent()
+	return m.eventSvc.Publish(event)
+}
+
+// ExecPasswd troca a senha do usuário logado.
+func (m *Manager) ExecPasswd(args []string) error {
+	if m.appState.UserID == "" {
+		m.appState.Chat.Write("You are not logged in.")
+		return nil
+	}
+	if len(args) < 2 {
+		m.appState.Chat.Write("Usage: /passwd <old_password> <new_password>")
+		return nil
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateChangePasswordEvent(args[0], args[1]))
+}
+
+// ExecProfile mostra o perfil do usuário logado.
+func (m *Manager) ExecProfile(args []string) error {
+	if m.appState.UserID == "" {
+		m.appState.Chat.Write("You are not logged in.")
+		return nil
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateGetProfileEvent())
+}
+
+// ExecDeleteAccount exclui a conta do usuário logado; exige a senha como confirmação.
+func (m *Manager) ExecDeleteAccount(args []string) error {
+	if m.appState.UserID == "" {
+		m.appState.Chat.Write("You are not logged in.")
+		return nil
+	}
+	if len(args) < 1 {
+		m.appState.Chat.Write("Usage: /deleteaccount <password> (cards are burned and open matches are forfeited)")
+		return nil
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateDeleteAccountEvent(args[0]))
+}
+
 // ExecClear clears the chat window display.
 func (m *Manager) ExecClear(args []string) error {
 	m.appState.Chat.Clear()
@@ -64,11 +114,20 @@ func (m *Manager) ExecHelp(args []string) error {
 	helpText := `Available commands:
 /register <username> <password> - Register a new user
 /login <username> <password>    - Login as an existing user
+/logout                       - Logout and revoke the current session
+/passwd <old> <new>           - Change your password
+/profile                      - Show your profile and match record
+/deleteaccount <password>     - Delete your account, burning its cards
 /chat <message>               - Send a chat message (or just type without a '/')
 /start                        - Start a new game
 /play <card_id>               - Play a card in game
 /surrender                    - Surrender current game
 /join <game_id>               - Join a game
+/mute <user_id> [minutes]     - Mute a user in this room (moderators)
+/ban <user_id>                - Ban a user from this room (moderators)
+/unban <user_id>              - Lift a mute or ban (moderators)
+/grant <user_id> [count]      - Grant cards to a user (admins)
+/cancelmatch <match_id>       - Cancel a match (admins)
 /clear                        - Clear the chat window
 /help                         - Show this help message
 /exit                         - Exit the application`
@@ -124,6 +183,69 @@ func (m *Manager) ExecJoin(args []string) error {
 	return m.eventSvc.Publish(event)
 }
 
+// ExecMute silencia um usuário na sala atual; o servidor confere se o usuário é moderador.
+func (m *Manager) ExecMute(args []string) error {
+	if len(args) < 1 {
+		m.appState.Chat.Write("Usage: /mute <user_id> [minutes]")
+		return nil
+	}
+	duration := 10 * time.Minute
+	if len(args) > 1 {
+		minutes, err := strconv.Atoi(args[1])
+		if err != nil || minutes <= 0 {
+			m.appState.Chat.Write("Usage: /mute <user_id> [minutes]")
+			return nil
+		}
+		duration = time.Duration(minutes) * time.Minute
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateModerationEvent("mute_user", args[0], duration))
+}
+
+// ExecBan bane um usuário da sala atual.
+func (m *Manager) ExecBan(args []string) error {
+	if len(args) < 1 {
+		m.appState.Chat.Write("Usage: /ban <user_id>")
+		return nil
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateModerationEvent("ban_user", args[0], 0))
+}
+
+// ExecUnban remove silêncio ou banimento de um usuário na sala atual.
+func (m *Manager) ExecUnban(args []string) error {
+	if len(args) < 1 {
+		m.appState.Chat.Write("Usage: /unban <user_id>")
+		return nil
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateModerationEvent("unban_user", args[0], 0))
+}
+
+// ExecGrant concede cartas a um usuário; exige papel de administrador no servidor.
+func (m *Manager) ExecGrant(args []string) error {
+	if len(args) < 1 {
+		m.appState.Chat.Write("Usage: /grant <user_id> [count]")
+		return nil
+	}
+	count := 5
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			m.appState.Chat.Write("Usage: /grant <user_id> [count]")
+			return nil
+		}
+		count = n
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateGrantCardsEvent(args[0], count))
+}
+
+// ExecCancelMatch encerra uma partida sem vencedor; exige papel de administrador no servidor.
+func (m *Manager) ExecCancelMatch(args []string) error {
+	if len(args) < 1 {
+		m.appState.Chat.Write("Usage: /cancelmatch <match_id>")
+		return nil
+	}
+	return m.eventSvc.Publish(m.eventSvc.CreateCancelMatchEvent(args[0]))
+}
+
 // ExecExit gracefully closes the MQTT connection and terminates the application.
 func (m *Manager) ExecExit(args []string) error {
 	m.appState.Chat.Write("Exiting chat...")
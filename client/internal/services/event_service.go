@@ -5,22 +5,40 @@ import (
 	"cod-client/internal/state"
 	"encoding/json"
 	"fmt"
+	shared_protocol "shared/protocol"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // EventService encapsula a lógica de criação e publicação de eventos.
 type EventService struct {
 	appState *state.State
+
+	requestSeq atomic.Uint64
+	pendingMu  sync.Mutex
+	pending    map[string]string // método -> request_id do último pedido
 }
 
 // NewEventService cria uma nova instância de EventService.
 func NewEventService(s *state.State) *EventService {
-	return &EventService{appState: s}
+	return &EventService{appState: s, pending: make(map[string]string)}
 }
 
 // createEvent é um helper genérico que constrói um Event com campos padrão.
+// Todo evento carrega um request_id, devolvido na resposta, e o token da sessão quando o
+// usuário já está logado. O client_id vem do tópico em que o pedido é publicado.
 func (s *EventService) createEvent(method string, payload map[string]interface{}) protocol.Event {
+	requestID := s.appState.ClientID + "-" + strconv.FormatUint(s.requestSeq.Add(1), 10)
+	payload["request_id"] = requestID
+	if s.appState.Token != "" {
+		payload["token"] = s.appState.Token
+	}
+	s.pendingMu.Lock()
+	s.pending[method] = requestID
+	s.pendingMu.Unlock()
 	return protocol.Event{
 		Method:    method,
 		Timestamp: time.Now(),
@@ -32,8 +50,10 @@ func (s *EventService) createEvent(method string, payload map[string]interface{}
 // As rotas são baseadas no método do evento e contexto (userID, roomID, etc).
 func (s *EventService) inferTopicFor(event protocol.Event) string {
 	switch event.Method {
-	case "register", "login":
+	case "register", "login", "refresh", "logout":
 		return "user/" + event.Method
+	case "change_password", "delete_account", "get_profile":
+		return "user/account"
 	case "chat":
 		return "chat/room/" + s.appState.RoomID
 	case "start":
@@ -48,18 +68,31 @@ func (s *EventService) inferTopicFor(event protocol.Event) string {
 		return "store/buy"
 	case "exchange":
 		return "cards/" + s.appState.RoomID + "/exchange" + s.appState.UserID
+	case "mute_user", "ban_user", "unban_user", "grant_cards", "cancel_match":
+		return "admin/" + event.Method
 	default:
 		return ""
 	}
 }
 
+// Expects informa se requestID é o do último pedido de method, ou seja, se a resposta é
+// para este cliente e não para um pedido anterior já substituído.
+func (s *EventService) Expects(method, requestID string) bool {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	return requestID != "" && s.pending[method] == requestID
+}
+
 // Publish serializa um evento em JSON e o publica no tópico MQTT apropriado.
+// Todos os pedidos, inclusive o chat, vão para requests/{client_id}/<tópico>; o servidor
+// repassa o chat para a sala depois de conferir as sanções.
 // Retorna um erro se o tópico for desconhecido ou se a publicação falhar.
 func (s *EventService) Publish(event protocol.Event) error {
 	topic := s.inferTopicFor(event)
 	if topic == "" {
 		return fmt.Errorf("unknown topic for method: %s", event.Method)
 	}
+	topic = shared_protocol.RequestTopic(s.appState.ClientID, topic)
 
 	payload, err := json.Marshal(event)
 	if err != nil {
@@ -82,13 +115,14 @@ func (s *EventService) CreateChatEvent(args []string) protocol.Event {
 		return s.createEvent("chat", map[string]interface{}{
 			"error":   "message content cannot be empty",
 			"content": content,
-			"user_id": s.appState.UserID,
+			"room_id": s.appState.RoomID,
 		})
 	}
 
+	// O autor é o usuário do token; o servidor o acrescenta ao repassar a mensagem
 	return s.createEvent("chat", map[string]interface{}{
 		"content": content,
-		"user_id": s.appState.UserID,
+		"room_id": s.appState.RoomID,
 	})
 }
 
@@ -168,6 +202,45 @@ func (s *EventService) CreateLoginEvent(args []string) protocol.Event {
 	})
 }
 
+// CreateRefreshEvent constrói um evento para trocar o token de refresh por um novo par de tokens.
+func (s *EventService) CreateRefreshEvent() protocol.Event {
+	return s.createEvent("refresh", map[string]interface{}{
+		"refresh_token": s.appState.RefreshToken,
+	})
+}
+
+// CreateLogoutEvent constrói um evento que revoga a sessão atual no servidor.
+func (s *EventService) CreateLogoutEvent() protocol.Event {
+	return s.createEvent("logout", map[string]interface{}{
+		"user_id":       s.appState.UserID,
+		"refresh_token": s.appState.RefreshToken,
+	})
+}
+
+// CreateChangePasswordEvent constrói um evento de troca de senha; o servidor devolve novos tokens.
+func (s *EventService) CreateChangePasswordEvent(oldPassword, newPassword string) protocol.Event {
+	return s.createEvent("change_password", map[string]interface{}{
+		"user_id":      s.appState.UserID,
+		"old_password": oldPassword,
+		"new_password": newPassword,
+	})
+}
+
+// CreateDeleteAccountEvent constrói um evento de exclusão da conta, confirmado pela senha.
+func (s *EventService) CreateDeleteAccountEvent(password string) protocol.Event {
+	return s.createEvent("delete_account", map[string]interface{}{
+		"user_id":  s.appState.UserID,
+		"password": password,
+	})
+}
+
+// CreateGetProfileEvent constrói um evento que pede o perfil do usuário logado.
+func (s *EventService) CreateGetProfileEvent() protocol.Event {
+	return s.createEvent("get_profile", map[string]interface{}{
+		"user_id": s.appState.UserID,
+	})
+}
+
 // CreateStartGameEvent builds an event to initiate a new game session.
 func (s *EventService) CreateStartGameEvent() protocol.Event {
 	return s.createEvent("start", map[string]interface{}{
@@ -217,3 +290,34 @@ func (s *EventService) CreateExchangeEvent(cardIDs []string) protocol.Event {
 		"card_ids": cardIDs,
 	})
 }
+
+// CreateModerationEvent constrói um evento de moderação (mute_user, ban_user ou unban_user)
+// para um usuário da sala atual. A duração só é usada por mute_user.
+func (s *EventService) CreateModerationEvent(method, targetUserID string, duration time.Duration) protocol.Event {
+	payload := map[string]interface{}{
+		"user_id":        s.appState.UserID,
+		"room_id":        s.appState.RoomID,
+		"target_user_id": targetUserID,
+	}
+	if duration > 0 {
+		payload["duration_seconds"] = int(duration.Seconds())
+	}
+	return s.createEvent(method, payload)
+}
+
+// CreateGrantCardsEvent constrói um evento administrativo que concede cartas a um usuário.
+func (s *EventService) CreateGrantCardsEvent(targetUserID string, count int) protocol.Event {
+	return s.createEvent("grant_cards", map[string]interface{}{
+		"user_id":        s.appState.UserID,
+		"target_user_id": targetUserID,
+		"count":          count,
+	})
+}
+
+// CreateCancelMatchEvent constrói um evento administrativo que encerra uma partida.
+func (s *EventService) CreateCancelMatchEvent(matchID string) protocol.Event {
+	return s.createEvent("cancel_match", map[string]interface{}{
+		"user_id":  s.appState.UserID,
+		"match_id": matchID,
+	})
+}
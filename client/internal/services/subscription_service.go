@@ -5,6 +5,9 @@ import (
 	"cod-client/internal/state"
 	"encoding/json"
 	"fmt"
+	shared_protocol "shared/protocol"
+	"sync"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
@@ -12,11 +15,15 @@ import (
 // SubscriptionService encapsula a lógica de inscrição e manipulação de eventos recebidos.
 type SubscriptionService struct {
 	appState *state.State
+	eventSvc *EventService // Usado para renovar o token automaticamente
+
+	refreshMu    sync.Mutex
+	refreshTimer *time.Timer
 }
 
 // NewSubscriptionService cria uma nova instância de SubscriptionService.
-func NewSubscriptionService(s *state.State) *SubscriptionService {
-	return &SubscriptionService{appState: s}
+func NewSubscriptionService(s *state.State, eventSvc *EventService) *SubscriptionService {
+	return &SubscriptionService{appState: s, eventSvc: eventSvc}
 }
 
 // subscribe é um helper para subscrever a um tópico com um dado manipulador de mensagem.
@@ -27,12 +34,37 @@ func (s *SubscriptionService) subscribe(topic string, handler mqtt.MessageHandle
 	}
 }
 
+// subscribeReplies assina o tópico privado de respostas de method e só repassa ao handler
+// a resposta do último pedido desse método feito por este cliente.
+func (s *SubscriptionService) subscribeReplies(method string, handler func(event protocol.Event)) {
+	s.subscribe(shared_protocol.ReplyTopic(s.appState.ClientID, method), func(c mqtt.Client, m mqtt.Message) {
+		event, err := s.decodeEvent(m)
+		if err != nil {
+			return
+		}
+		if requestID, _ := event.Payload["request_id"].(string); !s.eventSvc.Expects(method, requestID) {
+			return
+		}
+		handler(event)
+	})
+}
+
 // SubscribeToAll gerencia todas as subscrições de tópicos MQTT necessárias pela aplicação.
-// Os tópicos incluem salas de chat, respostas de registro de usuário e respostas de login.
+// Os tópicos incluem a sala de chat e as respostas privadas aos pedidos deste cliente.
 func (s *SubscriptionService) SubscribeToAll() {
 	s.subscribe("chat/room/"+s.appState.RoomID, s.onChatEvent)
-	s.subscribe("user/register/events", s.onRegisterEvent)
-	s.subscribe("user/login/events", s.onLoginEvent)
+	s.subscribe("chat/room/"+s.appState.RoomID+"/moderation", s.onModerationEvent)
+	s.subscribeReplies("register", s.onRegisterEvent)
+	s.subscribeReplies("login", s.onLoginEvent)
+	s.subscribeReplies("refresh", s.onRefreshEvent)
+	s.subscribeReplies("logout", s.onLogoutEvent)
+	for _, method := range []string{"change_password", "delete_account", "get_profile"} {
+		s.subscribeReplies(method, s.onAccountEvent)
+	}
+	for _, method := range []string{"mute_user", "ban_user", "unban_user"} {
+		s.subscribeReplies(method, s.onModerationReply)
+	}
+	s.subscribeReplies("chat", s.onChatReply)
 }
 
 // decodeEvent é um helper para desserializar um payload de mensagem MQTT em uma struct Event.
@@ -48,20 +80,23 @@ func (s *SubscriptionService) decodeEvent(msg mqtt.Message) (protocol.Event, err
 
 // --- Manipuladores de eventos para tópicos subscritos ---
 
-// onLoginEvent processa eventos de resposta de login e atualiza o estado da aplicação se bem-sucedido.
-func (s *SubscriptionService) onLoginEvent(c mqtt.Client, m mqtt.Message) {
-	event, err := s.decodeEvent(m)
-	if err != nil {
-		return
-	}
-
+// onLoginEvent processa a resposta do login deste cliente e atualiza o estado da aplicação se bem-sucedido.
+func (s *SubscriptionService) onLoginEvent(event protocol.Event) {
 	if status, ok := event.Payload["status"].(string); ok {
 		if status == "success" {
 			if newUserID, ok := event.Payload["user_id"].(string); ok {
 				s.appState.UserID = newUserID
+				s.storeTokens(event)
 				s.appState.Chat.Write("Login successful!")
 			}
 		} else {
+			code, _ := event.Payload["code"].(string)
+			if code == "account_locked" || code == "source_locked" {
+				if until, err := time.Parse(time.RFC3339Nano, fmt.Sprint(event.Payload["locked_until"])); err == nil {
+					s.appState.Chat.Write("Login blocked after too many failed attempts. Try again at " + until.Local().Format(time.Kitchen) + ".")
+					return
+				}
+			}
 			if errorMsg, ok := event.Payload["error"].(string); ok {
 				s.appState.Chat.Write("Login failed: " + errorMsg)
 			}
@@ -69,13 +104,101 @@ func (s *SubscriptionService) onLoginEvent(c mqtt.Client, m mqtt.Message) {
 	}
 }
 
-// onRegisterEvent processa eventos de resposta de registro e notifica o usuário.
-func (s *SubscriptionService) onRegisterEvent(c mqtt.Client, m mqtt.Message) {
-	event, err := s.decodeEvent(m)
-	if err != nil {
+// onRefreshEvent atualiza os tokens da sessão após uma renovação bem-sucedida.
+func (s *SubscriptionService) onRefreshEvent(event protocol.Event) {
+	if event.Method != "refresh_ok" {
 		return
 	}
+	if userID, ok := event.Payload["user_id"].(string); !ok || userID != s.appState.UserID {
+		return
+	}
+	s.storeTokens(event)
+}
+
+// onLogoutEvent limpa a sessão local quando o servidor confirma o logout.
+func (s *SubscriptionService) onLogoutEvent(event protocol.Event) {
+	if event.Method != "logout_ok" {
+		return
+	}
+	if userID, ok := event.Payload["user_id"].(string); !ok || userID != s.appState.UserID {
+		return
+	}
+	s.ClearSession()
+	s.appState.Chat.Write("Logged out.")
+}
+
+// onAccountEvent trata respostas de troca de senha, exclusão de conta e perfil.
+// Respostas de um usuário que já não é o da sessão (após logout ou troca de conta) são ignoradas.
+func (s *SubscriptionService) onAccountEvent(event protocol.Event) {
+	if userID, ok := event.Payload["user_id"].(string); !ok || userID != s.appState.UserID {
+		return
+	}
+
+	switch event.Method {
+	case "change_password_ok":
+		s.storeTokens(event)
+		s.appState.Chat.Write("Password changed. Other sessions were signed out.")
+	case "delete_account_ok":
+		s.ClearSession()
+		s.appState.Chat.Write("Account deleted.")
+	case "get_profile_ok":
+		profile, _ := event.Payload["profile"].(map[string]interface{})
+		s.appState.Chat.Write(fmt.Sprintf("Profile: %v (roles: %v)\nJoined: %v\nCards: %v | Wins: %v | Losses: %v",
+			profile["username"], profile["roles"], profile["created_at"],
+			profile["card_count"], profile["wins"], profile["losses"]))
+	default:
+		if errorMsg, ok := event.Payload["error"].(string); ok {
+			s.appState.Chat.Write("Account operation failed: " + errorMsg)
+		}
+	}
+}
+
+// storeTokens guarda o par de tokens do evento e agenda a próxima renovação.
+func (s *SubscriptionService) storeTokens(event protocol.Event) {
+	if token, ok := event.Payload["token"].(string); ok {
+		s.appState.Token = token
+	}
+	if refreshToken, ok := event.Payload["refresh_token"].(string); ok {
+		s.appState.RefreshToken = refreshToken
+	}
+	if expiresIn, ok := event.Payload["expires_in"].(float64); ok && expiresIn > 0 {
+		// Renova com folga de 20% antes de o token de acesso expirar
+		s.scheduleRefresh(time.Duration(expiresIn*0.8) * time.Second)
+	}
+}
+
+// scheduleRefresh substitui o timer de renovação pendente por um novo.
+func (s *SubscriptionService) scheduleRefresh(after time.Duration) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	if s.refreshTimer != nil {
+		s.refreshTimer.Stop()
+	}
+	s.refreshTimer = time.AfterFunc(after, func() {
+		if s.appState.RefreshToken == "" {
+			return
+		}
+		if err := s.eventSvc.Publish(s.eventSvc.CreateRefreshEvent()); err != nil {
+			s.appState.Chat.Write("Failed to refresh session: " + err.Error())
+		}
+	})
+}
+
+// ClearSession descarta identidade e tokens locais e cancela a renovação agendada.
+func (s *SubscriptionService) ClearSession() {
+	s.refreshMu.Lock()
+	if s.refreshTimer != nil {
+		s.refreshTimer.Stop()
+		s.refreshTimer = nil
+	}
+	s.refreshMu.Unlock()
+	s.appState.UserID = ""
+	s.appState.Token = ""
+	s.appState.RefreshToken = ""
+}
 
+// onRegisterEvent processa a resposta do registro deste cliente e notifica o usuário.
+func (s *SubscriptionService) onRegisterEvent(event protocol.Event) {
 	if status, ok := event.Payload["status"].(string); ok {
 		if status == "success" {
 			// Um registro bem-sucedido não loga automaticamente o usuário.
@@ -103,6 +226,53 @@ func (s *SubscriptionService) onChatEvent(c mqtt.Client, m mqtt.Message) {
 				return
 			}
 		}
+		// Mensagens de usuários sancionados nem chegam aqui: o servidor as recusa
+		if username, ok := event.Payload["username"].(string); ok && username != "" {
+			content = username + ": " + content
+		}
 		s.appState.Chat.Write(content)
 	}
 }
+
+// onModerationEvent aplica localmente as sanções anunciadas pelo servidor para a sala.
+func (s *SubscriptionService) onModerationEvent(c mqtt.Client, m mqtt.Message) {
+	event, err := s.decodeEvent(m)
+	if err != nil {
+		return
+	}
+
+	targetID, _ := event.Payload["target_user_id"].(string)
+	switch event.Method {
+	case "mute_user_ok":
+		until, err := time.Parse(time.RFC3339Nano, fmt.Sprint(event.Payload["until"]))
+		if err != nil {
+			return
+		}
+		s.appState.Sanctions.Mute(targetID, until)
+		s.appState.Chat.Write(fmt.Sprintf("User %s was muted until %s.", targetID, until.Local().Format(time.Kitchen)))
+	case "ban_user_ok":
+		s.appState.Sanctions.Ban(targetID)
+		s.appState.Chat.Write(fmt.Sprintf("User %s was banned from this room.", targetID))
+	case "unban_user_ok":
+		s.appState.Sanctions.Pardon(targetID)
+		s.appState.Chat.Write(fmt.Sprintf("User %s is no longer sanctioned.", targetID))
+	}
+}
+
+// onChatReply mostra por que o servidor recusou uma mensagem deste cliente.
+func (s *SubscriptionService) onChatReply(event protocol.Event) {
+	if event.Method != "chat_fail" {
+		return
+	}
+	if errorMsg, ok := event.Payload["error"].(string); ok {
+		s.appState.Chat.Write("Message not sent: " + errorMsg)
+	}
+}
+
+// onModerationReply mostra a falha de uma ação de moderação deste cliente. As aplicadas
+// chegam pelo tópico de moderação da sala, junto com os demais clientes.
+func (s *SubscriptionService) onModerationReply(event protocol.Event) {
+	if errorMsg, ok := event.Payload["error"].(string); ok {
+		s.appState.Chat.Write("Moderation failed: " + errorMsg)
+	}
+}
@@ -2,7 +2,10 @@ package state
 
 import (
 	"cod-client/internal/ui"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -11,10 +14,57 @@ import (
 // State mantém todas as instâncias e variáveis de estado para toda a aplicação.
 // Isso inclui identidade do usuário, contexto da sala, conexão do cliente MQTT e camada UI.
 type State struct {
-	UserID string      // O identificador único do usuário atualmente logado
-	RoomID string      // O identificador da sala de chat/jogo atual
-	Client mqtt.Client // Cliente MQTT para operações de publicação/subscrição
-	Chat   *ui.Chat    // UI baseada em terminal para interação do usuário
+	UserID       string      // O identificador único do usuário atualmente logado
+	Token        string      // JWT de acesso recebido no login, anexado a todas as requisições
+	RefreshToken string      // Token usado para renovar o acesso antes de expirar
+	RoomID       string      // O identificador da sala de chat/jogo atual
+	ClientID     string      // ID do cliente MQTT; prefixa os tópicos de pedido e de resposta
+	Client       mqtt.Client // Cliente MQTT para operações de publicação/subscrição
+	Chat         *ui.Chat    // UI baseada em terminal para interação do usuário
+	Sanctions    *Sanctions  // Silêncios e banimentos da sala atual
+}
+
+// Sanctions guarda os silêncios e banimentos anunciados no tópico de moderação da sala.
+// Quem aplica as sanções é o servidor, ao repassar o chat; aqui elas só evitam enviar mensagens
+// que seriam recusadas.
+type Sanctions struct {
+	mu         sync.RWMutex
+	mutedUntil map[string]time.Time
+	banned     map[string]bool
+}
+
+// NewSanctions cria um registro de sanções vazio.
+func NewSanctions() *Sanctions {
+	return &Sanctions{mutedUntil: make(map[string]time.Time), banned: make(map[string]bool)}
+}
+
+// Mute silencia o usuário até o instante informado.
+func (s *Sanctions) Mute(userID string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutedUntil[userID] = until
+}
+
+// Ban bane o usuário da sala.
+func (s *Sanctions) Ban(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.banned[userID] = true
+}
+
+// Pardon remove qualquer sanção do usuário.
+func (s *Sanctions) Pardon(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.mutedUntil, userID)
+	delete(s.banned, userID)
+}
+
+// Blocked informa se o usuário está silenciado ou banido agora.
+func (s *Sanctions) Blocked(userID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.banned[userID] || time.Now().Before(s.mutedUntil[userID])
 }
 
 // New initializes and returns a new application State instance,
@@ -25,7 +75,10 @@ func New() *State {
 
 	opts := mqtt.NewClientOptions()
 	opts.AddBroker("ssl://broker.emqx.io:8883")
-	opts.SetClientID("cod-client-" + fmt.Sprint(time.Now().UnixNano()))
+	// O broker entrega as respostas em replies/{client_id}/, e uma conexão nova com o mesmo id
+	// derruba a anterior, então o id precisa ser imprevisível
+	clientID := "cod-client-" + randomHex(16)
+	opts.SetClientID(clientID)
 
 	client := mqtt.NewClient(opts)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
@@ -33,8 +86,19 @@ func New() *State {
 	}
 
 	return &State{
-		RoomID: "messages",
-		Client: client,
-		Chat:   chat,
+		RoomID:    "messages",
+		ClientID:  clientID,
+		Client:    client,
+		Chat:      chat,
+		Sanctions: NewSanctions(),
+	}
+}
+
+// randomHex gera n bytes aleatórios em hexadecimal.
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("failed to generate client id: %v", err))
 	}
+	return hex.EncodeToString(buf)
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"cod-server/internal/api"
+	"cod-server/internal/cluster"
+	"fmt"
+	"os"
+	shared_protocol "shared/protocol"
+	"time"
+)
+
+const bootstrapAdminUsage = "uso: cod-server bootstrap-admin <username> [endereço-http-do-líder]"
+
+// runBootstrapAdmin implementa o subcomando bootstrap-admin e retorna o código de saída do
+// processo. Propõe bootstrap_admin ao líder por /raft/command, assinado com COD_CLUSTER_SECRET:
+// só quem tem o segredo do cluster cria o primeiro administrador, e a promoção é replicada
+// pelo log como qualquer outra escrita. O usuário precisa já estar registrado.
+func runBootstrapAdmin(args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, bootstrapAdminUsage)
+		return 2
+	}
+	address := getEnv("COD_HTTP_BIND_ADDR", "127.0.0.1:8080")
+	if len(args) > 1 {
+		address = args[1]
+	}
+	secret := []byte(getEnv("COD_CLUSTER_SECRET", ""))
+	if len(secret) == 0 && !getEnvBool("COD_DEV_MODE", false) {
+		fmt.Fprintln(os.Stderr, "COD_CLUSTER_SECRET é obrigatório (ou COD_DEV_MODE=true em desenvolvimento)")
+		return 2
+	}
+
+	event := api.Event{Event: shared_protocol.Event{
+		Method:    "bootstrap_admin",
+		Timestamp: time.Now(),
+		Payload:   map[string]any{"username": args[0]},
+	}}
+	reply, err := cluster.SendCommand(address, secret, event)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "falha ao promover %s: %v\n", args[0], err)
+		return 1
+	}
+	if reply.Method != "bootstrap_admin_ok" {
+		fmt.Fprintf(os.Stderr, "falha ao promover %s: %v\n", args[0], reply.Payload["error"])
+		return 1
+	}
+	fmt.Printf("%s (%v) agora tem os papéis %v\n", reply.Payload["username"], reply.Payload["user_id"], reply.Payload["roles"])
+	return 0
+}
@@ -5,23 +5,28 @@ import (
 	"cod-server/internal/api/mqtt"
 	"cod-server/internal/auth"
 	"cod-server/internal/cluster"
+	"cod-server/internal/data"
 	"cod-server/internal/data/cache"
-	"cod-server/internal/data/persistence"
+	"cod-server/internal/data/changes"
+	"cod-server/internal/data/storage"
+	"cod-server/internal/domain"
 	"cod-server/internal/services"
-	"database/sql"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
 	"os/signal"
+	shared_protocol "shared/protocol"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/charmbracelet/log"
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/hashicorp/raft"
-	raftboltdb "github.com/hashicorp/raft-boltdb"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
 	"github.com/joho/godotenv"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -35,6 +40,87 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// getEnvBool lê uma variável booleana (true/false, 1/0...); valor inválido encerra o servidor
+// em vez de cair silenciosamente no padrão.
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("%s inválido: %q não é um booleano", key, value)
+	}
+	return parsed
+}
+
+// splitList separa uma lista delimitada por vírgulas, ignorando entradas vazias.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTopicOptions interpreta a lista "filtro=qos[:retain],..." usada em COD_MQTT_TOPIC_QOS.
+// Exemplo: "replies/#=1,chat/room/+=0:retain".
+func parseTopicOptions(spec string) (map[string]mqtt.TopicOptions, error) {
+	options := make(map[string]mqtt.TopicOptions)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		filter, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("entrada sem '=': %s", entry)
+		}
+		qosStr, flag, _ := strings.Cut(value, ":")
+		qos, err := strconv.ParseUint(qosStr, 10, 8)
+		if err != nil || qos > 2 {
+			return nil, fmt.Errorf("qos inválido em %s", entry)
+		}
+		options[filter] = mqtt.TopicOptions{QoS: byte(qos), Retained: flag == "retain"}
+	}
+	return options, nil
+}
+
+// parseRateLimits interpreta "método=taxa:rajada,..." usado em COD_RATE_LIMITS, partindo
+// de api.DefaultRateLimits. O método "*" define o limite padrão; taxa 0 desativa o limite.
+// Taxa negativa e rajada menor que 1 são rejeitadas. Exemplo: "*=5:20,buy_pack=0.2:2".
+func parseRateLimits(spec string) (api.RateLimit, map[string]api.RateLimit, error) {
+	defaultLimit := api.RateLimit{Rate: 5, Burst: 20}
+	limits := api.DefaultRateLimits()
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		method, value, ok := strings.Cut(entry, "=")
+		rateStr, burstStr, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 {
+			return defaultLimit, nil, fmt.Errorf("entrada inválida: %s", entry)
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return defaultLimit, nil, fmt.Errorf("taxa inválida em %s", entry)
+		}
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return defaultLimit, nil, fmt.Errorf("rajada inválida em %s", entry)
+		}
+		if method == "*" {
+			defaultLimit = api.RateLimit{Rate: rate, Burst: burst}
+		} else {
+			limits[method] = api.RateLimit{Rate: rate, Burst: burst}
+		}
+	}
+	return defaultLimit, limits, nil
+}
+
 func main() {
 	// Carrega arquivo .env se existir; avisa mas continua se falhar
 	err := godotenv.Load()
@@ -42,52 +128,154 @@ func main() {
 		log.Warnf("Aviso: Não foi possível carregar o arquivo .env: %v. Usando variáveis de ambiente existentes ou padrões.", err)
 	}
 
+	// Subcomando de manutenção: cod-server migrate status|up|down [passos]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	// Subcomando de manutenção: cod-server bootstrap-admin <username> [endereço]
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-admin" {
+		os.Exit(runBootstrapAdmin(os.Args[2:]))
+	}
+
 	// Carrega configuração de variáveis de ambiente com valores padrão sensatos
 	raftDataDir := getEnv("COD_RAFT_DATA_DIR", "./raft-data")
 	raftBindAddr := getEnv("COD_RAFT_BIND_ADDR", "127.0.0.1:10000")
 	httpBindAddr := getEnv("COD_HTTP_BIND_ADDR", "127.0.0.1:8080")
 	nodeID := getEnv("COD_NODE_ID", "node-1")
 	mqttBrokerAddr := getEnv("COD_MQTT_BROKER_ADDR", "tcp://localhost:1883")
-	isFirstNodeStr := getEnv("COD_IS_FIRST_NODE", "false")
-	isFirstNode, _ := strconv.ParseBool(isFirstNodeStr)
+	mqttCleanSession := getEnvBool("COD_MQTT_CLEAN_SESSION", false)
+	mqttMaxReconnect, err := time.ParseDuration(getEnv("COD_MQTT_MAX_RECONNECT_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatalf("COD_MQTT_MAX_RECONNECT_INTERVAL inválido: %v", err)
+	}
+	mqttTopicOptions, err := parseTopicOptions(getEnv("COD_MQTT_TOPIC_QOS", ""))
+	if err != nil {
+		log.Fatalf("COD_MQTT_TOPIC_QOS inválido: %v", err)
+	}
+	defaultRateLimit, rateLimits, err := parseRateLimits(getEnv("COD_RATE_LIMITS", ""))
+	if err != nil {
+		log.Fatalf("COD_RATE_LIMITS inválido: %v", err)
+	}
+	// Leituras locais: get_cards e get_profile respondidos pela réplica, esperando até o timeout por min_index
+	localReads := getEnvBool("COD_LOCAL_READS", false)
+	readWaitTimeout, err := time.ParseDuration(getEnv("COD_READ_WAIT_TIMEOUT", "2s"))
+	if err != nil {
+		log.Fatalf("COD_READ_WAIT_TIMEOUT inválido: %v", err)
+	}
+	isFirstNode := getEnvBool("COD_IS_FIRST_NODE", false)
+	// Fora do modo de desenvolvimento, as rotas /raft e a descoberta exigem o segredo do cluster
+	devMode := getEnvBool("COD_DEV_MODE", false)
+	clusterSecret := []byte(getEnv("COD_CLUSTER_SECRET", ""))
+	switch {
+	case len(clusterSecret) >= cluster.MinClusterSecretLength:
+	case len(clusterSecret) == 0 && devMode:
+		log.Warn("COD_DEV_MODE sem COD_CLUSTER_SECRET: rotas /raft e descoberta aceitam qualquer nó")
+	default:
+		log.Fatalf("COD_CLUSTER_SECRET precisa de ao menos %d bytes (ou COD_DEV_MODE=true em desenvolvimento)", cluster.MinClusterSecretLength)
+	}
+	dispatcherConfig := cluster.DefaultDispatcherConfig()
+	if workers, err := strconv.Atoi(getEnv("COD_EVENT_WORKERS", "")); err == nil {
+		dispatcherConfig.Workers = workers
+	}
+	if queueSize, err := strconv.Atoi(getEnv("COD_EVENT_QUEUE_SIZE", "")); err == nil {
+		dispatcherConfig.QueueSize = queueSize
+	}
 
 	log.Info("Iniciando servidor COD...")
 
-	// Inicializa repositórios de dados e serviços com pool de conexões
-	db, err := sql.Open("sqlite3", "./game_data.db")
+	// Inicializa repositórios no backend escolhido por COD_STORAGE (SQLite migrado na abertura)
+	storageConfig := loadStorageConfig()
+	store, err := storage.Open(storageConfig)
 	if err != nil {
-		log.Fatal("falha ao abrir banco de dados SQLite: %v", err)
+		log.Fatalf("falha ao abrir armazenamento %s: %v", storageConfig.Backend, err)
 	}
-	defer db.Close()
-
-	// Configura pool de conexões SQLite para acesso concorrente
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	defer store.Close()
+	log.Infof("Armazenamento: %s %s", storageConfig.Backend, storageConfig.Path)
 
-	sqlUserRepo := persistence.NewSqlUserRepository(db)
-	sqlCardRepo := persistence.NewSqlCardRepository(db)
-	sqlMatchRepo := persistence.NewSqlMatchRepository(db)
-
-	userRepo := persistence.NewUserRepoAdapter(sqlUserRepo)
-	cardRepo := persistence.NewCardRepoAdapter(sqlCardRepo)
-	matchRepo := persistence.NewMatchRepoAdapter(sqlMatchRepo)
+	userRepo, cardRepo, matchRepo := store.Users, store.Cards, store.Matches
 
 	// Envolve repositórios com camada de cache para otimização de desempenho
-	userRepo = cache.NewCachedUserRepository(userRepo)
-	cardRepo = cache.NewCachedCardRepository(cardRepo)
-	matchRepo = cache.NewCachedMatchRepository(matchRepo)
-
-	userService := services.NewUserService(userRepo)
-	cardsService := services.NewCardsService(cardRepo, userRepo)
-	matchService := services.NewMatchService(matchRepo, cardRepo, userRepo)
+	// Os caches são versionados pelo índice aplicado do Raft e esvaziados ao restaurar um snapshot
+	appliedIndex := cache.NewAppliedIndex()
+	cacheConfig := cache.Config{TTL: 5 * time.Minute, Index: appliedIndex}
+	userRepo = cache.NewCachedUserRepository(userRepo, cacheConfig)
+	cardRepo = cache.NewCachedRepository(cardRepo, cacheConfig)
+	matchRepo = cache.NewCachedMatchRepository(matchRepo, cacheConfig)
+	for _, repo := range []any{userRepo, cardRepo, matchRepo} {
+		if closer, ok := repo.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
 
-	// Inicializa manipulador de eventos da API com serviços e autenticação
-	authService := auth.NewAuthService("") // Usa a chave padrão
-	eventHandler := api.NewEventHandler(userService, cardsService, matchService, authService)
+	// Alterações em várias entidades rodam em uma transação do backend; o cache só as vê após o commit
+	var uow data.UnitOfWork = cache.NewCachedUnitOfWork(store.UnitOfWork, data.Tx{Users: userRepo, Cards: cardRepo, Matches: matchRepo})
+	cachedRepos := map[string]any{"users_cache": userRepo, "cards_cache": cardRepo, "matches_cache": matchRepo}
+
+	// Escritas confirmadas viram eventos de mudança; as da unidade de trabalho saem só após o commit
+	feeds := changes.NewFeeds()
+	userRepo = changes.NewObservedUserRepository(userRepo, feeds.Users)
+	cardRepo = changes.NewObservedRepository(cardRepo, feeds.Cards)
+	matchRepo = changes.NewObservedMatchRepository(matchRepo, feeds.Matches)
+	uow = changes.NewObservedUnitOfWork(uow, feeds)
+
+	// O primeiro administrador é criado com o subcomando bootstrap-admin, replicado pelo log
+	userService := services.NewUserServiceWithConfig(services.UserServiceConfig{
+		Users:      userRepo,
+		Cards:      cardRepo,
+		Matches:    matchRepo,
+		UnitOfWork: uow,
+	})
+	// O catálogo faz parte do estado replicado: começa vazio e chega pelo log (sync_catalog,
+	// proposto pelo líder a partir de COD_CATALOG_FILE ou do embutido) ou por um snapshot
+	catalogService := services.NewCatalogService(nil)
+	cardsService := services.NewCardsServiceWithConfig(services.CardsServiceConfig{
+		Cards:      cardRepo,
+		Users:      userRepo,
+		UnitOfWork: uow,
+		Catalog:    catalogService,
+	})
+	// As regras de combate decidem as rodadas em todas as réplicas: mudá-las exige o mesmo
+	// binário em todo o cluster
+	matchService := services.NewMatchServiceWithConfig(services.MatchServiceConfig{
+		Matches:    matchRepo,
+		Cards:      cardRepo,
+		Users:      userRepo,
+		UnitOfWork: uow,
+		Rules:      domain.DefaultCombatRules(),
+	})
+	moderationService := services.NewModerationService()
+	loginGuard := services.NewLoginGuard(services.DefaultLoginGuardConfig())
+
+	// Inicializa manipulador de eventos da API com serviços e autenticação.
+	// Todos os nós precisam da mesma configuração de chaves para aceitar tokens uns dos outros.
+	keySet, err := auth.LoadKeySet(auth.KeyConfig{
+		Algorithm:    getEnv("COD_JWT_ALG", "HS256"),
+		KeyID:        getEnv("COD_JWT_KID", auth.DefaultKeyID),
+		Secret:       getEnv("COD_JWT_SECRET", ""),
+		KeyFile:      getEnv("COD_JWT_KEY_FILE", ""),
+		PreviousKeys: splitList(getEnv("COD_JWT_PREVIOUS_KEYS", "")),
+		DevMode:      devMode,
+	})
+	if err != nil {
+		log.Fatalf("falha ao carregar chaves JWT: %v", err)
+	}
+	authService := auth.NewAuthServiceWithKeys(keySet)
+	policy := auth.DefaultPolicy()
+	eventHandler := api.NewEventHandler(userService, cardsService, matchService, moderationService, loginGuard, catalogService, authService, policy)
 
 	// Cria Máquina de Estados Finitos do Raft para gerenciamento de estado distribuído
 	fsm := cluster.NewClusterFSM(eventHandler)
+	fsm.SetAppliedIndexObserver(appliedIndex)
+	fsm.SetCatalogStore(catalogService)
+	// Clientes logados de cada usuário, destino das notificações
+	sessions := cluster.NewSessions()
+	fsm.SetReplyObserver(sessions)
+	// Os caches ficam de fora: a FSM os zera pelo appliedIndex ao restaurar
+	fsm.RegisterState("repositories", store)
+	fsm.RegisterState("revocations", authService.Revocations())
+	fsm.RegisterState("moderation", moderationService)
+	fsm.RegisterState("login_guard", loginGuard)
+	fsm.RegisterState("sessions", sessions)
 
 	// Configura e inicializa consenso Raft com transporte TCP
 	config := raft.DefaultConfig()
@@ -143,56 +331,135 @@ func main() {
 		}
 	}
 
+	// Ao assumir a liderança, o nó propõe o catálogo local se ele for mais novo que o aplicado
+	catalogSync, err := cluster.NewCatalogSync(raftNode, catalogService, getEnv("COD_CATALOG_FILE", ""))
+	if err != nil {
+		log.Fatalf("falha ao carregar catálogo de cartas: %v", err)
+	}
+	stopCatalogSync := make(chan struct{})
+	defer close(stopCatalogSync)
+	catalogSync.Start(stopCatalogSync)
+
 	// Inicializa transporte HTTP da API para comunicação entre nós
-	httpTransport := cluster.NewGinHttpTransport(httpBindAddr, nodeID, raftNode)
+	httpTransport := cluster.NewGinHttpTransportWithConfig(cluster.HTTPTransportConfig{
+		BindAddress:   httpBindAddr,
+		NodeID:        nodeID,
+		ClusterSecret: clusterSecret,
+	}, raftNode)
+	httpTransport.EnableAdminRoutes(authService, policy, catalogSync)
 	if err := httpTransport.Start(); err != nil {
 		log.Fatal("Falha ao iniciar transporte HTTP: %v", err)
 	}
 
-	// Configura adaptador MQTT para comunicação de eventos do cliente
-	mqttAdapter, err := mqtt.NewMQTTAdapter(mqttBrokerAddr, nodeID)
+	// Configura adaptador MQTT para comunicação de eventos do cliente.
+	// Sessão persistente + reconexão automática: assinaturas são refeitas a cada reconexão.
+	mqttConfig := mqtt.DefaultConfig(mqttBrokerAddr, nodeID)
+	mqttConfig.CleanSession = mqttCleanSession
+	mqttConfig.Username = getEnv("COD_MQTT_USERNAME", "")
+	mqttConfig.Password = getEnv("COD_MQTT_PASSWORD", "")
+	mqttConfig.MaxReconnectInterval = mqttMaxReconnect
+	mqttConfig.Topics = mqttTopicOptions
+	mqttAdapter, err := mqtt.NewMQTTAdapterWithConfig(mqttConfig)
 	if err != nil {
 		log.Fatal("Falha ao criar adaptador MQTT: %v", err)
 	}
+	mqttAdapter.OnStateChange(func(state mqtt.ConnectionState) {
+		log.Infof("Estado da conexão MQTT: %s", state)
+	})
+	httpTransport.RegisterHealthCheck("mqtt", func() error {
+		if !mqttAdapter.IsConnected() {
+			return fmt.Errorf("mqtt %s", mqttAdapter.State())
+		}
+		return nil
+	})
 	if err := mqttAdapter.Connect(); err != nil {
 		log.Fatal("Falha ao conectar ao broker MQTT: %v", err)
 	}
 	defer mqttAdapter.Disconnect()
 
 	// Cria coordenador Raft para gerenciar roteamento de eventos e consenso
-	coordinator := cluster.NewRaftCoordinator(raftNode, httpTransport, mqttAdapter)
+	authenticator := api.NewAuthenticator(authService)
+	coordinator := cluster.NewRaftCoordinator(raftNode, httpTransport, mqttAdapter, authenticator)
+	if localReads {
+		coordinator.SetLocalReads(eventHandler, appliedIndex, readWaitTimeout)
+	}
+	// Chat passa pelo nó, que confere silêncios e banimentos antes de publicar na sala
+	coordinator.SetChatRelay(moderationService)
+	// Senhas são conferidas e trocadas por hashes aqui, antes do log
+	coordinator.SetCredentials(api.NewCredentials(userService))
+
+	// Mudanças nos repositórios são avisadas aos clientes dos usuários afetados em
+	// replies/{client_id}/notifications/...
+	defer cluster.NewChangeNotifier(raftNode, mqttAdapter, sessions).Subscribe(feeds)()
+
+	// Fila limitada + pool de workers: o handler MQTT apenas enfileira, sem bloquear o roteador do paho.
+	// Eventos do mesmo usuário caem sempre no mesmo worker, preservando a ordem das jogadas.
+	dispatcher := cluster.NewEventDispatcher(coordinator, mqttAdapter, dispatcherConfig)
+	rateLimiter := api.NewRateLimiter(authService, defaultRateLimit, rateLimits)
+	dispatcher.SetRateLimiter(rateLimiter)
+	httpTransport.RegisterMetrics("rate_limiter", rateLimiter.Stats)
+	httpTransport.RegisterMetrics("event_queue", dispatcher.Stats)
+	httpTransport.RegisterMetrics("login_guard", loginGuard.Stats)
+	// Acertos, falhas e remoções dos caches de repositório
+	for name, repo := range cachedRepos {
+		if reporter, ok := repo.(cache.StatsReporter); ok {
+			httpTransport.RegisterMetrics(name, reporter.Stats)
+		}
+	}
+	dispatcher.Start()
+	defer dispatcher.Stop()
 
-	// Inscreve-se em todos os tópicos de eventos do cliente
+	// Inscreve-se em todos os tópicos de eventos do cliente, sob requests/{client_id}/
 	// Tópicos correspondem aos definidos no EventService do cliente
 	tópicos := []string{
 		"user/register",
 		"user/login",
-		"chat/room/+", // Usando + como wildcard para qualquer room
+		"user/refresh",
+		"user/logout",
+		"user/account", // change_password, delete_account, get_profile
 		"game/start_game",
 		"game/+/play_card", // Wildcard para room específico
 		"game/+/surrender", // Wildcard para room específico
 		"game/join_game",
 		"store/buy",
+		"store/catalog",
 		"cards/+/exchange+", // Wildcard para room e user
 		"game/actions",      // Tópico original
+		"admin/+",           // Moderação e administração (mute_user, grant_cards...)
+		"chat/room/+",       // Chat, repassado para chat/room/{sala} pelo coordenador
 	}
 
 	for _, tópico := range tópicos {
-		mqttAdapter.Subscribe(tópico, func(client paho.Client, msg paho.Message) {
+		err := mqttAdapter.Subscribe(shared_protocol.RequestFilter(tópico), func(client paho.Client, msg paho.Message) {
+			clientID, ok := shared_protocol.ClientIDFromTopic(msg.Topic())
+			if !ok {
+				log.Warnf("Evento MQTT ignorado: tópico %s sem client id", msg.Topic())
+				return
+			}
 			event, err := api.FromJson(msg.Payload())
 			if err != nil {
 				log.Errorf("Erro ao desserializar evento MQTT: %v", err)
 				return
 			}
-			log.Infof("Evento MQTT recebido no tópico %s: %+v", msg.Topic(), event)
-			if err := coordinator.Handle(*event); err != nil {
-				log.Errorf("Erro ao processar evento via coordenador: %v", err)
+			// O client_id do payload é descartado: vale o do tópico, que o ACL do broker
+			// garante ser o da conexão que publicou
+			if event.Payload == nil {
+				event.Payload = make(map[string]any)
+			}
+			event.Payload["client_id"] = clientID
+			log.Infof("Evento MQTT recebido no tópico %s: %s", msg.Topic(), event.Method)
+			if err := dispatcher.Submit(*event); err != nil {
+				log.Warnf("Evento %s rejeitado: %v", event.Method, err)
 			}
 		})
+		if err != nil {
+			log.Errorf("Falha ao assinar tópico %s: %v", tópico, err)
+		}
 	}
 
 	// Inicializa serviço de descoberta de pares para associação automática ao cluster
 	discovery := cluster.NewDiscoveryService(string(transport.LocalAddr()), httpBindAddr)
+	discovery.Secret = clusterSecret
 	discovery.OnPeerDiscovered = func(peerIp string) {
 		targetAddr := fmt.Sprintf("%s:%s", peerIp, "8080") // Assumindo porta 8080
 		log.Infof("Nó par descoberto em %s. Tentando adicionar ao cluster...", targetAddr)
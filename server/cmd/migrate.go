@@ -0,0 +1,107 @@
+package main
+
+import (
+	"cod-server/internal/data/persistence"
+	"cod-server/internal/data/storage"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// loadStorageConfig lê o backend (COD_STORAGE) e o caminho (COD_STORAGE_PATH), usados pelo
+// servidor e pelo subcomando migrate.
+func loadStorageConfig() storage.Config {
+	backend := strings.ToLower(getEnv("COD_STORAGE", storage.SQLite))
+	return storage.Config{Backend: backend, Path: getEnv("COD_STORAGE_PATH", storage.DefaultPath(backend))}
+}
+
+const migrateUsage = "uso: cod-server migrate status|up|down [passos]"
+
+// runMigrate implementa o subcomando migrate e retorna o código de saída do processo.
+//   - status: lista as migrações e se cada uma foi aplicada
+//   - up: aplica as pendentes
+//   - down [passos]: reverte as últimas (1 por padrão)
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	config := loadStorageConfig()
+	if config.Backend != storage.SQLite {
+		fmt.Fprintf(os.Stderr, "migrate só se aplica ao backend %s (COD_STORAGE=%s)\n", storage.SQLite, config.Backend)
+		return 2
+	}
+	db, err := persistence.Open(config.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "falha ao abrir banco de dados SQLite: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := persistence.NewMigrator(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "falha ao carregar migrações: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "falha ao consultar migrações: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSÃO\tNOME\tESTADO\tAPLICADA EM")
+		for _, status := range statuses {
+			state, at := "pendente", "-"
+			if status.Applied {
+				state, at = "aplicada", status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, at)
+		}
+		w.Flush()
+
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("aplicada %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "falha ao aplicar migrações: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("nenhuma migração pendente")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			fmt.Printf("revertida %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "falha ao reverter migrações: %v\n", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("nenhuma migração aplicada")
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"cod-server/internal/auth"
+	"errors"
+)
+
+var (
+	// ErrUnauthenticated indica token ausente, inválido ou expirado.
+	ErrUnauthenticated = errors.New("invalid or missing token")
+	// ErrUserMismatch indica que o payload age em nome de outro usuário que não o do token.
+	ErrUserMismatch = errors.New("payload user does not match token")
+)
+
+// actorFields lista, por método protegido, os campos do payload que identificam o usuário
+// que executa a ação. Métodos fora deste mapa (register, login...) não exigem token.
+var actorFields = map[string][]string{
+	"get_cards":       {"user_id"},
+	"buy_pack":        {"user_id"},
+	"offer_trade":     {"from_user_id"},
+	"accept_trade":    {"to_user_id"},
+	"start_match":     {"user_id"},
+	"join_match":      {"user_id"},
+	"surrender_match": {"user_id"},
+	"make_move":       {"user_id"},
+	"logout":          {"user_id"},
+	"change_password": {"user_id"},
+	"delete_account":  {"user_id"},
+	"get_profile":     {"user_id"},
+	"mute_user":       {"user_id"},
+	"ban_user":        {"user_id"},
+	"unban_user":      {"user_id"},
+	"grant_cards":     {"user_id"},
+	"cancel_match":    {"user_id"},
+	"set_roles":       {"user_id"},
+	"chat":            {"user_id"},
+}
+
+// refreshTokenMethods lista os métodos que recebem um token de refresh e se ele é obrigatório.
+var refreshTokenMethods = map[string]bool{
+	"refresh": true,
+	"logout":  false,
+}
+
+// Authenticator valida o token de um evento uma única vez, na borda, antes do consenso.
+// O usuário que age é derivado das claims; o token é removido do payload para não ser
+// gravado no log do Raft, restando apenas seu jti e expiração (usados pelo logout).
+// O token de refresh recebe o mesmo tratamento: no log ficam só as claims de que a FSM precisa.
+type Authenticator struct {
+	authService *auth.AuthService
+}
+
+// NewAuthenticator cria um Authenticator sobre o AuthService fornecido.
+func NewAuthenticator(authService *auth.AuthService) *Authenticator {
+	return &Authenticator{authService: authService}
+}
+
+// RequiresAuth informa se o método exige um token válido.
+func RequiresAuth(method string) bool {
+	_, ok := actorFields[method]
+	return ok
+}
+
+// Authenticate valida o token do evento e preenche os campos de usuário a partir das claims.
+// Retorna nil, nil para métodos públicos.
+func (a *Authenticator) Authenticate(event *Event) (*auth.Claims, error) {
+	if required, ok := refreshTokenMethods[event.Method]; ok {
+		if err := a.authenticateRefresh(event, required); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, protected := actorFields[event.Method]
+	if !protected {
+		return nil, nil
+	}
+	if event.Payload == nil {
+		return nil, ErrUnauthenticated
+	}
+
+	token, _ := event.Payload["token"].(string)
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	claims, err := a.authService.ValidateToken(token)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	for _, field := range fields {
+		value, present := event.Payload[field]
+		if !present || value == "" {
+			event.Payload[field] = claims.UserID
+			continue
+		}
+		if userID, ok := value.(string); !ok || userID != claims.UserID {
+			return nil, ErrUserMismatch
+		}
+	}
+
+	delete(event.Payload, "token")
+	event.Payload["jti"] = claims.ID
+	event.Payload["token_exp"] = claims.ExpiresAt.Unix()
+	return claims, nil
+}
+
+// authenticateRefresh valida o refresh_token do evento e o substitui por refresh_jti,
+// refresh_exp, refresh_gen, refresh_user_id e refresh_username. A FSM ainda confere a revogação,
+// pois dois pedidos com o mesmo token podem passar por aqui antes de o primeiro ser aplicado.
+// Um token opcional inválido é apenas descartado.
+func (a *Authenticator) authenticateRefresh(event *Event, required bool) error {
+	if event.Payload == nil {
+		if required {
+			return ErrUnauthenticated
+		}
+		return nil
+	}
+	raw, _ := event.Payload["refresh_token"].(string)
+	delete(event.Payload, "refresh_token")
+
+	var claims *auth.Claims
+	var err error
+	if raw != "" {
+		claims, err = a.authService.ValidateRefreshToken(raw)
+	}
+	if raw == "" || err != nil {
+		if required {
+			return ErrUnauthenticated
+		}
+		return nil
+	}
+
+	event.Payload["refresh_jti"] = claims.ID
+	event.Payload["refresh_exp"] = claims.ExpiresAt.Unix()
+	event.Payload["refresh_gen"] = claims.Generation
+	event.Payload["refresh_user_id"] = claims.UserID
+	event.Payload["refresh_username"] = claims.Username
+	return nil
+}
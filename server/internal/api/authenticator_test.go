@@ -0,0 +1,74 @@
+package api
+
+import (
+	"cod-server/internal/auth"
+	shared_protocol "shared/protocol"
+	"testing"
+)
+
+func newTestAuthService(t *testing.T) *auth.AuthService {
+	t.Helper()
+	key, err := auth.NewHMACKey(auth.DefaultKeyID, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewKeySet(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.NewAuthServiceWithKeys(keys)
+}
+
+func authEvent(method string, payload map[string]any) *Event {
+	return &Event{Event: shared_protocol.Event{Method: method, Payload: payload}}
+}
+
+func TestAuthenticator_RefreshTokenNeverReachesTheLog(t *testing.T) {
+	authService := newTestAuthService(t)
+	tokens, err := authService.GenerateTokenPair("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := NewAuthenticator(authService)
+
+	event := authEvent("refresh", map[string]any{"refresh_token": tokens.RefreshToken, "client_id": "c1"})
+	if _, err := authenticator.Authenticate(event); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, ok := event.Payload["refresh_token"]; ok {
+		t.Error("refresh_token left in the payload")
+	}
+	if event.Payload["refresh_user_id"] != "alice-id" || event.Payload["refresh_username"] != "alice" || event.Payload["refresh_jti"] == "" {
+		t.Errorf("refresh claims missing: %v", event.Payload)
+	}
+
+	// Um token de acesso não serve como refresh
+	if _, err := authenticator.Authenticate(authEvent("refresh", map[string]any{"refresh_token": tokens.AccessToken})); err != ErrUnauthenticated {
+		t.Errorf("access token as refresh: got %v, want ErrUnauthenticated", err)
+	}
+	if _, err := authenticator.Authenticate(authEvent("refresh", map[string]any{})); err != ErrUnauthenticated {
+		t.Errorf("missing refresh token: got %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestAuthenticator_LogoutDropsInvalidRefreshToken(t *testing.T) {
+	authService := newTestAuthService(t)
+	tokens, err := authService.GenerateTokenPair("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := NewAuthenticator(authService)
+
+	event := authEvent("logout", map[string]any{"token": tokens.AccessToken, "refresh_token": "garbage"})
+	if _, err := authenticator.Authenticate(event); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	for _, field := range []string{"token", "refresh_token", "refresh_jti"} {
+		if _, ok := event.Payload[field]; ok {
+			t.Errorf("%s left in the logout payload", field)
+		}
+	}
+	if event.Payload["user_id"] != "alice-id" || event.Payload["jti"] == "" {
+		t.Errorf("access token claims missing: %v", event.Payload)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"cod-server/internal/domain"
+	"cod-server/internal/services"
+	"encoding/json"
+	"errors"
+	shared_protocol "shared/protocol"
+	"time"
+)
+
+// OnGetCatalog retorna o catálogo de modelos de carta, para o cliente exibir nome, elemento,
+// poder, raridade e descrição das cartas pelo template_id.
+func (eh *EventHandler) OnGetCatalog(event Event) Event {
+	catalog := eh.catalogService.Catalog()
+	if catalog == nil {
+		return NewErrorEvent("get_catalog_fail", "catalog_unavailable", services.ErrNoCatalog.Error())
+	}
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "get_catalog_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"version": catalog.Version, "templates": catalog.Templates},
+		},
+	}
+}
+
+// OnSyncCatalog troca o catálogo pelo do payload se a versão for maior. É proposto pelo líder
+// (ver cluster.CatalogSync), nunca por clientes: o coordenador recusa o método na borda.
+func (eh *EventHandler) OnSyncCatalog(event Event) Event {
+	catalog, err := catalogFromPayload(event.Payload["catalog"])
+	if err != nil {
+		return NewErrorEvent("sync_catalog_fail", "invalid_catalog", err.Error())
+	}
+	if err := eh.catalogService.Load(catalog); err != nil {
+		code := "invalid_catalog"
+		if errors.Is(err, services.ErrStaleCatalog) {
+			code = "stale_catalog"
+		}
+		return NewErrorEvent("sync_catalog_fail", code, err.Error())
+	}
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "sync_catalog_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"version": catalog.Version},
+		},
+	}
+}
+
+// catalogFromPayload converte o catálogo desserializado do log (mapas genéricos) de volta
+// para domain.Catalog, validando-o.
+func catalogFromPayload(value any) (*domain.Catalog, error) {
+	if value == nil {
+		return nil, domain.ErrInvalidCatalog
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return domain.ParseCatalog(raw)
+}
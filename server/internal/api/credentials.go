@@ -0,0 +1,103 @@
+package api
+
+import (
+	"cod-server/internal/services"
+)
+
+// passwordFields lista, por método, os campos do payload com senhas em texto puro.
+var passwordFields = map[string][]string{
+	"register":        {"password"},
+	"login":           {"password"},
+	"change_password": {"old_password", "new_password"},
+	"delete_account":  {"password"},
+}
+
+// hashFields são os campos que Credentials grava; um cliente não pode enviá-los prontos.
+var hashFields = []string{"password_hash", "new_password_hash"}
+
+// CarriesPassword informa se o método recebe senha e, por isso, precisa passar por Credentials.
+func CarriesPassword(method string) bool {
+	_, ok := passwordFields[method]
+	return ok
+}
+
+// Credentials tira as senhas dos eventos na borda, depois do Authenticator e antes do consenso,
+// para que nunca sejam gravadas no log do Raft nem nos snapshots. A senha é conferida na réplica
+// local e no log fica só o hash que foi conferido; a FSM o compara com o gravado, na ordem do
+// log, e recusa a operação se a senha mudou nesse meio tempo. No registro o hash da nova senha,
+// o id e o username normalizado também são calculados aqui, iguais para todas as réplicas.
+type Credentials struct {
+	userService services.UserServiceInterface
+}
+
+// NewCredentials cria um Credentials que confere senhas no UserService local.
+func NewCredentials(userService services.UserServiceInterface) *Credentials {
+	return &Credentials{userService: userService}
+}
+
+// Prepare troca as senhas do evento pelos hashes. Se a operação já pode ser recusada aqui
+// (senha fraca, senha atual errada...), retorna a resposta de falha e o evento não segue.
+// Um login com senha errada segue assim mesmo, com hash vazio, para a FSM contar a falha.
+func (c *Credentials) Prepare(event *Event) *Event {
+	fields, ok := passwordFields[event.Method]
+	if !ok || event.Payload == nil {
+		return nil
+	}
+	passwords := make([]string, len(fields))
+	complete := true
+	for i, field := range fields {
+		passwords[i], ok = event.Payload[field].(string)
+		complete = complete && ok
+		delete(event.Payload, field)
+	}
+	for _, field := range hashFields {
+		delete(event.Payload, field)
+	}
+	if !complete {
+		return nil // A FSM responde invalid payload
+	}
+
+	var fail Event
+	switch event.Method {
+	case "register":
+		username, _ := event.Payload["username"].(string)
+		registration, err := c.userService.PrepareRegistration(username, passwords[0])
+		if err != nil {
+			fail = NewErrorEvent("register_fail", registerErrorCode(err), err.Error())
+			fail.Payload["status"] = "fail" // Formato compatível com o cliente
+			return &fail
+		}
+		event.Payload["user_id"] = registration.UserID
+		event.Payload["username"] = registration.Username
+		event.Payload["password_hash"] = registration.PasswordHash
+
+	case "login":
+		username, _ := event.Payload["username"].(string)
+		hash, err := c.userService.VerifyLogin(username, passwords[0])
+		if err != nil {
+			fail = loginFail("login_failed", err.Error())
+			return &fail
+		}
+		event.Payload["password_hash"] = hash
+
+	case "change_password":
+		userID, _ := event.Payload["user_id"].(string)
+		currentHash, newHash, err := c.userService.PreparePasswordChange(userID, passwords[0], passwords[1])
+		if err != nil {
+			fail = accountFail("change_password_fail", userID, err)
+			return &fail
+		}
+		event.Payload["password_hash"] = currentHash
+		event.Payload["new_password_hash"] = newHash
+
+	case "delete_account":
+		userID, _ := event.Payload["user_id"].(string)
+		hash, err := c.userService.VerifyPassword(userID, passwords[0])
+		if err != nil {
+			fail = accountFail("delete_account_fail", userID, err)
+			return &fail
+		}
+		event.Payload["password_hash"] = hash
+	}
+	return nil
+}
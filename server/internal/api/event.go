@@ -2,6 +2,9 @@ package api
 
 import (
 	shared_protocol "shared/protocol"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // Tipo wrapper para permitir métodos customizados
@@ -22,3 +25,34 @@ func FromJson(data []byte) (*Event, error) {
 	converted := Event{Event: *ev}
 	return &converted, nil
 }
+
+// NewErrorEvent cria um evento de erro estruturado, com código legível por máquina e mensagem.
+func NewErrorEvent(method, code, message string) Event {
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    method,
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"error": message, "code": code},
+		},
+	}
+}
+
+// entryIDMethods são os eventos que criam entidades; os ids delas (e o sorteio de cartas)
+// derivam do entry_id, para serem iguais em todas as réplicas.
+var entryIDMethods = map[string]bool{
+	"buy_pack":    true,
+	"grant_cards": true,
+	"start_match": true,
+}
+
+// AssignEntryID grava no payload um entry_id aleatório, substituindo o que o cliente enviou.
+// Chamado na borda, antes de o evento entrar no log, como o instante do evento.
+func AssignEntryID(event *Event) {
+	if !entryIDMethods[event.Method] {
+		return
+	}
+	if event.Payload == nil {
+		event.Payload = make(map[string]any)
+	}
+	event.Payload["entry_id"] = uuid.New().String()
+}
@@ -2,19 +2,25 @@ package api
 
 import (
 	"cod-server/internal/auth"
+	"cod-server/internal/domain"
 	"cod-server/internal/services"
-	"fmt"
+	"errors"
 	shared_protocol "shared/protocol"
 	"time"
 )
 
 // EventHandler implementa EventHandlerInterface, roteando eventos para serviços apropriados.
 // Gerencia todas as operações voltadas ao usuário: registro, login, cartas, partidas e trocas.
+// Ações protegidas passam pela política de papéis antes de chegar aos serviços.
 type EventHandler struct {
-	userService  services.UserServiceInterface
-	cardsService services.CardsServiceInterface
-	matchService services.MatchServiceInterface
-	authService  *auth.AuthService
+	userService       services.UserServiceInterface
+	cardsService      services.CardsServiceInterface
+	matchService      services.MatchServiceInterface
+	moderationService services.ModerationServiceInterface
+	loginGuard        services.LoginGuardInterface
+	catalogService    services.CatalogServiceInterface
+	authService       *auth.AuthService
+	policy            *auth.Policy
 }
 
 // NewEventHandler cria um novo EventHandler com dependências injetadas.
@@ -22,80 +28,344 @@ func NewEventHandler(
 	userService services.UserServiceInterface,
 	cardsService services.CardsServiceInterface,
 	matchService services.MatchServiceInterface,
+	moderationService services.ModerationServiceInterface,
+	loginGuard services.LoginGuardInterface,
+	catalogService services.CatalogServiceInterface,
 	authService *auth.AuthService,
+	policy *auth.Policy,
 ) EventHandlerInterface {
 	return &EventHandler{
-		userService:  userService,
-		cardsService: cardsService,
-		matchService: matchService,
-		authService:  authService,
+		userService:       userService,
+		cardsService:      cardsService,
+		matchService:      matchService,
+		moderationService: moderationService,
+		loginGuard:        loginGuard,
+		catalogService:    catalogService,
+		authService:       authService,
+		policy:            policy,
 	}
 }
 
-// OnRegister processa solicitações de registro de usuário com validação e tratamento de erros.
+// OnRegister grava a conta preparada na borda por Credentials: id, username normalizado e hash
+// da senha já vêm no payload, e a data de cadastro é o instante do evento.
 func (eh *EventHandler) OnRegister(event Event) Event {
-	username, ok1 := event.Payload["username"].(string)
-	password, ok2 := event.Payload["password"].(string)
-	if !ok1 || !ok2 {
+	userID, ok1 := event.Payload["user_id"].(string)
+	username, ok2 := event.Payload["username"].(string)
+	passwordHash, ok3 := event.Payload["password_hash"].(string)
+	if !ok1 || !ok2 || !ok3 {
 		return makeErrorEvent("register_fail", "invalid payload")
 	}
 
-	err := eh.userService.Register(username, password)
+	err := eh.userService.CreateRegistered(services.Registration{
+		UserID:       userID,
+		Username:     username,
+		PasswordHash: passwordHash,
+	}, event.Timestamp.UTC())
 	if err != nil {
-		return makeErrorEvent("register_fail", err.Error())
+		fail := NewErrorEvent("register_fail", registerErrorCode(err), err.Error())
+		fail.Payload["status"] = "fail" // Formato compatível com o cliente
+		return fail
 	}
 
 	return Event{
 		Event: shared_protocol.Event{
 			Method:    "register_ok",
 			Timestamp: time.Now(),
-			Payload:   map[string]any{"username": username},
+			Payload:   map[string]any{"username": username, "status": "success"},
 		},
 	}
 }
 
+// loginGuardKeys deriva as chaves de contagem a partir do client_id de origem, que o handler
+// MQTT tira do tópico requests/{client_id}/... (o ACL do broker só deixa cada conexão publicar
+// sob o próprio client id). A chave da conta é por par conta e origem: quem erra a senha de
+// alice bloqueia só as próprias tentativas, não o login de alice em outras conexões. O username
+// é normalizado, exista a conta ou não, para não revelar quais existem. Sem origem não há chave.
+func loginGuardKeys(username string, event Event) (string, string, bool) {
+	source, _ := event.Payload["client_id"].(string)
+	if source == "" {
+		return "", "", false
+	}
+	normalized, err := domain.NormalizeUsername(username)
+	if err != nil {
+		normalized = username
+	}
+	return "account:" + normalized + "|source:" + source, "source:" + source, true
+}
+
+// loginFail cria o login_fail com código e o status esperado pelo cliente.
+func loginFail(code, message string) Event {
+	fail := NewErrorEvent("login_fail", code, message)
+	fail.Payload["status"] = "fail"
+	return fail
+}
+
+// accountFail cria a falha de uma operação de conta, com o user_id para o cliente descartar
+// respostas de uma sessão que já encerrou.
+func accountFail(method, userID string, err error) Event {
+	code := "account_error"
+	switch {
+	case errors.Is(err, services.ErrWrongPassword):
+		code = "wrong_password"
+	case errors.Is(err, domain.ErrWeakPassword):
+		code = "weak_password"
+	case errors.Is(err, services.ErrUserNotFound):
+		code = "user_not_found"
+	}
+	fail := NewErrorEvent(method, code, err.Error())
+	fail.Payload["user_id"] = userID
+	return fail
+}
+
+// registerErrorCode traduz erros de registro para os códigos enviados em register_fail.
+func registerErrorCode(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrUsernameTaken):
+		return "username_taken"
+	case errors.Is(err, domain.ErrInvalidUsername):
+		return "invalid_username"
+	case errors.Is(err, domain.ErrWeakPassword):
+		return "weak_password"
+	default:
+		return "registration_failed"
+	}
+}
+
+// OnLogin conclui o login cuja senha foi conferida na borda por Credentials: password_hash é o
+// hash conferido, ou vazio se a senha não confere, e ainda precisa ser o gravado na conta.
 func (eh *EventHandler) OnLogin(event Event) Event {
 	username, ok1 := event.Payload["username"].(string)
-	password, ok2 := event.Payload["password"].(string)
+	passwordHash, ok2 := event.Payload["password_hash"].(string)
 	if !ok1 || !ok2 {
-		return makeErrorEvent("login_fail", "invalid payload")
+		return loginFail("invalid_payload", "invalid payload")
 	}
 
-	user, err := eh.userService.Login(username, password)
+	// Tentativas são contadas por conta na origem e por origem. O instante é o do evento, igual
+	// em todas as réplicas, então o bloqueio vale no cluster inteiro.
+	at := event.Timestamp
+	accountKey, sourceKey, ok := loginGuardKeys(username, event)
+	if !ok {
+		return loginFail("invalid_payload", "missing client id")
+	}
+	if key, lockedUntil, locked := eh.loginGuard.Locked(at, accountKey, sourceKey); locked {
+		code := "account_locked"
+		if key == sourceKey {
+			code = "source_locked"
+		}
+		fail := loginFail(code, "too many failed login attempts")
+		fail.Payload["locked_until"] = lockedUntil
+		fail.Payload["retry_after_ms"] = lockedUntil.Sub(at).Milliseconds()
+		return fail
+	}
+
+	user, err := eh.userService.LoginWithHash(username, passwordHash)
 	if err != nil {
-		return makeErrorEvent("login_fail", err.Error())
+		return loginFail("login_failed", err.Error())
 	}
 	if user == nil {
-		return makeErrorEvent("login_fail", "invalid credentials")
+		eh.loginGuard.RecordFailure(at, accountKey, sourceKey)
+		return loginFail("invalid_credentials", "invalid credentials")
 	}
+	// Só a conta nesta origem é liberada: um login válido não pode zerar o contador da origem
+	eh.loginGuard.RecordSuccess(accountKey)
 
-	// Gerar token JWT após login bem-sucedido
-	// user é do tipo *domain.UserInterface, então primeiro desreferenciamos
-	userID := (*user).GetID()
-	token, err := eh.authService.GenerateToken(userID, username)
+	// Gerar par de tokens JWT após login bem-sucedido
+	userID := user.GetID()
+	roles := roleNames(user.GetRoles())
+	tokens, err := eh.authService.GenerateTokenPair(userID, user.GetUsername(), roles)
 	if err != nil {
-		return makeErrorEvent("login_fail", "failed to generate token")
+		return loginFail("login_failed", "failed to generate token")
 	}
 
+	payload := tokenPayload(tokens)
+	payload["user_id"] = userID
+	payload["roles"] = roles
+	payload["status"] = "success" // Formato compatível com o cliente
 	return Event{
 		Event: shared_protocol.Event{
 			Method:    "login_ok",
 			Timestamp: time.Now(),
-			Payload:   map[string]any{"user_id": userID, "status": "success", "token": token}, // Formato compatível com o cliente
+			Payload:   payload,
 		},
 	}
 }
 
-func (eh *EventHandler) OnGetCards(event Event) Event {
+// OnRefresh troca um token de refresh válido por um novo par de tokens.
+// O refresh usado é revogado (rotação), então cada token de refresh vale uma única vez.
+// O token foi validado na borda pelo Authenticator; aqui chegam só as suas claims, e a
+// revogação é conferida na ordem do log, o que recusa o segundo uso do mesmo token.
+func (eh *EventHandler) OnRefresh(event Event) Event {
+	claims, ok := refreshClaims(event)
+	if !ok {
+		return makeErrorEvent("refresh_fail", "invalid payload")
+	}
+	if event.Timestamp.After(claims.expiresAt) || eh.authService.IsRevoked(claims.jti, claims.userID, claims.generation) {
+		return makeErrorEvent("refresh_fail", "invalid or expired refresh token")
+	}
+	eh.authService.Revoke(claims.jti, claims.expiresAt)
+
+	// Papéis são relidos do usuário para que promoções e rebaixamentos valham no refresh
+	user, err := eh.userService.GetUser(claims.userID)
+	if err != nil {
+		return makeErrorEvent("refresh_fail", "user not found")
+	}
+	roles := roleNames(user.GetRoles())
+	tokens, err := eh.authService.GenerateTokenPair(claims.userID, claims.username, roles)
+	if err != nil {
+		return makeErrorEvent("refresh_fail", "failed to generate token")
+	}
+
+	payload := tokenPayload(tokens)
+	payload["user_id"] = claims.userID
+	payload["roles"] = roles
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "refresh_ok",
+			Timestamp: time.Now(),
+			Payload:   payload,
+		},
+	}
+}
+
+// OnLogout revoga o token de acesso da sessão e, se enviado, o token de refresh.
+// Aplicado via Raft, a revogação chega à lista de todos os nós.
+func (eh *EventHandler) OnLogout(event Event) Event {
+	userID, ok1 := event.Payload["user_id"].(string)
+	jti, ok2 := event.Payload["jti"].(string)
+	if !ok1 || !ok2 {
+		return makeErrorEvent("logout_fail", "invalid payload")
+	}
+
+	// Números do payload chegam como float64 após a desserialização JSON do log
+	if exp, ok := event.Payload["token_exp"].(float64); ok {
+		eh.authService.Revoke(jti, time.Unix(int64(exp), 0))
+	} else {
+		eh.authService.Revoke(jti, event.Timestamp.Add(auth.AccessTokenTTL))
+	}
+
+	if claims, ok := refreshClaims(event); ok && claims.userID == userID {
+		eh.authService.Revoke(claims.jti, claims.expiresAt)
+	}
+
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "logout_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"user_id": userID},
+		},
+	}
+}
+
+// validatedRefresh são as claims do token de refresh gravadas no payload pelo Authenticator.
+type validatedRefresh struct {
+	jti, userID, username string
+	generation            uint64
+	expiresAt             time.Time
+}
+
+// refreshClaims lê do payload as claims do token de refresh validado na borda.
+func refreshClaims(event Event) (validatedRefresh, bool) {
+	jti, ok1 := event.Payload["refresh_jti"].(string)
+	userID, ok2 := event.Payload["refresh_user_id"].(string)
+	username, _ := event.Payload["refresh_username"].(string)
+	// Números do payload chegam como float64 após a desserialização JSON do log
+	gen, ok3 := event.Payload["refresh_gen"].(float64)
+	exp, ok4 := event.Payload["refresh_exp"].(float64)
+	if !ok1 || !ok2 || !ok3 || !ok4 || jti == "" || userID == "" {
+		return validatedRefresh{}, false
+	}
+	return validatedRefresh{
+		jti:        jti,
+		userID:     userID,
+		username:   username,
+		generation: uint64(gen),
+		expiresAt:  time.Unix(int64(exp), 0),
+	}, true
+}
+
+// OnChangePassword troca a senha e revoga todos os tokens emitidos antes da troca, em todos
+// os nós. A resposta traz um novo par de tokens para que a sessão atual continue.
+// As senhas foram trocadas por hashes na borda por Credentials.
+func (eh *EventHandler) OnChangePassword(event Event) Event {
+	userID, ok1 := event.Payload["user_id"].(string)
+	currentHash, ok2 := event.Payload["password_hash"].(string)
+	newHash, ok3 := event.Payload["new_password_hash"].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return makeErrorEvent("change_password_fail", "invalid payload")
+	}
+
+	if err := eh.userService.SetPasswordHash(userID, currentHash, newHash); err != nil {
+		return accountFail("change_password_fail", userID, err)
+	}
+	eh.authService.RevokeUserTokens(userID)
+
+	user, err := eh.userService.GetUser(userID)
+	if err != nil {
+		return accountFail("change_password_fail", userID, err)
+	}
+	tokens, err := eh.authService.GenerateTokenPair(userID, user.GetUsername(), roleNames(user.GetRoles()))
+	if err != nil {
+		return makeErrorEvent("change_password_fail", "failed to generate token")
+	}
+
+	payload := tokenPayload(tokens)
+	payload["user_id"] = userID
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "change_password_ok",
+			Timestamp: time.Now(),
+			Payload:   payload,
+		},
+	}
+}
+
+// OnDeleteAccount remove a conta, queimando cartas e abandonando partidas, e revoga seus tokens.
+// A senha foi conferida na borda por Credentials; aqui chega o hash conferido.
+func (eh *EventHandler) OnDeleteAccount(event Event) Event {
 	userID, ok1 := event.Payload["user_id"].(string)
-	token, ok2 := event.Payload["token"].(string)
+	passwordHash, ok2 := event.Payload["password_hash"].(string)
 	if !ok1 || !ok2 {
-		return makeErrorEvent("get_cards_fail", "invalid payload - user_id and token required")
+		return makeErrorEvent("delete_account_fail", "invalid payload")
 	}
 
-	// Validar o token
-	if err := eh.validateTokenForUser(userID, token); err != nil {
-		return makeErrorEvent("get_cards_fail", "invalid or expired token")
+	if err := eh.userService.DeleteAccountWithHash(userID, passwordHash); err != nil {
+		return accountFail("delete_account_fail", userID, err)
+	}
+	eh.authService.RevokeUserTokens(userID)
+
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "delete_account_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"user_id": userID},
+		},
+	}
+}
+
+// OnGetProfile retorna username, data de cadastro, número de cartas e histórico de partidas.
+func (eh *EventHandler) OnGetProfile(event Event) Event {
+	userID, ok := event.Payload["user_id"].(string)
+	if !ok {
+		return makeErrorEvent("get_profile_fail", "invalid payload")
+	}
+
+	profile, err := eh.userService.GetProfile(userID)
+	if err != nil {
+		return accountFail("get_profile_fail", userID, err)
+	}
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "get_profile_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"user_id": userID, "profile": profile},
+		},
+	}
+}
+
+func (eh *EventHandler) OnGetCards(event Event) Event {
+	userID, ok := event.Payload["user_id"].(string)
+	if !ok {
+		return makeErrorEvent("get_cards_fail", "invalid payload")
 	}
 
 	cards, err := eh.cardsService.GetCards(userID)
@@ -118,7 +388,12 @@ func (eh *EventHandler) OnBuyPack(event Event) Event {
 		return makeErrorEvent("buy_pack_fail", "invalid payload")
 	}
 
-	err := eh.cardsService.BuyPack(userID)
+	if fail, denied := eh.authorize(event, "user_id", "buy_pack_fail", auth.PermPlay); denied {
+		return fail
+	}
+
+	entryID, _ := event.Payload["entry_id"].(string)
+	err := eh.cardsService.BuyPack(userID, entryID)
 	if err != nil {
 		return makeErrorEvent("buy_pack_fail", err.Error())
 	}
@@ -141,6 +416,10 @@ func (eh *EventHandler) OnOfferTrade(event Event) Event {
 		return makeErrorEvent("offer_trade_fail", "invalid payload")
 	}
 
+	if fail, denied := eh.authorize(event, "from_user_id", "offer_trade_fail", auth.PermPlay); denied {
+		return fail
+	}
+
 	err := eh.cardsService.OfferTrade(fromUserID, toUserID, cardID)
 	if err != nil {
 		return makeErrorEvent("offer_trade_fail", err.Error())
@@ -160,6 +439,10 @@ func (eh *EventHandler) OnAcceptTrade(event Event) Event {
 		return makeErrorEvent("accept_trade_fail", "invalid payload")
 	}
 
+	if fail, denied := eh.authorize(event, "to_user_id", "accept_trade_fail", auth.PermPlay); denied {
+		return fail
+	}
+
 	err := eh.cardsService.AcceptTrade(fromUserID, toUserID, cardID)
 	if err != nil {
 		return makeErrorEvent("accept_trade_fail", err.Error())
@@ -179,7 +462,13 @@ func (eh *EventHandler) OnStartMatch(event Event) Event {
 	if !ok {
 		return makeErrorEvent("start_match_fail", "invalid payload")
 	}
-	match, err := eh.matchService.StartMatch(userID)
+	if fail, denied := eh.authorize(event, "user_id", "start_match_fail", auth.PermPlay); denied {
+		return fail
+	}
+
+	// O id da partida é o da entrada, atribuído na borda: o mesmo em todas as réplicas
+	entryID, _ := event.Payload["entry_id"].(string)
+	match, err := eh.matchService.StartMatch(userID, entryID)
 	if err != nil {
 		return makeErrorEvent("start_match_fail", err.Error())
 	}
@@ -200,6 +489,10 @@ func (eh *EventHandler) OnJoinMatch(event Event) Event {
 		return makeErrorEvent("join_match_fail", "invalid payload")
 	}
 
+	if fail, denied := eh.authorize(event, "user_id", "join_match_fail", auth.PermPlay); denied {
+		return fail
+	}
+
 	err := eh.matchService.JoinMatch(userID, matchID)
 	if err != nil {
 		return makeErrorEvent("join_match_fail", err.Error())
@@ -220,6 +513,10 @@ func (eh *EventHandler) OnSurrenderMatch(event Event) Event {
 	if !ok1 || !ok2 {
 		return makeErrorEvent("surrender_match_fail", "invalid payload")
 	}
+	if fail, denied := eh.authorize(event, "user_id", "surrender_match_fail", auth.PermPlay); denied {
+		return fail
+	}
+
 	err := eh.matchService.SurrenderMatch(userID, matchID)
 	if err != nil {
 		return makeErrorEvent("surrender_match_fail", err.Error())
@@ -241,6 +538,10 @@ func (eh *EventHandler) OnMakeMove(event Event) Event {
 		return makeErrorEvent("make_move_fail", "invalid payload")
 	}
 
+	if fail, denied := eh.authorize(event, "user_id", "make_move_fail", auth.PermPlay); denied {
+		return fail
+	}
+
 	err := eh.matchService.MakeMove(userID, matchID, cardID)
 	if err != nil {
 		return makeErrorEvent("make_move_fail", err.Error())
@@ -254,18 +555,13 @@ func (eh *EventHandler) OnMakeMove(event Event) Event {
 	}
 }
 
-// validateTokenForUser verifica se o token é válido para o usuário especificado
-func (eh *EventHandler) validateTokenForUser(userID string, token string) error {
-	claims, err := eh.authService.ValidateToken(token)
-	if err != nil {
-		return err
+// tokenPayload converte um par de tokens no formato enviado ao cliente
+func tokenPayload(tokens *auth.TokenPair) map[string]any {
+	return map[string]any{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    int64(time.Until(tokens.AccessExpiresAt).Seconds()),
 	}
-
-	if claims.UserID != userID {
-		return fmt.Errorf("token does not match user ID")
-	}
-
-	return nil
 }
 
 // makeErrorEvent é uma função auxiliar para criar eventos de erro padronizados
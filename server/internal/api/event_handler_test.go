@@ -0,0 +1,473 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cod-server/internal/auth"
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+	"cod-server/internal/services"
+	shared_protocol "shared/protocol"
+)
+
+// testRepos são os repositórios em memória por trás do EventHandler de teste.
+type testRepos struct {
+	users   data.Repository[domain.UserInterface]
+	cards   data.Repository[domain.CardInterface]
+	matches data.Repository[domain.MatchInterface]
+}
+
+// newTestHandler monta um EventHandler sobre repositórios em memória, com o usuário alice.
+func newTestHandler(t *testing.T, guardConfig services.LoginGuardConfig) *EventHandler {
+	handler, _ := newTestHandlerWithRepos(t, guardConfig)
+	return handler
+}
+
+func newTestHandlerWithRepos(t *testing.T, guardConfig services.LoginGuardConfig) (*EventHandler, testRepos) {
+	t.Helper()
+	repos := testRepos{
+		users:   data.NewMemoryRepository[domain.UserInterface](),
+		cards:   data.NewMemoryRepository[domain.CardInterface](),
+		matches: data.NewMemoryRepository[domain.MatchInterface](),
+	}
+	userService := services.NewUserServiceWithConfig(services.UserServiceConfig{Users: repos.users, Cards: repos.cards, Matches: repos.matches})
+	if err := userService.Register("alice", "alicepass1"); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewEventHandler(userService, nil, nil, services.NewModerationService(), services.NewLoginGuard(guardConfig),
+		nil, newTestAuthService(t), auth.DefaultPolicy())
+	return handler.(*EventHandler), repos
+}
+
+// atEdge passa o evento por Credentials, como o coordenador faz antes de o evento entrar no log.
+func atEdge(t *testing.T, handler *EventHandler, event Event) Event {
+	t.Helper()
+	if fail := NewCredentials(handler.userService).Prepare(&event); fail != nil {
+		t.Fatalf("%s rejected at the edge: %v", event.Method, fail.Payload)
+	}
+	return event
+}
+
+func accountEvent(method string, at time.Time, payload map[string]any) Event {
+	return Event{Event: shared_protocol.Event{Method: method, Timestamp: at, Payload: payload}}
+}
+
+func replyCode(reply Event) string {
+	code, _ := reply.Payload["code"].(string)
+	return code
+}
+
+func loginEvent(at time.Time, clientID, username, password string) Event {
+	return Event{Event: shared_protocol.Event{
+		Method:    "login",
+		Timestamp: at,
+		Payload:   map[string]any{"client_id": clientID, "username": username, "password": password},
+	}}
+}
+
+func TestOnLogin_LockoutIsPerAccountAndSource(t *testing.T) {
+	handler := newTestHandler(t, services.LoginGuardConfig{MaxFailures: 3, BaseLockout: time.Minute, MaxLockout: time.Hour, ResetAfter: time.Hour})
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	login := func(at time.Time, clientID, username, password string) Event {
+		return handler.OnLogin(atEdge(t, handler, loginEvent(at, clientID, username, password)))
+	}
+
+	for i := 0; i < 3; i++ {
+		if reply := login(at, "attacker", "ALICE", "wrongpass1"); replyCode(reply) != "invalid_credentials" {
+			t.Fatalf("failure %d: code %q", i, replyCode(reply))
+		}
+	}
+	if reply := login(at, "attacker", "alice", "alicepass1"); replyCode(reply) != "account_locked" {
+		t.Errorf("attacker after lockout: code %q, want account_locked", replyCode(reply))
+	}
+	// O bloqueio não alcança alice em outra conexão
+	if reply := login(at, "alice-laptop", "alice", "alicepass1"); reply.Method != "login_ok" {
+		t.Errorf("alice from another client: %s %v, want login_ok", reply.Method, reply.Payload)
+	}
+
+	// Errar em contas diferentes esgota a origem
+	for _, username := range []string{"bob", "carol"} {
+		login(at, "attacker", username, "wrongpass1")
+	}
+	if reply := login(at, "attacker", "dave", "wrongpass1"); replyCode(reply) != "source_locked" {
+		t.Errorf("source after failures on many accounts: code %q, want source_locked", replyCode(reply))
+	}
+	if reply := login(at.Add(time.Minute), "attacker", "alice", "alicepass1"); reply.Method != "login_ok" {
+		t.Errorf("after the lockout ends: %s %v, want login_ok", reply.Method, reply.Payload)
+	}
+}
+
+func TestOnLogin_RequiresClientID(t *testing.T) {
+	handler := newTestHandler(t, services.DefaultLoginGuardConfig())
+	reply := handler.OnLogin(atEdge(t, handler, loginEvent(time.Now(), "", "alice", "alicepass1")))
+	if reply.Method != "login_fail" || reply.Payload["code"] != "invalid_payload" {
+		t.Errorf("login without client id: %s %v", reply.Method, reply.Payload)
+	}
+}
+
+func TestCredentials_KeepPasswordsOutOfTheLog(t *testing.T) {
+	handler, repos := newTestHandlerWithRepos(t, services.DefaultLoginGuardConfig())
+	aliceID := mustUserID(t, repos, "alice")
+
+	events := []Event{
+		accountEvent("register", time.Now(), map[string]any{"username": " Bob ", "password": "bobsecret1", "user_id": "forged", "password_hash": "forged"}),
+		accountEvent("login", time.Now(), map[string]any{"client_id": "c1", "username": "alice", "password": "alicepass1"}),
+		accountEvent("change_password", time.Now(), map[string]any{"user_id": aliceID, "old_password": "alicepass1", "new_password": "alicepass2"}),
+		accountEvent("delete_account", time.Now(), map[string]any{"user_id": aliceID, "password": "alicepass1"}),
+	}
+	for _, event := range events {
+		prepared := atEdge(t, handler, event)
+		raw, err := json.Marshal(prepared.Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"bobsecret1", "alicepass1", "alicepass2", "forged"} {
+			if strings.Contains(string(raw), secret) {
+				t.Errorf("%s payload still carries %q: %s", event.Method, secret, raw)
+			}
+		}
+		if hash, _ := prepared.Payload["password_hash"].(string); hash == "" {
+			t.Errorf("%s payload without password_hash: %s", event.Method, raw)
+		}
+	}
+	if username := events[0].Payload["username"]; username != "bob" {
+		t.Errorf("register username = %v, want it normalized at the edge", username)
+	}
+}
+
+func TestCredentials_RejectsAtTheEdge(t *testing.T) {
+	handler, repos := newTestHandlerWithRepos(t, services.DefaultLoginGuardConfig())
+	aliceID := mustUserID(t, repos, "alice")
+	credentials := NewCredentials(handler.userService)
+
+	tests := []struct {
+		event    Event
+		wantCode string
+	}{
+		{accountEvent("register", time.Now(), map[string]any{"username": "bob", "password": "short"}), "weak_password"},
+		{accountEvent("register", time.Now(), map[string]any{"username": "ALICE", "password": "bobsecret1"}), "username_taken"},
+		{accountEvent("change_password", time.Now(), map[string]any{"user_id": aliceID, "old_password": "wrongpass1", "new_password": "alicepass2"}), "wrong_password"},
+		{accountEvent("change_password", time.Now(), map[string]any{"user_id": aliceID, "old_password": "alicepass1", "new_password": "alicepass1"}), "weak_password"},
+		{accountEvent("delete_account", time.Now(), map[string]any{"user_id": aliceID, "password": "wrongpass1"}), "wrong_password"},
+	}
+	for _, tt := range tests {
+		fail := credentials.Prepare(&tt.event)
+		if fail == nil {
+			t.Errorf("%s %v accepted at the edge", tt.event.Method, tt.event.Payload)
+			continue
+		}
+		if fail.Method != tt.event.Method+"_fail" || replyCode(*fail) != tt.wantCode {
+			t.Errorf("%s: got %s %q, want code %q", tt.event.Method, fail.Method, replyCode(*fail), tt.wantCode)
+		}
+	}
+
+	// Senha errada no login segue para a FSM, que conta a falha
+	login := loginEvent(time.Now(), "c1", "alice", "wrongpass1")
+	if fail := credentials.Prepare(&login); fail != nil {
+		t.Fatalf("wrong login password rejected at the edge: %v", fail.Payload)
+	}
+	if reply := handler.OnLogin(login); replyCode(reply) != "invalid_credentials" {
+		t.Errorf("login with a wrong password: code %q", replyCode(reply))
+	}
+}
+
+func TestOnRegister_AppliesPreparedAccount(t *testing.T) {
+	handler := newTestHandler(t, services.DefaultLoginGuardConfig())
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := atEdge(t, handler, accountEvent("register", at, map[string]any{"username": "Bob", "password": "bobsecret1"}))
+
+	if reply := handler.OnRegister(event); reply.Method != "register_ok" || reply.Payload["username"] != "bob" {
+		t.Fatalf("register: %s %v", reply.Method, reply.Payload)
+	}
+	user, err := handler.userService.GetUser(event.Payload["user_id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.GetCreatedAt().Equal(at) || !user.CheckPassword("bobsecret1") {
+		t.Errorf("stored account: created_at %v, want the event time %v", user.GetCreatedAt(), at)
+	}
+
+	// Dois registros preparados antes de qualquer um ser aplicado: o segundo perde na FSM
+	again := atEdge(t, handler, accountEvent("register", at, map[string]any{"username": "carol", "password": "carolpass1"}))
+	duplicate := atEdge(t, handler, accountEvent("register", at, map[string]any{"username": "CAROL", "password": "carolpass2"}))
+	handler.OnRegister(again)
+	if reply := handler.OnRegister(duplicate); replyCode(reply) != "username_taken" {
+		t.Errorf("duplicate registration: %s %v", reply.Method, reply.Payload)
+	}
+}
+
+func TestOnChangePassword_RevokesTokensAndStaleHashes(t *testing.T) {
+	handler := newTestHandler(t, services.DefaultLoginGuardConfig())
+	at := time.Now()
+	login := handler.OnLogin(atEdge(t, handler, loginEvent(at, "c1", "alice", "alicepass1")))
+	if login.Method != "login_ok" {
+		t.Fatalf("login: %v", login.Payload)
+	}
+	aliceID := login.Payload["user_id"].(string)
+	oldToken := login.Payload["token"].(string)
+
+	// Preparados na borda antes da troca ser aplicada
+	change := atEdge(t, handler, accountEvent("change_password", at, map[string]any{"user_id": aliceID, "old_password": "alicepass1", "new_password": "alicepass2"}))
+	staleChange := atEdge(t, handler, accountEvent("change_password", at, map[string]any{"user_id": aliceID, "old_password": "alicepass1", "new_password": "alicepass3"}))
+	staleLogin := atEdge(t, handler, loginEvent(at, "c2", "alice", "alicepass1"))
+	staleDelete := atEdge(t, handler, accountEvent("delete_account", at, map[string]any{"user_id": aliceID, "password": "alicepass1"}))
+
+	reply := handler.OnChangePassword(change)
+	if reply.Method != "change_password_ok" {
+		t.Fatalf("change_password: %v", reply.Payload)
+	}
+	// No mesmo segundo do login: a geração do token é que o derruba, não o iat
+	if _, err := handler.authService.ValidateToken(oldToken); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Errorf("token issued before the change: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := handler.authService.ValidateToken(reply.Payload["token"].(string)); err != nil {
+		t.Errorf("token returned by the change rejected: %v", err)
+	}
+
+	if reply := handler.OnChangePassword(staleChange); replyCode(reply) != "wrong_password" {
+		t.Errorf("change verified against the old password: %s %v", reply.Method, reply.Payload)
+	}
+	if reply := handler.OnLogin(staleLogin); replyCode(reply) != "invalid_credentials" {
+		t.Errorf("login verified against the old password: %s %v", reply.Method, reply.Payload)
+	}
+	if reply := handler.OnDeleteAccount(staleDelete); replyCode(reply) != "wrong_password" {
+		t.Errorf("deletion verified against the old password: %s %v", reply.Method, reply.Payload)
+	}
+	if reply := handler.OnLogin(atEdge(t, handler, loginEvent(at, "c2", "alice", "alicepass2"))); reply.Method != "login_ok" {
+		t.Errorf("login with the new password: %v", reply.Payload)
+	}
+}
+
+func TestOnDeleteAccount_BurnsCardsAndLeavesMatches(t *testing.T) {
+	handler, repos := newTestHandlerWithRepos(t, services.DefaultLoginGuardConfig())
+	if err := handler.userService.Register("bob", "bobsecret1"); err != nil {
+		t.Fatal(err)
+	}
+	aliceID, bobID := mustUserID(t, repos, "alice"), mustUserID(t, repos, "bob")
+	alice, _ := handler.userService.GetUser(aliceID)
+	bob, _ := handler.userService.GetUser(bobID)
+
+	for id, owner := range map[string]string{"a1": aliceID, "a2": aliceID, "b1": bobID} {
+		if err := repos.cards.Create(id, &domain.Card{ID: id, OwnerID: owner, Type: "fire"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	duel := &domain.Match{ID: "duel", Players: []domain.UserInterface{alice, bob}, Scores: map[string]int{}}
+	solo := &domain.Match{ID: "solo", Players: []domain.UserInterface{alice}, Scores: map[string]int{}}
+	for _, match := range []*domain.Match{duel, solo} {
+		if err := repos.matches.Create(match.ID, match); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokens, err := handler.authService.GenerateTokenPair(aliceID, "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := atEdge(t, handler, accountEvent("delete_account", time.Now(), map[string]any{"user_id": aliceID, "password": "alicepass1"}))
+	if reply := handler.OnDeleteAccount(event); reply.Method != "delete_account_ok" {
+		t.Fatalf("delete_account: %v", reply.Payload)
+	}
+
+	if _, err := handler.userService.GetUser(aliceID); !errors.Is(err, services.ErrUserNotFound) {
+		t.Errorf("deleted user still readable: %v", err)
+	}
+	for id, want := range map[string]bool{"a1": false, "a2": false, "b1": true} {
+		if _, err := repos.cards.Read(id); (err == nil) != want {
+			t.Errorf("card %s present = %v, want %v", id, err == nil, want)
+		}
+	}
+	match, err := repos.matches.Read("duel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if winner, _ := match.GetWinner(); winner != bobID {
+		t.Errorf("match against bob: winner %q, want bob by surrender", winner)
+	}
+	if match, _ := repos.matches.Read("solo"); match == nil || !match.IsCancelled() {
+		t.Error("match without an opponent was not cancelled")
+	}
+	if _, err := handler.authService.ValidateRefreshToken(tokens.RefreshToken); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Errorf("refresh token of the deleted account: got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestOnGetProfile(t *testing.T) {
+	handler, repos := newTestHandlerWithRepos(t, services.DefaultLoginGuardConfig())
+	if err := handler.userService.Register("bob", "bobsecret1"); err != nil {
+		t.Fatal(err)
+	}
+	aliceID, bobID := mustUserID(t, repos, "alice"), mustUserID(t, repos, "bob")
+	alice, _ := handler.userService.GetUser(aliceID)
+	bob, _ := handler.userService.GetUser(bobID)
+
+	for _, id := range []string{"a1", "a2", "a3"} {
+		if err := repos.cards.Create(id, &domain.Card{ID: id, OwnerID: aliceID, Type: "water"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matches := []*domain.Match{
+		{ID: "won", Players: []domain.UserInterface{alice, bob}, Winner: aliceID},
+		{ID: "lost", Players: []domain.UserInterface{alice, bob}, Winner: bobID},
+		{ID: "open", Players: []domain.UserInterface{alice, bob}},
+		{ID: "cancelled", Players: []domain.UserInterface{alice}, Cancelled: true},
+	}
+	for _, match := range matches {
+		if err := repos.matches.Create(match.ID, match); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reply := handler.OnGetProfile(accountEvent("get_profile", time.Now(), map[string]any{"user_id": aliceID}))
+	profile, ok := reply.Payload["profile"].(*services.Profile)
+	if reply.Method != "get_profile_ok" || !ok {
+		t.Fatalf("get_profile: %s %v", reply.Method, reply.Payload)
+	}
+	if profile.Username != "alice" || profile.CardCount != 3 || profile.Wins != 1 || profile.Losses != 1 {
+		t.Errorf("profile = %+v, want alice with 3 cards, 1 win and 1 loss", *profile)
+	}
+	if len(profile.Roles) != 1 || profile.Roles[0] != string(domain.RolePlayer) {
+		t.Errorf("roles = %v, want [player]", profile.Roles)
+	}
+
+	reply = handler.OnGetProfile(accountEvent("get_profile", time.Now(), map[string]any{"user_id": "ghost"}))
+	if reply.Method != "get_profile_fail" || replyCode(reply) != "user_not_found" {
+		t.Errorf("profile of a missing user: %s %v", reply.Method, reply.Payload)
+	}
+}
+
+// mustUserID retorna o id do usuário com o username informado.
+func mustUserID(t *testing.T, repos testRepos, username string) string {
+	t.Helper()
+	users, err := repos.users.ListBy(func(u domain.UserInterface) bool { return u.GetUsername() == username })
+	if err != nil || len(users) != 1 {
+		t.Fatalf("user %s: %v", username, err)
+	}
+	return users[0].GetID()
+}
+
+// catalogEvent monta um sync_catalog como chega do log: o catálogo desserializado em mapas.
+func catalogEvent(t *testing.T, catalog any) Event {
+	t.Helper()
+	raw, err := json.Marshal(map[string]any{"method": "sync_catalog", "payload": map[string]any{"catalog": catalog}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event Event
+	if err := json.Unmarshal(raw, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestOnSyncCatalog(t *testing.T) {
+	catalogService := services.NewCatalogService(nil)
+	handler := NewEventHandler(nil, nil, nil, nil, nil, catalogService, nil, nil)
+
+	// Antes do primeiro sync_catalog a réplica não tem catálogo
+	if reply := handler.OnGetCatalog(Event{}); replyCode(reply) != "catalog_unavailable" {
+		t.Errorf("get_catalog before any sync: %s %v", reply.Method, reply.Payload)
+	}
+
+	v1 := &domain.Catalog{Version: 1, Templates: []domain.CardTemplate{{ID: "rock-pebble", Name: "Pebble", Element: "rock", Power: 1, Rarity: domain.RarityCommon}}}
+	if reply := handler.OnSyncCatalog(catalogEvent(t, v1)); reply.Method != "sync_catalog_ok" {
+		t.Fatalf("sync_catalog v1: %s %v", reply.Method, reply.Payload)
+	}
+	if template, err := catalogService.Template("rock-pebble"); err != nil || template != v1.Templates[0] {
+		t.Errorf("applied template = %+v (err %v), want %+v", template, err, v1.Templates[0])
+	}
+	if reply := handler.OnGetCatalog(Event{}); reply.Method != "get_catalog_ok" || reply.Payload["version"] != 1 {
+		t.Errorf("get_catalog after sync: %s %v", reply.Method, reply.Payload)
+	}
+
+	failures := []struct {
+		name    string
+		catalog any
+		code    string
+	}{
+		{"same version", v1, "stale_catalog"},
+		{"zero version", &domain.Catalog{Version: 0, Templates: v1.Templates}, "invalid_catalog"},
+		{"invalid template", &domain.Catalog{Version: 2, Templates: []domain.CardTemplate{{ID: "x"}}}, "invalid_catalog"},
+		{"not an object", "catalog", "invalid_catalog"},
+		{"missing", nil, "invalid_catalog"},
+	}
+	for _, tt := range failures {
+		reply := handler.OnSyncCatalog(catalogEvent(t, tt.catalog))
+		if reply.Method != "sync_catalog_fail" || replyCode(reply) != tt.code {
+			t.Errorf("%s: %s %v, want sync_catalog_fail with %s", tt.name, reply.Method, reply.Payload, tt.code)
+		}
+	}
+	if catalogService.Catalog().Version != 1 {
+		t.Errorf("rejected catalogs replaced the applied one: version %d", catalogService.Catalog().Version)
+	}
+}
+
+func TestModeration_BoundsCountAndDuration(t *testing.T) {
+	handler, repos := newTestHandlerWithRepos(t, services.DefaultLoginGuardConfig())
+	if err := handler.userService.Register("bob", "bobsecret1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.userService.BootstrapAdmin("alice"); err != nil {
+		t.Fatal(err)
+	}
+	aliceID, bobID := mustUserID(t, repos, "alice"), mustUserID(t, repos, "bob")
+
+	for _, count := range []float64{0, 2.5, MaxGrantCount + 1, 1e9} {
+		reply := handler.OnGrantCards(accountEvent("grant_cards", time.Now(), map[string]any{"user_id": aliceID, "target_user_id": bobID, "count": count}))
+		if reply.Method != "grant_cards_fail" {
+			t.Errorf("grant of %v cards: %s %v", count, reply.Method, reply.Payload)
+		}
+	}
+
+	at := time.Now()
+	reply := handler.OnMuteUser(accountEvent("mute_user", at, map[string]any{"user_id": aliceID, "room_id": "lobby", "target_user_id": bobID, "duration_seconds": 1e12}))
+	if reply.Method != "mute_user_ok" {
+		t.Fatalf("mute_user: %s %v", reply.Method, reply.Payload)
+	}
+	if until := reply.Payload["until"].(time.Time); !until.Equal(at.Add(MaxMuteDuration)) {
+		t.Errorf("until = %v, want the event time plus MaxMuteDuration", until)
+	}
+}
+
+func TestOnSetRoles_RevokesTokens(t *testing.T) {
+	handler, repos := newTestHandlerWithRepos(t, services.DefaultLoginGuardConfig())
+	if err := handler.userService.Register("bob", "bobsecret1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.userService.BootstrapAdmin("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.userService.BootstrapAdmin("bob"); err != nil {
+		t.Fatal(err)
+	}
+	login := handler.OnLogin(atEdge(t, handler, loginEvent(time.Now(), "c1", "bob", "bobsecret1")))
+	if login.Method != "login_ok" {
+		t.Fatalf("login: %v", login.Payload)
+	}
+
+	reply := handler.OnSetRoles(accountEvent("set_roles", time.Now(), map[string]any{"user_id": mustUserID(t, repos, "alice"), "target_user_id": mustUserID(t, repos, "bob"), "roles": []any{"player"}}))
+	if reply.Method != "set_roles_ok" {
+		t.Fatalf("set_roles: %s %v", reply.Method, reply.Payload)
+	}
+	// As claims do token ainda dizem admin; a rota /admin não pode continuar aceitando
+	if _, err := handler.authService.ValidateToken(login.Payload["token"].(string)); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Errorf("token issued before the demotion: got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestAssignEntryID_ReplacesClientValue(t *testing.T) {
+	event := accountEvent("buy_pack", time.Now(), map[string]any{"entry_id": "forged"})
+	AssignEntryID(&event)
+	if id, _ := event.Payload["entry_id"].(string); id == "" || id == "forged" {
+		t.Errorf("entry_id = %q, want a fresh id", id)
+	}
+	read := accountEvent("get_cards", time.Now(), map[string]any{})
+	AssignEntryID(&read)
+	if _, ok := read.Payload["entry_id"]; ok {
+		t.Error("entry_id assigned to a method that creates nothing")
+	}
+}
@@ -7,14 +7,29 @@ import (
 type EventHandlerInterface interface {
 	OnRegister(event Event) Event
 	OnLogin(event Event) Event
+	OnRefresh(event Event) Event
+	OnLogout(event Event) Event
+	OnChangePassword(event Event) Event
+	OnDeleteAccount(event Event) Event
+	OnGetProfile(event Event) Event
 
 	OnGetCards(event Event) Event
 	OnBuyPack(event Event) Event
 	OnOfferTrade(event Event) Event
 	OnAcceptTrade(event Event) Event
+	OnGetCatalog(event Event) Event
+	OnSyncCatalog(event Event) Event
 
 	OnStartMatch(event Event) Event
 	OnJoinMatch(event Event) Event
 	OnSurrenderMatch(event Event) Event
 	OnMakeMove(event Event) Event
+
+	OnMuteUser(event Event) Event
+	OnBanUser(event Event) Event
+	OnUnbanUser(event Event) Event
+	OnGrantCards(event Event) Event
+	OnCancelMatch(event Event) Event
+	OnSetRoles(event Event) Event
+	OnBootstrapAdmin(event Event) Event
 }
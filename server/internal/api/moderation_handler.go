@@ -0,0 +1,248 @@
+package api
+
+import (
+	"cod-server/internal/auth"
+	"cod-server/internal/domain"
+	"fmt"
+	"math"
+	shared_protocol "shared/protocol"
+	"strings"
+	"time"
+)
+
+// DefaultMuteDuration é usada quando mute_user não informa duration_seconds.
+const DefaultMuteDuration = 10 * time.Minute
+
+// MaxMuteDuration limita duration_seconds; silêncios mais longos são reduzidos a ele.
+const MaxMuteDuration = 30 * 24 * time.Hour
+
+// DefaultGrantCount é usado quando grant_cards não informa count.
+const DefaultGrantCount = 5
+
+// MaxGrantCount limita count em grant_cards: todas as cartas são criadas numa só entrada do log.
+const MaxGrantCount = 50
+
+// authorize verifica se o usuário que age (campo actorField, preenchido pelo Authenticator)
+// tem a permissão. Os papéis são lidos do repositório, não das claims: a decisão é a mesma em
+// todas as réplicas e uma mudança de papel vale imediatamente. Retorna o evento de falha e true
+// quando a ação deve ser recusada.
+func (eh *EventHandler) authorize(event Event, actorField, failMethod string, perm auth.Permission) (Event, bool) {
+	actorID, ok := event.Payload[actorField].(string)
+	if !ok || actorID == "" {
+		return NewErrorEvent(failMethod, "unauthorized", ErrUnauthenticated.Error()), true
+	}
+	actor, err := eh.userService.GetUser(actorID)
+	if err != nil {
+		return NewErrorEvent(failMethod, "unauthorized", "user not found"), true
+	}
+	if err := eh.policy.Authorize(roleNames(actor.GetRoles()), perm); err != nil {
+		return NewErrorEvent(failMethod, "forbidden", err.Error()), true
+	}
+	return Event{}, false
+}
+
+// OnMuteUser silencia um usuário em uma sala por duration_seconds a partir do instante do evento.
+func (eh *EventHandler) OnMuteUser(event Event) Event {
+	roomID, targetID, fail, ok := eh.moderationTarget(event, "mute_user_fail")
+	if !ok {
+		return fail
+	}
+
+	duration := DefaultMuteDuration
+	// Números do payload chegam como float64 após a desserialização JSON do log
+	if seconds, ok := event.Payload["duration_seconds"].(float64); ok && seconds > 0 {
+		// Comparado ainda em float64: a conversão de valores grandes para Duration transbordaria
+		duration = MaxMuteDuration
+		if seconds < MaxMuteDuration.Seconds() {
+			duration = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	until := event.Timestamp.Add(duration)
+
+	if err := eh.moderationService.Mute(roomID, targetID, until); err != nil {
+		return makeErrorEvent("mute_user_fail", err.Error())
+	}
+	return moderationEvent("mute_user_ok", roomID, targetID, map[string]any{"until": until})
+}
+
+// OnBanUser bane um usuário de uma sala até que seja perdoado com unban_user.
+func (eh *EventHandler) OnBanUser(event Event) Event {
+	roomID, targetID, fail, ok := eh.moderationTarget(event, "ban_user_fail")
+	if !ok {
+		return fail
+	}
+
+	if err := eh.moderationService.Ban(roomID, targetID); err != nil {
+		return makeErrorEvent("ban_user_fail", err.Error())
+	}
+	return moderationEvent("ban_user_ok", roomID, targetID, nil)
+}
+
+// OnUnbanUser remove silêncio e banimento de um usuário na sala.
+func (eh *EventHandler) OnUnbanUser(event Event) Event {
+	roomID, targetID, fail, ok := eh.moderationTarget(event, "unban_user_fail")
+	if !ok {
+		return fail
+	}
+
+	if err := eh.moderationService.Pardon(roomID, targetID); err != nil {
+		return makeErrorEvent("unban_user_fail", err.Error())
+	}
+	return moderationEvent("unban_user_ok", roomID, targetID, nil)
+}
+
+// moderationTarget valida permissão e payload comuns às ações de moderação.
+// Moderadores não podem sancionar quem também modera; só administradores podem.
+func (eh *EventHandler) moderationTarget(event Event, failMethod string) (string, string, Event, bool) {
+	if fail, denied := eh.authorize(event, "user_id", failMethod, auth.PermModerateChat); denied {
+		return "", "", fail, false
+	}
+	roomID, ok1 := event.Payload["room_id"].(string)
+	targetID, ok2 := event.Payload["target_user_id"].(string)
+	// A sala compõe o tópico de moderação; '/' ou curingas o desviariam para outro tópico
+	if !ok1 || !ok2 || roomID == "" || targetID == "" || strings.ContainsAny(roomID, "/+#") {
+		return "", "", makeErrorEvent(failMethod, "invalid payload"), false
+	}
+
+	target, err := eh.userService.GetUser(targetID)
+	if err != nil {
+		return "", "", makeErrorEvent(failMethod, "target user not found"), false
+	}
+	if eh.policy.Allows(roleNames(target.GetRoles()), auth.PermModerateChat) {
+		if fail, denied := eh.authorize(event, "user_id", failMethod, auth.PermManageRoles); denied {
+			return "", "", fail, false
+		}
+	}
+	return roomID, targetID, Event{}, true
+}
+
+// OnGrantCards cria cartas para outro usuário sem cobrança.
+func (eh *EventHandler) OnGrantCards(event Event) Event {
+	if fail, denied := eh.authorize(event, "user_id", "grant_cards_fail", auth.PermGrantCards); denied {
+		return fail
+	}
+	targetID, ok := event.Payload["target_user_id"].(string)
+	if !ok || targetID == "" {
+		return makeErrorEvent("grant_cards_fail", "invalid payload")
+	}
+	count := DefaultGrantCount
+	if value, ok := event.Payload["count"].(float64); ok {
+		if value != math.Trunc(value) || value < 1 || value > MaxGrantCount {
+			return makeErrorEvent("grant_cards_fail", fmt.Sprintf("count must be an integer between 1 and %d", MaxGrantCount))
+		}
+		count = int(value)
+	}
+
+	entryID, _ := event.Payload["entry_id"].(string)
+	if err := eh.cardsService.GrantCards(targetID, count, entryID); err != nil {
+		return makeErrorEvent("grant_cards_fail", err.Error())
+	}
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "grant_cards_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"target_user_id": targetID, "count": count},
+		},
+	}
+}
+
+// OnCancelMatch encerra uma partida sem vencedor.
+func (eh *EventHandler) OnCancelMatch(event Event) Event {
+	if fail, denied := eh.authorize(event, "user_id", "cancel_match_fail", auth.PermCancelMatch); denied {
+		return fail
+	}
+	matchID, ok := event.Payload["match_id"].(string)
+	if !ok || matchID == "" {
+		return makeErrorEvent("cancel_match_fail", "invalid payload")
+	}
+
+	if err := eh.matchService.CancelMatch(matchID); err != nil {
+		return makeErrorEvent("cancel_match_fail", err.Error())
+	}
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "cancel_match_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"match_id": matchID},
+		},
+	}
+}
+
+// OnSetRoles substitui os papéis de um usuário e revoga seus tokens: as rotas /admin autorizam
+// pelos papéis das claims, que só voltam a valer, já com os novos papéis, a partir do próximo login.
+func (eh *EventHandler) OnSetRoles(event Event) Event {
+	if fail, denied := eh.authorize(event, "user_id", "set_roles_fail", auth.PermManageRoles); denied {
+		return fail
+	}
+	targetID, ok1 := event.Payload["target_user_id"].(string)
+	rawRoles, ok2 := event.Payload["roles"].([]any)
+	if !ok1 || !ok2 || targetID == "" {
+		return makeErrorEvent("set_roles_fail", "invalid payload")
+	}
+	roles := make([]domain.Role, 0, len(rawRoles))
+	for _, raw := range rawRoles {
+		name, ok := raw.(string)
+		if !ok {
+			return makeErrorEvent("set_roles_fail", "invalid payload")
+		}
+		roles = append(roles, domain.Role(name))
+	}
+
+	if err := eh.userService.SetRoles(targetID, roles); err != nil {
+		return makeErrorEvent("set_roles_fail", err.Error())
+	}
+	eh.authService.RevokeUserTokens(targetID)
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "set_roles_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"target_user_id": targetID, "roles": roleNames(roles)},
+		},
+	}
+}
+
+// OnBootstrapAdmin promove a admin o usuário do campo username. Não passa por authorize: o
+// comando só entra no log por /raft/command, assinado com o segredo do cluster (subcomando
+// bootstrap-admin), e é recusado quando chega de clientes.
+func (eh *EventHandler) OnBootstrapAdmin(event Event) Event {
+	username, ok := event.Payload["username"].(string)
+	if !ok || username == "" {
+		return makeErrorEvent("bootstrap_admin_fail", "invalid payload")
+	}
+
+	user, err := eh.userService.BootstrapAdmin(username)
+	if err != nil {
+		return makeErrorEvent("bootstrap_admin_fail", err.Error())
+	}
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    "bootstrap_admin_ok",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"user_id": user.GetID(), "username": user.GetUsername(), "roles": roleNames(user.GetRoles())},
+		},
+	}
+}
+
+// moderationEvent monta a resposta publicada no tópico de moderação da sala.
+func moderationEvent(method, roomID, targetID string, extra map[string]any) Event {
+	payload := map[string]any{"room_id": roomID, "target_user_id": targetID}
+	for key, value := range extra {
+		payload[key] = value
+	}
+	return Event{
+		Event: shared_protocol.Event{
+			Method:    method,
+			Timestamp: time.Now(),
+			Payload:   payload,
+		},
+	}
+}
+
+// roleNames converte papéis do domínio para os nomes usados pela política e nas claims.
+func roleNames(roles []domain.Role) []string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return names
+}
@@ -2,7 +2,10 @@ package mqtt
 
 import (
 	"cod-server/internal/api"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -10,47 +13,173 @@ import (
 	"github.com/google/uuid"
 )
 
+// ConnectionState representa o estado da conexão do adaptador com o broker.
+type ConnectionState string
+
+const (
+	StateDisconnected ConnectionState = "disconnected"
+	StateConnecting   ConnectionState = "connecting"
+	StateConnected    ConnectionState = "connected"
+	StateReconnecting ConnectionState = "reconnecting"
+)
+
+// TopicOptions define QoS e flag de retenção usados ao publicar ou assinar um tópico.
+type TopicOptions struct {
+	QoS      byte
+	Retained bool
+}
+
+// Config agrupa as opções de conexão do adaptador MQTT.
+type Config struct {
+	Broker   string
+	ClientID string
+	// Username e Password autenticam o servidor no broker, cujo ACL reserva aos nós a leitura
+	// de requests/# e a escrita em replies/# e chat/#.
+	Username string
+	Password string
+	// CleanSession falso mantém a sessão no broker (assinaturas e mensagens QoS>0 pendentes)
+	// entre reconexões. Exige um ClientID estável.
+	CleanSession bool
+	// AutoReconnect reconecta com backoff exponencial até MaxReconnectInterval.
+	AutoReconnect        bool
+	ConnectRetryInterval time.Duration
+	MaxReconnectInterval time.Duration
+	ConnectTimeout       time.Duration
+	KeepAlive            time.Duration
+	// DefaultTopicOptions é usado quando nenhum filtro de Topics casa com o tópico.
+	DefaultTopicOptions TopicOptions
+	// Topics mapeia filtros MQTT (com + e #) para opções específicas.
+	Topics map[string]TopicOptions
+}
+
+// DefaultConfig retorna uma configuração resiliente: sessão persistente, reconexão automática e QoS 1.
+func DefaultConfig(broker, clientID string) Config {
+	return Config{
+		Broker:               broker,
+		ClientID:             clientID,
+		CleanSession:         false,
+		AutoReconnect:        true,
+		ConnectRetryInterval: 2 * time.Second,
+		MaxReconnectInterval: 1 * time.Minute,
+		ConnectTimeout:       10 * time.Second,
+		KeepAlive:            30 * time.Second,
+		DefaultTopicOptions:  TopicOptions{QoS: 1, Retained: false},
+		Topics:               make(map[string]TopicOptions),
+	}
+}
+
 type MQTTAdapterInterface interface {
 	Connect() error
 	Publish(topic string, event api.Event) error
+	// Subscribe registra o handler; ele é reassinado automaticamente a cada reconexão.
 	Subscribe(topic string, handler mqtt.MessageHandler) error
 	Disconnect()
+
+	// SetTopicOptions define QoS e retenção para os tópicos que casam com o filtro.
+	SetTopicOptions(filter string, options TopicOptions)
+	// State retorna o estado atual da conexão.
+	State() ConnectionState
+	// IsConnected informa se a conexão com o broker está ativa.
+	IsConnected() bool
+	// OnStateChange registra um callback chamado a cada mudança de estado da conexão.
+	OnStateChange(callback func(state ConnectionState))
 }
 
 type MQTTAdapter struct {
 	client mqtt.Client
 	logger *log.Logger
+
+	mu             sync.RWMutex
+	state          ConnectionState
+	subscriptions  map[string]mqtt.MessageHandler
+	topicOptions   map[string]TopicOptions
+	defaultOptions TopicOptions
+	stateCallbacks []func(state ConnectionState)
+	connectTimeout time.Duration
+	closed         bool
 }
 
-// NewMQTTAdapter cria uma nova instância do adaptador MQTT
+// NewMQTTAdapter cria uma nova instância do adaptador MQTT com a configuração padrão
 func NewMQTTAdapter(broker, clientID string) (MQTTAdapterInterface, error) {
-	if clientID == "" {
-		clientID = uuid.New().String()
+	return NewMQTTAdapterWithConfig(DefaultConfig(broker, clientID))
+}
+
+// NewMQTTAdapterWithConfig cria o adaptador MQTT a partir de uma configuração explícita
+func NewMQTTAdapterWithConfig(config Config) (MQTTAdapterInterface, error) {
+	if config.Broker == "" {
+		return nil, errors.New("mqtt broker address is required")
+	}
+	if config.ClientID == "" {
+		if !config.CleanSession {
+			return nil, errors.New("persistent sessions require a stable client id")
+		}
+		config.ClientID = uuid.New().String()
 	}
 
 	logger := log.With("component", "mqtt")
 
+	adapter := &MQTTAdapter{
+		logger:         logger,
+		state:          StateDisconnected,
+		subscriptions:  make(map[string]mqtt.MessageHandler),
+		topicOptions:   make(map[string]TopicOptions),
+		defaultOptions: config.DefaultTopicOptions,
+		connectTimeout: config.ConnectTimeout,
+	}
+	for filter, options := range config.Topics {
+		adapter.topicOptions[filter] = options
+	}
+
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(broker)
-	opts.SetClientID(clientID)
+	opts.AddBroker(config.Broker)
+	opts.SetClientID(config.ClientID)
+	if config.Username != "" {
+		opts.SetUsername(config.Username)
+		opts.SetPassword(config.Password)
+	}
+	opts.SetCleanSession(config.CleanSession)
+	opts.SetAutoReconnect(config.AutoReconnect)
+	opts.SetConnectRetry(config.AutoReconnect)
+	opts.SetConnectRetryInterval(config.ConnectRetryInterval)
+	opts.SetMaxReconnectInterval(config.MaxReconnectInterval)
+	opts.SetConnectTimeout(config.ConnectTimeout)
+	opts.SetKeepAlive(config.KeepAlive)
 	opts.SetDefaultPublishHandler(func(client mqtt.Client, msg mqtt.Message) {
 		logger.Infof("Received message on topic %s: %s\n", msg.Topic(), msg.Payload())
 	})
 
-	opts.OnConnect = func(client mqtt.Client) {
-		logger.Info("Connected to broker")
-	}
-	opts.OnConnectionLost = func(client mqtt.Client, err error) {
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		adapter.onConnect()
+	})
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
 		logger.Warnf("Connection lost: %v", err)
-	}
+		adapter.setState(StateDisconnected)
+	})
+	opts.SetReconnectingHandler(func(client mqtt.Client, options *mqtt.ClientOptions) {
+		logger.Info("Reconnecting to broker...")
+		adapter.setState(StateReconnecting)
+	})
 
-	client := mqtt.NewClient(opts)
-	return &MQTTAdapter{client: client, logger: logger}, nil
+	adapter.client = mqtt.NewClient(opts)
+	return adapter, nil
 }
 
-// Connect estabelece a conexão com o broker MQTT
+// Connect estabelece a conexão com o broker MQTT.
+// Com reconexão automática habilitada, tentativas falhas são repetidas em segundo plano
+// e Connect retorna assim que a primeira tentativa terminar.
 func (a *MQTTAdapter) Connect() error {
-	if token := a.client.Connect(); token.Wait() && token.Error() != nil {
+	a.mu.Lock()
+	a.closed = false
+	a.mu.Unlock()
+	a.setState(StateConnecting)
+
+	token := a.client.Connect()
+	if !token.WaitTimeout(a.connectTimeout) && !a.client.IsConnected() {
+		a.logger.Warn("Broker unreachable; retrying in background")
+		return nil
+	}
+	if token.Error() != nil {
+		a.setState(StateDisconnected)
 		return fmt.Errorf("mqtt connection error: %w", token.Error())
 	}
 	return nil
@@ -63,7 +192,8 @@ func (a *MQTTAdapter) Publish(topic string, event api.Event) error {
 		return fmt.Errorf("failed to serialize event to json: %w", err)
 	}
 
-	token := a.client.Publish(topic, 1, false, payload)
+	options := a.optionsFor(topic)
+	token := a.client.Publish(topic, options.QoS, options.Retained, payload)
 
 	// Aguardar o token de forma não bloqueante com timeout ou usar uma abordagem assíncrona mais controlada
 	// A chamada original esperava dentro de uma goroutine, mas vamos manter isso para não bloquear
@@ -92,17 +222,147 @@ func (a *MQTTAdapter) Publish(topic string, event api.Event) error {
 	return nil
 }
 
-// Subscribe se inscreve em um tópico MQTT
+// Subscribe se inscreve em um tópico MQTT e registra o handler para reassinatura
 func (a *MQTTAdapter) Subscribe(topic string, handler mqtt.MessageHandler) error {
-	if token := a.client.Subscribe(topic, 1, handler); token.Wait() && token.Error() != nil {
+	a.mu.Lock()
+	a.subscriptions[topic] = handler
+	a.mu.Unlock()
+
+	// Sem conexão ativa a assinatura fica registrada e é feita no próximo OnConnect
+	if !a.client.IsConnectionOpen() {
+		a.logger.Infof("Subscription to %s deferred until connected", topic)
+		return nil
+	}
+	return a.subscribe(topic, handler)
+}
+
+// subscribe efetua a assinatura no broker usando o QoS configurado para o tópico
+func (a *MQTTAdapter) subscribe(topic string, handler mqtt.MessageHandler) error {
+	options := a.optionsFor(topic)
+	if token := a.client.Subscribe(topic, options.QoS, handler); token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, token.Error())
 	}
 	a.logger.Infof("Subscribed to topic: %s", topic)
 	return nil
 }
 
+// onConnect marca a conexão como ativa e refaz as assinaturas; o broker pode ter perdido
+// a sessão (CleanSession ou expiração) enquanto estávamos desconectados.
+func (a *MQTTAdapter) onConnect() {
+	a.logger.Info("Connected to broker")
+	a.setState(StateConnected)
+	a.resubscribe()
+}
+
+// resubscribe refaz todas as assinaturas registradas após uma (re)conexão
+func (a *MQTTAdapter) resubscribe() {
+	a.mu.RLock()
+	subscriptions := make(map[string]mqtt.MessageHandler, len(a.subscriptions))
+	for topic, handler := range a.subscriptions {
+		subscriptions[topic] = handler
+	}
+	a.mu.RUnlock()
+
+	for topic, handler := range subscriptions {
+		if err := a.subscribe(topic, handler); err != nil {
+			a.logger.Errorf("Resubscribe failed: %v", err)
+		}
+	}
+}
+
 // Disconnect encerra a conexão com o broker MQTT
 func (a *MQTTAdapter) Disconnect() {
 	a.logger.Info("Disconnecting...")
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
 	a.client.Disconnect(250)
+	a.setState(StateDisconnected)
+}
+
+// SetTopicOptions define QoS e retenção para tópicos que casam com o filtro
+func (a *MQTTAdapter) SetTopicOptions(filter string, options TopicOptions) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.topicOptions[filter] = options
+}
+
+// State retorna o estado atual da conexão
+func (a *MQTTAdapter) State() ConnectionState {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.state
+}
+
+// IsConnected informa se a conexão com o broker está ativa
+func (a *MQTTAdapter) IsConnected() bool {
+	return a.client.IsConnectionOpen()
+}
+
+// OnStateChange registra um callback para mudanças de estado da conexão
+func (a *MQTTAdapter) OnStateChange(callback func(state ConnectionState)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stateCallbacks = append(a.stateCallbacks, callback)
+}
+
+// setState atualiza o estado e notifica os callbacks registrados quando há mudança
+func (a *MQTTAdapter) setState(state ConnectionState) {
+	a.mu.Lock()
+	if a.state == state {
+		a.mu.Unlock()
+		return
+	}
+	// Após Disconnect explícito, ignoramos eventos tardios de reconexão
+	if a.closed && state != StateDisconnected {
+		a.mu.Unlock()
+		return
+	}
+	a.state = state
+	callbacks := append([]func(state ConnectionState){}, a.stateCallbacks...)
+	a.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(state)
+	}
+}
+
+// optionsFor resolve as opções do tópico; o filtro mais específico (mais longo) vence
+func (a *MQTTAdapter) optionsFor(topic string) TopicOptions {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if options, ok := a.topicOptions[topic]; ok {
+		return options
+	}
+	best := ""
+	options := a.defaultOptions
+	for filter, opts := range a.topicOptions {
+		if topicMatches(filter, topic) && len(filter) > len(best) {
+			best = filter
+			options = opts
+		}
+	}
+	return options
+}
+
+// topicMatches verifica se o tópico casa com o filtro MQTT (suporta + e #)
+func topicMatches(filter, topic string) bool {
+	if filter == topic {
+		return true
+	}
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
 }
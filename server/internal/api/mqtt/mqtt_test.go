@@ -0,0 +1,143 @@
+package mqtt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeToken é um token já concluído.
+type fakeToken struct{ err error }
+
+func (t fakeToken) Wait() bool                     { return true }
+func (t fakeToken) WaitTimeout(time.Duration) bool { return true }
+func (t fakeToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (t fakeToken) Error() error { return t.err }
+
+// fakeClient registra as assinaturas feitas no broker. Os métodos não usados pelo
+// adaptador ficam com o mqtt.Client nulo embutido.
+type fakeClient struct {
+	mqtt.Client
+	mu         sync.Mutex
+	open       bool
+	subscribed map[string]byte
+}
+
+func (c *fakeClient) IsConnectionOpen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.open
+}
+
+func (c *fakeClient) Subscribe(topic string, qos byte, handler mqtt.MessageHandler) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed[topic] = qos
+	return fakeToken{}
+}
+
+func newTestAdapter(config Config) (*MQTTAdapter, *fakeClient) {
+	adapter, err := NewMQTTAdapterWithConfig(config)
+	if err != nil {
+		panic(err)
+	}
+	client := &fakeClient{subscribed: make(map[string]byte)}
+	a := adapter.(*MQTTAdapter)
+	a.client = client
+	return a, client
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"chat/room/1", "chat/room/1", true},
+		{"chat/room/+", "chat/room/1", true},
+		{"chat/room/+", "chat/room/1/extra", false},
+		{"chat/#", "chat/room/1", true},
+		{"responses/+/login", "responses/abc/login", true},
+		{"responses/+/login", "responses/abc/logout", false},
+		{"a/b/c", "a/b", false},
+	}
+	for _, tt := range tests {
+		if got := topicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsFor_MostSpecificFilterWins(t *testing.T) {
+	config := DefaultConfig("tcp://broker:1883", "node-1")
+	config.Topics["chat/#"] = TopicOptions{QoS: 0}
+	config.Topics["chat/room/+"] = TopicOptions{QoS: 2, Retained: true}
+	adapter, _ := newTestAdapter(config)
+
+	tests := map[string]TopicOptions{
+		"chat/room/1":      {QoS: 2, Retained: true},
+		"chat/global":      {QoS: 0},
+		"responses/client": config.DefaultTopicOptions,
+	}
+	for topic, want := range tests {
+		if got := adapter.optionsFor(topic); got != want {
+			t.Errorf("optionsFor(%q) = %+v, want %+v", topic, got, want)
+		}
+	}
+
+	adapter.SetTopicOptions("chat/global", TopicOptions{QoS: 1})
+	if got := adapter.optionsFor("chat/global"); got.QoS != 1 {
+		t.Errorf("exact filter set later: got QoS %d, want 1", got.QoS)
+	}
+}
+
+func TestSubscribe_DeferredAndResubscribedOnReconnect(t *testing.T) {
+	config := DefaultConfig("tcp://broker:1883", "node-1")
+	config.Topics["store/#"] = TopicOptions{QoS: 2}
+	adapter, client := newTestAdapter(config)
+	handler := func(mqtt.Client, mqtt.Message) {}
+
+	// Sem conexão a assinatura só é registrada
+	if err := adapter.Subscribe("store/buy_pack", handler); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if len(client.subscribed) != 0 {
+		t.Fatalf("subscribed while disconnected: %v", client.subscribed)
+	}
+
+	var states []ConnectionState
+	adapter.OnStateChange(func(state ConnectionState) { states = append(states, state) })
+
+	client.open = true
+	adapter.onConnect()
+	if qos, ok := client.subscribed["store/buy_pack"]; !ok || qos != 2 {
+		t.Fatalf("after connect: got %v, want store/buy_pack with QoS 2", client.subscribed)
+	}
+
+	// Um broker que perdeu a sessão recebe as assinaturas de novo na reconexão
+	delete(client.subscribed, "store/buy_pack")
+	adapter.setState(StateReconnecting)
+	adapter.onConnect()
+	if _, ok := client.subscribed["store/buy_pack"]; !ok {
+		t.Fatalf("after reconnect: got %v, want store/buy_pack resubscribed", client.subscribed)
+	}
+	if want := []ConnectionState{StateConnected, StateReconnecting, StateConnected}; len(states) != len(want) {
+		t.Errorf("state changes: got %v, want %v", states, want)
+	}
+}
+
+func TestNewMQTTAdapterWithConfig_PersistentSessionNeedsClientID(t *testing.T) {
+	if _, err := NewMQTTAdapterWithConfig(DefaultConfig("tcp://broker:1883", "")); err == nil {
+		t.Error("expected an error for a persistent session without client id")
+	}
+	config := DefaultConfig("tcp://broker:1883", "")
+	config.CleanSession = true
+	if _, err := NewMQTTAdapterWithConfig(config); err != nil {
+		t.Errorf("clean session without client id: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"cod-server/internal/auth"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit descreve um token bucket: Rate fichas por segundo com rajada máxima Burst.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// DefaultRateLimits retorna limites por método para as operações mais sensíveis a spam.
+func DefaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		"buy_pack": {Rate: 0.5, Burst: 3},
+		"chat":     {Rate: 2, Burst: 10},
+		"register": {Rate: 0.2, Burst: 3},
+		"login":    {Rate: 0.5, Burst: 5},
+	}
+}
+
+// bucketIdleTTL define após quanto tempo sem uso um bucket cheio pode ser descartado.
+const bucketIdleTTL = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter aplica token buckets por (identidade, método).
+// A identidade é o usuário do JWT validado quando presente; caso contrário, o client_id do
+// tópico em que o pedido chegou, que o ACL do broker amarra à conexão. Eventos sem nenhuma
+// das duas são recusados, em vez de dividirem um bucket "anônimo" que qualquer um esgotaria.
+type RateLimiter struct {
+	mu           sync.Mutex
+	authService  *auth.AuthService
+	defaultLimit RateLimit
+	limits       map[string]RateLimit
+	buckets      map[string]*tokenBucket
+	allowed      map[string]uint64
+	throttled    map[string]uint64
+	lastSweep    time.Time
+	now          func() time.Time
+}
+
+// NewRateLimiter cria o limitador com um limite padrão e sobrescritas por método.
+func NewRateLimiter(authService *auth.AuthService, defaultLimit RateLimit, limits map[string]RateLimit) *RateLimiter {
+	copied := make(map[string]RateLimit, len(limits))
+	for method, limit := range limits {
+		copied[method] = limit
+	}
+	return &RateLimiter{
+		authService:  authService,
+		defaultLimit: defaultLimit,
+		limits:       copied,
+		buckets:      make(map[string]*tokenBucket),
+		allowed:      make(map[string]uint64),
+		throttled:    make(map[string]uint64),
+		lastSweep:    time.Now(),
+		now:          time.Now,
+	}
+}
+
+// Allow consome uma ficha do bucket do evento. Quando negado, retorna também
+// quanto tempo o cliente deve esperar até a próxima ficha.
+func (rl *RateLimiter) Allow(event Event) (bool, time.Duration) {
+	limit := rl.limitFor(event.Method)
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+	identity := rl.identity(event)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if identity == "" {
+		rl.throttled[event.Method]++
+		return false, 0
+	}
+	key := identity + "|" + event.Method
+
+	now := rl.now()
+	rl.sweep(now)
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), lastSeen: now}
+		rl.buckets[key] = bucket
+	}
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+elapsed*limit.Rate)
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		rl.allowed[event.Method]++
+		return true, 0
+	}
+
+	rl.throttled[event.Method]++
+	wait := time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Stats retorna os contadores de eventos permitidos e limitados por método.
+func (rl *RateLimiter) Stats() map[string]any {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	allowed := make(map[string]uint64, len(rl.allowed))
+	for method, count := range rl.allowed {
+		allowed[method] = count
+	}
+	throttled := make(map[string]uint64, len(rl.throttled))
+	for method, count := range rl.throttled {
+		throttled[method] = count
+	}
+	return map[string]any{
+		"allowed":        allowed,
+		"throttled":      throttled,
+		"active_buckets": len(rl.buckets),
+	}
+}
+
+func (rl *RateLimiter) limitFor(method string) RateLimit {
+	if limit, ok := rl.limits[method]; ok {
+		return limit
+	}
+	return rl.defaultLimit
+}
+
+// identity deriva a chave do limitador: usuário autenticado ou client_id; "" se não houver.
+// O client_id do payload é sempre o do tópico, gravado na borda ao receber a mensagem.
+func (rl *RateLimiter) identity(event Event) string {
+	if token, ok := event.Payload["token"].(string); ok && token != "" && rl.authService != nil {
+		if claims, err := rl.authService.ValidateToken(token); err == nil {
+			return "user:" + claims.UserID
+		}
+	}
+	if clientID, ok := event.Payload["client_id"].(string); ok && clientID != "" {
+		return "client:" + clientID
+	}
+	return ""
+}
+
+// sweep descarta buckets ociosos para manter o mapa limitado; chamado com mu travado.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < time.Minute {
+		return
+	}
+	rl.lastSweep = now
+	for key, bucket := range rl.buckets {
+		if now.Sub(bucket.lastSeen) > bucketIdleTTL {
+			delete(rl.buckets, key)
+		}
+	}
+}
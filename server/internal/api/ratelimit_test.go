@@ -0,0 +1,127 @@
+package api
+
+import (
+	"cod-server/internal/auth"
+	shared_protocol "shared/protocol"
+	"testing"
+	"time"
+)
+
+// fakeClock é um relógio controlado pelo teste.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(t *testing.T, limits map[string]RateLimit) (*RateLimiter, *auth.AuthService, *fakeClock) {
+	t.Helper()
+	key, err := auth.NewHMACKey(auth.DefaultKeyID, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewKeySet(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authService := auth.NewAuthServiceWithKeys(keys)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	limiter := NewRateLimiter(authService, RateLimit{Rate: 100, Burst: 100}, limits)
+	limiter.now = clock.Now
+	return limiter, authService, clock
+}
+
+func limitedEvent(method string, payload map[string]any) Event {
+	return Event{Event: shared_protocol.Event{Method: method, Payload: payload}}
+}
+
+func TestRateLimiter_ExhaustsBurstThenRefills(t *testing.T) {
+	limiter, _, clock := newTestLimiter(t, map[string]RateLimit{"buy_pack": {Rate: 0.5, Burst: 3}})
+	event := limitedEvent("buy_pack", map[string]any{"client_id": "c1"})
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow(event); !ok {
+			t.Fatalf("request %d within burst was throttled", i)
+		}
+	}
+	ok, wait := limiter.Allow(event)
+	if ok {
+		t.Fatal("request over the burst was allowed")
+	}
+	if wait != 2*time.Second {
+		t.Errorf("retry after = %v, want 2s at 0.5 tokens/s", wait)
+	}
+
+	clock.Advance(time.Second)
+	if ok, _ := limiter.Allow(event); ok {
+		t.Error("allowed after half a token refilled")
+	}
+	clock.Advance(time.Second)
+	if ok, _ := limiter.Allow(event); !ok {
+		t.Error("throttled after a full token refilled")
+	}
+
+	// A recarga não passa da rajada
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		limiter.Allow(event)
+	}
+	if ok, _ := limiter.Allow(event); ok {
+		t.Error("bucket refilled beyond its burst")
+	}
+
+	stats := limiter.Stats()
+	if allowed := stats["allowed"].(map[string]uint64)["buy_pack"]; allowed != 7 {
+		t.Errorf("allowed = %d, want 7", allowed)
+	}
+	if throttled := stats["throttled"].(map[string]uint64)["buy_pack"]; throttled != 3 {
+		t.Errorf("throttled = %d, want 3", throttled)
+	}
+}
+
+func TestRateLimiter_BucketsPerIdentityAndMethod(t *testing.T) {
+	limiter, authService, _ := newTestLimiter(t, map[string]RateLimit{"login": {Rate: 1, Burst: 1}, "chat": {Rate: 1, Burst: 1}})
+	token, err := authService.GenerateToken("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allow := func(method string, payload map[string]any) bool {
+		ok, _ := limiter.Allow(limitedEvent(method, payload))
+		return ok
+	}
+	if !allow("login", map[string]any{"client_id": "c1"}) {
+		t.Fatal("first login from c1 throttled")
+	}
+	if allow("login", map[string]any{"client_id": "c1"}) {
+		t.Error("second login from c1 allowed")
+	}
+	if !allow("login", map[string]any{"client_id": "c2"}) {
+		t.Error("c2 shares the bucket of c1")
+	}
+	if !allow("chat", map[string]any{"client_id": "c1"}) {
+		t.Error("chat shares the bucket of login")
+	}
+
+	// Com token válido o bucket é do usuário, em qualquer conexão
+	if !allow("chat", map[string]any{"client_id": "c3", "token": token}) {
+		t.Fatal("first chat from alice throttled")
+	}
+	if allow("chat", map[string]any{"client_id": "c4", "token": token}) {
+		t.Error("alice got a fresh bucket by switching connections")
+	}
+	// Um token inválido não derruba para um bucket compartilhado
+	if !allow("chat", map[string]any{"client_id": "c5", "token": "garbage"}) {
+		t.Error("invalid token did not fall back to the client bucket")
+	}
+}
+
+func TestRateLimiter_RejectsEventsWithoutIdentity(t *testing.T) {
+	limiter, _, _ := newTestLimiter(t, map[string]RateLimit{"register": {Rate: 1, Burst: 5}, "get_catalog": {Rate: 0}})
+
+	if ok, _ := limiter.Allow(limitedEvent("register", map[string]any{})); ok {
+		t.Error("event without client id or token was allowed")
+	}
+	if ok, _ := limiter.Allow(limitedEvent("get_catalog", nil)); !ok {
+		t.Error("unlimited method was throttled")
+	}
+}
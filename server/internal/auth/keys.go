@@ -0,0 +1,258 @@
+package auth
+
+// Chaves de assinatura JWT identificadas por kid. O KeySet tem uma chave ativa, usada para
+// assinar, e chaves anteriores mantidas apenas para validar tokens emitidos antes de uma rotação.
+// Todos os nós do cluster precisam carregar o mesmo conjunto de chaves.
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultKeyID é o kid da chave HMAC criada por NewAuthService.
+const DefaultKeyID = "default"
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrUnexpectedMethod = errors.New("unexpected signing method")
+	// ErrMissingSecret indica HS256 sem segredo configurado fora do modo de desenvolvimento.
+	ErrMissingSecret = errors.New("hmac secret is required")
+)
+
+// SigningKey associa um kid a um algoritmo e ao material de chave correspondente.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   any // nil em chaves carregadas só com a parte pública
+	verifyKey any
+}
+
+// NewHMACKey cria uma chave simétrica HS256.
+func NewHMACKey(kid string, secret []byte) (*SigningKey, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("hmac secret must have at least 32 bytes")
+	}
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// CanSign informa se a chave possui a parte privada.
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// LoadKeyFile carrega uma chave de arquivo. Para HS256 o arquivo contém o segredo bruto;
+// para RS256 e EdDSA, uma chave PEM privada (PKCS#1/PKCS#8) ou pública (PKIX, só validação).
+func LoadKeyFile(kid, alg, path string) (*SigningKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		return NewHMACKey(kid, []byte(strings.TrimSpace(string(raw))))
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		return parsePEMKey(kid, alg, raw)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
+
+func parsePEMKey(kid, alg string, raw []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", block.Type, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if alg != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", alg)
+		}
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, signKey: key, verifyKey: &key.PublicKey}, nil
+	case *rsa.PublicKey:
+		if alg != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", alg)
+		}
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, verifyKey: key}, nil
+	case ed25519.PrivateKey:
+		if alg != jwt.SigningMethodEdDSA.Alg() {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", alg)
+		}
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, signKey: key, verifyKey: key.Public()}, nil
+	case ed25519.PublicKey:
+		if alg != jwt.SigningMethodEdDSA.Alg() {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", alg)
+		}
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, verifyKey: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// KeySet guarda as chaves conhecidas por kid e qual delas assina novos tokens.
+type KeySet struct {
+	mu     sync.RWMutex
+	keys   map[string]*SigningKey
+	active string
+}
+
+// NewKeySet cria um conjunto com a chave ativa e, opcionalmente, chaves anteriores.
+func NewKeySet(active *SigningKey, previous ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, key := range previous {
+		ks.keys[key.ID] = key
+	}
+	if err := ks.Rotate(active); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Rotate torna a chave fornecida a ativa. A chave anterior continua válida para
+// verificação até ser removida com Retire, então tokens emitidos antes seguem aceitos.
+func (ks *KeySet) Rotate(key *SigningKey) error {
+	if key == nil || key.ID == "" {
+		return errors.New("signing key requires a kid")
+	}
+	if !key.CanSign() {
+		return fmt.Errorf("key %s has no private part and cannot sign", key.ID)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+	ks.active = key.ID
+	return nil
+}
+
+// Retire remove uma chave antiga; tokens assinados por ela passam a ser rejeitados.
+func (ks *KeySet) Retire(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if kid == ks.active {
+		return errors.New("cannot retire the active key")
+	}
+	delete(ks.keys, kid)
+	return nil
+}
+
+// Active retorna a chave usada para assinar.
+func (ks *KeySet) Active() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[ks.active]
+}
+
+// Lookup retorna a chave pelo kid.
+func (ks *KeySet) Lookup(kid string) (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// Algorithms lista os algoritmos aceitos, um por tipo de chave conhecida.
+func (ks *KeySet) Algorithms() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	seen := make(map[string]bool)
+	var algs []string
+	for _, key := range ks.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// KeyConfig descreve de onde carregar as chaves de assinatura.
+type KeyConfig struct {
+	Algorithm string // HS256, RS256 ou EdDSA
+	KeyID     string
+	Secret    string // Segredo HS256 direto; ignorado se KeyFile for informado
+	KeyFile   string
+	// PreviousKeys lista chaves aposentadas no formato "kid:alg:caminho".
+	PreviousKeys []string
+	// DevMode permite HS256 sem segredo: uma chave aleatória é gerada a cada início, então os
+	// tokens não valem em outros nós nem após reiniciar. Sem DevMode, falta de segredo é erro.
+	DevMode bool
+}
+
+// LoadKeySet monta o KeySet a partir da configuração.
+func LoadKeySet(config KeyConfig) (*KeySet, error) {
+	if config.Algorithm == "" {
+		config.Algorithm = jwt.SigningMethodHS256.Alg()
+	}
+	if config.KeyID == "" {
+		config.KeyID = DefaultKeyID
+	}
+
+	var active *SigningKey
+	var err error
+	switch {
+	case config.KeyFile != "":
+		active, err = LoadKeyFile(config.KeyID, config.Algorithm, config.KeyFile)
+	case config.Algorithm == jwt.SigningMethodHS256.Alg():
+		secret := []byte(config.Secret)
+		if len(secret) == 0 {
+			if !config.DevMode {
+				return nil, ErrMissingSecret
+			}
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, fmt.Errorf("failed to generate development secret: %w", err)
+			}
+		}
+		active, err = NewHMACKey(config.KeyID, secret)
+	default:
+		err = fmt.Errorf("%s requires a key file", config.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []*SigningKey
+	for _, spec := range config.PreviousKeys {
+		parts := strings.SplitN(spec, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid previous key %q, expected kid:alg:path", spec)
+		}
+		key, err := LoadKeyFile(parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+
+	return NewKeySet(active, previous...)
+}
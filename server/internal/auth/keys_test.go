@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func pemBlock(t *testing.T, blockType string, der []byte) []byte {
+	t.Helper()
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestParsePEMKey(t *testing.T) {
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPKCS8, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPKIX, err := x509.MarshalPKIXPublicKey(edPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPKIX, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		alg     string
+		raw     []byte
+		canSign bool
+		wantErr bool
+	}{
+		{"ed25519 PKCS#8 private", "EdDSA", pemBlock(t, "PRIVATE KEY", edPKCS8), true, false},
+		{"ed25519 PKIX public", "EdDSA", pemBlock(t, "PUBLIC KEY", edPKIX), false, false},
+		{"rsa PKCS#1 private", "RS256", pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), true, false},
+		{"rsa PKIX public", "RS256", pemBlock(t, "PUBLIC KEY", rsaPKIX), false, false},
+		{"ed25519 key declared as RS256", "RS256", pemBlock(t, "PRIVATE KEY", edPKCS8), false, true},
+		{"rsa key declared as EdDSA", "EdDSA", pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), false, true},
+		{"unsupported block", "EdDSA", pemBlock(t, "EC PRIVATE KEY", []byte{1, 2, 3}), false, true},
+		{"corrupt key bytes", "EdDSA", pemBlock(t, "PRIVATE KEY", []byte{1, 2, 3}), false, true},
+		{"not PEM", "EdDSA", []byte("not a key"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parsePEMKey("k1", tt.alg, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePEMKey error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if key.ID != "k1" || key.Method.Alg() != tt.alg {
+				t.Errorf("key = %s/%s, want k1/%s", key.ID, key.Method.Alg(), tt.alg)
+			}
+			if key.CanSign() != tt.canSign {
+				t.Errorf("CanSign = %v, want %v", key.CanSign(), tt.canSign)
+			}
+		})
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "old-secret")
+	if err := os.WriteFile(secretFile, []byte("fedcba9876543210fedcba9876543210\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  KeyConfig
+		wantErr error
+	}{
+		{"secret", KeyConfig{Secret: testSecret}, nil},
+		{"missing secret", KeyConfig{}, ErrMissingSecret},
+		{"short secret", KeyConfig{Secret: "short"}, errors.New("any")},
+		{"RS256 without key file", KeyConfig{Algorithm: "RS256"}, errors.New("any")},
+		{"previous key from file", KeyConfig{Secret: testSecret, PreviousKeys: []string{"old:HS256:" + secretFile}}, nil},
+		{"malformed previous key", KeyConfig{Secret: testSecret, PreviousKeys: []string{"old:" + secretFile}}, errors.New("any")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeySet(tt.config)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("LoadKeySet: %v", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatal("LoadKeySet: expected an error")
+			case tt.wantErr == ErrMissingSecret && !errors.Is(err, ErrMissingSecret):
+				t.Fatalf("LoadKeySet error = %v, want ErrMissingSecret", err)
+			}
+		})
+	}
+}
+
+func TestLoadKeySet_DevModeUsesEphemeralKey(t *testing.T) {
+	first, err := LoadKeySet(KeyConfig{DevMode: true})
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	second, err := LoadKeySet(KeyConfig{DevMode: true})
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+
+	token, err := NewAuthServiceWithKeys(first).GenerateToken("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAuthServiceWithKeys(second).ValidateToken(token); err == nil {
+		t.Error("two development key sets share a secret")
+	}
+}
+
+func TestNewAuthService_RequiresStrongSecret(t *testing.T) {
+	for _, secret := range []string{"", "too-short"} {
+		if _, err := NewAuthService(secret); err == nil {
+			t.Errorf("NewAuthService(%q): expected an error", secret)
+		}
+	}
+	if _, err := NewAuthService(testSecret); err != nil {
+		t.Errorf("NewAuthService: %v", err)
+	}
+}
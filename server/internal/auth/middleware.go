@@ -37,7 +37,22 @@ func AuthMiddleware(authService *AuthService) gin.HandlerFunc {
 		// Armazenar as claims no contexto para uso posterior
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("roles", claims.Roles)
 
 		c.Next()
 	}
 }
+
+// RequirePermission deve vir depois de AuthMiddleware; recusa com 403 quando os papéis
+// das claims não concedem a permissão.
+func RequirePermission(policy *Policy, perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles := c.GetStringSlice("roles")
+		if err := policy.Authorize(roles, perm); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package auth
+
+// Política de autorização baseada em papéis. Os papéis vêm do usuário (gravados no repositório)
+// e são copiados para as claims no login; cada papel concede um conjunto de permissões.
+
+import (
+	"errors"
+	"sync"
+)
+
+// Permission nomeia uma ação protegida por papel.
+type Permission string
+
+const (
+	PermPlay          Permission = "play"           // Cartas, partidas e trocas
+	PermModerateChat  Permission = "chat.moderate"  // Silenciar e banir em salas de chat
+	PermGrantCards    Permission = "cards.grant"    // Conceder cartas a qualquer usuário
+	PermCancelMatch   Permission = "match.cancel"   // Encerrar partidas sem vencedor
+	PermManageRoles   Permission = "users.roles"    // Alterar papéis de usuários
+	PermManageCluster Permission = "cluster.manage" // Rotas administrativas do cluster
+)
+
+// ErrForbidden indica que nenhum dos papéis do usuário concede a permissão exigida.
+var ErrForbidden = errors.New("insufficient permissions")
+
+// Policy mapeia papéis para permissões.
+type Policy struct {
+	mu     sync.RWMutex
+	grants map[string]map[Permission]bool
+}
+
+// NewPolicy cria uma política vazia; use Grant para configurá-la.
+func NewPolicy() *Policy {
+	return &Policy{grants: make(map[string]map[Permission]bool)}
+}
+
+// DefaultPolicy retorna a política padrão: jogadores jogam, moderadores também moderam o chat
+// e administradores têm todas as permissões.
+func DefaultPolicy() *Policy {
+	p := NewPolicy()
+	p.Grant("player", PermPlay)
+	p.Grant("moderator", PermPlay, PermModerateChat)
+	p.Grant("admin", PermPlay, PermModerateChat, PermGrantCards, PermCancelMatch, PermManageRoles, PermManageCluster)
+	return p
+}
+
+// Grant concede permissões a um papel.
+func (p *Policy) Grant(role string, perms ...Permission) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.grants[role] == nil {
+		p.grants[role] = make(map[Permission]bool)
+	}
+	for _, perm := range perms {
+		p.grants[role][perm] = true
+	}
+}
+
+// Allows informa se algum dos papéis concede a permissão.
+func (p *Policy) Allows(roles []string, perm Permission) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, role := range roles {
+		if p.grants[role][perm] {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize retorna ErrForbidden quando os papéis não concedem a permissão.
+func (p *Policy) Authorize(roles []string, perm Permission) error {
+	if !p.Allows(roles, perm) {
+		return ErrForbidden
+	}
+	return nil
+}
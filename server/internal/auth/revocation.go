@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// RevocationList guarda os jti revogados até a expiração natural de cada token.
+// Cada nó mantém sua cópia; as revogações chegam a todos via log do Raft (evento logout)
+// e entram nos snapshots da FSM (SnapshotState/RestoreState), então sobrevivem à compactação.
+type RevocationList struct {
+	mu          sync.RWMutex
+	revoked     map[string]time.Time // jti -> expiração do token revogado
+	generations map[string]uint64    // userID -> geração atual; tokens de gerações anteriores são inválidos
+}
+
+// NewRevocationList cria uma lista de revogação vazia.
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		revoked:     make(map[string]time.Time),
+		generations: make(map[string]uint64),
+	}
+}
+
+// Revoke marca o jti como revogado até expiresAt. Entradas vencidas são descartadas
+// de forma oportunista para manter a lista limitada aos tokens ainda válidos.
+func (l *RevocationList) Revoke(jti string, expiresAt time.Time) {
+	if jti == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+	l.revoked[jti] = expiresAt
+}
+
+// RevokeUser invalida todos os tokens já emitidos para o usuário avançando sua geração.
+// A geração vai na claim gen de cada token, então a decisão não depende de relógio nem da
+// precisão do iat: um token emitido no mesmo segundo da troca de senha também cai.
+func (l *RevocationList) RevokeUser(userID string) {
+	if userID == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.generations[userID]++
+}
+
+// Generation retorna a geração atual dos tokens do usuário, gravada nos tokens emitidos agora.
+func (l *RevocationList) Generation(userID string) uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.generations[userID]
+}
+
+// IsStale informa se um token do usuário emitido na geração informada foi revogado por RevokeUser.
+func (l *RevocationList) IsStale(userID string, generation uint64) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return generation < l.generations[userID]
+}
+
+// prune descarta jti expirados; chamado com mu travado. As gerações não expiram: se uma fosse
+// descartada, réplicas que ainda a guardam passariam a recusar os tokens emitidos pelas outras.
+func (l *RevocationList) prune(now time.Time) {
+	for id, exp := range l.revoked {
+		if now.After(exp) {
+			delete(l.revoked, id)
+		}
+	}
+}
+
+// IsRevoked informa se o jti foi revogado.
+func (l *RevocationList) IsRevoked(jti string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.revoked[jti]
+	return ok
+}
+
+// revocationState é o conteúdo da lista gravado nos snapshots.
+type revocationState struct {
+	Revoked     map[string]time.Time `json:"revoked"`
+	Generations map[string]uint64    `json:"generations"`
+}
+
+// SnapshotState serializa os jti revogados e as gerações por usuário.
+func (l *RevocationList) SnapshotState() ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return json.Marshal(revocationState{Revoked: l.revoked, Generations: l.generations})
+}
+
+// RestoreState substitui o conteúdo da lista pelo de um snapshot; data vazio a esvazia.
+func (l *RevocationList) RestoreState(data []byte) error {
+	state := revocationState{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+	}
+	if state.Revoked == nil {
+		state.Revoked = make(map[string]time.Time)
+	}
+	if state.Generations == nil {
+		state.Generations = make(map[string]uint64)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.revoked = state.Revoked
+	l.generations = state.Generations
+	return nil
+}
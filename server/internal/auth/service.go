@@ -1,68 +1,212 @@
 package auth
 
 // AuthService fornece utilitários de autenticação baseados em JWT para emitir e validar tokens.
-// Em produção, o segredo deve ser injetado via variáveis de ambiente ou um cofre seguro.
+// Não há segredo padrão: as chaves vêm de KeyConfig (variáveis de ambiente ou arquivos).
+// Tokens de acesso têm vida curta; tokens de refresh permitem renová-los e são rotacionados a cada uso.
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var jwtSecret = []byte("cod-server-secret-key-change-in-production") // Definir via env em produção
+const (
+	// Issuer e Audience são exigidos na validação de todo token.
+	Issuer   = "cod-server"
+	Audience = "cod-client"
+
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenRevoked = errors.New("token revoked")
+)
 
 // Claims estende claims registrados do JWT com campos específicos da aplicação.
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
+	UserID    string   `json:"user_id"`
+	Username  string   `json:"username"`
+	TokenType string   `json:"typ"`
+	Roles     []string `json:"roles,omitempty"`
+	// Generation é a geração de tokens do usuário na emissão; RevokeUserTokens a avança.
+	Generation uint64 `json:"gen,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenPair agrupa o token de acesso e o token de refresh emitidos juntos.
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+}
+
 type AuthService struct {
-	secret []byte
+	keys    *KeySet
+	revoked *RevocationList
+}
+
+// NewAuthService constrói um AuthService HS256 com o segredo fornecido, que precisa ter ao
+// menos 32 bytes.
+func NewAuthService(secret string) (*AuthService, error) {
+	key, err := NewHMACKey(DefaultKeyID, []byte(secret))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := NewKeySet(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthServiceWithKeys(keys), nil
+}
+
+// NewAuthServiceWithKeys constrói um AuthService sobre um conjunto de chaves rotacionáveis.
+func NewAuthServiceWithKeys(keys *KeySet) *AuthService {
+	return &AuthService{keys: keys, revoked: NewRevocationList()}
+}
+
+// Revocations expõe a lista de revogação, que a FSM inclui nos snapshots.
+func (s *AuthService) Revocations() *RevocationList {
+	return s.revoked
+}
+
+// IsRevoked informa se o token (jti, do usuário, emitido na geração informada) foi revogado, por
+// logout ou por RevokeUserTokens. A FSM usa esta verificação com claims validadas na borda.
+func (s *AuthService) IsRevoked(jti, userID string, generation uint64) bool {
+	return s.revoked.IsRevoked(jti) || s.revoked.IsStale(userID, generation)
+}
+
+// Keys expõe o conjunto de chaves para rotação em tempo de execução.
+func (s *AuthService) Keys() *KeySet {
+	return s.keys
+}
+
+// GenerateToken cria um token de acesso assinado para o usuário fornecido.
+func (s *AuthService) GenerateToken(userID, username string, roles []string) (string, error) {
+	token, _, err := s.sign(userID, username, roles, TokenTypeAccess, AccessTokenTTL)
+	return token, err
 }
 
-// NewAuthService constrói um AuthService, usando um segredo interno padrão se vazio.
-func NewAuthService(secret string) *AuthService {
-	if secret == "" {
-		secret = string(jwtSecret)
+// GenerateTokenPair emite um token de acesso de vida curta e um token de refresh.
+// Os papéis vão nas claims; uma mudança de papel vale a partir do próximo login ou refresh.
+func (s *AuthService) GenerateTokenPair(userID, username string, roles []string) (*TokenPair, error) {
+	access, accessExp, err := s.sign(userID, username, roles, TokenTypeAccess, AccessTokenTTL)
+	if err != nil {
+		return nil, err
 	}
-	return &AuthService{secret: []byte(secret)}
+	refresh, refreshExp, err := s.sign(userID, username, roles, TokenTypeRefresh, RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		AccessExpiresAt:  accessExp,
+		RefreshExpiresAt: refreshExp,
+	}, nil
 }
 
-// GenerateToken cria um JWT assinado para o usuário fornecido com expiração de 24h.
-func (s *AuthService) GenerateToken(userID, username string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// ValidateToken analisa e valida um token de acesso, retornando claims quando válido.
+func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
+	return s.validate(tokenString, TokenTypeAccess)
+}
+
+// ValidateRefreshToken analisa e valida um token de refresh.
+func (s *AuthService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return s.validate(tokenString, TokenTypeRefresh)
+}
+
+// Revoke invalida o token identificado pelo jti até sua expiração.
+func (s *AuthService) Revoke(jti string, expiresAt time.Time) {
+	s.revoked.Revoke(jti, expiresAt)
+}
+
+// RevokeUserTokens invalida todos os tokens já emitidos para o usuário (troca de senha,
+// exclusão de conta); os emitidos depois carregam a nova geração.
+func (s *AuthService) RevokeUserTokens(userID string) {
+	s.revoked.RevokeUser(userID)
+}
+
+// sign monta e assina um token do tipo informado, com jti único, iss e aud.
+func (s *AuthService) sign(userID, username string, roles []string, tokenType string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expirationTime := now.Add(ttl)
 
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:     userID,
+		Username:   username,
+		TokenType:  tokenType,
+		Roles:      roles,
+		Generation: s.revoked.Generation(userID),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			Subject:   userID,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secret)
+	key := s.keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.signKey)
+	return signed, expirationTime, err
 }
 
-// ValidateToken analisa e valida um JWT, retornando claims quando válido.
-func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
+// validate verifica assinatura, iss, aud, expiração, jti, tipo e revogação.
+// A chave é escolhida pelo kid do cabeçalho e o alg do token precisa ser o da chave.
+func (s *AuthService) validate(tokenString, tokenType string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return s.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyFor,
+		jwt.WithValidMethods(s.keys.Algorithms()),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return nil, ErrInvalidToken
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidToken)
+	}
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("%w: expected %s token", ErrInvalidToken, tokenType)
+	}
+	if s.IsRevoked(claims.ID, claims.UserID, claims.Generation) {
+		return nil, ErrTokenRevoked
 	}
 
 	return claims, nil
 }
+
+// keyFor resolve a chave de verificação pelo kid, rejeitando algoritmos diferentes do da chave.
+func (s *AuthService) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("%w: missing kid", ErrUnknownKey)
+	}
+	key, err := s.keys.Lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedMethod, token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
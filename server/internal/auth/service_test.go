@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestService(t *testing.T) *AuthService {
+	t.Helper()
+	service, err := NewAuthService(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func newEdDSAKey(t *testing.T, kid string) *SigningKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, signKey: private, verifyKey: private.Public()}
+}
+
+// forge assina claims válidas com o método, kid e chave dados, sem passar pelo AuthService.
+func forge(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	now := time.Now()
+	claims := &Claims{
+		UserID:    "mallory-id",
+		TokenType: TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "forged",
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestValidateToken_RejectsUnexpectedAlgorithms(t *testing.T) {
+	hmacKey, err := NewHMACKey("old", []byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey := newEdDSAKey(t, "ed")
+	keys, err := NewKeySet(edKey, hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewAuthServiceWithKeys(keys)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"alg none", forge(t, jwt.SigningMethodNone, "ed", jwt.UnsafeAllowNoneSignatureType)},
+		// HS256 é aceito no conjunto (chave "old"), mas não para o kid de uma chave EdDSA
+		{"HS256 under an EdDSA kid", forge(t, jwt.SigningMethodHS256, "ed", []byte(testSecret))},
+		// Confusão de algoritmo: a chave pública usada como segredo HMAC
+		{"HS256 keyed with the public key", forge(t, jwt.SigningMethodHS256, "ed", []byte(edKey.verifyKey.(ed25519.PublicKey)))},
+		{"algorithm not in the key set", forge(t, jwt.SigningMethodHS512, "old", []byte(testSecret))},
+		{"missing kid", forge(t, jwt.SigningMethodHS256, "", []byte(testSecret))},
+		{"unknown kid", forge(t, jwt.SigningMethodHS256, "nope", []byte(testSecret))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := service.ValidateToken(tt.token); err == nil {
+				t.Fatalf("token accepted with claims %+v", claims)
+			}
+		})
+	}
+
+	// Controle: o mesmo forjador com a chave certa produz um token aceito
+	if _, err := service.ValidateToken(forge(t, jwt.SigningMethodHS256, "old", []byte(testSecret))); err != nil {
+		t.Errorf("HS256 token under its own kid rejected: %v", err)
+	}
+}
+
+func TestKeySet_RotationAndRetirement(t *testing.T) {
+	service := newTestService(t)
+	before, err := service.GenerateToken("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Keys().Rotate(newEdDSAKey(t, "2026-02")); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	after, err := service.GenerateToken("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(after, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "2026-02" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("new token header = %v, want kid 2026-02 with EdDSA", parsed.Header)
+	}
+
+	for name, token := range map[string]string{"before rotation": before, "after rotation": after} {
+		if _, err := service.ValidateToken(token); err != nil {
+			t.Errorf("token issued %s rejected: %v", name, err)
+		}
+	}
+
+	if err := service.Keys().Retire("2026-02"); err == nil {
+		t.Error("retired the active key")
+	}
+	if err := service.Keys().Retire(DefaultKeyID); err != nil {
+		t.Fatalf("Retire: %v", err)
+	}
+	// Sem chaves HS256 no conjunto, o token antigo cai já no filtro de algoritmos
+	if _, err := service.ValidateToken(before); err == nil {
+		t.Error("token of a retired key accepted")
+	}
+	if _, err := service.ValidateToken(after); err != nil {
+		t.Errorf("token of the active key rejected after retirement: %v", err)
+	}
+
+	public := &SigningKey{ID: "public-only", Method: jwt.SigningMethodEdDSA, verifyKey: newEdDSAKey(t, "x").verifyKey}
+	if err := service.Keys().Rotate(public); err == nil {
+		t.Error("rotated to a key without private part")
+	}
+}
+
+func TestValidate_TokenTypesAndRevocation(t *testing.T) {
+	service := newTestService(t)
+	pair, err := service.GenerateTokenPair("alice-id", "alice", []string{"player"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.ValidateToken(pair.RefreshToken); err == nil {
+		t.Error("refresh token accepted as access token")
+	}
+	if _, err := service.ValidateRefreshToken(pair.AccessToken); err == nil {
+		t.Error("access token accepted as refresh token")
+	}
+
+	claims, err := service.ValidateRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Revoke(claims.ID, claims.ExpiresAt.Time)
+	if _, err := service.ValidateRefreshToken(pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("revoked refresh token: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.ValidateToken(pair.AccessToken); err != nil {
+		t.Errorf("revoking the refresh token revoked the access token: %v", err)
+	}
+}
+
+func TestRevokeUserTokens_SameSecond(t *testing.T) {
+	service := newTestService(t)
+	before, err := service.GenerateTokenPair("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.GenerateToken("bob-id", "bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sem pausa: o token anterior e o posterior à revogação têm o mesmo iat
+	service.RevokeUserTokens("alice-id")
+	after, err := service.GenerateTokenPair("alice-id", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.ValidateToken(before.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("access token issued before the revocation: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.ValidateRefreshToken(before.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("refresh token issued before the revocation: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.ValidateToken(after.AccessToken); err != nil {
+		t.Errorf("token issued after the revocation rejected: %v", err)
+	}
+	if _, err := service.ValidateToken(other); err != nil {
+		t.Errorf("revoking alice revoked bob: %v", err)
+	}
+}
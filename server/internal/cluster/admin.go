@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"cod-server/internal/auth"
+	"cod-server/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/raft"
+)
+
+// EnableAdminRoutes registers the /admin group. Every route requires a valid access token
+// whose roles grant auth.PermManageCluster under the given policy. catalogSync backs
+// /admin/catalog/reload; without it the route is not registered.
+func (t *GinHttpTransport) EnableAdminRoutes(authService *auth.AuthService, policy *auth.Policy, catalogSync *CatalogSync) {
+	group := t.router.Group("/admin")
+	group.Use(auth.AuthMiddleware(authService), auth.RequirePermission(policy, auth.PermManageCluster))
+
+	group.GET("/cluster", t.handleClusterStatus)
+	group.POST("/cluster/nodes", t.handleAddNode)
+	group.DELETE("/cluster/nodes/:id", t.handleRemoveNode)
+	group.POST("/cluster/leadership-transfer", t.handleLeadershipTransfer)
+	if catalogSync != nil {
+		group.POST("/catalog/reload", func(c *gin.Context) { t.handleCatalogReload(c, catalogSync) })
+	}
+}
+
+// handleClusterStatus reports this node's raft state, the known leader and the configuration.
+func (t *GinHttpTransport) handleClusterStatus(c *gin.Context) {
+	future := t.raftNode.GetConfiguration()
+	if err := future.Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "falha ao obter configuração do cluster: " + err.Error()})
+		return
+	}
+
+	servers := make([]gin.H, 0, len(future.Configuration().Servers))
+	for _, srv := range future.Configuration().Servers {
+		servers = append(servers, gin.H{
+			"id":       srv.ID,
+			"address":  srv.Address,
+			"suffrage": srv.Suffrage.String(),
+		})
+	}
+	leaderAddr, leaderID := t.raftNode.LeaderWithID()
+	c.JSON(http.StatusOK, gin.H{
+		"node_id":        t.nodeID,
+		"state":          t.raftNode.State().String(),
+		"leader_id":      leaderID,
+		"leader_address": leaderAddr,
+		"servers":        servers,
+		"stats":          t.raftNode.Stats(),
+	})
+}
+
+// handleAddNode adds a voter, like /raft/join, but on behalf of an administrator.
+func (t *GinHttpTransport) handleAddNode(c *gin.Context) {
+	var req JoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "corpo da requisição inválido: " + err.Error()})
+		return
+	}
+	if !t.requireLeader(c) {
+		return
+	}
+
+	future := t.raftNode.AddVoter(raft.ServerID(req.NodeID), raft.ServerAddress(req.NodeAddress), 0, 0)
+	if err := future.Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "falha ao adicionar nó ao cluster: " + err.Error()})
+		return
+	}
+	t.logger.Infof("Nó %s adicionado por administrador (%s)", req.NodeID, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleRemoveNode removes a server from the raft configuration.
+func (t *GinHttpTransport) handleRemoveNode(c *gin.Context) {
+	if !t.requireLeader(c) {
+		return
+	}
+	nodeID := c.Param("id")
+
+	future := t.raftNode.RemoveServer(raft.ServerID(nodeID), 0, 0)
+	if err := future.Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "falha ao remover nó do cluster: " + err.Error()})
+		return
+	}
+	t.logger.Infof("Nó %s removido por administrador (%s)", nodeID, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleLeadershipTransfer asks the current leader to hand leadership to another voter.
+func (t *GinHttpTransport) handleLeadershipTransfer(c *gin.Context) {
+	if !t.requireLeader(c) {
+		return
+	}
+	if err := t.raftNode.LeadershipTransfer().Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "falha ao transferir liderança: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleCatalogReload re-reads the leader's catalog file and replicates it when its version is
+// newer than the applied one. An invalid file is rejected and the applied catalog stays.
+func (t *GinHttpTransport) handleCatalogReload(c *gin.Context, catalogSync *CatalogSync) {
+	if !t.requireLeader(c) {
+		return
+	}
+	version, proposed, err := catalogSync.Reload()
+	if errors.Is(err, domain.ErrInvalidCatalog) || errors.Is(err, fs.ErrNotExist) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "catálogo inválido: " + err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "falha ao replicar catálogo: " + err.Error()})
+		return
+	}
+	t.logger.Infof("Catálogo versão %d recarregado por administrador (%s)", version, c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "version": version, "proposed": proposed})
+}
+
+// requireLeader answers 503 with the leader address when this node cannot change the configuration.
+func (t *GinHttpTransport) requireLeader(c *gin.Context) bool {
+	if t.raftNode.State() == raft.Leader {
+		return true
+	}
+	leaderAddr, leaderID := t.raftNode.LeaderWithID()
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":          "não sou o líder",
+		"leader_id":      leaderID,
+		"leader_address": leaderAddr,
+	})
+	return false
+}
@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"cod-server/internal/api"
+	"cod-server/internal/data/catalog"
+	"cod-server/internal/domain"
+	"encoding/json"
+	"fmt"
+	shared_protocol "shared/protocol"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	raft "github.com/hashicorp/raft"
+)
+
+// CatalogSync replica o catálogo do arquivo local (ou o embutido, se path for vazio): sempre
+// que este nó assume a liderança, espera a FSM aplicar o log e, se o arquivo tiver versão maior
+// que a do catálogo aplicado (ou nenhum tiver sido aplicado), propõe sync_catalog. Assim todas
+// as réplicas recebem o catálogo, inclusive o inicial, no mesmo ponto do log. Publicar cartas
+// novas é editar o arquivo do líder e chamar Reload (POST /admin/catalog/reload).
+type CatalogSync struct {
+	raftNode *raft.Raft
+	store    CatalogStore
+	path     string
+	timeout  time.Duration
+
+	mu    sync.Mutex // Serializa Sync e Reload
+	local *domain.Catalog
+}
+
+// NewCatalogSync lê o catálogo de path; um arquivo inválido impede o nó de subir.
+func NewCatalogSync(r *raft.Raft, store CatalogStore, path string) (*CatalogSync, error) {
+	local, err := catalog.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &CatalogSync{raftNode: r, store: store, path: path, local: local, timeout: 10 * time.Second}, nil
+}
+
+// Start acompanha a liderança até stop ser fechado.
+func (s *CatalogSync) Start(stop <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case isLeader := <-s.raftNode.LeaderCh():
+				if isLeader {
+					if err := s.Sync(); err != nil {
+						log.Errorf("Falha ao sincronizar catálogo de cartas: %v", err)
+					}
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Reload relê o arquivo e, se ele for mais novo que o catálogo aplicado, o propõe. Só tem
+// efeito no líder; retorna a versão lida e se ela foi proposta. Um arquivo inválido é recusado
+// e o catálogo lido antes continua valendo.
+func (s *CatalogSync) Reload() (version int, proposed bool, err error) {
+	local, err := catalog.Load(s.path)
+	if err != nil {
+		return 0, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.local = local
+	proposed, err = s.sync()
+	return local.Version, proposed, err
+}
+
+// Sync propõe o catálogo local se ele for mais novo que o aplicado. Só tem efeito no líder.
+func (s *CatalogSync) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.sync()
+	return err
+}
+
+func (s *CatalogSync) sync() (bool, error) {
+	// A barreira garante que o catálogo comparado já inclui todas as entradas do log
+	if err := s.raftNode.Barrier(s.timeout).Error(); err != nil {
+		return false, fmt.Errorf("barreira do raft: %w", err)
+	}
+	if current := s.store.Catalog(); current != nil && s.local.Version <= current.Version {
+		return false, nil
+	}
+
+	event := api.Event{
+		Event: shared_protocol.Event{
+			Method:    "sync_catalog",
+			Timestamp: time.Now(),
+			Payload:   map[string]any{"catalog": s.local},
+		},
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return false, fmt.Errorf("falha ao serializar catálogo: %w", err)
+	}
+	future := s.raftNode.Apply(data, s.timeout)
+	if err := future.Error(); err != nil {
+		return false, fmt.Errorf("erro ao aplicar catálogo no raft: %w", err)
+	}
+	if reply, ok := future.Response().(api.Event); ok && reply.Method != "sync_catalog_ok" {
+		return false, fmt.Errorf("catálogo recusado pela FSM: %v", reply.Payload["error"])
+	}
+	log.Infof("Catálogo de cartas versão %d replicado", s.local.Version)
+	return true, nil
+}
@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"cod-server/internal/api"
+	"cod-server/internal/api/mqtt"
+	"cod-server/internal/data/changes"
+	"cod-server/internal/domain"
+	shared_protocol "shared/protocol"
+	"time"
+
+	"github.com/charmbracelet/log"
+	raft "github.com/hashicorp/raft"
+)
+
+// ChangeNotifier publica no MQTT as mudanças confirmadas nos repositórios, no tópico privado
+// replies/{client_id}/notifications/{cards|matches|account} de cada cliente em que o usuário
+// afetado está logado (Sessions). O ACL do broker só deixa cada cliente ler os próprios replies.
+//
+// Toda réplica aplica o log e emite as mesmas mudanças; só o líder publica, para que o
+// cliente não receba uma cópia por nó. Durante uma troca de líder uma notificação pode se
+// perder ou se repetir: elas avisam que algo mudou, e o estado continua vindo das leituras.
+type ChangeNotifier struct {
+	raftNode    *raft.Raft
+	mqttAdapter mqtt.MQTTAdapterInterface
+	sessions    *Sessions
+}
+
+func NewChangeNotifier(r *raft.Raft, mqttAdapter mqtt.MQTTAdapterInterface, sessions *Sessions) *ChangeNotifier {
+	return &ChangeNotifier{raftNode: r, mqttAdapter: mqttAdapter, sessions: sessions}
+}
+
+// Subscribe assina os feeds e retorna a função que cancela as assinaturas.
+func (n *ChangeNotifier) Subscribe(feeds *changes.Feeds) (unsubscribe func()) {
+	unsubscribers := []func(){
+		feeds.Users.Subscribe(n.onUser),
+		feeds.Cards.Subscribe(n.onCard),
+		feeds.Matches.Subscribe(n.onMatch),
+	}
+	return func() {
+		for _, unsubscribe := range unsubscribers {
+			unsubscribe()
+		}
+	}
+}
+
+// NotificationTopic é o tópico das notificações de um cliente sobre um tipo de entidade.
+func NotificationTopic(clientID, entity string) string {
+	return "replies/" + clientID + "/notifications/" + entity
+}
+
+// onUser avisa o próprio usuário; o payload nunca leva o hash da senha.
+func (n *ChangeNotifier) onUser(change changes.Change[domain.UserInterface]) {
+	payload := map[string]any{"user_id": change.ID}
+	if user := change.Entity; user != nil {
+		payload["username"] = user.GetUsername()
+		payload["roles"] = user.GetRoles()
+	}
+	n.publish(change.ID, "account", "user_"+string(change.Kind), payload)
+}
+
+// onCard avisa o dono da carta, inclusive quando ela chega por uma troca.
+func (n *ChangeNotifier) onCard(change changes.Change[domain.CardInterface]) {
+	card := change.Entity
+	if card == nil {
+		return
+	}
+	payload := map[string]any{
+		"card_id":  change.ID,
+		"owner_id": card.GetOwnerID(),
+		"type":     card.GetType(),
+		"power":    card.GetPower(),
+	}
+	n.publish(card.GetOwnerID(), "cards", "card_"+string(change.Kind), payload)
+}
+
+// onMatch avisa todos os jogadores da partida.
+func (n *ChangeNotifier) onMatch(change changes.Change[domain.MatchInterface]) {
+	match := change.Entity
+	if match == nil {
+		return
+	}
+	players := make([]string, 0, len(match.GetPlayers()))
+	for _, player := range match.GetPlayers() {
+		players = append(players, player.GetID())
+	}
+	payload := map[string]any{
+		"match_id":  change.ID,
+		"players":   players,
+		"scores":    match.GetScores(),
+		"cancelled": match.IsCancelled(),
+	}
+	if winner, err := match.GetWinner(); err == nil {
+		payload["winner"] = winner
+	}
+	for _, playerID := range players {
+		n.publish(playerID, "matches", "match_"+string(change.Kind), payload)
+	}
+}
+
+// publish envia a notificação a cada cliente do usuário; sem clientes logados, não sai nada.
+func (n *ChangeNotifier) publish(userID, entity, method string, payload map[string]any) {
+	if n.raftNode.State() != raft.Leader {
+		return
+	}
+	clients := n.sessions.Clients(userID)
+	if len(clients) == 0 {
+		return
+	}
+	event := api.Event{
+		Event: shared_protocol.Event{
+			Method:    method,
+			Timestamp: time.Now(),
+			Payload:   payload,
+		},
+	}
+	for _, clientID := range clients {
+		topic := NotificationTopic(clientID, entity)
+		if err := n.mqttAdapter.Publish(topic, event); err != nil {
+			log.Errorf("Falha ao publicar notificação %s em %s: %v", method, topic, err)
+		}
+	}
+}
@@ -3,12 +3,17 @@ package cluster
 import (
 	"cod-server/internal/api"
 	"cod-server/internal/api/mqtt"
+	"cod-server/internal/auth"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	shared_protocol "shared/protocol"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/log"
 	raft "github.com/hashicorp/raft"
 )
 
@@ -18,25 +23,130 @@ type CoordinatorInterface interface {
 	Handle(event api.Event) error
 }
 
+// IndexWaiter espera a réplica local aplicar uma entrada do log (ex.: cache.AppliedIndex).
+type IndexWaiter interface {
+	WaitFor(ctx context.Context, index uint64) error
+}
+
+// MaxChatMessageLength limita, em bytes, o conteúdo de uma mensagem de chat repassada.
+const MaxChatMessageLength = 1024
+
+// ChatModeration informa as sanções de um usuário em uma sala (ex.: services.ModerationServiceInterface).
+type ChatModeration interface {
+	Status(roomID, userID string, at time.Time) (muted bool, banned bool)
+}
+
+// localReadMethods são os eventos somente leitura que podem ser respondidos pela réplica local.
+var localReadMethods = map[string]bool{
+	"get_cards":   true,
+	"get_profile": true,
+	"get_catalog": true,
+}
+
+// internalMethods são propostos ao log pelo próprio cluster (ex.: sync_catalog pelo líder,
+// bootstrap_admin pelo subcomando de manutenção) e recusados quando chegam de clientes.
+var internalMethods = map[string]bool{
+	"sync_catalog":    true,
+	"bootstrap_admin": true,
+}
+
 // RaftCoordinator é a implementação que decide entre aplicar localmente ou encaminhar
 type RaftCoordinator struct {
-	raftNode    *raft.Raft                // Para verificar estado e aplicar logs
-	transport   ClusterTransportInterface // Para encaminhar se não for líder
-	mqttAdapter mqtt.MQTTAdapterInterface // Para publicar respostas de volta ao cliente
-	timeout     time.Duration             // Tempo máximo de espera pelo consenso
+	raftNode      *raft.Raft                // Para verificar estado e aplicar logs
+	transport     ClusterTransportInterface // Para encaminhar se não for líder
+	mqttAdapter   mqtt.MQTTAdapterInterface // Para publicar respostas de volta ao cliente
+	authenticator *api.Authenticator        // Valida o token antes de o evento entrar no log
+	timeout       time.Duration             // Tempo máximo de espera pelo consenso
+
+	// Leituras locais (opcional): respondidas sem passar pelo log, quando a réplica alcança min_index
+	readHandler api.EventHandlerInterface
+	applied     IndexWaiter
+	readTimeout time.Duration
+
+	// Chat (opcional): repassado para a sala sem entrar no log, se o autor não estiver sancionado
+	moderation ChatModeration
+
+	// Troca senhas por hashes antes do log; sem ele register, login e afins são recusados
+	credentials *api.Credentials
 }
 
 // NewRaftCoordinator cria a instância
-func NewRaftCoordinator(r *raft.Raft, t ClusterTransportInterface, mqttAdapter mqtt.MQTTAdapterInterface) *RaftCoordinator {
+func NewRaftCoordinator(r *raft.Raft, t ClusterTransportInterface, mqttAdapter mqtt.MQTTAdapterInterface, authenticator *api.Authenticator) *RaftCoordinator {
 	return &RaftCoordinator{
-		raftNode:    r,
-		transport:   t,
-		mqttAdapter: mqttAdapter,
-		timeout:     10 * time.Second, // Exemplo de valor
+		raftNode:      r,
+		transport:     t,
+		mqttAdapter:   mqttAdapter,
+		authenticator: authenticator,
+		timeout:       10 * time.Second, // Exemplo de valor
 	}
 }
 
+// SetLocalReads faz get_cards, get_profile e get_catalog serem respondidos pela réplica que
+// recebeu o evento, sem entrar no log. Se o payload trouxer "min_index" (o "applied_index" da resposta de uma
+// escrita), a leitura espera até timeout a réplica aplicar essa entrada; assim o cliente lê as
+// próprias escritas em qualquer nó.
+func (c *RaftCoordinator) SetLocalReads(handler api.EventHandlerInterface, applied IndexWaiter, timeout time.Duration) {
+	c.readHandler = handler
+	c.applied = applied
+	c.readTimeout = timeout
+}
+
+// SetCredentials faz as senhas de register, login, change_password e delete_account serem
+// conferidas e trocadas por hashes neste nó, antes de o evento entrar no log. Sem isto esses
+// métodos são recusados, para que nenhuma senha seja gravada no log do Raft.
+func (c *RaftCoordinator) SetCredentials(credentials *api.Credentials) {
+	c.credentials = credentials
+}
+
+// SetChatRelay faz o chat ser repassado por este nó: o cliente publica em
+// requests/{client_id}/chat/room/{sala}, o nó confere as sanções na réplica local e publica em
+// chat/room/{sala} com o autor tirado do token. Sem isto o chat é recusado.
+func (c *RaftCoordinator) SetChatRelay(moderation ChatModeration) {
+	c.moderation = moderation
+}
+
 func (c *RaftCoordinator) Handle(event api.Event) error {
+	if internalMethods[event.Method] {
+		c.publishReply(event, api.NewErrorEvent(event.Method+"_fail", "forbidden", "internal method"))
+		return fmt.Errorf("evento %s rejeitado: método interno do cluster", event.Method)
+	}
+
+	// Autenticação acontece uma vez, no nó que recebeu o evento, antes de encaminhar ou aplicar.
+	// O líder confia no que recebe por /raft/command porque a rota só aceita requisições
+	// assinadas com o segredo do cluster, ou seja, vindas de um nó que já fez esta verificação.
+	var claims *auth.Claims
+	if c.authenticator != nil {
+		var err error
+		if claims, err = c.authenticator.Authenticate(&event); err != nil {
+			c.publishReply(event, api.NewErrorEvent(event.Method+"_fail", "unauthorized", err.Error()))
+			return fmt.Errorf("evento %s rejeitado: %w", event.Method, err)
+		}
+	}
+
+	// O instante do evento e o entry_id são definidos por quem o recebe, não pelo cliente: a FSM
+	// os usa (ex.: fim de um silêncio, ids das cartas de um pacote) e precisa dos mesmos valores
+	// confiáveis em todas as réplicas.
+	event.Timestamp = time.Now()
+	api.AssignEntryID(&event)
+
+	if api.CarriesPassword(event.Method) {
+		if c.credentials == nil {
+			c.publishReply(event, api.NewErrorEvent(event.Method+"_fail", "unavailable", "credentials are not handled by this node"))
+			return fmt.Errorf("evento %s rejeitado: nó sem verificação de credenciais", event.Method)
+		}
+		if fail := c.credentials.Prepare(&event); fail != nil {
+			c.publishReply(event, *fail)
+			return nil
+		}
+	}
+
+	if event.Method == "chat" {
+		return c.handleChat(event, claims)
+	}
+	if c.readHandler != nil && localReadMethods[event.Method] {
+		return c.handleLocalRead(event)
+	}
+
 	if c.raftNode.State() != raft.Leader {
 		leaderAddr := c.raftNode.Leader()
 		if leaderAddr == "" {
@@ -82,36 +192,136 @@ func (c *RaftCoordinator) Handle(event api.Event) error {
 			// É um erro, então não é o tipo esperado de resposta
 			return err
 		} else if responseEvent, ok := response.(api.Event); ok {
-			// Obter tópico de resposta apropriado
-			replyTopic := c.getReplyTopic(event)
-			if replyTopic != "" {
-				if err := c.mqttAdapter.Publish(replyTopic, responseEvent); err != nil {
-					// Log do erro, mas não retornar erro para não afetar o fluxo principal
-					fmt.Printf("Erro ao publicar resposta no MQTT: %v\n", err)
-				}
+			// Índice da entrada, para o cliente pedir leituras locais que já a incluam
+			if responseEvent.Payload != nil {
+				responseEvent.Payload["applied_index"] = applyFuture.Index()
 			}
+			c.publishReply(event, responseEvent)
 		}
 	}
 
 	return nil
 }
 
-// getReplyTopic determina o tópico de resposta apropriado com base no método do evento
-func (c *RaftCoordinator) getReplyTopic(event api.Event) string {
+// handleLocalRead responde um evento somente leitura com o estado desta réplica.
+func (c *RaftCoordinator) handleLocalRead(event api.Event) error {
+	var minIndex uint64
+	if value, ok := event.Payload["min_index"].(float64); ok && value > 0 {
+		minIndex = uint64(value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.readTimeout)
+	defer cancel()
+	if err := c.applied.WaitFor(ctx, minIndex); err != nil {
+		c.publishReply(event, api.NewErrorEvent(event.Method+"_fail", "stale_replica", "replica has not applied the requested index"))
+		return fmt.Errorf("leitura local de %s sem alcançar o índice %d: %w", event.Method, minIndex, err)
+	}
+
+	var reply api.Event
 	switch event.Method {
-	case "register":
-		return "user/register/events"
-	case "login":
-		return "user/login/events"
-	case "chat":
-		// Para chat, responder no próprio tópico de chat
-		if roomID, ok := event.Payload["room_id"].(string); ok {
-			return "chat/room/" + roomID
-		} else {
-			return "chat/room/messages"
+	case "get_cards":
+		reply = c.readHandler.OnGetCards(event)
+	case "get_profile":
+		reply = c.readHandler.OnGetProfile(event)
+	case "get_catalog":
+		reply = c.readHandler.OnGetCatalog(event)
+	}
+	c.publishReply(event, reply)
+	return nil
+}
+
+// handleChat repassa uma mensagem de chat para a sala. As sanções são as desta réplica: uma
+// sanção recém-aplicada vale aqui assim que a entrada correspondente for aplicada.
+func (c *RaftCoordinator) handleChat(event api.Event, claims *auth.Claims) error {
+	if c.moderation == nil || claims == nil {
+		c.publishReply(event, api.NewErrorEvent("chat_fail", "unavailable", "chat is not enabled on this node"))
+		return errors.New("chat recusado: repasse de chat não configurado")
+	}
+	roomID, _ := event.Payload["room_id"].(string)
+	content, _ := event.Payload["content"].(string)
+	// A sala vira um nível do tópico; curingas ou '/' permitiriam publicar em outros tópicos
+	if roomID == "" || strings.ContainsAny(roomID, "/+#") || content == "" || len(content) > MaxChatMessageLength {
+		c.publishReply(event, api.NewErrorEvent("chat_fail", "invalid_payload", "invalid room or message"))
+		return fmt.Errorf("chat de %s recusado: payload inválido", claims.UserID)
+	}
+
+	muted, banned := c.moderation.Status(roomID, claims.UserID, event.Timestamp)
+	switch {
+	case banned:
+		c.publishReply(event, api.NewErrorEvent("chat_fail", "banned", "you are banned from this room"))
+		return nil
+	case muted:
+		c.publishReply(event, api.NewErrorEvent("chat_fail", "muted", "you are muted in this room"))
+		return nil
+	}
+
+	message := api.Event{Event: shared_protocol.Event{
+		Method:    "chat",
+		Timestamp: event.Timestamp,
+		Payload: map[string]any{
+			"room_id":  roomID,
+			"user_id":  claims.UserID,
+			"username": claims.Username,
+			"content":  content,
+		},
+	}}
+	if err := c.mqttAdapter.Publish(ChatTopic(roomID), message); err != nil {
+		return fmt.Errorf("falha ao publicar chat na sala %s: %w", roomID, err)
+	}
+	return nil
+}
+
+// publishReply publica a resposta no tópico privado do cliente que enviou o evento e, para
+// sanções aplicadas, também no tópico de moderação da sala.
+func (c *RaftCoordinator) publishReply(event api.Event, reply api.Event) {
+	reply = Correlate(event, reply)
+	for _, topic := range []string{ReplyTopic(event), BroadcastTopic(event, reply)} {
+		if topic == "" {
+			continue
+		}
+		if err := c.mqttAdapter.Publish(topic, reply); err != nil {
+			// Log do erro, mas não retornar erro para não afetar o fluxo principal
+			log.Errorf("Erro ao publicar resposta em %s: %v", topic, err)
 		}
-	default:
-		// Para outros métodos, retornar tópico genérico ou baseado no método
-		return "responses/" + event.Method
 	}
 }
+
+// ReplyTopic é o tópico privado replies/{client_id}/{método} do cliente que enviou o evento.
+// O client_id vem do tópico em que o pedido chegou, nunca do payload enviado pelo cliente.
+func ReplyTopic(event api.Event) string {
+	clientID, _ := event.Payload["client_id"].(string)
+	if clientID == "" {
+		return ""
+	}
+	return shared_protocol.ReplyTopic(clientID, event.Method)
+}
+
+// BroadcastTopic retorna o tópico público em que a resposta também é anunciada, ou "".
+// Sanções aplicadas vão para a sala, para que os clientes filtrem as mensagens.
+func BroadcastTopic(event api.Event, reply api.Event) string {
+	switch reply.Method {
+	case "mute_user_ok", "ban_user_ok", "unban_user_ok":
+		if roomID, ok := event.Payload["room_id"].(string); ok && roomID != "" {
+			return ChatTopic(roomID) + "/moderation"
+		}
+	}
+	return ""
+}
+
+// ChatTopic é o tópico público da sala, em que só os nós do cluster publicam.
+func ChatTopic(roomID string) string {
+	return "chat/room/" + roomID
+}
+
+// Correlate copia o request_id do pedido para a resposta, para o cliente casar as duas.
+func Correlate(event api.Event, reply api.Event) api.Event {
+	requestID, ok := event.Payload["request_id"].(string)
+	if !ok || requestID == "" {
+		return reply
+	}
+	if reply.Payload == nil {
+		reply.Payload = make(map[string]any)
+	}
+	reply.Payload["request_id"] = requestID
+	return reply
+}
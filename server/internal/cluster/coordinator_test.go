@@ -0,0 +1,234 @@
+package cluster
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"cod-server/internal/api"
+	"cod-server/internal/api/mqtt"
+	"cod-server/internal/auth"
+	"cod-server/internal/data"
+	"cod-server/internal/data/cache"
+	"cod-server/internal/domain"
+	"cod-server/internal/services"
+	shared_protocol "shared/protocol"
+)
+
+// publishedEvent é uma publicação registrada por recordingPublisher.
+type publishedEvent struct {
+	topic string
+	event api.Event
+}
+
+// recordingPublisher guarda as publicações; os demais métodos do adaptador não são usados.
+type recordingPublisher struct {
+	mqtt.MQTTAdapterInterface
+	mu        sync.Mutex
+	published []publishedEvent
+}
+
+func (p *recordingPublisher) Publish(topic string, event api.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, publishedEvent{topic: topic, event: event})
+	return nil
+}
+
+func (p *recordingPublisher) take() []publishedEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	published := p.published
+	p.published = nil
+	return published
+}
+
+func newChatCoordinator(t *testing.T) (*RaftCoordinator, *recordingPublisher, services.ModerationServiceInterface, string) {
+	t.Helper()
+	authService, err := auth.NewAuthService(string(testClusterSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := authService.GenerateToken("bob-id", "bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := &recordingPublisher{}
+	moderation := services.NewModerationService()
+	coordinator := NewRaftCoordinator(nil, nil, publisher, api.NewAuthenticator(authService))
+	coordinator.SetChatRelay(moderation)
+	return coordinator, publisher, moderation, token
+}
+
+func chatEvent(payload map[string]any) api.Event {
+	payload["client_id"] = "c1"
+	return api.Event{Event: shared_protocol.Event{Method: "chat", Payload: payload}}
+}
+
+func TestRaftCoordinator_RelaysChatWithAuthorFromToken(t *testing.T) {
+	coordinator, publisher, _, token := newChatCoordinator(t)
+
+	err := coordinator.Handle(chatEvent(map[string]any{"token": token, "room_id": "lobby", "content": "oi", "username": "alice"}))
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	published := publisher.take()
+	if len(published) != 1 || published[0].topic != "chat/room/lobby" {
+		t.Fatalf("published = %+v, want one message on chat/room/lobby", published)
+	}
+	payload := published[0].event.Payload
+	if payload["user_id"] != "bob-id" || payload["username"] != "bob" || payload["content"] != "oi" {
+		t.Errorf("relayed payload = %v, want bob's message", payload)
+	}
+	for _, field := range []string{"jti", "token", "client_id"} {
+		if _, ok := payload[field]; ok {
+			t.Errorf("relayed payload leaks %s", field)
+		}
+	}
+}
+
+func TestRaftCoordinator_RejectsChat(t *testing.T) {
+	coordinator, publisher, moderation, token := newChatCoordinator(t)
+	if err := moderation.Mute("muted-room", "bob-id", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := moderation.Ban("banned-room", "bob-id"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		payload  map[string]any
+		wantCode string
+	}{
+		{"no token", map[string]any{"room_id": "lobby", "content": "oi"}, "unauthorized"},
+		{"other user", map[string]any{"token": token, "user_id": "alice-id", "room_id": "lobby", "content": "oi"}, "unauthorized"},
+		{"muted", map[string]any{"token": token, "room_id": "muted-room", "content": "oi"}, "muted"},
+		{"banned", map[string]any{"token": token, "room_id": "banned-room", "content": "oi"}, "banned"},
+		{"room escapes topic", map[string]any{"token": token, "room_id": "lobby/moderation", "content": "oi"}, "invalid_payload"},
+		{"wildcard room", map[string]any{"token": token, "room_id": "+", "content": "oi"}, "invalid_payload"},
+		{"empty message", map[string]any{"token": token, "room_id": "lobby", "content": ""}, "invalid_payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coordinator.Handle(chatEvent(tt.payload))
+			published := publisher.take()
+			if len(published) != 1 {
+				t.Fatalf("published = %+v, want only the failure reply", published)
+			}
+			reply := published[0]
+			if reply.topic != "replies/c1/chat" || reply.event.Method != "chat_fail" || reply.event.Payload["code"] != tt.wantCode {
+				t.Errorf("reply = %s %s %v, want chat_fail with code %s on replies/c1/chat", reply.topic, reply.event.Method, reply.event.Payload, tt.wantCode)
+			}
+		})
+	}
+
+	// O silêncio termina no instante gravado
+	if err := moderation.Mute("muted-room", "bob-id", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.Handle(chatEvent(map[string]any{"token": token, "room_id": "muted-room", "content": "voltei"}))
+	if published := publisher.take(); len(published) != 1 || published[0].topic != "chat/room/muted-room" {
+		t.Errorf("chat after the mute expired: published = %+v", published)
+	}
+}
+
+func TestRaftCoordinator_RejectsInternalMethods(t *testing.T) {
+	coordinator, publisher, _, _ := newChatCoordinator(t)
+	event := api.Event{Event: shared_protocol.Event{Method: "bootstrap_admin", Payload: map[string]any{"client_id": "c1", "username": "mallory"}}}
+
+	if err := coordinator.Handle(event); err == nil {
+		t.Fatal("bootstrap_admin from a client was accepted")
+	}
+	if published := publisher.take(); len(published) != 1 || published[0].event.Payload["code"] != "forbidden" {
+		t.Errorf("published = %+v, want a forbidden reply", published)
+	}
+}
+
+func TestRaftCoordinator_PasswordsNeedCredentials(t *testing.T) {
+	coordinator, publisher, _, _ := newChatCoordinator(t)
+	register := func() api.Event {
+		return api.Event{Event: shared_protocol.Event{Method: "register", Payload: map[string]any{"client_id": "c1", "username": "carol", "password": "short"}}}
+	}
+
+	// Sem Credentials a senha iria para o log: o evento é recusado antes do Raft
+	if err := coordinator.Handle(register()); err == nil {
+		t.Fatal("register accepted by a node without credentials")
+	}
+	if published := publisher.take(); len(published) != 1 || published[0].event.Payload["code"] != "unavailable" {
+		t.Errorf("published = %+v, want an unavailable reply", published)
+	}
+
+	// Falhas detectadas na borda são respondidas sem chegar ao Raft
+	coordinator.SetCredentials(api.NewCredentials(services.NewUserService(data.NewMemoryRepository[domain.UserInterface]())))
+	if err := coordinator.Handle(register()); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	published := publisher.take()
+	if len(published) != 1 || published[0].topic != "replies/c1/register" || published[0].event.Payload["code"] != "weak_password" {
+		t.Errorf("published = %+v, want register_fail weak_password on the reply topic", published)
+	}
+}
+
+// profileReader responde get_profile; os demais métodos do handler não são usados.
+type profileReader struct {
+	api.EventHandlerInterface
+}
+
+func (profileReader) OnGetProfile(event api.Event) api.Event {
+	return api.Event{Event: shared_protocol.Event{Method: "get_profile_ok", Payload: map[string]any{"user_id": event.Payload["user_id"]}}}
+}
+
+func TestRaftCoordinator_LocalReadWaitsForMinIndex(t *testing.T) {
+	coordinator, publisher, _, token := newChatCoordinator(t)
+	applied := cache.NewAppliedIndex()
+	applied.Advance(5)
+	coordinator.SetLocalReads(profileReader{}, applied, time.Second)
+	read := func(payload map[string]any) api.Event {
+		payload["client_id"] = "c1"
+		payload["token"] = token
+		return api.Event{Event: shared_protocol.Event{Method: "get_profile", Payload: payload}}
+	}
+	expectProfile := func(step string) {
+		t.Helper()
+		published := publisher.take()
+		if len(published) != 1 || published[0].topic != "replies/c1/get_profile" || published[0].event.Method != "get_profile_ok" {
+			t.Fatalf("%s: published = %+v, want get_profile_ok", step, published)
+		}
+		if published[0].event.Payload["user_id"] != "bob-id" {
+			t.Errorf("%s: profile of %v, want the token's user", step, published[0].event.Payload["user_id"])
+		}
+	}
+
+	// Sem min_index, ou com um índice já aplicado, responde na hora
+	for _, payload := range []map[string]any{{}, {"min_index": float64(5)}} {
+		if err := coordinator.Handle(read(payload)); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		expectProfile("applied index")
+	}
+
+	// À frente da réplica: espera a entrada ser aplicada
+	done := make(chan error)
+	go func() { done <- coordinator.Handle(read(map[string]any{"min_index": float64(8)})) }()
+	select {
+	case err := <-done:
+		t.Fatalf("read answered before index 8 was applied: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	applied.Advance(8)
+	if err := <-done; err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	expectProfile("after waiting")
+
+	// A réplica não alcança o índice a tempo
+	coordinator.SetLocalReads(profileReader{}, applied, 20*time.Millisecond)
+	if err := coordinator.Handle(read(map[string]any{"min_index": float64(100)})); err == nil {
+		t.Fatal("read past the timeout returned no error")
+	}
+	published := publisher.take()
+	if len(published) != 1 || published[0].event.Method != "get_profile_fail" || published[0].event.Payload["code"] != "stale_replica" {
+		t.Errorf("published = %+v, want get_profile_fail stale_replica", published)
+	}
+}
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"crypto/hmac"
 	"fmt"
 	"net"
 	"net/http"
@@ -30,7 +31,10 @@ type DiscoveryService struct {
 	Message          string
 	knownPeers       []string
 	OnPeerDiscovered func(peerRaftAddress string)
-	logger           *log.Logger
+	// Secret assina os anúncios; com ele, anúncios sem assinatura válida são ignorados,
+	// já que um par descoberto vira voter do cluster.
+	Secret []byte
+	logger *log.Logger
 }
 
 // NewDiscoveryService creates a new DiscoveryService with default intervals and message signature.
@@ -129,7 +133,11 @@ func (ds *DiscoveryService) listen() {
 		message := string(buf[:n])
 		// Extrai o endereço do Raft da mensagem, que é o payload
 		if len(message) > len(ds.Message) && message[:len(ds.Message)] == ds.Message {
-			peerRaftAddr := message[len(ds.Message):]
+			peerRaftAddr, ok := ds.verify(message[len(ds.Message):])
+			if !ok {
+				ds.logger.Warn("Anúncio de descoberta sem assinatura válida ignorado")
+				continue
+			}
 
 			// Não reagir às próprias mensagens
 			if peerRaftAddr == ds.raftAddress {
@@ -160,8 +168,11 @@ func (ds *DiscoveryService) broadcast() {
 	ticker := time.NewTicker(ds.Interval)
 	defer ticker.Stop()
 
-	// Mensagem a ser enviada = MagicString + nosso endereço Raft
+	// Mensagem a ser enviada = MagicString + nosso endereço Raft [+ "|" + assinatura]
 	message := ds.Message + ds.raftAddress
+	if len(ds.Secret) > 0 {
+		message += "|" + signature(ds.Secret, discoverySignatureContext, []byte(ds.raftAddress))
+	}
 
 	for {
 		<-ticker.C
@@ -173,3 +184,19 @@ func (ds *DiscoveryService) broadcast() {
 		}
 	}
 }
+
+// discoverySignatureContext separa as assinaturas de anúncios das de requisições HTTP.
+const discoverySignatureContext = "discovery"
+
+// verify separa o endereço Raft da assinatura do anúncio e a confere quando há Secret.
+func (ds *DiscoveryService) verify(payload string) (string, bool) {
+	if len(ds.Secret) == 0 {
+		return payload, true
+	}
+	addr, sig, ok := strings.Cut(payload, "|")
+	if !ok {
+		return "", false
+	}
+	expected := signature(ds.Secret, discoverySignatureContext, []byte(addr))
+	return addr, hmac.Equal([]byte(expected), []byte(sig))
+}
@@ -0,0 +1,207 @@
+package cluster
+
+import (
+	"cod-server/internal/api"
+	"cod-server/internal/api/mqtt"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+var (
+	// ErrServerBusy é retornado quando a fila de eventos do nó está cheia.
+	ErrServerBusy = errors.New("server busy")
+	// ErrRateLimited é retornado quando o remetente excedeu o limite do método.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// OrderingKeyFunc extrai a chave de ordenação de um evento.
+// Eventos com a mesma chave são processados na ordem de chegada; chave vazia não impõe ordem.
+type OrderingKeyFunc func(event api.Event) string
+
+// DefaultOrderingKey ordena por usuário: jogadas, compras e trocas de um mesmo usuário
+// nunca são processadas fora de ordem.
+func DefaultOrderingKey(event api.Event) string {
+	for _, field := range []string{"user_id", "from_user_id"} {
+		if userID, ok := event.Payload[field].(string); ok && userID != "" {
+			return userID
+		}
+	}
+	return ""
+}
+
+// DispatcherConfig define o tamanho do pool e o limite da fila de eventos.
+type DispatcherConfig struct {
+	Workers   int             // Número de workers processando eventos em paralelo
+	QueueSize int             // Eventos pendentes admitidos no nó, somando todos os workers
+	KeyFunc   OrderingKeyFunc // Chave de ordenação; nil usa DefaultOrderingKey
+}
+
+// DefaultDispatcherConfig retorna valores conservadores para um nó do cluster.
+func DefaultDispatcherConfig() DispatcherConfig {
+	return DispatcherConfig{
+		Workers:   8,
+		QueueSize: 1024,
+		KeyFunc:   DefaultOrderingKey,
+	}
+}
+
+// EventDispatcher desacopla o recebimento de eventos MQTT do consenso Raft.
+// Eventos são distribuídos entre os workers por hash da chave de ordenação, o que preserva
+// a ordem por chave sem serializar o nó inteiro. O limite de pendentes é do nó, não de cada
+// worker: uma chave muito ativa pode ocupar a fila toda, mas só recebe "server busy" quando
+// o nó inteiro está cheio. Conter um único usuário é papel do RateLimiter.
+type EventDispatcher struct {
+	coordinator CoordinatorInterface
+	mqttAdapter mqtt.MQTTAdapterInterface // Para responder "server busy" ao cliente
+	keyFunc     OrderingKeyFunc
+	rateLimiter *api.RateLimiter // Opcional; aplicado antes de enfileirar
+	queues      []chan api.Event
+	capacity    int64
+	pending     atomic.Int64  // Admitidos e ainda não processados, em todas as filas
+	next        atomic.Uint64 // Round-robin para eventos sem chave
+	wg          sync.WaitGroup
+	mu          sync.RWMutex // Protege o fechamento das filas contra Submit concorrente
+	stopped     bool
+	logger      *log.Logger
+
+	processed atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+// NewEventDispatcher cria o dispatcher; os workers só começam a consumir após Start.
+func NewEventDispatcher(coordinator CoordinatorInterface, mqttAdapter mqtt.MQTTAdapterInterface, config DispatcherConfig) *EventDispatcher {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.QueueSize < config.Workers {
+		config.QueueSize = config.Workers
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = DefaultOrderingKey
+	}
+
+	// Cada fila comporta o limite inteiro; quem barra a admissão é o contador pending
+	queues := make([]chan api.Event, config.Workers)
+	for i := range queues {
+		queues[i] = make(chan api.Event, config.QueueSize)
+	}
+
+	return &EventDispatcher{
+		coordinator: coordinator,
+		mqttAdapter: mqttAdapter,
+		keyFunc:     config.KeyFunc,
+		queues:      queues,
+		capacity:    int64(config.QueueSize),
+		logger:      log.With("component", "dispatcher"),
+	}
+}
+
+// SetRateLimiter ativa a limitação de taxa na admissão de eventos.
+func (d *EventDispatcher) SetRateLimiter(limiter *api.RateLimiter) {
+	d.rateLimiter = limiter
+}
+
+// Start inicia um worker por fila.
+func (d *EventDispatcher) Start() {
+	for i, queue := range d.queues {
+		d.wg.Add(1)
+		go d.work(i, queue)
+	}
+}
+
+// Stop fecha as filas e aguarda os workers drenarem os eventos pendentes.
+func (d *EventDispatcher) Stop() {
+	d.mu.Lock()
+	if !d.stopped {
+		d.stopped = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// Submit enfileira o evento sem bloquear. Com a fila cheia o evento é descartado,
+// o cliente recebe uma resposta "server busy" e ErrServerBusy é retornado.
+func (d *EventDispatcher) Submit(event api.Event) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.stopped {
+		return ErrServerBusy
+	}
+
+	if d.rateLimiter != nil {
+		if ok, retryAfter := d.rateLimiter.Allow(event); !ok {
+			d.reject(event, "rate_limited", "too many requests", retryAfter)
+			return ErrRateLimited
+		}
+	}
+
+	if d.pending.Add(1) > d.capacity {
+		d.pending.Add(-1)
+		d.rejected.Add(1)
+		d.logger.Warnf("Fila cheia, descartando evento %s", event.Method)
+		d.reject(event, "server_busy", "server busy, try again later", 0)
+		return ErrServerBusy
+	}
+	// Não bloqueia: pending <= capacity garante espaço em qualquer fila
+	d.queues[d.shard(event)] <- event
+	return nil
+}
+
+// Stats retorna contadores e ocupação atual das filas.
+func (d *EventDispatcher) Stats() map[string]any {
+	return map[string]any{
+		"workers":   len(d.queues),
+		"pending":   d.pending.Load(),
+		"capacity":  d.capacity,
+		"processed": d.processed.Load(),
+		"rejected":  d.rejected.Load(),
+	}
+}
+
+// shard escolhe a fila do evento: hash da chave de ordenação ou round-robin.
+func (d *EventDispatcher) shard(event api.Event) int {
+	key := d.keyFunc(event)
+	if key == "" {
+		return int(d.next.Add(1) % uint64(len(d.queues)))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+func (d *EventDispatcher) work(id int, queue <-chan api.Event) {
+	defer d.wg.Done()
+	for event := range queue {
+		if err := d.coordinator.Handle(event); err != nil {
+			d.logger.Errorf("Worker %d: erro ao processar evento %s: %v", id, event.Method, err)
+		}
+		d.pending.Add(-1)
+		d.processed.Add(1)
+	}
+}
+
+// reject responde ao cliente com um evento de erro estruturado quando o evento não é admitido.
+func (d *EventDispatcher) reject(event api.Event, code, message string, retryAfter time.Duration) {
+	if d.mqttAdapter == nil {
+		return
+	}
+	replyTopic := ReplyTopic(event)
+	if replyTopic == "" {
+		return
+	}
+	reply := Correlate(event, api.NewErrorEvent(event.Method+"_fail", code, message))
+	if retryAfter > 0 {
+		reply.Payload["retry_after_ms"] = retryAfter.Milliseconds()
+	}
+	if err := d.mqttAdapter.Publish(replyTopic, reply); err != nil {
+		d.logger.Errorf("Falha ao publicar rejeição de evento: %v", err)
+	}
+}
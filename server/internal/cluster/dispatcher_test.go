@@ -0,0 +1,116 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"cod-server/internal/api"
+	shared_protocol "shared/protocol"
+)
+
+// recordingCoordinator guarda os eventos na ordem em que foram tratados. Com gate, cada
+// Handle espera o canal ser fechado, o que segura os workers.
+type recordingCoordinator struct {
+	mu     sync.Mutex
+	events []api.Event
+	gate   chan struct{}
+}
+
+func (c *recordingCoordinator) Handle(event api.Event) error {
+	if c.gate != nil {
+		<-c.gate
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+	return nil
+}
+
+func (c *recordingCoordinator) handled() []api.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]api.Event(nil), c.events...)
+}
+
+func userEvent(userID string, seq int) api.Event {
+	return api.Event{Event: shared_protocol.Event{Method: "play_card", Payload: map[string]any{"user_id": userID, "seq": seq}}}
+}
+
+func TestEventDispatcher_PreservesOrderPerUser(t *testing.T) {
+	coordinator := &recordingCoordinator{}
+	dispatcher := NewEventDispatcher(coordinator, nil, DispatcherConfig{Workers: 4, QueueSize: 1000})
+	dispatcher.Start()
+
+	users := []string{"alice", "bob", "carol", "dave", "erin"}
+	for seq := 0; seq < 100; seq++ {
+		for _, user := range users {
+			if err := dispatcher.Submit(userEvent(user, seq)); err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+		}
+	}
+	dispatcher.Stop()
+
+	last := make(map[string]int)
+	for _, event := range coordinator.handled() {
+		user, seq := event.Payload["user_id"].(string), event.Payload["seq"].(int)
+		if prev, ok := last[user]; ok && seq != prev+1 {
+			t.Fatalf("user %s: event %d handled after %d", user, seq, prev)
+		}
+		last[user] = seq
+	}
+	if len(coordinator.handled()) != 100*len(users) {
+		t.Errorf("handled %d events, want %d", len(coordinator.handled()), 100*len(users))
+	}
+}
+
+func TestEventDispatcher_BusyOnlyWhenNodeIsFull(t *testing.T) {
+	coordinator := &recordingCoordinator{gate: make(chan struct{})}
+	dispatcher := NewEventDispatcher(coordinator, nil, DispatcherConfig{Workers: 4, QueueSize: 8})
+	dispatcher.Start()
+
+	// Um único usuário usa o limite do nó inteiro, não só o da sua fila. Com o Handle
+	// bloqueado, os oito contam como pendentes até serem processados.
+	for seq := 0; seq < 8; seq++ {
+		if err := dispatcher.Submit(userEvent("hot", seq)); err != nil {
+			t.Fatalf("Submit %d: %v", seq, err)
+		}
+	}
+	if err := dispatcher.Submit(userEvent("other", 0)); !errors.Is(err, ErrServerBusy) {
+		t.Errorf("Submit over the limit: got %v, want ErrServerBusy", err)
+	}
+	if stats := dispatcher.Stats(); stats["rejected"].(uint64) != 1 || stats["pending"].(int64) != 8 {
+		t.Errorf("Stats: got %v", stats)
+	}
+
+	close(coordinator.gate)
+	dispatcher.Stop()
+	if got := len(coordinator.handled()); got != 8 {
+		t.Errorf("handled %d events, want 8", got)
+	}
+	if stats := dispatcher.Stats(); stats["pending"].(int64) != 0 || stats["processed"].(uint64) != 8 {
+		t.Errorf("Stats after Stop: got %v", stats)
+	}
+}
+
+func TestEventDispatcher_StopDrainsAndRejectsLateSubmits(t *testing.T) {
+	coordinator := &recordingCoordinator{}
+	dispatcher := NewEventDispatcher(coordinator, nil, DispatcherConfig{Workers: 2, QueueSize: 64})
+	for i := 0; i < 50; i++ {
+		if err := dispatcher.Submit(userEvent(fmt.Sprint("user-", i%3), i)); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	// Eventos enfileirados antes de Start também são processados até Stop retornar
+	dispatcher.Start()
+	dispatcher.Stop()
+	if got := len(coordinator.handled()); got != 50 {
+		t.Errorf("handled %d events, want 50", got)
+	}
+	if err := dispatcher.Submit(userEvent("late", 0)); !errors.Is(err, ErrServerBusy) {
+		t.Errorf("Submit after Stop: got %v, want ErrServerBusy", err)
+	}
+	dispatcher.Stop() // idempotente
+}
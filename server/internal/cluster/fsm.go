@@ -2,9 +2,11 @@ package cluster
 
 import (
 	"cod-server/internal/api"
+	"cod-server/internal/domain"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sync/atomic"
 
 	raft "github.com/hashicorp/raft"
 )
@@ -34,37 +36,131 @@ func (s *FSMSnapshot) Persist(sink raft.SnapshotSink) error {
 // Release é chamado quando o snapshot não é mais necessário.
 func (s *FSMSnapshot) Release() {}
 
+// AppliedIndexObserver recebe da FSM o índice de cada entrada aplicada e o do snapshot
+// restaurado (ex.: cache.AppliedIndex, que mantém os caches coerentes com o estado aplicado).
+type AppliedIndexObserver interface {
+	Advance(index uint64)
+	Restore(index uint64)
+}
+
+// ReplyObserver recebe da FSM cada evento aplicado com a resposta do manipulador (ex.: Sessions,
+// que acompanha logins e logouts). Roda em todas as réplicas, na ordem do log.
+type ReplyObserver interface {
+	Observe(event api.Event, reply any)
+}
+
+// CatalogStore guarda o catálogo de cartas aplicado (ex.: services.CatalogService). A FSM o
+// grava nos snapshots, para que uma réplica restaurada não dependa das entradas sync_catalog
+// já compactadas, e ao restaurar troca o catálogo pelo do snapshot, mesmo que seja nil.
+type CatalogStore interface {
+	Catalog() *domain.Catalog
+	Restore(catalog *domain.Catalog)
+}
+
+// StatePart é uma parte do estado aplicado: os repositórios ou o que fica fora deles (ex.: a
+// lista de revogação de tokens). A FSM a grava nos snapshots sob um nome e a substitui ao restaurar;
+// RestoreState recebe nil quando o snapshot não tem a parte e deve então esvaziá-la.
+type StatePart interface {
+	SnapshotState() ([]byte, error)
+	RestoreState(data []byte) error
+}
+
+// snapshotState é o conteúdo serializado do snapshot.
+type snapshotState struct {
+	AppliedIndex uint64                     `json:"applied_index"`
+	Catalog      *domain.Catalog            `json:"catalog,omitempty"`
+	State        map[string]json.RawMessage `json:"state,omitempty"`
+}
+
 // ClusterFSM é a FSM do Raft que converte logs comprometidos do Raft em ações do sistema.
 type ClusterFSM struct {
 	// eventHandler roteia eventos para manipuladores de lógica de negócios apropriados
 	eventHandler api.EventHandlerInterface
+	// appliedIndex é o índice da última entrada aplicada (ou do snapshot restaurado)
+	appliedIndex atomic.Uint64
+	observer     AppliedIndexObserver
+	replies      ReplyObserver
+	catalog      CatalogStore
+	parts        map[string]StatePart
 }
 
 // NewClusterFSM cria um novo ClusterFSM com injeção de dependência.
 func NewClusterFSM(handler api.EventHandlerInterface) *ClusterFSM {
 	return &ClusterFSM{
 		eventHandler: handler,
+		parts:        make(map[string]StatePart),
 	}
 }
 
+// SetAppliedIndexObserver registra quem acompanha o índice aplicado; chamar antes de iniciar o Raft.
+func (fsm *ClusterFSM) SetAppliedIndexObserver(observer AppliedIndexObserver) {
+	fsm.observer = observer
+}
+
+// SetReplyObserver registra quem acompanha as respostas aplicadas; chamar antes de iniciar o Raft.
+func (fsm *ClusterFSM) SetReplyObserver(observer ReplyObserver) {
+	fsm.replies = observer
+}
+
+// SetCatalogStore registra o catálogo incluído nos snapshots; chamar antes de iniciar o Raft.
+func (fsm *ClusterFSM) SetCatalogStore(store CatalogStore) {
+	fsm.catalog = store
+}
+
+// RegisterState inclui uma parte do estado nos snapshots; chamar antes de iniciar o Raft.
+func (fsm *ClusterFSM) RegisterState(name string, part StatePart) {
+	fsm.parts[name] = part
+}
+
+// AppliedIndex retorna o índice da última entrada aplicada nesta réplica.
+func (fsm *ClusterFSM) AppliedIndex() uint64 {
+	return fsm.appliedIndex.Load()
+}
+
 // Apply é chamado quando uma entrada de log é comprometida para convertê-la em ações do sistema.
+// O índice só avança depois que o manipulador terminou, para que quem espera por ele veja as escritas.
 func (fsm *ClusterFSM) Apply(log *raft.Log) interface{} {
+	defer fsm.advance(log.Index)
+
 	var event api.Event
 	if err := json.Unmarshal(log.Data, &event); err != nil {
 		return fmt.Errorf("failed to unmarshal log data: %w", err)
 	}
 
+	reply := fsm.apply(event)
+	if fsm.replies != nil {
+		fsm.replies.Observe(event, reply)
+	}
+	return reply
+}
+
+// apply entrega o evento ao manipulador do seu método e retorna a resposta.
+func (fsm *ClusterFSM) apply(event api.Event) interface{} {
 	switch event.Method {
 	case "register":
 		return fsm.eventHandler.OnRegister(event)
 	case "login":
 		return fsm.eventHandler.OnLogin(event)
+	case "refresh":
+		return fsm.eventHandler.OnRefresh(event)
+	case "logout":
+		return fsm.eventHandler.OnLogout(event)
+	case "change_password":
+		return fsm.eventHandler.OnChangePassword(event)
+	case "delete_account":
+		return fsm.eventHandler.OnDeleteAccount(event)
+	case "get_profile":
+		return fsm.eventHandler.OnGetProfile(event)
 	case "get_cards":
 		return fsm.eventHandler.OnGetCards(event)
 	case "buy_pack":
 		return fsm.eventHandler.OnBuyPack(event)
 	case "offer_trade":
 		return fsm.eventHandler.OnOfferTrade(event)
+	case "get_catalog":
+		return fsm.eventHandler.OnGetCatalog(event)
+	case "sync_catalog":
+		return fsm.eventHandler.OnSyncCatalog(event)
 	case "start_match":
 		return fsm.eventHandler.OnStartMatch(event)
 	case "join_match":
@@ -73,6 +169,20 @@ func (fsm *ClusterFSM) Apply(log *raft.Log) interface{} {
 		return fsm.eventHandler.OnSurrenderMatch(event)
 	case "make_move":
 		return fsm.eventHandler.OnMakeMove(event)
+	case "mute_user":
+		return fsm.eventHandler.OnMuteUser(event)
+	case "ban_user":
+		return fsm.eventHandler.OnBanUser(event)
+	case "unban_user":
+		return fsm.eventHandler.OnUnbanUser(event)
+	case "grant_cards":
+		return fsm.eventHandler.OnGrantCards(event)
+	case "cancel_match":
+		return fsm.eventHandler.OnCancelMatch(event)
+	case "set_roles":
+		return fsm.eventHandler.OnSetRoles(event)
+	case "bootstrap_admin":
+		return fsm.eventHandler.OnBootstrapAdmin(event)
 	default:
 		return fmt.Errorf("unhandled fsm event method: %s", event.Method)
 	}
@@ -80,23 +190,66 @@ func (fsm *ClusterFSM) Apply(log *raft.Log) interface{} {
 
 // Snapshot retorna uma cópia pontual do estado atual do sistema.
 func (fsm *ClusterFSM) Snapshot() (raft.FSMSnapshot, error) {
-
-	// TODO: Implementar serialização completa do estado dos repositórios para produção.
-	// Atualmente retorna um snapshot vazio como placeholder.
-	snapData := []byte("{}") // Placeholder para dados reais de estado
+	state := snapshotState{AppliedIndex: fsm.appliedIndex.Load()}
+	if fsm.catalog != nil {
+		state.Catalog = fsm.catalog.Catalog()
+	}
+	// Snapshot não roda junto com Apply, então cada parte é lida num ponto consistente do log
+	state.State = make(map[string]json.RawMessage, len(fsm.parts))
+	for name, part := range fsm.parts {
+		data, err := part.SnapshotState()
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", name, err)
+		}
+		state.State[name] = data
+	}
+	snapData, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
 
 	return &FSMSnapshot{data: snapData}, nil
 }
 
 // Restore reconstrói o estado da FSM a partir de um backup de snapshot.
 func (fsm *ClusterFSM) Restore(rc io.ReadCloser) error {
-	// Lê dados do snapshot; apenas implementação placeholder.
-	_, err := io.ReadAll(rc)
+	defer rc.Close()
+	raw, err := io.ReadAll(rc)
 	if err != nil {
 		return fmt.Errorf("failed to read snapshot data: %w", err)
 	}
+	var state snapshotState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot data: %w", err)
+	}
 
-	// TODO: Desserializar e repopular estado do repositório para produção.
+	// O catálogo é o do snapshot, como o resto do estado, ainda que a réplica tenha aplicado
+	// um mais novo que ficou fora dele
+	if fsm.catalog != nil {
+		if state.Catalog != nil {
+			if err := state.Catalog.Validate(); err != nil {
+				return fmt.Errorf("failed to restore card catalog: %w", err)
+			}
+		}
+		fsm.catalog.Restore(state.Catalog)
+	}
+	for name, part := range fsm.parts {
+		if err := part.RestoreState(state.State[name]); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
 
+	// O estado foi trocado de uma vez: tudo o que foi lido antes, como os caches, fica velho
+	fsm.appliedIndex.Store(state.AppliedIndex)
+	if fsm.observer != nil {
+		fsm.observer.Restore(state.AppliedIndex)
+	}
 	return nil
 }
+
+func (fsm *ClusterFSM) advance(index uint64) {
+	fsm.appliedIndex.Store(index)
+	if fsm.observer != nil {
+		fsm.observer.Advance(index)
+	}
+}
@@ -0,0 +1,144 @@
+package cluster
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"cod-server/internal/auth"
+	"cod-server/internal/data/cache"
+	"cod-server/internal/domain"
+	"cod-server/internal/services"
+
+	"github.com/hashicorp/raft"
+)
+
+// memorySink guarda em memória o que o snapshot persiste.
+type memorySink struct {
+	bytes.Buffer
+	cancelled bool
+}
+
+func (s *memorySink) ID() string    { return "test" }
+func (s *memorySink) Close() error  { return nil }
+func (s *memorySink) Cancel() error { s.cancelled = true; return nil }
+
+// snapshotBytes tira um snapshot da FSM e devolve o conteúdo persistido.
+func snapshotBytes(t *testing.T, fsm *ClusterFSM) []byte {
+	t.Helper()
+	snapshot, err := fsm.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	sink := &memorySink{}
+	if err := snapshot.Persist(sink); err != nil {
+		t.Fatalf("Persist: %v", err)
+	}
+	return sink.Bytes()
+}
+
+func TestClusterFSM_SnapshotRestoresRevocations(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	source := auth.NewRevocationList()
+	source.Revoke("jti-1", exp)
+	source.RevokeUser("alice-id")
+
+	fsm := NewClusterFSM(nil)
+	fsm.RegisterState("revocations", source)
+	data := snapshotBytes(t, fsm)
+
+	restored := auth.NewRevocationList()
+	restored.Revoke("only-on-this-replica", exp)
+	replica := NewClusterFSM(nil)
+	replica.RegisterState("revocations", restored)
+	if err := replica.Restore(io.NopCloser(bytes.NewReader(data))); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if !restored.IsRevoked("jti-1") {
+		t.Error("revoked jti lost after restore")
+	}
+	if !restored.IsStale("alice-id", 0) || restored.Generation("alice-id") != 1 {
+		t.Error("user token generation lost after restore")
+	}
+	if restored.IsRevoked("only-on-this-replica") {
+		t.Error("restore kept a revocation that is not in the snapshot")
+	}
+}
+
+func TestClusterFSM_RestoreWithoutPartResetsIt(t *testing.T) {
+	data := snapshotBytes(t, NewClusterFSM(nil))
+
+	list := auth.NewRevocationList()
+	list.Revoke("jti-1", time.Now().Add(time.Hour))
+	fsm := NewClusterFSM(nil)
+	fsm.RegisterState("revocations", list)
+	if err := fsm.Restore(io.NopCloser(bytes.NewReader(data))); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if list.IsRevoked("jti-1") {
+		t.Error("state absent from the snapshot was not reset")
+	}
+}
+
+func TestClusterFSM_TracksAppliedIndex(t *testing.T) {
+	index := cache.NewAppliedIndex()
+	fsm := NewClusterFSM(nil)
+	fsm.SetAppliedIndexObserver(index)
+
+	// Mesmo uma entrada que o handler recusa conta como aplicada
+	for i := uint64(1); i <= 3; i++ {
+		fsm.Apply(&raft.Log{Index: i, Data: []byte(`{"method":"unknown"}`)})
+	}
+	if fsm.AppliedIndex() != 3 || index.Index() != 3 {
+		t.Fatalf("applied index = %d (observer %d), want 3", fsm.AppliedIndex(), index.Index())
+	}
+	data := snapshotBytes(t, fsm)
+
+	var restoredAt []uint64
+	index.OnRestore(func(i uint64) { restoredAt = append(restoredAt, i) })
+	fsm.Apply(&raft.Log{Index: 7, Data: []byte(`{"method":"unknown"}`)})
+	if err := fsm.Restore(io.NopCloser(bytes.NewReader(data))); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if fsm.AppliedIndex() != 3 || index.Index() != 3 {
+		t.Errorf("applied index after Restore = %d (observer %d), want the snapshot's 3", fsm.AppliedIndex(), index.Index())
+	}
+	if len(restoredAt) != 1 || restoredAt[0] != 3 {
+		t.Errorf("restore listeners got %v, want [3]", restoredAt)
+	}
+}
+
+func TestClusterFSM_SnapshotRestoresCatalog(t *testing.T) {
+	template := domain.CardTemplate{ID: "rock-pebble", Name: "Pebble", Element: "rock", Power: 1, Rarity: domain.RarityCommon}
+	v1 := &domain.Catalog{Version: 1, Templates: []domain.CardTemplate{template}}
+	v2 := &domain.Catalog{Version: 2, Templates: []domain.CardTemplate{template, {ID: "paper-scroll", Name: "Scroll", Element: "paper", Power: 3, Rarity: domain.RarityRare}}}
+
+	source := NewClusterFSM(nil)
+	source.SetCatalogStore(services.NewCatalogService(v1))
+	withCatalog := snapshotBytes(t, source)
+	empty := NewClusterFSM(nil)
+	empty.SetCatalogStore(services.NewCatalogService(nil))
+	withoutCatalog := snapshotBytes(t, empty)
+
+	// O snapshot vale mesmo contra um catálogo mais novo aplicado depois dele
+	replica := services.NewCatalogService(v2)
+	fsm := NewClusterFSM(nil)
+	fsm.SetCatalogStore(replica)
+	if err := fsm.Restore(io.NopCloser(bytes.NewReader(withCatalog))); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := replica.Catalog(); got == nil || !reflect.DeepEqual(got, v1) {
+		t.Errorf("catalog after restore = %+v, want %+v", got, v1)
+	}
+
+	// Snapshot de antes do primeiro sync_catalog: a réplica fica sem catálogo
+	if err := fsm.Restore(io.NopCloser(bytes.NewReader(withoutCatalog))); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := replica.Catalog(); got != nil {
+		t.Errorf("catalog after restoring a snapshot without one = %+v, want nil", got)
+	}
+}
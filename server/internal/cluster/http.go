@@ -2,9 +2,12 @@ package cluster
 
 import (
 	"bytes"
+	"cod-server/internal/api"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -22,10 +25,29 @@ type GinHttpTransport struct {
 	raftNode    *raft.Raft
 	timeout     time.Duration
 	logger      *log.Logger
+	secret      []byte // Cluster secret that signs /raft requests; empty only in dev mode
+
+	statusMu       sync.RWMutex // Protects health checks and metrics sources
+	healthChecks   map[string]HealthCheck
+	metricsSources map[string]MetricsSource
+}
+
+// HTTPTransportConfig groups the options of the inter-node HTTP transport.
+type HTTPTransportConfig struct {
+	BindAddress string
+	NodeID      string
+	// ClusterSecret signs and verifies /raft/join and /raft/command. Without it those routes
+	// accept any caller, which is only acceptable in development.
+	ClusterSecret []byte
 }
 
-// NewGinHttpTransport constructs the HTTP transport, sets up routes and logging.
+// NewGinHttpTransport constructs the HTTP transport without a cluster secret (development only).
 func NewGinHttpTransport(bindAddress, nodeID string, raftNode *raft.Raft) ClusterTransportInterface {
+	return NewGinHttpTransportWithConfig(HTTPTransportConfig{BindAddress: bindAddress, NodeID: nodeID}, raftNode)
+}
+
+// NewGinHttpTransportWithConfig constructs the HTTP transport, sets up routes and logging.
+func NewGinHttpTransportWithConfig(config HTTPTransportConfig, raftNode *raft.Raft) ClusterTransportInterface {
 	logger := log.With("component", "http-transport")
 
 	gin.SetMode(gin.ReleaseMode)
@@ -37,14 +59,24 @@ func NewGinHttpTransport(bindAddress, nodeID string, raftNode *raft.Raft) Cluste
 	router.Use(LoggerMiddleware(logger))
 
 	transport := &GinHttpTransport{
-		bindAddress: bindAddress,
-		nodeID:      nodeID,
+		bindAddress: config.BindAddress,
+		nodeID:      config.NodeID,
 		router:      router,
 		client:      resty.New(),
 		raftNode:    raftNode,
 		timeout:     10 * time.Second,
 		logger:      logger,
+		secret:      config.ClusterSecret,
+
+		healthChecks:   make(map[string]HealthCheck),
+		metricsSources: make(map[string]MetricsSource),
 	}
+	transport.RegisterHealthCheck("raft", func() error {
+		if leader := raftNode.Leader(); leader == "" {
+			return fmt.Errorf("no known leader (state: %s)", raftNode.State())
+		}
+		return nil
+	})
 	transport.setupRoutes()
 	return transport
 }
@@ -100,8 +132,23 @@ func LoggerMiddleware(logger *log.Logger) gin.HandlerFunc {
 
 func (t *GinHttpTransport) setupRoutes() {
 	group := t.router.Group("/raft")
+	if len(t.secret) > 0 {
+		group.Use(PeerAuthMiddleware(t.secret))
+	} else {
+		t.logger.Warn("Rotas /raft sem segredo do cluster: qualquer um na rede pode aplicar comandos")
+	}
 	group.POST("/join", t.handleJoin)
 	group.POST("/command", t.handleCommand)
+
+	t.router.GET("/health", t.handleHealth)
+	t.router.GET("/metrics", t.handleMetrics)
+}
+
+// RegisterHealthCheck adds or replaces a named check reported by GET /health.
+func (t *GinHttpTransport) RegisterHealthCheck(name string, check HealthCheck) {
+	t.statusMu.Lock()
+	defer t.statusMu.Unlock()
+	t.healthChecks[name] = check
 }
 
 // Start launches the Gin HTTP server asynchronously in the background.
@@ -122,9 +169,13 @@ func (t *GinHttpTransport) JoinCluster(targetAddress string, myRaftID string, my
 		NodeAddress: myRaftAddress,
 	}
 
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("falha ao serializar requisição de join: %w", err)
+	}
+
 	t.logger.Infof("Enviando requisição de join para %s", targetAddress)
-	resp, err := t.client.R().
-		SetBody(req).
+	resp, err := t.peerRequest(body).
 		Post(fmt.Sprintf("http://%s/raft/join", targetAddress))
 
 	if err != nil {
@@ -141,9 +192,7 @@ func (t *GinHttpTransport) JoinCluster(targetAddress string, myRaftID string, my
 // ForwardCommand forwards a serialized event to the cluster leader for application.
 func (t *GinHttpTransport) ForwardCommand(leaderAddress string, eventBytes []byte) error {
 	t.logger.Debugf("Encaminhando comando para o líder em %s", leaderAddress)
-	resp, err := t.client.R().
-		SetBody(bytes.NewReader(eventBytes)).
-		SetHeader("Content-Type", "application/json").
+	resp, err := t.peerRequest(eventBytes).
 		Post(fmt.Sprintf("http://%s/raft/command", leaderAddress))
 
 	if err != nil {
@@ -155,6 +204,36 @@ func (t *GinHttpTransport) ForwardCommand(leaderAddress string, eventBytes []byt
 	return nil
 }
 
+// SendCommand posts a signed event to /raft/command on the node at address and returns the
+// FSM reply. Maintenance subcommands (e.g. bootstrap-admin) use it to propose log entries from
+// outside the cluster; the node must be the leader.
+func SendCommand(address string, secret []byte, event api.Event) (*api.Event, error) {
+	body, err := event.Json()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao serializar comando: %w", err)
+	}
+	client := &GinHttpTransport{client: resty.New(), secret: secret}
+	resp, err := client.peerRequest(body).Post(fmt.Sprintf("http://%s/raft/command", address))
+	if err != nil {
+		return nil, fmt.Errorf("falha ao enviar comando para %s: %w", address, err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("erro do nó ao processar comando. status: %s, body: %s", resp.Status(), resp.String())
+	}
+	return api.FromJson(resp.Body())
+}
+
+// peerRequest prepares a JSON request to another node, signed with the cluster secret.
+func (t *GinHttpTransport) peerRequest(body []byte) *resty.Request {
+	req := t.client.R().
+		SetBody(bytes.NewReader(body)).
+		SetHeader("Content-Type", "application/json")
+	if len(t.secret) > 0 {
+		req.SetHeaders(signHeaders(t.secret, body, time.Now()))
+	}
+	return req
+}
+
 // Gin HTTP handlers (internal)
 func (t *GinHttpTransport) handleJoin(c *gin.Context) {
 	var req JoinRequest
@@ -183,9 +262,8 @@ func (t *GinHttpTransport) handleJoin(c *gin.Context) {
 }
 
 func (t *GinHttpTransport) handleCommand(c *gin.Context) {
-	if t.raftNode.State() != raft.Leader {
+	if !t.requireLeader(c) {
 		t.logger.Warn("Recebido comando para aplicar, mas não sou o líder")
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "não sou o líder"})
 		return
 	}
 
@@ -214,3 +292,49 @@ func (t *GinHttpTransport) handleCommand(c *gin.Context) {
 
 	c.JSON(http.StatusOK, res)
 }
+
+// RegisterMetrics adds or replaces a named metrics source reported by GET /metrics.
+func (t *GinHttpTransport) RegisterMetrics(name string, source MetricsSource) {
+	t.statusMu.Lock()
+	defer t.statusMu.Unlock()
+	t.metricsSources[name] = source
+}
+
+// handleHealth runs every registered check; any failure turns the response into 503.
+func (t *GinHttpTransport) handleHealth(c *gin.Context) {
+	t.statusMu.RLock()
+	checks := make(map[string]HealthCheck, len(t.healthChecks))
+	for name, check := range t.healthChecks {
+		checks[name] = check
+	}
+	t.statusMu.RUnlock()
+
+	status := http.StatusOK
+	results := gin.H{}
+	for name, check := range checks {
+		if err := check(); err != nil {
+			status = http.StatusServiceUnavailable
+			results[name] = gin.H{"status": "down", "error": err.Error()}
+			continue
+		}
+		results[name] = gin.H{"status": "up"}
+	}
+
+	c.JSON(status, gin.H{"node_id": t.nodeID, "checks": results})
+}
+
+// handleMetrics collects a snapshot from every registered metrics source.
+func (t *GinHttpTransport) handleMetrics(c *gin.Context) {
+	t.statusMu.RLock()
+	sources := make(map[string]MetricsSource, len(t.metricsSources))
+	for name, source := range t.metricsSources {
+		sources[name] = source
+	}
+	t.statusMu.RUnlock()
+
+	metrics := gin.H{}
+	for name, source := range sources {
+		metrics[name] = source()
+	}
+	c.JSON(http.StatusOK, gin.H{"node_id": t.nodeID, "metrics": metrics})
+}
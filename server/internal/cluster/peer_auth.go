@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Requisições entre nós (/raft/join, /raft/command) são assinadas com o segredo do cluster:
+// HMAC-SHA256 de "<timestamp>\n<corpo>", enviado em SignatureHeader junto do timestamp.
+const (
+	SignatureHeader          = "X-Cod-Signature"
+	SignatureTimestampHeader = "X-Cod-Timestamp"
+
+	// MinClusterSecretLength é o tamanho mínimo do segredo, o mesmo exigido das chaves HMAC do JWT.
+	MinClusterSecretLength = 32
+	// signatureMaxSkew limita a diferença de relógio aceita e por quanto tempo uma
+	// requisição capturada poderia ser reapresentada.
+	signatureMaxSkew = 30 * time.Second
+)
+
+// ErrInvalidSignature indica requisição entre nós sem assinatura válida do segredo do cluster.
+var ErrInvalidSignature = errors.New("invalid cluster signature")
+
+// signature calcula a assinatura de body no instante timestamp (segundos Unix, em texto).
+func signature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signHeaders retorna os cabeçalhos que autenticam body perante os outros nós.
+func signHeaders(secret []byte, body []byte, now time.Time) map[string]string {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	return map[string]string{
+		SignatureTimestampHeader: timestamp,
+		SignatureHeader:          signature(secret, timestamp, body),
+	}
+}
+
+// verifySignature confere a assinatura e se o timestamp está dentro da janela aceita.
+func verifySignature(secret []byte, timestamp, sig string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > signatureMaxSkew || skew < -signatureMaxSkew {
+		return ErrInvalidSignature
+	}
+	expected := signature(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// PeerAuthMiddleware recusa com 401 requisições sem assinatura válida do segredo do cluster.
+// O corpo é lido para a verificação e devolvido ao request para o handler.
+func PeerAuthMiddleware(secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "falha ao ler corpo da requisição: " + err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		timestamp := c.GetHeader(SignatureTimestampHeader)
+		sig := c.GetHeader(SignatureHeader)
+		if err := verifySignature(secret, timestamp, sig, body, time.Now()); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
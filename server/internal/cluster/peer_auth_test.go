@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testClusterSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"method":"sync_catalog"}`)
+	headers := signHeaders(testClusterSecret, body, now)
+	timestamp, sig := headers[SignatureTimestampHeader], headers[SignatureHeader]
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		timestamp string
+		body      []byte
+		at        time.Time
+		wantErr   bool
+	}{
+		{"valid", testClusterSecret, timestamp, body, now, false},
+		{"within skew", testClusterSecret, timestamp, body, now.Add(signatureMaxSkew), false},
+		{"tampered body", testClusterSecret, timestamp, []byte(`{"method":"set_roles"}`), now, true},
+		{"other secret", []byte("fedcba9876543210fedcba9876543210"), timestamp, body, now, true},
+		{"replayed too late", testClusterSecret, timestamp, body, now.Add(signatureMaxSkew + time.Second), true},
+		{"timestamp changed", testClusterSecret, strconv.FormatInt(now.Unix()+1, 10), body, now, true},
+		{"missing timestamp", testClusterSecret, "", body, now, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.secret, tt.timestamp, sig, tt.body, tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifySignature error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPeerAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var received []byte
+	router.POST("/raft/command", PeerAuthMiddleware(testClusterSecret), func(c *gin.Context) {
+		received, _ = c.GetRawData()
+		c.Status(http.StatusOK)
+	})
+
+	body := []byte(`{"method":"register","payload":{"username":"alice"}}`)
+	send := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/raft/command", bytes.NewReader(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned request: status %d, want 401", rec.Code)
+	}
+	if received != nil {
+		t.Fatal("handler ran for an unsigned request")
+	}
+	if rec := send(signHeaders(testClusterSecret, body, time.Now())); rec.Code != http.StatusOK {
+		t.Errorf("signed request: status %d, want 200", rec.Code)
+	}
+	if !bytes.Equal(received, body) {
+		t.Errorf("handler body = %q, want %q", received, body)
+	}
+}
+
+func TestDiscoveryService_Verify(t *testing.T) {
+	signed := NewDiscoveryService("10.0.0.1:10000", "10.0.0.1:8080")
+	signed.Secret = testClusterSecret
+	sig := signature(testClusterSecret, discoverySignatureContext, []byte("10.0.0.2:10000"))
+
+	if addr, ok := signed.verify("10.0.0.2:10000|" + sig); !ok || addr != "10.0.0.2:10000" {
+		t.Errorf("signed beacon: got %q, %v", addr, ok)
+	}
+	for _, payload := range []string{"10.0.0.2:10000", "10.0.0.3:10000|" + sig} {
+		if _, ok := signed.verify(payload); ok {
+			t.Errorf("beacon %q accepted", payload)
+		}
+	}
+
+	open := NewDiscoveryService("10.0.0.1:10000", "10.0.0.1:8080")
+	if addr, ok := open.verify("10.0.0.2:10000"); !ok || addr != "10.0.0.2:10000" {
+		t.Errorf("beacon without secret: got %q, %v", addr, ok)
+	}
+}
@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"cod-server/internal/api"
+	"encoding/json"
+	"sync"
+)
+
+// MaxClientsPerUser limita os clientes lembrados por usuário; o mais antigo sai primeiro.
+const MaxClientsPerUser = 8
+
+// Sessions liga cada usuário aos client ids em que ele está logado, para que as notificações
+// saiam no tópico privado replies/{client_id}/... desses clientes, que o ACL do broker protege.
+//
+// Toda réplica a atualiza com as respostas das entradas que aplica (Observe) e ela entra nos
+// snapshots da FSM, então o líder que assumir conhece os mesmos clientes. Um client id passa
+// a um usuário no login ou refresh e sai no logout; troca de senha, mudança de papéis e
+// exclusão da conta, que revogam os tokens do usuário, desligam todos os seus clientes.
+type Sessions struct {
+	mu      sync.RWMutex
+	clients map[string][]string // usuário -> client ids, do mais antigo ao mais recente
+	owners  map[string]string   // client id -> usuário
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{
+		clients: make(map[string][]string),
+		owners:  make(map[string]string),
+	}
+}
+
+// Clients retorna os client ids em que o usuário está logado.
+func (s *Sessions) Clients(userID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.clients[userID]...)
+}
+
+// Observe atualiza as ligações com a resposta que a FSM deu a um evento aplicado.
+func (s *Sessions) Observe(event api.Event, reply any) {
+	response, ok := reply.(api.Event)
+	if !ok || response.Payload == nil {
+		return
+	}
+	clientID, _ := event.Payload["client_id"].(string)
+	userID, _ := response.Payload["user_id"].(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch response.Method {
+	case "login_ok", "refresh_ok":
+		s.bind(userID, clientID)
+	case "logout_ok":
+		s.unbind(clientID)
+	case "change_password_ok":
+		// Só o cliente que trocou a senha recebeu tokens novos
+		s.drop(userID)
+		s.bind(userID, clientID)
+	case "delete_account_ok":
+		s.drop(userID)
+	case "set_roles_ok":
+		targetID, _ := response.Payload["target_user_id"].(string)
+		s.drop(targetID)
+	}
+}
+
+// bind liga o cliente ao usuário, desligando-o de quem o usava antes.
+func (s *Sessions) bind(userID, clientID string) {
+	if userID == "" || clientID == "" {
+		return
+	}
+	s.unbind(clientID)
+	clients := append(s.clients[userID], clientID)
+	if len(clients) > MaxClientsPerUser {
+		for _, evicted := range clients[:len(clients)-MaxClientsPerUser] {
+			delete(s.owners, evicted)
+		}
+		clients = append([]string(nil), clients[len(clients)-MaxClientsPerUser:]...)
+	}
+	s.clients[userID] = clients
+	s.owners[clientID] = userID
+}
+
+func (s *Sessions) unbind(clientID string) {
+	userID, ok := s.owners[clientID]
+	if !ok {
+		return
+	}
+	delete(s.owners, clientID)
+	clients := s.clients[userID]
+	for i, id := range clients {
+		if id == clientID {
+			clients = append(clients[:i:i], clients[i+1:]...)
+			break
+		}
+	}
+	if len(clients) == 0 {
+		delete(s.clients, userID)
+		return
+	}
+	s.clients[userID] = clients
+}
+
+func (s *Sessions) drop(userID string) {
+	for _, clientID := range s.clients[userID] {
+		delete(s.owners, clientID)
+	}
+	delete(s.clients, userID)
+}
+
+// SnapshotState serializa os clientes de cada usuário.
+func (s *Sessions) SnapshotState() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.Marshal(s.clients)
+}
+
+// RestoreState substitui as ligações pelas do snapshot; nil as esvazia.
+func (s *Sessions) RestoreState(data []byte) error {
+	clients := make(map[string][]string)
+	if data != nil {
+		if err := json.Unmarshal(data, &clients); err != nil {
+			return err
+		}
+	}
+	owners := make(map[string]string)
+	for userID, ids := range clients {
+		for _, clientID := range ids {
+			owners[clientID] = userID
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients = clients
+	s.owners = owners
+	return nil
+}
@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"cod-server/internal/api"
+	shared_protocol "shared/protocol"
+)
+
+// observe aplica em sessions a resposta method, com payload, a um evento do cliente clientID.
+func observe(sessions *Sessions, clientID, method string, payload map[string]any) {
+	event := api.Event{Event: shared_protocol.Event{Payload: map[string]any{"client_id": clientID}}}
+	sessions.Observe(event, api.Event{Event: shared_protocol.Event{Method: method, Payload: payload}})
+}
+
+func TestSessions_TracksLoggedInClients(t *testing.T) {
+	sessions := NewSessions()
+	observe(sessions, "c1", "login_ok", map[string]any{"user_id": "alice-id"})
+	observe(sessions, "c2", "refresh_ok", map[string]any{"user_id": "alice-id"})
+	observe(sessions, "c3", "login_fail", map[string]any{"user_id": "alice-id"})
+	if got := sessions.Clients("alice-id"); !reflect.DeepEqual(got, []string{"c1", "c2"}) {
+		t.Fatalf("clients after login = %v, want [c1 c2]", got)
+	}
+
+	// Outro usuário no mesmo cliente: alice deixa de ser notificada nele
+	observe(sessions, "c2", "login_ok", map[string]any{"user_id": "bob-id"})
+	observe(sessions, "c1", "logout_ok", map[string]any{"user_id": "alice-id"})
+	if got := sessions.Clients("alice-id"); len(got) != 0 {
+		t.Errorf("alice clients = %v, want none", got)
+	}
+	if got := sessions.Clients("bob-id"); !reflect.DeepEqual(got, []string{"c2"}) {
+		t.Errorf("bob clients = %v, want [c2]", got)
+	}
+
+	observe(sessions, "c4", "login_ok", map[string]any{"user_id": "bob-id"})
+	observe(sessions, "admin", "set_roles_ok", map[string]any{"target_user_id": "bob-id", "roles": []string{"player"}})
+	if got := sessions.Clients("bob-id"); len(got) != 0 {
+		t.Errorf("bob clients after set_roles = %v, want none", got)
+	}
+
+	observe(sessions, "c5", "login_ok", map[string]any{"user_id": "carol-id"})
+	observe(sessions, "c6", "change_password_ok", map[string]any{"user_id": "carol-id"})
+	if got := sessions.Clients("carol-id"); !reflect.DeepEqual(got, []string{"c6"}) {
+		t.Errorf("carol clients after change_password = %v, want [c6]", got)
+	}
+
+	for i := 0; i <= MaxClientsPerUser; i++ {
+		observe(sessions, fmt.Sprintf("d%d", i), "login_ok", map[string]any{"user_id": "dave-id"})
+	}
+	if got := sessions.Clients("dave-id"); len(got) != MaxClientsPerUser || got[0] != "d1" {
+		t.Errorf("dave clients = %v, want the last %d", got, MaxClientsPerUser)
+	}
+}
+
+func TestClusterFSM_SnapshotRestoresSessions(t *testing.T) {
+	source := NewSessions()
+	observe(source, "c1", "login_ok", map[string]any{"user_id": "alice-id"})
+	fsm := NewClusterFSM(nil)
+	fsm.RegisterState("sessions", source)
+	data := snapshotBytes(t, fsm)
+
+	restored := NewSessions()
+	observe(restored, "c2", "login_ok", map[string]any{"user_id": "bob-id"})
+	replica := NewClusterFSM(nil)
+	replica.RegisterState("sessions", restored)
+	if err := replica.Restore(io.NopCloser(bytes.NewReader(data))); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if got := restored.Clients("alice-id"); !reflect.DeepEqual(got, []string{"c1"}) {
+		t.Errorf("alice clients after restore = %v, want [c1]", got)
+	}
+	if got := restored.Clients("bob-id"); len(got) != 0 {
+		t.Errorf("restore kept a client that is not in the snapshot: %v", got)
+	}
+	// As ligações restauradas continuam valendo para o logout
+	observe(restored, "c1", "logout_ok", map[string]any{"user_id": "alice-id"})
+	if got := restored.Clients("alice-id"); len(got) != 0 {
+		t.Errorf("alice clients after logout = %v, want none", got)
+	}
+}
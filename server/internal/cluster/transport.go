@@ -1,5 +1,7 @@
 package cluster
 
+import "cod-server/internal/auth"
+
 // DTOs (Data Transfer Objects) used for JSON communication
 // -------------------------------------------------
 
@@ -27,4 +29,19 @@ type ClusterTransportInterface interface {
 
 	// ForwardCommand forwards an event to the cluster leader for application
 	ForwardCommand(leaderAddress string, eventBytes []byte) error
+
+	// RegisterHealthCheck adds a named check reported by the /health endpoint
+	RegisterHealthCheck(name string, check HealthCheck)
+
+	// RegisterMetrics adds a named metrics source reported by the /metrics endpoint
+	RegisterMetrics(name string, source MetricsSource)
+
+	// EnableAdminRoutes exposes /admin cluster management and catalog reload, restricted by JWT role
+	EnableAdminRoutes(authService *auth.AuthService, policy *auth.Policy, catalogSync *CatalogSync)
 }
+
+// HealthCheck returns nil when the checked component is healthy
+type HealthCheck func() error
+
+// MetricsSource returns a point-in-time snapshot of a component's counters
+type MetricsSource func() map[string]any
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// AppliedIndex acompanha o último índice do log do Raft aplicado nesta réplica. A FSM avança o
+// índice depois de cada entrada e o redefine ao restaurar um snapshot; os repositórios em cache
+// usam o índice como versão dos itens e se esvaziam na restauração, e leituras podem esperar
+// que a réplica alcance o índice de uma escrita já confirmada.
+type AppliedIndex struct {
+	mutex     sync.Mutex
+	index     uint64
+	advanced  chan struct{} // Fechado e trocado a cada mudança do índice
+	onRestore []func(index uint64)
+}
+
+func NewAppliedIndex() *AppliedIndex {
+	return &AppliedIndex{advanced: make(chan struct{})}
+}
+
+// Index retorna o último índice aplicado.
+func (a *AppliedIndex) Index() uint64 {
+	if a == nil {
+		return 0
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.index
+}
+
+// Advance registra que a entrada index já foi aplicada; índices menores são ignorados.
+func (a *AppliedIndex) Advance(index uint64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if index > a.index {
+		a.set(index)
+	}
+}
+
+// Restore redefine o índice para o do snapshot restaurado e avisa os interessados, que devem
+// descartar tudo o que leram antes: o estado por trás deles foi trocado de uma vez.
+func (a *AppliedIndex) Restore(index uint64) {
+	a.mutex.Lock()
+	a.set(index)
+	listeners := append([]func(uint64){}, a.onRestore...)
+	a.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(index)
+	}
+}
+
+// OnRestore registra uma função chamada a cada Restore.
+func (a *AppliedIndex) OnRestore(listener func(index uint64)) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.onRestore = append(a.onRestore, listener)
+}
+
+// WaitFor bloqueia até o índice aplicado alcançar index ou ctx terminar.
+func (a *AppliedIndex) WaitFor(ctx context.Context, index uint64) error {
+	for {
+		a.mutex.Lock()
+		reached, advanced := a.index >= index, a.advanced
+		a.mutex.Unlock()
+		if reached {
+			return nil
+		}
+
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// set troca o índice e acorda quem espera; chamado com mutex travado.
+func (a *AppliedIndex) set(index uint64) {
+	a.index = index
+	close(a.advanced)
+	a.advanced = make(chan struct{})
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+func TestAppliedIndex_WaitFor(t *testing.T) {
+	index := NewAppliedIndex()
+	index.Advance(3)
+	if err := index.WaitFor(context.Background(), 3); err != nil {
+		t.Fatalf("WaitFor an applied index: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- index.WaitFor(context.Background(), 5) }()
+	index.Advance(4)
+	select {
+	case err := <-done:
+		t.Fatalf("WaitFor(5) returned at index 4: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	index.Advance(6)
+	if err := <-done; err != nil {
+		t.Fatalf("WaitFor(5) after reaching 6: %v", err)
+	}
+
+	// Índices menores são ignorados
+	index.Advance(2)
+	if got := index.Index(); got != 6 {
+		t.Errorf("Index after a smaller Advance = %d, want 6", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := index.WaitFor(ctx, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitFor past the deadline: got %v, want DeadlineExceeded", err)
+	}
+
+	var nilIndex *AppliedIndex
+	if nilIndex.Index() != 0 {
+		t.Error("nil AppliedIndex must report 0")
+	}
+}
+
+func TestAppliedIndex_RestoreNotifiesAndWakesWaiters(t *testing.T) {
+	index := NewAppliedIndex()
+	index.Advance(10)
+
+	var restored []uint64
+	index.OnRestore(func(i uint64) { restored = append(restored, i) })
+
+	done := make(chan error)
+	go func() { done <- index.WaitFor(context.Background(), 20) }()
+	index.Restore(25)
+	if err := <-done; err != nil {
+		t.Fatalf("WaitFor(20) after restoring index 25: %v", err)
+	}
+
+	// Restore pode voltar o índice: o snapshot é o estado, não um avanço
+	index.Restore(4)
+	if got := index.Index(); got != 4 {
+		t.Errorf("Index after Restore(4) = %d, want 4", got)
+	}
+	if len(restored) != 2 || restored[0] != 25 || restored[1] != 4 {
+		t.Errorf("OnRestore got %v, want [25 4]", restored)
+	}
+}
+
+func TestAppliedIndex_RestoreResetsCachedRepositories(t *testing.T) {
+	index := NewAppliedIndex()
+	base := data.NewMemoryRepository[domain.CardInterface]()
+	repo := NewCachedRepository[domain.CardInterface](base, Config{Index: index})
+	defer repo.(interface{ Close() error }).Close()
+
+	index.Advance(3)
+	if err := repo.Create("c1", &domain.Card{ID: "c1", OwnerID: "alice", Type: "fire"}); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := repo.Count(data.NewQuery()); count != 1 {
+		t.Fatalf("Count = %d, want 1", count)
+	}
+
+	// Uma carga iniciada antes da restauração traz o estado antigo
+	started, release := make(chan struct{}), make(chan struct{})
+	inFlight := make(chan struct{})
+	go func() {
+		defer close(inFlight)
+		repo.(*CachedRepository[domain.CardInterface]).entities.GetOrLoad("c2", func() (domain.CardInterface, error) {
+			close(started)
+			<-release
+			return &domain.Card{ID: "c2", OwnerID: "old-state", Type: "water"}, nil
+		})
+	}()
+	<-started
+
+	// A restauração troca o estado do repositório base sem passar pelo cache
+	card, _ := base.Read("c1")
+	card.(*domain.Card).OwnerID = "bob"
+	if err := base.Update("c1", card); err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Create("c2", &domain.Card{ID: "c2", OwnerID: "bob", Type: "water"}); err != nil {
+		t.Fatal(err)
+	}
+	index.Restore(9)
+	close(release)
+	<-inFlight
+
+	if card, err := repo.Read("c1"); err != nil || card.GetOwnerID() != "bob" {
+		t.Errorf("entity after Restore: %v, %v; want the restored owner bob", card, err)
+	}
+	if card, err := repo.Read("c2"); err != nil || card.GetOwnerID() != "bob" {
+		t.Errorf("load started before Restore was cached: %v, %v", card, err)
+	}
+	if count, _ := repo.Count(data.NewQuery()); count != 2 {
+		t.Errorf("Count after Restore = %d, want 2", count)
+	}
+}
@@ -1,85 +1,333 @@
 package cache
 
-// Cache é um mapa em memória com expiração via TTL e limpeza periódica.
-// CacheItem armazena um valor com timestamp de expiração em nanossegundos.
-// Cache mantém itens e controle de concorrência via RWMutex.
-// NewCache inicializa a Cache e inicia uma goroutine de limpeza em segundo plano.
-// Set grava um valor com duração de TTL, calculando a expiração.
-// Get recupera um valor se existir e não estiver expirado.
-// Delete remove um valor do cache pela chave.
-// cleanup remove itens expirados periodicamente; roda em background com ticker.
+// Cache é um LRU em memória, tipado por chave e valor, com expiração via TTL, limite de itens
+// e limpeza periódica que para com Close. Itens podem depender de tags, calculadas por
+// Options.Deps: Invalidate remove de uma vez todos os itens que dependem de uma tag, o que
+// permite descartar listas e consultas derivadas quando uma entidade muda. GetOrLoad junta
+// leituras concorrentes da mesma chave em uma única chamada (singleflight). Stats expõe
+// acertos, falhas e remoções para o endpoint de métricas. Com Options.Version, cada item guarda
+// a versão do estado em que foi lido (ex.: o índice aplicado do Raft), e Reset descarta tudo
+// abaixo de uma versão mínima, inclusive cargas que ainda estão em andamento.
 
 import (
+	"container/list"
+	"errors"
 	"sync"
 	"time"
 )
 
-type CacheItem struct {
-	Value      interface{}
-	Expiration int64
+const (
+	// DefaultCapacity é o limite de itens quando Options.Capacity não é informado; o menos
+	// usado recentemente sai primeiro.
+	DefaultCapacity = 10000
+	DefaultTTL      = 5 * time.Minute
+	// DefaultCleanupInterval é o intervalo da limpeza de itens expirados.
+	DefaultCleanupInterval = 5 * time.Minute
+)
+
+var errLoadPanicked = errors.New("cache load panicked")
+
+// Options configura um Cache; campos zerados usam os padrões.
+type Options[K comparable, V any] struct {
+	Capacity        int
+	TTL             time.Duration
+	CleanupInterval time.Duration
+	// Deps calcula as tags das quais o item depende; nil = nenhuma.
+	Deps func(key K, value V) []string
+	// Version retorna a versão atual do estado por trás do cache; nil = sempre 0.
+	Version func() uint64
+}
+
+type cacheItem[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64
+	version    uint64
+	deps       []string
 }
 
-type Cache struct {
-	items map[string]CacheItem
-	mutex sync.RWMutex
+// loadCall é uma carga em andamento; quem pede a mesma chave espera done e usa o resultado.
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
 }
 
-func NewCache() *Cache {
-	cache := &Cache{
-		items: make(map[string]CacheItem),
+// Stats são os contadores acumulados do cache.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	SharedLoads   uint64 `json:"shared_loads"`  // Falhas atendidas por uma carga já em andamento
+	Evictions     uint64 `json:"evictions"`     // Removidos por falta de espaço
+	Expirations   uint64 `json:"expirations"`   // Removidos por TTL
+	Invalidations uint64 `json:"invalidations"` // Removidos por Delete, Invalidate ou Clear
+	Size          int    `json:"size"`
+	Capacity      int    `json:"capacity"`
+}
+
+type Cache[K comparable, V any] struct {
+	capacity   int
+	ttl        time.Duration
+	deps       func(K, V) []string
+	version    func() uint64
+	floor      uint64              // Versão mínima aceita; definida por Reset
+	items      map[K]*list.Element // Valores *cacheItem; frente = usado mais recentemente
+	order      *list.List
+	dependents map[string]map[K]struct{} // tag -> chaves que dependem dela
+	loading    map[K]*loadCall[V]
+	generation uint64 // Incrementada a cada escrita ou remoção externa
+	stats      Stats
+	mutex      sync.Mutex
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+func NewCache[K comparable, V any](opts Options[K, V]) *Cache[K, V] {
+	if opts.Capacity <= 0 {
+		opts.Capacity = DefaultCapacity
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = DefaultCleanupInterval
+	}
+	cache := &Cache[K, V]{
+		capacity:   opts.Capacity,
+		ttl:        opts.TTL,
+		deps:       opts.Deps,
+		version:    opts.Version,
+		items:      make(map[K]*list.Element),
+		order:      list.New(),
+		dependents: make(map[string]map[K]struct{}),
+		loading:    make(map[K]*loadCall[V]),
+		stop:       make(chan struct{}),
 	}
 
-	// Limpeza automática em segundo plano
-	go cache.cleanup()
+	// Limpeza automática em segundo plano, até Close
+	go cache.cleanup(opts.CleanupInterval)
 
 	return cache
 }
 
-func (c *Cache) Set(key string, value interface{}, duration time.Duration) {
+// Close para a limpeza em segundo plano. O cache continua utilizável; itens expirados só
+// saem quando lidos ou empurrados pelo LRU.
+func (c *Cache[K, V]) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	expiration := time.Now().Add(duration).UnixNano()
-	c.items[key] = CacheItem{
-		Value:      value,
-		Expiration: expiration,
-	}
+	c.changed()
+	c.set(key, value, c.currentVersion())
 }
 
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	item, found := c.items[key]
+	var zero V
+	elem, found := c.items[key]
 	if !found {
-		return nil, false
+		c.stats.Misses++
+		return zero, false
 	}
 
 	// Verifica se expirou
-	if time.Now().UnixNano() > item.Expiration {
-		return nil, false
+	item := elem.Value.(*cacheItem[K, V])
+	if time.Now().UnixNano() > item.expiration {
+		c.remove(elem)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.stats.Hits++
+	return item.value, true
+}
+
+// GetOrLoad retorna o valor em cache ou chama load e guarda o resultado. Chamadas
+// concorrentes para a mesma chave esperam a primeira carga em vez de repeti-la. Se o cache
+// mudar enquanto load roda, o resultado é devolvido mas não guardado: ele pode ter sido lido
+// antes de uma escrita que já invalidou a chave. Erros de load não são guardados.
+func (c *Cache[K, V]) GetOrLoad(key K, load func() (V, error)) (V, error) {
+	if value, found := c.Get(key); found {
+		return value, nil
+	}
+
+	c.mutex.Lock()
+	if call, ok := c.loading[key]; ok {
+		c.stats.SharedLoads++
+		c.mutex.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &loadCall[V]{done: make(chan struct{}), err: errLoadPanicked}
+	c.loading[key] = call
+	generation, version := c.generation, c.currentVersion()
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		if c.loading[key] == call {
+			delete(c.loading, key)
+		}
+		if call.err == nil && c.generation == generation {
+			c.set(key, call.value, version)
+		}
+		c.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = load()
+	return call.value, call.err
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.changed()
+	if elem, found := c.items[key]; found {
+		c.remove(elem)
+		c.stats.Invalidations++
+	}
+}
+
+// Invalidate remove todos os itens que dependem de alguma das tags.
+func (c *Cache[K, V]) Invalidate(tags ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.changed()
+	for _, tag := range tags {
+		for key := range c.dependents[tag] {
+			if elem, found := c.items[key]; found {
+				c.remove(elem)
+				c.stats.Invalidations++
+			}
+		}
 	}
+}
+
+// Clear remove todos os itens.
+func (c *Cache[K, V]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	return item.Value, true
+	c.clear()
 }
 
-func (c *Cache) Delete(key string) {
+// Reset remove todos os itens e passa a recusar os lidos em versões anteriores a floor, como
+// cargas iniciadas antes de o estado ser trocado.
+func (c *Cache[K, V]) Reset(floor uint64) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	delete(c.items, key)
+	c.clear()
+	c.floor = floor
+}
+
+// Stats retorna uma cópia dos contadores.
+func (c *Cache[K, V]) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	stats.Capacity = c.capacity
+	return stats
+}
+
+// clear remove todos os itens; chamado com mutex travado.
+func (c *Cache[K, V]) clear() {
+	c.changed()
+	c.stats.Invalidations += uint64(c.order.Len())
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+	c.dependents = make(map[string]map[K]struct{})
+}
+
+func (c *Cache[K, V]) currentVersion() uint64 {
+	if c.version == nil {
+		return 0
+	}
+	return c.version()
 }
 
-func (c *Cache) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute) // Limpeza a cada 5 minutos
+// changed marca uma escrita externa: cargas em andamento não serão guardadas, e novas
+// leituras não se juntam a elas. Chamado com mutex travado.
+func (c *Cache[K, V]) changed() {
+	c.generation++
+	clear(c.loading)
+}
+
+// set grava o item e remove os menos usados acima da capacidade; itens lidos em versão
+// anterior a floor são ignorados. Chamado com mutex travado.
+func (c *Cache[K, V]) set(key K, value V, version uint64) {
+	if version < c.floor {
+		return
+	}
+	if elem, found := c.items[key]; found {
+		c.remove(elem)
+	}
+
+	item := &cacheItem[K, V]{
+		key:        key,
+		value:      value,
+		expiration: time.Now().Add(c.ttl).UnixNano(),
+		version:    version,
+	}
+	if c.deps != nil {
+		item.deps = c.deps(key, value)
+	}
+	c.items[key] = c.order.PushFront(item)
+	for _, tag := range item.deps {
+		if c.dependents[tag] == nil {
+			c.dependents[tag] = make(map[K]struct{})
+		}
+		c.dependents[tag][key] = struct{}{}
+	}
+
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove tira o item da lista, do índice e das tags; chamado com mutex travado.
+func (c *Cache[K, V]) remove(elem *list.Element) {
+	item := elem.Value.(*cacheItem[K, V])
+	c.order.Remove(elem)
+	delete(c.items, item.key)
+	for _, tag := range item.deps {
+		delete(c.dependents[tag], item.key)
+		if len(c.dependents[tag]) == 0 {
+			delete(c.dependents, tag)
+		}
+	}
+}
+
+func (c *Cache[K, V]) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
 		now := time.Now().UnixNano()
 		c.mutex.Lock()
-		for k, v := range c.items {
-			if now > v.Expiration {
-				delete(c.items, k)
+		for _, elem := range c.items {
+			if now > elem.Value.(*cacheItem[K, V]).expiration {
+				c.remove(elem)
+				c.stats.Expirations++
 			}
 		}
 		c.mutex.Unlock()
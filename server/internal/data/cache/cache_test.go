@@ -0,0 +1,329 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor espera cond valer, falhando o teste após um segundo.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(Options[string, int]{Capacity: 3})
+	defer c.Close()
+
+	for i, key := range []string{"a", "b", "c"} {
+		c.Set(key, i)
+	}
+	c.Get("a") // b passa a ser o menos usado
+	c.Set("d", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used item survived the eviction")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s evicted, want only b out", key)
+		}
+	}
+
+	// Regravar uma chave não ocupa espaço a mais
+	c.Set("d", 4)
+	if stats := c.Stats(); stats.Size != 3 || stats.Evictions != 1 {
+		t.Errorf("size %d, evictions %d; want 3 and 1", stats.Size, stats.Evictions)
+	}
+	if value, _ := c.Get("d"); value != 4 {
+		t.Errorf("d = %d, want the rewritten 4", value)
+	}
+}
+
+func TestCache_ExpiresAfterTTL(t *testing.T) {
+	c := NewCache(Options[string, int]{TTL: 20 * time.Millisecond})
+	defer c.Close()
+
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("item missing before its TTL")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("item read after its TTL")
+	}
+	if stats := c.Stats(); stats.Expirations != 1 || stats.Size != 0 {
+		t.Errorf("expirations %d, size %d; want 1 and 0", stats.Expirations, stats.Size)
+	}
+}
+
+func TestCache_CleanupRemovesExpiredItems(t *testing.T) {
+	c := NewCache(Options[string, int]{TTL: 10 * time.Millisecond, CleanupInterval: 5 * time.Millisecond})
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	deadline := time.Now().Add(time.Second)
+	for c.Stats().Size > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Sem nenhuma leitura: quem removeu foi a limpeza periódica
+	if stats := c.Stats(); stats.Size != 0 || stats.Expirations != 2 || stats.Misses != 0 {
+		t.Errorf("stats after cleanup = %+v, want both items expired without reads", stats)
+	}
+}
+
+func TestCache_InvalidateByDependency(t *testing.T) {
+	// Cada item depende das letras da chave
+	c := NewCache(Options[string, int]{Deps: func(key string, _ int) []string {
+		tags := make([]string, len(key))
+		for i := range key {
+			tags[i] = key[i : i+1]
+		}
+		return tags
+	}})
+	defer c.Close()
+
+	for i, key := range []string{"ab", "bc", "cd"} {
+		c.Set(key, i)
+	}
+	c.Invalidate("b")
+	for key, want := range map[string]bool{"ab": false, "bc": false, "cd": true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("%s present = %v after Invalidate(b), want %v", key, ok, want)
+		}
+	}
+
+	// Regravar com outras tags tira a chave das antigas
+	c.Set("cd", 9)
+	c.Invalidate("b", "x")
+	if _, ok := c.Get("cd"); !ok {
+		t.Error("cd invalidated by tags it does not depend on")
+	}
+	c.Delete("cd")
+	c.Set("ab", 1)
+	c.Clear()
+	if stats := c.Stats(); stats.Size != 0 || stats.Invalidations != 4 {
+		t.Errorf("size %d, invalidations %d; want 0 and 4", stats.Size, stats.Invalidations)
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := NewCache(Options[string, int]{Capacity: 2})
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+	loads := 0
+	for i := 0; i < 2; i++ {
+		c.GetOrLoad("b", func() (int, error) { loads++; return 2, nil })
+	}
+	c.Set("c", 3) // Tira a, o menos usado
+
+	want := Stats{Hits: 3, Misses: 2, Evictions: 1, Size: 2, Capacity: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+	if loads != 1 {
+		t.Errorf("load ran %d times, want once", loads)
+	}
+}
+
+func TestCache_GetOrLoadSharesConcurrentLoads(t *testing.T) {
+	c := NewCache(Options[string, int]{})
+	defer c.Close()
+
+	const callers = 8
+	var loads atomic.Int32
+	release := make(chan struct{})
+	results := make(chan int, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.GetOrLoad("k", func() (int, error) {
+				loads.Add(1)
+				<-release
+				return 42, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			results <- value
+		}()
+	}
+	waitFor(t, "callers to join the load", func() bool { return c.Stats().SharedLoads == callers-1 })
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("load ran %d times, want once", n)
+	}
+	for value := range results {
+		if value != 42 {
+			t.Errorf("caller got %d, want 42", value)
+		}
+	}
+	if value, ok := c.Get("k"); !ok || value != 42 {
+		t.Errorf("loaded value not cached: %d, %v", value, ok)
+	}
+
+	// Erros chegam a todos e não ficam no cache
+	errLoad := errors.New("load failed")
+	if _, err := c.GetOrLoad("bad", func() (int, error) { return 0, errLoad }); !errors.Is(err, errLoad) {
+		t.Errorf("GetOrLoad error = %v, want errLoad", err)
+	}
+	if _, ok := c.Get("bad"); ok {
+		t.Error("failed load was cached")
+	}
+}
+
+func TestCache_GetOrLoadDiscardsLoadsOverlappingWrites(t *testing.T) {
+	writes := map[string]func(c *Cache[string, int]){
+		"Set":        func(c *Cache[string, int]) { c.Set("other", 1) },
+		"Delete":     func(c *Cache[string, int]) { c.Delete("k") },
+		"Invalidate": func(c *Cache[string, int]) { c.Invalidate("tag") },
+		"Clear":      func(c *Cache[string, int]) { c.Clear() },
+	}
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			c := NewCache(Options[string, int]{})
+			defer c.Close()
+
+			started, release := make(chan struct{}), make(chan struct{})
+			done := make(chan int)
+			go func() {
+				value, _ := c.GetOrLoad("k", func() (int, error) {
+					close(started)
+					<-release
+					return 1, nil // Lido antes da escrita: velho
+				})
+				done <- value
+			}()
+			<-started
+			write(c)
+
+			// Quem chega depois da escrita não se junta à carga velha
+			fresh, err := c.GetOrLoad("k", func() (int, error) { return 2, nil })
+			if err != nil || fresh != 2 {
+				t.Errorf("load after the write: %d, %v; want its own load of 2", fresh, err)
+			}
+			close(release)
+			if stale := <-done; stale != 1 {
+				t.Errorf("overlapping caller got %d, want its own result 1", stale)
+			}
+			if value, ok := c.Get("k"); !ok || value != 2 {
+				t.Errorf("cached %d, %v; want the load that followed the write", value, ok)
+			}
+		})
+	}
+}
+
+func TestCache_GetOrLoadPanic(t *testing.T) {
+	c := NewCache(Options[string, int]{})
+	defer c.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	recovered := make(chan any)
+	go func() {
+		defer func() { recovered <- recover() }()
+		c.GetOrLoad("k", func() (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, err := c.GetOrLoad("k", func() (int, error) { return 0, nil })
+		waiter <- err
+	}()
+	waitFor(t, "the waiter to join the load", func() bool { return c.Stats().SharedLoads == 1 })
+	close(release)
+
+	if p := <-recovered; p != "boom" {
+		t.Errorf("loader recovered %v, want the panic to reach it", p)
+	}
+	if err := <-waiter; !errors.Is(err, errLoadPanicked) {
+		t.Errorf("waiter got %v, want errLoadPanicked", err)
+	}
+	if value, err := c.GetOrLoad("k", func() (int, error) { return 7, nil }); err != nil || value != 7 {
+		t.Errorf("load after the panic: %d, %v", value, err)
+	}
+}
+
+func TestCache_ResetRefusesLoadsFromOlderVersions(t *testing.T) {
+	var version atomic.Uint64
+	version.Store(5)
+	c := NewCache(Options[string, int]{Version: version.Load})
+	defer c.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.GetOrLoad("k", func() (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+	<-started
+	version.Store(10)
+	c.Reset(10)
+	close(release)
+	<-done
+
+	if _, ok := c.Get("k"); ok {
+		t.Error("load started at version 5 cached after Reset(10)")
+	}
+	c.Set("k", 2)
+	if value, ok := c.Get("k"); !ok || value != 2 {
+		t.Errorf("write at the floor version: %d, %v", value, ok)
+	}
+
+	// Cargas que começam depois do Reset, mas com a réplica ainda atrás do piso, também ficam fora
+	c.Reset(20)
+	if value, err := c.GetOrLoad("j", func() (int, error) { return 3, nil }); err != nil || value != 3 {
+		t.Fatalf("GetOrLoad below the floor: %d, %v", value, err)
+	}
+	if _, ok := c.Get("j"); ok {
+		t.Error("load at version 10 cached with floor 20")
+	}
+	version.Store(20)
+	c.GetOrLoad("j", func() (int, error) { return 4, nil })
+	if value, ok := c.Get("j"); !ok || value != 4 {
+		t.Errorf("load at the floor version: %d, %v", value, ok)
+	}
+}
+
+func TestCache_CloseStopsCleanup(t *testing.T) {
+	c := NewCache(Options[string, int]{TTL: 5 * time.Millisecond, CleanupInterval: 2 * time.Millisecond})
+	c.Close()
+	c.Close() // Idempotente
+	time.Sleep(10 * time.Millisecond)
+
+	c.Set("a", 1)
+	time.Sleep(30 * time.Millisecond)
+	if stats := c.Stats(); stats.Size != 1 || stats.Expirations != 0 {
+		t.Errorf("stats after Close = %+v, want the expired item left for the next read", stats)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("expired item read after Close")
+	}
+}
@@ -1,305 +1,393 @@
 package cache
 
-// Repositórios em cache envolvem repositórios base com um cache em memória (TTL) para melhorar leituras.
-// Escritas atualizam o cache; listagens podem usar chaves separadas.
-// CachedUserRepository fornece um adaptador em cache para repositórios de usuários.
-// NewCachedUserRepository constrói um repositório de usuários com TTL padrão.
+// CachedRepository envolve qualquer data.Repository[T] com caches em memória (TTL + LRU) para
+// melhorar leituras. Escritas passam pelo repositório base e depois atualizam o cache
+// (write-through). Cada tipo de resultado tem seu próprio Cache tipado:
+//   - entities: entidade por id
+//   - lists, pages, counts: List/ListBy, Find e Count; descartados por qualquer escrita
+//   - derivados (ex.: username -> id, rodadas de uma partida): dependem do id da entidade e
+//     caem com Invalidate quando ela muda
+// CachedUserRepository e CachedMatchRepository só acrescentam as capacidades opcionais
+// (data.UserFinder, data.MatchHistory); o resto vem de CachedRepository.
+// Com Config.Index, os itens são versionados pelo índice aplicado do Raft e todos os caches do
+// repositório se esvaziam quando a FSM restaura um snapshot.
 
 import (
 	"cod-server/internal/data"
 	"cod-server/internal/domain"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
-type CachedUserRepository struct {
-	repo  data.Repository[domain.UserInterface]
-	cache *Cache
-	ttl   time.Duration
+// StatsReporter é implementado pelos repositórios em cache para o endpoint de métricas.
+type StatsReporter interface {
+	Stats() map[string]any
 }
 
-func NewCachedUserRepository(repo data.Repository[domain.UserInterface]) data.Repository[domain.UserInterface] {
-	return &CachedUserRepository{
-		repo:  repo,
-		cache: NewCache(),
-		ttl:   5 * time.Minute,
-	}
+// Config configura os caches de um CachedRepository; campos zerados usam os padrões.
+type Config struct {
+	TTL             time.Duration
+	Capacity        int
+	CleanupInterval time.Duration
+	Index           *AppliedIndex // Opcional: índice aplicado do Raft desta réplica
 }
 
-func (c *CachedUserRepository) Create(id string, entity domain.UserInterface) error {
-	err := c.repo.Create(id, entity)
-	if err != nil {
-		return err
+func cacheOptions[K comparable, V any](config Config, deps func(K, V) []string) Options[K, V] {
+	opts := Options[K, V]{Capacity: config.Capacity, TTL: config.TTL, CleanupInterval: config.CleanupInterval, Deps: deps}
+	if config.Index != nil {
+		opts.Version = config.Index.Index
 	}
-
-	// Adiciona ao cache
-	cacheKey := "user:" + id
-	c.cache.Set(cacheKey, entity, c.ttl)
-
-	return nil
+	return opts
 }
 
-func (c *CachedUserRepository) Read(id string) (domain.UserInterface, error) {
-	var zero domain.UserInterface
-
-	// Attempt to read from cache first
-	cacheKey := "user:" + id
-	if cachedValue, found := c.cache.Get(cacheKey); found {
-		return cachedValue.(domain.UserInterface), nil
-	}
-
-	// Se não estiver no cache, lê do repositório original
-	entity, err := c.repo.Read(id)
-	if err != nil {
-		return zero, err
-	}
-
-	// Armazena no cache
-	c.cache.Set(cacheKey, entity, c.ttl)
+// derivedCache é um cache de dados derivados de entidades do repositório.
+type derivedCache interface {
+	Invalidate(tags ...string)
+	Reset(floor uint64)
+	Stats() Stats
+	Close()
+}
 
-	return entity, nil
+// queryKey identifica a consulta nas chaves de cache; ok é false se ela não puder ser serializada.
+func queryKey(q data.Query) (string, bool) {
+	raw, err := json.Marshal(q)
+	return string(raw), err == nil
 }
 
-func (c *CachedUserRepository) Update(id string, entity domain.UserInterface) error {
-	err := c.repo.Update(id, entity)
-	if err != nil {
-		return err
+func cloneAll[T any](entities []T) []T {
+	clones := make([]T, len(entities))
+	for i, entity := range entities {
+		clones[i] = data.Clone(entity)
 	}
-
-	// Atualiza o cache
-	cacheKey := "user:" + id
-	c.cache.Set(cacheKey, entity, c.ttl)
-
-	return nil
+	return clones
 }
 
-func (c *CachedUserRepository) Delete(id string) error {
-	err := c.repo.Delete(id)
-	if err != nil {
-		return err
+func filterClones[T any](entities []T, filter func(T) bool) []T {
+	var filtered []T
+	for _, entity := range entities {
+		if filter(entity) {
+			filtered = append(filtered, data.Clone(entity))
+		}
 	}
+	return filtered
+}
 
-	// Remove do cache
-	cacheKey := "user:" + id
-	c.cache.Delete(cacheKey)
-
-	return nil
+func clonePage[T any](page data.Page[T]) data.Page[T] {
+	return data.Page[T]{Items: cloneAll(page.Items), NextCursor: page.NextCursor}
 }
 
-func (c *CachedUserRepository) List() ([]domain.UserInterface, error) {
-	// Use a separate cache key for list operations
-	cacheKey := "users:all"
-	if cachedValue, found := c.cache.Get(cacheKey); found {
-		return cachedValue.([]domain.UserInterface), nil
+func statsMap(stats Stats) map[string]any {
+	hitRate := 0.0
+	if total := stats.Hits + stats.Misses; total > 0 {
+		hitRate = float64(stats.Hits) / float64(total)
 	}
-
-	entities, err := c.repo.List()
-	if err != nil {
-		return nil, err
+	return map[string]any{
+		"hits":          stats.Hits,
+		"misses":        stats.Misses,
+		"hit_rate":      hitRate,
+		"shared_loads":  stats.SharedLoads,
+		"evictions":     stats.Evictions,
+		"expirations":   stats.Expirations,
+		"invalidations": stats.Invalidations,
+		"size":          stats.Size,
+		"capacity":      stats.Capacity,
 	}
-
-	// Armazena a lista no cache
-	c.cache.Set(cacheKey, entities, c.ttl)
-
-	return entities, nil
 }
 
-func (c *CachedUserRepository) ListBy(filter func(domain.UserInterface) bool) ([]domain.UserInterface, error) {
-	// Filtering is complex to cache; delegate to underlying repository
-	// CachedCardRepository provides a cached adapter for card repositories.
-	// NewCachedCardRepository constructs a cached card repo with default TTL.
-	return c.repo.ListBy(filter)
+// allKey é a chave da lista completa em lists; as demais chaves têm prefixo.
+const allKey = "all"
+
+type CachedRepository[T any] struct {
+	repo     data.Repository[T]
+	entities *Cache[string, T]
+	lists    *Cache[string, []T]
+	pages    *Cache[string, data.Page[T]]
+	counts   *Cache[string, int]
+	derived  map[string]derivedCache
 }
 
-type CachedCardRepository struct {
-	repo  data.Repository[domain.CardInterface]
-	cache *Cache
-	ttl   time.Duration
+// NewCachedRepository envolve repo com cache; serve para repositórios sem capacidades extras.
+func NewCachedRepository[T any](repo data.Repository[T], config Config) data.Repository[T] {
+	return newCachedRepository(repo, config)
 }
 
-func NewCachedCardRepository(repo data.Repository[domain.CardInterface]) data.Repository[domain.CardInterface] {
-	return &CachedCardRepository{
-		repo:  repo,
-		cache: NewCache(),
-		ttl:   5 * time.Minute,
+func newCachedRepository[T any](repo data.Repository[T], config Config) *CachedRepository[T] {
+	c := &CachedRepository[T]{
+		repo:     repo,
+		entities: NewCache(cacheOptions[string, T](config, nil)),
+		lists:    NewCache(cacheOptions[string, []T](config, nil)),
+		pages:    NewCache(cacheOptions[string, data.Page[T]](config, nil)),
+		counts:   NewCache(cacheOptions[string, int](config, nil)),
+		derived:  make(map[string]derivedCache),
 	}
+	if config.Index != nil {
+		config.Index.OnRestore(c.reset)
+	}
+	return c
 }
 
-func (c *CachedCardRepository) Create(id string, entity domain.CardInterface) error {
+func (c *CachedRepository[T]) Create(id string, entity T) error {
 	err := c.repo.Create(id, entity)
 	if err != nil {
 		return err
 	}
 
-	// Adiciona ao cache
-	cacheKey := "card:" + id
-	c.cache.Set(cacheKey, entity, c.ttl)
+	// Adiciona ao cache e descarta listas e consultas
+	c.cacheEntity(id, entity)
 
 	return nil
 }
 
-func (c *CachedCardRepository) Read(id string) (domain.CardInterface, error) {
-	var zero domain.CardInterface
-
-	// Attempt to read from cache first
-	cacheKey := "card:" + id
-	if cachedValue, found := c.cache.Get(cacheKey); found {
-		return cachedValue.(domain.CardInterface), nil
-	}
-
-	// Se não estiver no cache, lê do repositório original
-	entity, err := c.repo.Read(id)
+func (c *CachedRepository[T]) Read(id string) (T, error) {
+	// Tenta ler do cache primeiro; na falta, lê do repositório original
+	cached, err := c.entities.GetOrLoad(id, func() (T, error) {
+		return c.repo.Read(id)
+	})
 	if err != nil {
+		var zero T
 		return zero, err
 	}
-
-	// Armazena no cache
-	c.cache.Set(cacheKey, entity, c.ttl)
-
-	return entity, nil
+	return data.Clone(cached), nil
 }
 
-func (c *CachedCardRepository) Update(id string, entity domain.CardInterface) error {
+func (c *CachedRepository[T]) Update(id string, entity T) error {
 	err := c.repo.Update(id, entity)
 	if err != nil {
+		if errors.Is(err, data.ErrConflict) {
+			// A versão em cache está velha; a próxima leitura busca a gravada
+			c.evictEntity(id)
+		}
 		return err
 	}
 
-	// Atualiza o cache
-	cacheKey := "card:" + id
-	c.cache.Set(cacheKey, entity, c.ttl)
+	// Atualiza o cache; dados derivados da versão anterior caem junto
+	c.cacheEntity(id, entity)
 
 	return nil
 }
 
-func (c *CachedCardRepository) Delete(id string) error {
+func (c *CachedRepository[T]) Delete(id string) error {
 	err := c.repo.Delete(id)
 	if err != nil {
 		return err
 	}
 
 	// Remove do cache
-	cacheKey := "card:" + id
-	c.cache.Delete(cacheKey)
+	c.evictEntity(id)
 
 	return nil
 }
 
-func (c *CachedCardRepository) List() ([]domain.CardInterface, error) {
-	// Use a separate cache key for list operations
-	cacheKey := "cards:all"
-	if cachedValue, found := c.cache.Get(cacheKey); found {
-		return cachedValue.([]domain.CardInterface), nil
+func (c *CachedRepository[T]) List() ([]T, error) {
+	cached, err := c.lists.GetOrLoad(allKey, c.repo.List)
+	if err != nil {
+		return nil, err
 	}
+	return cloneAll(cached), nil
+}
 
-	entities, err := c.repo.List()
+func (c *CachedRepository[T]) ListBy(filter func(T) bool) ([]T, error) {
+	// O predicado não serve de chave; filtra a lista em cache
+	cached, err := c.lists.GetOrLoad(allKey, c.repo.List)
 	if err != nil {
 		return nil, err
 	}
+	return filterClones(cached, filter), nil
+}
 
-	// Armazena a lista no cache
-	c.cache.Set(cacheKey, entities, c.ttl)
+// Find e Count guardam o resultado por consulta; qualquer escrita na coleção o descarta.
+func (c *CachedRepository[T]) Find(q data.Query) (data.Page[T], error) {
+	key, ok := queryKey(q)
+	if !ok {
+		return c.repo.Find(q)
+	}
+	cached, err := c.pages.GetOrLoad(key, func() (data.Page[T], error) {
+		return c.repo.Find(q)
+	})
+	if err != nil {
+		return data.Page[T]{}, err
+	}
+	return clonePage(cached), nil
+}
 
-	return entities, nil
+func (c *CachedRepository[T]) Count(q data.Query) (int, error) {
+	key, ok := queryKey(q)
+	if !ok {
+		return c.repo.Count(q)
+	}
+	return c.counts.GetOrLoad(key, func() (int, error) {
+		return c.repo.Count(q)
+	})
 }
 
-func (c *CachedCardRepository) ListBy(filter func(domain.CardInterface) bool) ([]domain.CardInterface, error) {
-	// Filtering is complex to cache; delegate to underlying repository
-	return c.repo.ListBy(filter)
+// cacheEntity grava no cache uma entidade já confirmada no repositório base.
+func (c *CachedRepository[T]) cacheEntity(id string, entity T) {
+	c.changed(id)
+	c.entities.Set(id, data.Clone(entity))
 }
 
-type CachedMatchRepository struct {
-	repo  data.Repository[domain.MatchInterface]
-	cache *Cache
-	ttl   time.Duration
+// evictEntity remove do cache uma entidade apagada no repositório base ou com versão velha.
+func (c *CachedRepository[T]) evictEntity(id string) {
+	c.entities.Delete(id)
+	c.changed(id)
 }
 
-func NewCachedMatchRepository(repo data.Repository[domain.MatchInterface]) data.Repository[domain.MatchInterface] {
-	return &CachedMatchRepository{
-		repo:  repo,
-		cache: NewCache(),
-		ttl:   5 * time.Minute, // Partidas podem ter TTL menor devido a mudanças frequentes
+// changed descarta listas, consultas e os dados derivados da entidade.
+func (c *CachedRepository[T]) changed(id string) {
+	c.lists.Clear()
+	c.pages.Clear()
+	c.counts.Clear()
+	for _, derived := range c.derived {
+		derived.Invalidate(id)
 	}
 }
 
-func (c *CachedMatchRepository) Create(id string, entity domain.MatchInterface) error {
-	err := c.repo.Create(id, entity)
-	if err != nil {
-		return err
+// reset esvazia todos os caches quando o estado por trás deles é trocado por um snapshot.
+func (c *CachedRepository[T]) reset(index uint64) {
+	c.entities.Reset(index)
+	c.lists.Reset(index)
+	c.pages.Reset(index)
+	c.counts.Reset(index)
+	for _, derived := range c.derived {
+		derived.Reset(index)
 	}
-
-	// Adiciona ao cache
-	cacheKey := "match:" + id
-	c.cache.Set(cacheKey, entity, c.ttl)
-
-	return nil
 }
 
-func (c *CachedMatchRepository) Read(id string) (domain.MatchInterface, error) {
-	var zero domain.MatchInterface
+// derive registra um cache de dados derivados, invalidado pelo id da entidade a cada escrita.
+func (c *CachedRepository[T]) derive(name string, cache derivedCache) {
+	c.derived[name] = cache
+}
 
-	// Tenta ler do cache primeiro
-	cacheKey := "match:" + id
-	if cachedValue, found := c.cache.Get(cacheKey); found {
-		return cachedValue.(domain.MatchInterface), nil
+// Stats implementa StatsReporter, com os contadores de cada cache.
+func (c *CachedRepository[T]) Stats() map[string]any {
+	stats := map[string]any{
+		"entities": statsMap(c.entities.Stats()),
+		"lists":    statsMap(c.lists.Stats()),
+		"pages":    statsMap(c.pages.Stats()),
+		"counts":   statsMap(c.counts.Stats()),
 	}
+	for name, derived := range c.derived {
+		stats[name] = statsMap(derived.Stats())
+	}
+	return stats
+}
 
-	// Se não estiver no cache, lê do repositório original
-	entity, err := c.repo.Read(id)
-	if err != nil {
-		return zero, err
+// Close para a limpeza em segundo plano dos caches.
+func (c *CachedRepository[T]) Close() error {
+	c.entities.Close()
+	c.lists.Close()
+	c.pages.Close()
+	c.counts.Close()
+	for _, derived := range c.derived {
+		derived.Close()
 	}
+	return nil
+}
 
-	// Armazena no cache
-	c.cache.Set(cacheKey, entity, c.ttl)
+var errUsernameNotFound = errors.New("username not found")
 
-	return entity, nil
+// CachedUserRepository acrescenta a CachedRepository o índice username -> id usado por
+// FindByUsername.
+type CachedUserRepository struct {
+	*CachedRepository[domain.UserInterface]
+	usernames *Cache[string, string]
 }
 
-func (c *CachedMatchRepository) Update(id string, entity domain.MatchInterface) error {
-	err := c.repo.Update(id, entity)
-	if err != nil {
-		return err
+func NewCachedUserRepository(repo data.Repository[domain.UserInterface], config Config) data.Repository[domain.UserInterface] {
+	c := &CachedUserRepository{
+		CachedRepository: newCachedRepository(repo, config),
+		// O mapeamento depende do id: renomear ou apagar o usuário o descarta
+		usernames: NewCache(cacheOptions(config, func(_ string, id string) []string { return []string{id} })),
 	}
-
-	// Atualiza o cache
-	cacheKey := "match:" + id
-	c.cache.Set(cacheKey, entity, c.ttl)
-
-	return nil
+	c.derive("usernames", c.usernames)
+	return c
 }
 
-func (c *CachedMatchRepository) Delete(id string) error {
-	err := c.repo.Delete(id)
+// FindByUsername resolve username -> id pelo cache e lê o usuário via Read (também em cache).
+// Sem acerto, delega ao repositório base se ele implementar data.UserFinder; senão, usa ListBy.
+func (c *CachedUserRepository) FindByUsername(username string) (domain.UserInterface, error) {
+	id, err := c.usernames.GetOrLoad(username, func() (string, error) {
+		entity, err := c.findUncached(username)
+		if err != nil {
+			return "", err
+		}
+		if entity == nil {
+			return "", errUsernameNotFound
+		}
+		return entity.GetID(), nil
+	})
+	if errors.Is(err, errUsernameNotFound) {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Remove do cache
-	cacheKey := "match:" + id
-	c.cache.Delete(cacheKey)
+	entity, err := c.Read(id)
+	if err == nil && entity != nil && entity.GetUsername() == username {
+		return entity, nil
+	}
+	// Mapeamento velho (ex.: escrita que não passou por este cache); busca de novo
+	c.usernames.Delete(username)
+	return c.findUncached(username)
+}
 
-	return nil
+func (c *CachedUserRepository) findUncached(username string) (domain.UserInterface, error) {
+	if finder, ok := c.repo.(data.UserFinder); ok {
+		return finder.FindByUsername(username)
+	}
+	users, err := c.repo.ListBy(func(u domain.UserInterface) bool {
+		return u.GetUsername() == username
+	})
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+// CachedMatchRepository acrescenta a CachedRepository o histórico de partidas (data.MatchHistory).
+type CachedMatchRepository struct {
+	*CachedRepository[domain.MatchInterface]
+	rounds *Cache[string, []data.Round]
 }
 
-func (c *CachedMatchRepository) List() ([]domain.MatchInterface, error) {
-	// Para a lista, usamos um cache separado
-	cacheKey := "matches:all"
-	if cachedValue, found := c.cache.Get(cacheKey); found {
-		return cachedValue.([]domain.MatchInterface), nil
+func NewCachedMatchRepository(repo data.Repository[domain.MatchInterface], config Config) data.Repository[domain.MatchInterface] {
+	c := &CachedMatchRepository{
+		CachedRepository: newCachedRepository(repo, config),
+		// As rodadas dependem só da própria partida
+		rounds: NewCache(cacheOptions(config, func(matchID string, _ []data.Round) []string { return []string{matchID} })),
 	}
+	c.derive("rounds", c.rounds)
+	return c
+}
 
-	entities, err := c.repo.List()
+// MatchesOf e RoundsOf implementam data.MatchHistory sobre o repositório base. As partidas de
+// um usuário ficam com as listas e caem a cada escrita na coleção.
+func (c *CachedMatchRepository) MatchesOf(userID string) ([]domain.MatchInterface, error) {
+	cached, err := c.lists.GetOrLoad("of:"+userID, func() ([]domain.MatchInterface, error) {
+		return data.MatchesOf(c.repo, userID)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	// Armazena a lista no cache
-	c.cache.Set(cacheKey, entities, c.ttl)
-
-	return entities, nil
+	return cloneAll(cached), nil
 }
 
-func (c *CachedMatchRepository) ListBy(filter func(domain.MatchInterface) bool) ([]domain.MatchInterface, error) {
-	// O filtro é complicado de cachear, então vamos diretamente ao repositório
-	return c.repo.ListBy(filter)
+func (c *CachedMatchRepository) RoundsOf(matchID string) ([]data.Round, error) {
+	rounds, err := c.rounds.GetOrLoad(matchID, func() ([]data.Round, error) {
+		return data.RoundsOf(c.repo, matchID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// As cartas são compartilhadas; os mapas de jogadas são copiados para o chamador
+	copies := make([]data.Round, len(rounds))
+	for i, round := range rounds {
+		copies[i] = data.Round{Number: round.Number, Moves: make(map[string]domain.CardInterface, len(round.Moves)), WinnerID: round.WinnerID}
+		for playerID, card := range round.Moves {
+			copies[i].Moves[playerID] = card
+		}
+	}
+	return copies, nil
 }
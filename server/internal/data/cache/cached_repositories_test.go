@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"testing"
+
+	"cod-server/internal/data"
+	"cod-server/internal/data/datatest"
+	"cod-server/internal/domain"
+)
+
+// newCachedTx decora repositórios em memória como main.go decora os do backend.
+func newCachedTx(t *testing.T) (data.Tx, data.UnitOfWork) {
+	t.Helper()
+	_, cached, uow := newCachedStore(t)
+	return cached, uow
+}
+
+// newCachedStore é newCachedTx com os repositórios de base, para escrever por fora do cache.
+func newCachedStore(t *testing.T) (data.Tx, data.Tx, data.UnitOfWork) {
+	t.Helper()
+	base := data.Tx{
+		Users:   data.NewMemoryRepository[domain.UserInterface](),
+		Cards:   data.NewMemoryRepository[domain.CardInterface](),
+		Matches: data.NewMemoryRepository[domain.MatchInterface](),
+	}
+	uow, err := data.NewMemoryUnitOfWork(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := data.Tx{
+		Users:   NewCachedUserRepository(base.Users, Config{}),
+		Cards:   NewCachedRepository(base.Cards, Config{}),
+		Matches: NewCachedMatchRepository(base.Matches, Config{}),
+	}
+	t.Cleanup(func() {
+		for _, repo := range []any{cached.Users, cached.Cards, cached.Matches} {
+			repo.(interface{ Close() error }).Close()
+		}
+	})
+	return base, cached, NewCachedUnitOfWork(uow, cached)
+}
+
+func TestCachedRepositories(t *testing.T) {
+	repos, _ := newCachedTx(t)
+	t.Run("users", func(t *testing.T) { datatest.TestRepository(t, repos.Users, datatest.Users) })
+	t.Run("cards", func(t *testing.T) { datatest.TestRepository(t, repos.Cards, datatest.Cards) })
+	t.Run("matches", func(t *testing.T) { datatest.TestRepository(t, repos.Matches, datatest.Matches) })
+}
+
+func TestCachedUnitOfWork(t *testing.T) {
+	repos, uow := newCachedTx(t)
+	datatest.TestUnitOfWork(t, repos, uow)
+}
+
+func TestCachedUnitOfWork_ReadsThroughTheTransaction(t *testing.T) {
+	base, cached, uow := newCachedStore(t)
+	if err := cached.Cards.Create("c1", &domain.Card{ID: "c1", OwnerID: "alice", Type: "fire"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.Cards.Read("c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Uma escrita por fora do cache deixa a cópia em cache velha
+	card, _ := base.Cards.Read("c1")
+	card.(*domain.Card).OwnerID = "bob"
+	if err := base.Cards.Update("c1", card); err != nil {
+		t.Fatal(err)
+	}
+
+	err := uow.Do(func(tx data.Tx) error {
+		card, err := tx.Cards.Read("c1")
+		if err != nil {
+			return err
+		}
+		if owner := card.GetOwnerID(); owner != "bob" {
+			t.Errorf("read inside the transaction: owner %q, want the stored bob", owner)
+		}
+		card.(*domain.Card).OwnerID = "carol"
+		return tx.Cards.Update("c1", card)
+	})
+	if err != nil {
+		t.Fatalf("transaction on a stale cache entry: %v", err)
+	}
+	if card, _ := cached.Cards.Read("c1"); card.GetOwnerID() != "carol" {
+		t.Errorf("cache after commit: owner %q, want carol", card.GetOwnerID())
+	}
+}
+
+// countingRepository conta as leituras que chegam ao repositório base.
+type countingRepository[T any] struct {
+	data.Repository[T]
+	reads map[string]int
+}
+
+func newCountingRepository[T any]() *countingRepository[T] {
+	return &countingRepository[T]{Repository: data.NewMemoryRepository[T](), reads: make(map[string]int)}
+}
+
+func (r *countingRepository[T]) Read(id string) (T, error) {
+	r.reads["read"]++
+	return r.Repository.Read(id)
+}
+
+func (r *countingRepository[T]) List() ([]T, error) {
+	r.reads["list"]++
+	return r.Repository.List()
+}
+
+func (r *countingRepository[T]) Find(q data.Query) (data.Page[T], error) {
+	r.reads["find"]++
+	return r.Repository.Find(q)
+}
+
+func (r *countingRepository[T]) Count(q data.Query) (int, error) {
+	r.reads["count"]++
+	return r.Repository.Count(q)
+}
+
+func TestCachedRepository_WritesDropListsAndQueries(t *testing.T) {
+	base := newCountingRepository[domain.CardInterface]()
+	repo := NewCachedRepository[domain.CardInterface](base, Config{})
+	defer repo.(interface{ Close() error }).Close()
+
+	fire := func(card domain.CardInterface) bool { return card.GetType() == "fire" }
+	byOwner := data.NewQuery().Where("owner_id", "alice")
+	// read lê tudo duas vezes e confere que a segunda rodada veio do cache
+	read := func(step string) (list, listBy, found, count int) {
+		t.Helper()
+		before := map[string]int{}
+		for k, v := range base.reads {
+			before[k] = v
+		}
+		for i := 0; i < 2; i++ {
+			all, err := repo.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			filtered, err := repo.ListBy(fire)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, err := repo.Find(byOwner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := repo.Count(byOwner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			list, listBy, found, count = len(all), len(filtered), len(page.Items), n
+		}
+		for _, kind := range []string{"list", "find", "count"} {
+			if loads := base.reads[kind] - before[kind]; loads != 1 {
+				t.Errorf("%s: %s reached the base %d times, want once", step, kind, loads)
+			}
+		}
+		return list, listBy, found, count
+	}
+
+	if err := repo.Create("c1", &domain.Card{ID: "c1", OwnerID: "alice", Type: "fire"}); err != nil {
+		t.Fatal(err)
+	}
+	if l, lb, f, c := read("first read"); l != 1 || lb != 1 || f != 1 || c != 1 {
+		t.Fatalf("first read: %d %d %d %d, want 1 1 1 1", l, lb, f, c)
+	}
+
+	if err := repo.Create("c2", &domain.Card{ID: "c2", OwnerID: "alice", Type: "water"}); err != nil {
+		t.Fatal(err)
+	}
+	if l, lb, f, c := read("after Create"); l != 2 || lb != 1 || f != 2 || c != 2 {
+		t.Errorf("after Create: %d %d %d %d, want 2 1 2 2", l, lb, f, c)
+	}
+
+	card, _ := repo.Read("c2")
+	card.(*domain.Card).Type = "fire"
+	card.(*domain.Card).OwnerID = "bob"
+	if err := repo.Update("c2", card); err != nil {
+		t.Fatal(err)
+	}
+	if l, lb, f, c := read("after Update"); l != 2 || lb != 2 || f != 1 || c != 1 {
+		t.Errorf("after Update: %d %d %d %d, want 2 2 1 1", l, lb, f, c)
+	}
+
+	if err := repo.Delete("c1"); err != nil {
+		t.Fatal(err)
+	}
+	if l, lb, f, c := read("after Delete"); l != 1 || lb != 1 || f != 0 || c != 0 {
+		t.Errorf("after Delete: %d %d %d %d, want 1 1 0 0", l, lb, f, c)
+	}
+
+	// Entidades: a segunda leitura não chega à base, e quem altera a cópia lida não altera o cache
+	reads := base.reads["read"]
+	first, _ := repo.Read("c2")
+	first.(*domain.Card).Type = "changed outside"
+	second, _ := repo.Read("c2")
+	if base.reads["read"] != reads || second.GetType() != "fire" {
+		t.Errorf("cached Read: base reads %d -> %d, type %q", reads, base.reads["read"], second.GetType())
+	}
+}
+
+func TestCachedUserRepository_RenameDropsUsernameIndex(t *testing.T) {
+	repo := NewCachedUserRepository(data.NewMemoryRepository[domain.UserInterface](), Config{}).(*CachedUserRepository)
+	defer repo.Close()
+
+	if err := repo.Create("u1", datatest.NewUser("u1")); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := repo.FindByUsername("name-u1"); err != nil || user == nil || user.GetID() != "u1" {
+		t.Fatalf("FindByUsername: %v, %v", user, err)
+	}
+
+	renamed, _ := repo.Read("u1")
+	renamed.(*domain.User).Username = "renamed"
+	if err := repo.Update("u1", renamed); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := repo.FindByUsername("name-u1"); err != nil || user != nil {
+		t.Errorf("old username after rename: %v, %v", user, err)
+	}
+	if user, err := repo.FindByUsername("renamed"); err != nil || user == nil {
+		t.Errorf("new username after rename: %v, %v", user, err)
+	}
+
+	if err := repo.Delete("u1"); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := repo.FindByUsername("renamed"); err != nil || user != nil {
+		t.Errorf("username of a deleted user: %v, %v", user, err)
+	}
+
+	stats := repo.Stats()
+	usernames, ok := stats["usernames"].(map[string]any)
+	if !ok {
+		t.Fatalf("Stats without the usernames cache: %v", stats)
+	}
+	if usernames["invalidations"].(uint64) == 0 || usernames["misses"].(uint64) == 0 {
+		t.Errorf("usernames stats = %v, want misses and invalidations counted", usernames)
+	}
+	for _, name := range []string{"entities", "lists", "pages", "counts"} {
+		if _, ok := stats[name]; !ok {
+			t.Errorf("Stats without %s", name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+// cacheWriter é implementado pelos repositórios em cache para receber, após o commit, as
+// escritas feitas por uma unidade de trabalho diretamente no repositório base.
+type cacheWriter[T any] interface {
+	cacheEntity(id string, entity T)
+	evictEntity(id string)
+}
+
+// CachedUnitOfWork envolve uma unidade de trabalho do armazenamento e mantém os repositórios
+// em cache coerentes: dentro da transação tudo é lido do armazenamento, pela transação, e as
+// escritas só chegam ao cache depois do commit. Em rollback o cache fica intacto.
+type CachedUnitOfWork struct {
+	uow    data.UnitOfWork
+	cached data.Tx
+}
+
+// NewCachedUnitOfWork recebe a unidade de trabalho do armazenamento e os repositórios em
+// cache que envolvem esse mesmo armazenamento.
+func NewCachedUnitOfWork(uow data.UnitOfWork, cached data.Tx) data.UnitOfWork {
+	return &CachedUnitOfWork{uow: uow, cached: cached}
+}
+
+func (u *CachedUnitOfWork) Do(fn func(tx data.Tx) error) error {
+	var users *trackedRepository[domain.UserInterface]
+	var cards *trackedRepository[domain.CardInterface]
+	var matches *trackedRepository[domain.MatchInterface]
+
+	err := u.uow.Do(func(tx data.Tx) error {
+		users = newTrackedRepository(tx.Users, u.cached.Users)
+		cards = newTrackedRepository(tx.Cards, u.cached.Cards)
+		matches = newTrackedRepository(tx.Matches, u.cached.Matches)
+		return fn(data.Tx{Users: users, Cards: cards, Matches: matches})
+	})
+	if err != nil {
+		return err
+	}
+
+	flush(users, u.cached.Users)
+	flush(cards, u.cached.Cards)
+	flush(matches, u.cached.Matches)
+	return nil
+}
+
+// flush publica no cache as escritas confirmadas.
+func flush[T any](tracked *trackedRepository[T], cached data.Repository[T]) {
+	writer, ok := cached.(cacheWriter[T])
+	if tracked == nil || !ok {
+		return
+	}
+	for id, write := range tracked.writes {
+		if write.deleted {
+			writer.evictEntity(id)
+		} else {
+			writer.cacheEntity(id, write.entity)
+		}
+	}
+}
+
+type trackedWrite[T any] struct {
+	entity  T
+	deleted bool
+}
+
+// trackedRepository registra as escritas de uma transação para publicá-las no cache após o
+// commit. Leituras, listagens e consultas vão ao repositório da transação: ler pelo cache
+// veria um estado de fora da transação, sem o isolamento nem os bloqueios dela.
+type trackedRepository[T any] struct {
+	data.Repository[T]
+	cached data.Repository[T]
+	mu     sync.Mutex
+	writes map[string]trackedWrite[T]
+}
+
+func newTrackedRepository[T any](tx, cached data.Repository[T]) *trackedRepository[T] {
+	return &trackedRepository[T]{Repository: tx, cached: cached, writes: make(map[string]trackedWrite[T])}
+}
+
+func (r *trackedRepository[T]) record(id string, write trackedWrite[T]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes[id] = write
+}
+
+func (r *trackedRepository[T]) Create(id string, entity T) error {
+	if err := r.Repository.Create(id, entity); err != nil {
+		return err
+	}
+	r.record(id, trackedWrite[T]{entity: entity})
+	return nil
+}
+
+func (r *trackedRepository[T]) Update(id string, entity T) error {
+	if err := r.Repository.Update(id, entity); err != nil {
+		if writer, ok := r.cached.(cacheWriter[T]); ok && errors.Is(err, data.ErrConflict) {
+			// Outra escrita passou à frente; a cópia em cache também pode estar velha
+			writer.evictEntity(id)
+		}
+		return err
+	}
+	r.record(id, trackedWrite[T]{entity: entity})
+	return nil
+}
+
+func (r *trackedRepository[T]) Delete(id string) error {
+	if err := r.Repository.Delete(id); err != nil {
+		return err
+	}
+	r.record(id, trackedWrite[T]{deleted: true})
+	return nil
+}
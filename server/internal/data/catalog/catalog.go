@@ -0,0 +1,36 @@
+// Package catalog carrega o catálogo de modelos de carta de um arquivo JSON versionado.
+// O catalog.json deste pacote é embutido no binário e é o que o líder replica quando não há
+// arquivo externo; as réplicas só usam o catálogo que chega pelo log (ver cluster.CatalogSync).
+package catalog
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"cod-server/internal/domain"
+)
+
+//go:embed catalog.json
+var defaultCatalog []byte
+
+// Default retorna o catálogo embutido.
+func Default() *domain.Catalog {
+	catalog, err := domain.ParseCatalog(defaultCatalog)
+	if err != nil {
+		panic(fmt.Sprintf("embedded catalog: %v", err))
+	}
+	return catalog
+}
+
+// Load lê o catálogo de path; path vazio retorna o catálogo embutido.
+func Load(path string) (*domain.Catalog, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+	return domain.ParseCatalog(raw)
+}
@@ -0,0 +1,101 @@
+package changes
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+func record[T any](feed *Feed[T]) *[]Change[T] {
+	var got []Change[T]
+	feed.Subscribe(func(change Change[T]) { got = append(got, change) })
+	return &got
+}
+
+func kinds[T any](changes []Change[T]) []Kind {
+	var got []Kind
+	for _, change := range changes {
+		got = append(got, change.Kind)
+	}
+	return got
+}
+
+func TestObservedRepository_PublishesWrites(t *testing.T) {
+	feed := NewFeed[domain.CardInterface]()
+	got := record(feed)
+	repo := NewObservedRepository(data.NewMemoryRepository[domain.CardInterface](), feed)
+
+	card := &domain.Card{ID: "c1", OwnerID: "alice", Type: "rock"}
+	if err := repo.Create("c1", card); err != nil {
+		t.Fatal(err)
+	}
+	card.OwnerID = "bob"
+	if err := repo.Update("c1", card); err != nil {
+		t.Fatal(err)
+	}
+	// Escritas que falham não são publicadas
+	if err := repo.Create("c1", card); err == nil {
+		t.Fatal("duplicate Create must fail")
+	}
+	if err := repo.Delete("c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Kind{Created, Updated, Deleted}; !reflect.DeepEqual(kinds(*got), want) {
+		t.Fatalf("kinds: got %v, want %v", kinds(*got), want)
+	}
+	if owner := (*got)[0].Entity.GetOwnerID(); owner != "alice" {
+		t.Errorf("Created snapshot changed after the write: owner %q", owner)
+	}
+	if owner := (*got)[2].Entity.GetOwnerID(); owner != "bob" {
+		t.Errorf("Deleted must carry the removed entity: owner %q", owner)
+	}
+}
+
+func TestObservedUnitOfWork_PublishesAfterCommit(t *testing.T) {
+	repos := data.Tx{
+		Users:   data.NewMemoryRepository[domain.UserInterface](),
+		Cards:   data.NewMemoryRepository[domain.CardInterface](),
+		Matches: data.NewMemoryRepository[domain.MatchInterface](),
+	}
+	memory, err := data.NewMemoryUnitOfWork(repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeds := NewFeeds()
+	got := record(feeds.Cards)
+	uow := NewObservedUnitOfWork(memory, feeds)
+
+	failure := errors.New("abort")
+	err = uow.Do(func(tx data.Tx) error {
+		if err := tx.Cards.Create("c1", &domain.Card{ID: "c1", OwnerID: "alice"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Do: got %v, want the function's error", err)
+	}
+	if len(*got) != 0 {
+		t.Fatalf("rolled back unit of work published %v", kinds(*got))
+	}
+
+	err = uow.Do(func(tx data.Tx) error {
+		if err := tx.Cards.Create("c1", &domain.Card{ID: "c1", OwnerID: "alice"}); err != nil {
+			return err
+		}
+		if len(*got) != 0 {
+			t.Error("change published before commit")
+		}
+		return tx.Cards.Create("c2", &domain.Card{ID: "c2", OwnerID: "alice"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 2 || (*got)[0].ID != "c1" || (*got)[1].ID != "c2" {
+		t.Fatalf("committed changes: got %+v", *got)
+	}
+}
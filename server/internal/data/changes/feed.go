@@ -0,0 +1,85 @@
+// Package changes publica as escritas confirmadas nos repositórios como eventos tipados
+// (criado, alterado, removido) para assinantes registrados: notificações, auditoria ou
+// projeções reagem ao estado sem que cada handler precise avisá-los.
+//
+// Os eventos saem depois do commit: escritas diretas em um repositório valem na hora, e as
+// de uma unidade de trabalho só são publicadas, na ordem em que foram feitas, se ela for
+// confirmada. Em um cluster cada réplica aplica o log e publica as próprias mudanças.
+package changes
+
+import (
+	"sync"
+
+	"cod-server/internal/domain"
+)
+
+// Kind é o tipo da mudança.
+type Kind string
+
+const (
+	Created Kind = "created"
+	Updated Kind = "updated"
+	Deleted Kind = "deleted"
+)
+
+// Change descreve uma escrita confirmada. Entity é uma cópia da entidade gravada; em Deleted,
+// é a entidade como estava antes da remoção (valor zero se não foi possível lê-la).
+type Change[T any] struct {
+	Kind   Kind
+	ID     string
+	Entity T
+}
+
+// Feed distribui as mudanças de um tipo de entidade aos assinantes.
+type Feed[T any] struct {
+	mu          sync.RWMutex
+	next        int
+	subscribers map[int]func(Change[T])
+}
+
+func NewFeed[T any]() *Feed[T] {
+	return &Feed[T]{subscribers: make(map[int]func(Change[T]))}
+}
+
+// Subscribe registra fn e retorna a função que cancela a assinatura. fn é chamada na
+// goroutine que confirmou a escrita, então deve ser rápida e não escrever nos repositórios.
+func (f *Feed[T]) Subscribe(fn func(Change[T])) (unsubscribe func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.next
+	f.next++
+	f.subscribers[id] = fn
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.subscribers, id)
+	}
+}
+
+func (f *Feed[T]) publish(change Change[T]) {
+	f.mu.RLock()
+	subscribers := make([]func(Change[T]), 0, len(f.subscribers))
+	for _, fn := range f.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	f.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// Feeds reúne os feeds das entidades guardadas em data.Tx.
+type Feeds struct {
+	Users   *Feed[domain.UserInterface]
+	Cards   *Feed[domain.CardInterface]
+	Matches *Feed[domain.MatchInterface]
+}
+
+func NewFeeds() *Feeds {
+	return &Feeds{
+		Users:   NewFeed[domain.UserInterface](),
+		Cards:   NewFeed[domain.CardInterface](),
+		Matches: NewFeed[domain.MatchInterface](),
+	}
+}
@@ -0,0 +1,141 @@
+package changes
+
+import (
+	"sync"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+// ObservedRepository envolve um repositório e emite uma mudança a cada escrita bem-sucedida.
+// Leituras e consultas passam direto.
+type ObservedRepository[T any] struct {
+	data.Repository[T]
+	emit func(Change[T])
+}
+
+// NewObservedRepository publica em feed as escritas feitas diretamente em repo.
+func NewObservedRepository[T any](repo data.Repository[T], feed *Feed[T]) data.Repository[T] {
+	return newObservedRepository(repo, feed.publish)
+}
+
+func newObservedRepository[T any](repo data.Repository[T], emit func(Change[T])) *ObservedRepository[T] {
+	return &ObservedRepository[T]{Repository: repo, emit: emit}
+}
+
+func (r *ObservedRepository[T]) Create(id string, entity T) error {
+	if err := r.Repository.Create(id, entity); err != nil {
+		return err
+	}
+	r.emit(Change[T]{Kind: Created, ID: id, Entity: data.Clone(entity)})
+	return nil
+}
+
+func (r *ObservedRepository[T]) Update(id string, entity T) error {
+	if err := r.Repository.Update(id, entity); err != nil {
+		return err
+	}
+	r.emit(Change[T]{Kind: Updated, ID: id, Entity: data.Clone(entity)})
+	return nil
+}
+
+// Delete lê a entidade antes de removê-la, para que os assinantes saibam o que saiu.
+func (r *ObservedRepository[T]) Delete(id string) error {
+	previous, _ := r.Repository.Read(id)
+	if err := r.Repository.Delete(id); err != nil {
+		return err
+	}
+	r.emit(Change[T]{Kind: Deleted, ID: id, Entity: previous})
+	return nil
+}
+
+// ObservedUserRepository mantém a capacidade data.UserFinder do repositório envolvido.
+type ObservedUserRepository struct {
+	*ObservedRepository[domain.UserInterface]
+}
+
+func NewObservedUserRepository(repo data.Repository[domain.UserInterface], feed *Feed[domain.UserInterface]) data.Repository[domain.UserInterface] {
+	return &ObservedUserRepository{ObservedRepository: newObservedRepository(repo, feed.publish)}
+}
+
+// FindByUsername delega ao repositório envolvido se ele implementar data.UserFinder; senão, usa ListBy.
+func (r *ObservedUserRepository) FindByUsername(username string) (domain.UserInterface, error) {
+	if finder, ok := r.Repository.(data.UserFinder); ok {
+		return finder.FindByUsername(username)
+	}
+	users, err := r.Repository.ListBy(func(u domain.UserInterface) bool {
+		return u.GetUsername() == username
+	})
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+// ObservedMatchRepository mantém a capacidade data.MatchHistory do repositório envolvido.
+type ObservedMatchRepository struct {
+	*ObservedRepository[domain.MatchInterface]
+}
+
+func NewObservedMatchRepository(repo data.Repository[domain.MatchInterface], feed *Feed[domain.MatchInterface]) data.Repository[domain.MatchInterface] {
+	return &ObservedMatchRepository{ObservedRepository: newObservedRepository(repo, feed.publish)}
+}
+
+func (r *ObservedMatchRepository) MatchesOf(userID string) ([]domain.MatchInterface, error) {
+	return data.MatchesOf(r.Repository, userID)
+}
+
+func (r *ObservedMatchRepository) RoundsOf(matchID string) ([]data.Round, error) {
+	return data.RoundsOf(r.Repository, matchID)
+}
+
+// ObservedUnitOfWork guarda as mudanças feitas dentro de Do e só as publica após o commit,
+// na ordem em que foram feitas. Em rollback nada é publicado.
+type ObservedUnitOfWork struct {
+	uow   data.UnitOfWork
+	feeds *Feeds
+}
+
+func NewObservedUnitOfWork(uow data.UnitOfWork, feeds *Feeds) data.UnitOfWork {
+	return &ObservedUnitOfWork{uow: uow, feeds: feeds}
+}
+
+func (u *ObservedUnitOfWork) Do(fn func(tx data.Tx) error) error {
+	var mu sync.Mutex
+	var pending []func()
+
+	err := u.uow.Do(func(tx data.Tx) error {
+		// Uma nova tentativa da transação descarta as mudanças da anterior
+		pending = nil
+		return fn(data.Tx{
+			Users:   observeTx(tx.Users, deferTo(&mu, &pending, u.feeds.Users)),
+			Cards:   observeTx(tx.Cards, deferTo(&mu, &pending, u.feeds.Cards)),
+			Matches: observeTx(tx.Matches, deferTo(&mu, &pending, u.feeds.Matches)),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, publish := range pending {
+		publish()
+	}
+	return nil
+}
+
+// observeTx envolve um repositório da transação; repositórios ausentes continuam nil.
+func observeTx[T any](repo data.Repository[T], emit func(Change[T])) data.Repository[T] {
+	if repo == nil {
+		return nil
+	}
+	return newObservedRepository(repo, emit)
+}
+
+// deferTo adia a publicação em feed para depois do commit.
+func deferTo[T any](mu *sync.Mutex, pending *[]func(), feed *Feed[T]) func(Change[T]) {
+	return func(change Change[T]) {
+		mu.Lock()
+		defer mu.Unlock()
+		*pending = append(*pending, func() { feed.publish(change) })
+	}
+}
@@ -0,0 +1,211 @@
+// Package datatest reúne a suíte de conformidade que toda implementação de
+// data.Repository e data.UnitOfWork deve passar: as mesmas leituras, os mesmos erros
+// padrão (data.ErrNotFound, data.ErrAlreadyExists, data.ErrConflict) e a mesma
+// atomicidade, para que os serviços possam contar com errors.Is em qualquer backend.
+package datatest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+// Fixture descreve como criar e alterar entidades de um tipo para a suíte genérica.
+// New deve devolver entidades iguais (reflect.DeepEqual) para o mesmo id.
+type Fixture[T any] struct {
+	New    func(id string) T
+	Change func(entity T)
+}
+
+// NewUser cria um usuário completo, com papéis e pacote de cartas.
+func NewUser(id string) domain.UserInterface {
+	return &domain.User{
+		ID:        id,
+		Username:  "name-" + id,
+		Password:  "hash-" + id,
+		Roles:     []domain.Role{domain.RolePlayer},
+		CreatedAt: time.Unix(1700000000, 0).UTC(),
+		Cards:     &domain.Pack{ID: "pack-" + id, Cards: []domain.CardInterface{&domain.Card{ID: "card-" + id, OwnerID: id, Type: "rock", TemplateID: "rock-pebble", Power: 1}}},
+	}
+}
+
+// Users altera username e papéis, que os backends guardam em colunas e índices próprios.
+var Users = Fixture[domain.UserInterface]{
+	New: NewUser,
+	Change: func(user domain.UserInterface) {
+		user.(*domain.User).Username += "-renamed"
+		user.(*domain.User).Roles = append(user.(*domain.User).Roles, domain.RoleAdmin)
+	},
+}
+
+var Cards = Fixture[domain.CardInterface]{
+	New: func(id string) domain.CardInterface {
+		return &domain.Card{ID: id, OwnerID: "owner-" + id, Type: "paper", TemplateID: "paper-scroll", Power: 3}
+	},
+	Change: func(card domain.CardInterface) {
+		card.(*domain.Card).Type = "scissors"
+		card.(*domain.Card).TemplateID = "scissors-blade"
+		card.(*domain.Card).Power = 4
+	},
+}
+
+// Matches cria partidas cujos jogadores não existem em Users: a partida guarda só o
+// retrato deles.
+var Matches = Fixture[domain.MatchInterface]{
+	New: func(id string) domain.MatchInterface {
+		return &domain.Match{
+			ID: id,
+			Players: []domain.UserInterface{
+				&domain.User{ID: id + "-p1", Username: id + "-p1", CreatedAt: time.Unix(1700000000, 0).UTC()},
+				&domain.User{ID: id + "-p2", Username: id + "-p2", CreatedAt: time.Unix(1700000100, 0).UTC()},
+			},
+			Moves: []map[string]domain.CardInterface{
+				{id + "-p1": &domain.Card{ID: "c1", OwnerID: id + "-p1", Type: "rock", TemplateID: "rock-golem", Power: 5}, id + "-p2": &domain.Card{ID: "c2", OwnerID: id + "-p2", Type: "scissors"}},
+			},
+			RoundWinners: []string{id + "-p1"},
+			Scores:       map[string]int{id + "-p1": 1},
+		}
+	},
+	Change: func(match domain.MatchInterface) {
+		m := match.(*domain.Match)
+		m.Scores[m.ID+"-p1"] = 2
+		m.Winner = m.ID + "-p1"
+	},
+}
+
+func version(entity any) int64 {
+	if v, ok := entity.(data.Versioned); ok {
+		return v.GetVersion()
+	}
+	return 0
+}
+
+// TestRepository confere o contrato de data.Repository sobre um repositório vazio.
+// Entidades versionadas também passam pelo controle otimista de Update.
+func TestRepository[T any](t *testing.T, repo data.Repository[T], f Fixture[T]) {
+	t.Helper()
+
+	if _, err := repo.Read("missing"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Read of a missing id: got %v, want ErrNotFound", err)
+	}
+	if err := repo.Update("missing", f.New("missing")); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Update of a missing id: got %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete("missing"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Delete of a missing id: got %v, want ErrNotFound", err)
+	}
+
+	ids := []string{"a", "b", "c"}
+	for _, id := range ids {
+		if err := repo.Create(id, f.New(id)); err != nil {
+			t.Fatalf("Create %s: %v", id, err)
+		}
+	}
+	if err := repo.Create("a", f.New("a")); !errors.Is(err, data.ErrAlreadyExists) {
+		t.Errorf("Create of an existing id: got %v, want ErrAlreadyExists", err)
+	}
+
+	got, err := repo.Read("a")
+	if err != nil || !reflect.DeepEqual(got, f.New("a")) {
+		t.Fatalf("Read after Create: got %+v, want %+v (err %v)", got, f.New("a"), err)
+	}
+
+	// Update grava a alteração e incrementa a versão da instância; a cópia antiga fica velha
+	stale, _ := repo.Read("a")
+	f.Change(got)
+	if err := repo.Update("a", got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	updated, err := repo.Read("a")
+	if err != nil || !reflect.DeepEqual(updated, got) {
+		t.Fatalf("Read after Update: got %+v, want %+v (err %v)", updated, got, err)
+	}
+	if _, ok := any(got).(data.Versioned); ok {
+		if version(got) != version(stale)+1 {
+			t.Errorf("Update must bump the version: got %d, want %d", version(got), version(stale)+1)
+		}
+		f.Change(stale)
+		if err := repo.Update("a", stale); !errors.Is(err, data.ErrConflict) {
+			t.Errorf("Update with a stale version: got %v, want ErrConflict", err)
+		}
+	}
+
+	list, err := repo.List()
+	if err != nil || len(list) != len(ids) {
+		t.Errorf("List: got %d entities, want %d (err %v)", len(list), len(ids), err)
+	}
+	filtered, err := repo.ListBy(func(entity T) bool { return reflect.DeepEqual(entity, updated) })
+	if err != nil || len(filtered) != 1 {
+		t.Errorf("ListBy: got %d entities, want 1 (err %v)", len(filtered), err)
+	}
+	page, err := repo.Find(data.NewQuery().Order("id", true).Page(2, 0))
+	if err != nil || len(page.Items) != 2 || any(page.Items[0]).(interface{ GetID() string }).GetID() != "c" {
+		t.Errorf("Find: got %+v (err %v)", page.Items, err)
+	}
+	count, err := repo.Count(data.NewQuery().Where("id", "b"))
+	if err != nil || count != 1 {
+		t.Errorf("Count: got %d, want 1 (err %v)", count, err)
+	}
+
+	if err := repo.Delete("a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.Read("a"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Read after Delete: got %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete("a"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("second Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestUnitOfWork confere que uow grava em repos tudo ou nada e que a transação lê as
+// próprias escritas. repos são os repositórios fora da transação, vazios no início.
+func TestUnitOfWork(t *testing.T, repos data.Tx, uow data.UnitOfWork) {
+	t.Helper()
+
+	failure := errors.New("abort")
+	err := uow.Do(func(tx data.Tx) error {
+		if err := tx.Users.Create("rolled-back", NewUser("rolled-back")); err != nil {
+			return err
+		}
+		if err := tx.Cards.Create("rolled-back", Cards.New("rolled-back")); err != nil {
+			return err
+		}
+		if _, err := tx.Users.Read("rolled-back"); err != nil {
+			return err
+		}
+		if err := tx.Users.Create("rolled-back", NewUser("rolled-back")); !errors.Is(err, data.ErrAlreadyExists) {
+			t.Errorf("Create of an id written in the same unit of work: got %v, want ErrAlreadyExists", err)
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Do: got %v, want the function's error", err)
+	}
+	if _, err := repos.Users.Read("rolled-back"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("user written by a rolled back unit of work: got %v, want ErrNotFound", err)
+	}
+	if _, err := repos.Cards.Read("rolled-back"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("card written by a rolled back unit of work: got %v, want ErrNotFound", err)
+	}
+
+	err = uow.Do(func(tx data.Tx) error {
+		if err := tx.Users.Create("committed", NewUser("committed")); err != nil {
+			return err
+		}
+		return tx.Cards.Create("committed", Cards.New("committed"))
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if _, err := repos.Users.Read("committed"); err != nil {
+		t.Errorf("committed user: %v", err)
+	}
+	if _, err := repos.Cards.Read("committed"); err != nil {
+		t.Errorf("committed card: %v", err)
+	}
+}
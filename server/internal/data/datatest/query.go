@@ -0,0 +1,170 @@
+package datatest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+// queryCards são as cartas de TestCardQueries: id, dono e tipo.
+var queryCards = [][3]string{
+	{"c1", "alice", "fire"},
+	{"c2", "bob", "water"},
+	{"c3", "alice", "water"},
+	{"c4", "alice", "fire"},
+	{"c5", "bob", "fire"},
+	{"c6", "alice", "earth"},
+	{"c7", "carol", "water"},
+}
+
+// TestCardQueries confere a semântica de data.Query (filtros, ordenação com desempate pelo id,
+// limit/offset, cursor e erros) sobre um repositório de cartas vazio. Todos os backends
+// precisam devolver as mesmas páginas que data.ApplyQuery.
+func TestCardQueries(t *testing.T, repo data.Repository[domain.CardInterface]) {
+	t.Helper()
+	for _, c := range queryCards {
+		if err := repo.Create(c[0], &domain.Card{ID: c[0], OwnerID: c[1], Type: c[2]}); err != nil {
+			t.Fatalf("Create %s: %v", c[0], err)
+		}
+	}
+	alice := data.NewQuery().Where("owner_id", "alice")
+
+	pages := []struct {
+		name  string
+		query data.Query
+		want  []string
+	}{
+		{"all by id", data.NewQuery(), []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7"}},
+		{"filter", alice, []string{"c1", "c3", "c4", "c6"}},
+		{"two filters", alice.Where("type", "fire"), []string{"c1", "c4"}},
+		{"no match", data.NewQuery().Where("owner_id", "nobody"), []string{}},
+		{"id desc", data.NewQuery().Order("id", true).Page(3, 0), []string{"c7", "c6", "c5"}},
+		{"field asc ties by id", data.NewQuery().Order("type", false), []string{"c6", "c1", "c4", "c5", "c2", "c3", "c7"}},
+		{"field desc ties by id desc", data.NewQuery().Order("type", true), []string{"c7", "c3", "c2", "c5", "c4", "c1", "c6"}},
+		{"limit and offset", data.NewQuery().Order("type", false).Page(2, 2), []string{"c4", "c5"}},
+		{"offset without limit", alice.Order("type", true).Page(0, 1), []string{"c4", "c1", "c6"}},
+		{"offset past the end", data.NewQuery().Page(2, 10), []string{}},
+	}
+	for _, tt := range pages {
+		page, err := repo.Find(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := cardIDs(page.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Percorrer por cursor devolve a mesma sequência da consulta sem paginação
+	walks := []data.Query{
+		data.NewQuery().Order("type", false).Page(3, 0),
+		data.NewQuery().Order("type", true).Page(2, 0),
+		data.NewQuery().Order("id", true).Page(3, 0),
+		alice.Order("type", false).Page(1, 0),
+		alice.Page(4, 0),
+	}
+	for _, query := range walks {
+		full, err := repo.Find(query.Page(0, 0))
+		if err != nil {
+			t.Fatalf("Find %+v: %v", query, err)
+		}
+		var walked []string
+		for next, i := query, 0; ; i++ {
+			page, err := repo.Find(next)
+			if err != nil {
+				t.Fatalf("Find %+v: %v", next, err)
+			}
+			if len(page.Items) > query.Limit {
+				t.Fatalf("page of %d items for limit %d", len(page.Items), query.Limit)
+			}
+			walked = append(walked, cardIDs(page.Items)...)
+			if page.NextCursor == "" || i > len(queryCards) {
+				break
+			}
+			next = query.StartAfter(page.NextCursor)
+		}
+		if want := cardIDs(full.Items); !reflect.DeepEqual(walked, want) {
+			t.Errorf("cursor walk over %+v: got %v, want %v", query, walked, want)
+		}
+	}
+
+	count, err := repo.Count(alice.Order("type", true).Page(1, 1))
+	if err != nil || count != 4 {
+		t.Errorf("Count ignores paging: got %d, want 4 (err %v)", count, err)
+	}
+
+	typeCursor, err := repo.Find(data.NewQuery().Order("type", false).Page(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongValue, err := data.EncodeCursor(data.Cursor{Field: "type", Value: 5, ID: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := []struct {
+		name  string
+		query data.Query
+		want  error
+	}{
+		{"number for a text field", data.NewQuery().Where("owner_id", 7), data.ErrUnsupportedQuery},
+		{"bool for a text field", data.NewQuery().Where("type", true), data.ErrUnsupportedQuery},
+		{"unknown filter field", data.NewQuery().Where("power", 3), data.ErrUnsupportedQuery},
+		{"unknown order field", data.NewQuery().Order("power", false), data.ErrUnsupportedQuery},
+		{"negative limit", data.NewQuery().Page(-1, 0), data.ErrUnsupportedQuery},
+		{"cursor and offset", data.NewQuery().Order("type", false).Page(1, 1).StartAfter(typeCursor.NextCursor), data.ErrUnsupportedQuery},
+		{"garbage cursor", data.NewQuery().StartAfter("not a cursor"), data.ErrInvalidCursor},
+		{"cursor of another order", data.NewQuery().Order("id", false).StartAfter(typeCursor.NextCursor), data.ErrInvalidCursor},
+		{"cursor value of another type", data.NewQuery().Order("type", false).StartAfter(wrongValue), data.ErrInvalidCursor},
+	}
+	for _, tt := range failures {
+		if _, err := repo.Find(tt.query); !errors.Is(err, tt.want) {
+			t.Errorf("Find with %s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := repo.Count(data.NewQuery().Where("owner_id", 7)); !errors.Is(err, data.ErrUnsupportedQuery) {
+		t.Errorf("Count with a number for a text field: got %v, want ErrUnsupportedQuery", err)
+	}
+}
+
+// TestMatchQueries confere filtros booleanos sobre um repositório de partidas vazio.
+func TestMatchQueries(t *testing.T, repo data.Repository[domain.MatchInterface]) {
+	t.Helper()
+	for _, id := range []string{"m1", "m2", "m3"} {
+		match := Matches.New(id).(*domain.Match)
+		match.Cancelled = id == "m2"
+		if err := repo.Create(id, match); err != nil {
+			t.Fatalf("Create %s: %v", id, err)
+		}
+	}
+
+	for cancelled, want := range map[bool][]string{true: {"m2"}, false: {"m1", "m3"}} {
+		page, err := repo.Find(data.NewQuery().Where("cancelled", cancelled))
+		if err != nil {
+			t.Fatalf("Find cancelled=%v: %v", cancelled, err)
+		}
+		var got []string
+		for _, match := range page.Items {
+			got = append(got, match.GetID())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cancelled=%v: got %v, want %v", cancelled, got, want)
+		}
+	}
+	for _, value := range []any{"true", 1} {
+		if _, err := repo.Find(data.NewQuery().Where("cancelled", value)); !errors.Is(err, data.ErrUnsupportedQuery) {
+			t.Errorf("cancelled = %#v: got %v, want ErrUnsupportedQuery", value, err)
+		}
+	}
+}
+
+func cardIDs(cards []domain.CardInterface) []string {
+	ids := make([]string, 0, len(cards))
+	for _, card := range cards {
+		ids = append(ids, card.GetID())
+	}
+	return ids
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"errors"
+
+	"cod-server/internal/domain"
+)
+
+// Round é uma rodada de uma partida: a carta jogada por cada jogador e o vencedor, vazio em
+// empate ou enquanto a rodada não estiver completa. Number começa em 1.
+type Round struct {
+	Number   int
+	Moves    map[string]domain.CardInterface
+	WinnerID string
+}
+
+// MatchHistory é uma capacidade opcional de repositórios de partidas: consultas de histórico
+// respondidas pelo armazenamento. MatchesOf e RoundsOf a detectam por type assertion e, na
+// ausência, recorrem a ListBy e às jogadas da partida.
+type MatchHistory interface {
+	// MatchesOf lista as partidas das quais o usuário participa.
+	MatchesOf(userID string) ([]domain.MatchInterface, error)
+	// RoundsOf retorna as rodadas da partida em ordem.
+	RoundsOf(matchID string) ([]Round, error)
+}
+
+// MatchesOf lista as partidas das quais o usuário participa.
+func MatchesOf(repo Repository[domain.MatchInterface], userID string) ([]domain.MatchInterface, error) {
+	if history, ok := repo.(MatchHistory); ok {
+		return history.MatchesOf(userID)
+	}
+	return repo.ListBy(func(m domain.MatchInterface) bool {
+		for _, player := range m.GetPlayers() {
+			if player.GetID() == userID {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RoundsOf retorna as rodadas da partida em ordem.
+func RoundsOf(repo Repository[domain.MatchInterface], matchID string) ([]Round, error) {
+	if history, ok := repo.(MatchHistory); ok {
+		return history.RoundsOf(matchID)
+	}
+	entity, err := repo.Read(matchID)
+	if err != nil {
+		return nil, err
+	}
+	match, ok := entity.(*domain.Match)
+	if !ok {
+		return nil, errors.New("unsupported match type")
+	}
+	return RoundsOfMatch(match), nil
+}
+
+// RoundsOfMatch monta o histórico a partir das jogadas de domain.Match e dos vencedores
+// decididos quando cada rodada foi jogada.
+func RoundsOfMatch(match *domain.Match) []Round {
+	rounds := make([]Round, len(match.Moves))
+	for i, round := range match.Moves {
+		rounds[i] = Round{Number: i + 1, Moves: make(map[string]domain.CardInterface, len(round))}
+		if i < len(match.RoundWinners) {
+			rounds[i].WinnerID = match.RoundWinners[i]
+		}
+		for playerID, card := range round {
+			rounds[i].Moves[playerID] = card
+		}
+	}
+	return rounds
+}
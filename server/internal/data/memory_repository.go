@@ -1,11 +1,10 @@
 package data
 
-import (
-	"errors"
-	"sync"
-)
+import "sync"
 
 // MemoryRepository é uma implementação em memória da interface Repository genérica.
+// Entidades que implementam Cloner são copiadas na escrita e na leitura, e as que implementam
+// Versioned passam pela verificação de versão em Update.
 type MemoryRepository[T any] struct {
 	mu   sync.RWMutex
 	data map[string]T
@@ -24,10 +23,10 @@ func (r *MemoryRepository[T]) Create(id string, entity T) error {
 	defer r.mu.Unlock()
 
 	if _, exists := r.data[id]; exists {
-		return errors.New("entity with this id already exists")
+		return ErrAlreadyExists
 	}
 
-	r.data[id] = entity
+	r.data[id] = Clone(entity)
 	return nil
 }
 
@@ -39,22 +38,27 @@ func (r *MemoryRepository[T]) Read(id string) (T, error) {
 	entity, exists := r.data[id]
 	if !exists {
 		var zero T
-		return zero, errors.New("entity not found")
+		return zero, ErrNotFound
 	}
 
-	return entity, nil
+	return Clone(entity), nil
 }
 
-// Update substitui entidade existente pelo id; retorna erro se id não existe.
+// Update substitui entidade existente pelo id; retorna erro se id não existe e ErrConflict
+// se a versão da entidade não for a gravada.
 func (r *MemoryRepository[T]) Update(id string, entity T) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.data[id]; !exists {
-		return errors.New("entity not found")
+	stored, exists := r.data[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if err := BumpVersion(stored, entity); err != nil {
+		return err
 	}
 
-	r.data[id] = entity
+	r.data[id] = Clone(entity)
 	return nil
 }
 
@@ -64,7 +68,7 @@ func (r *MemoryRepository[T]) Delete(id string) error {
 	defer r.mu.Unlock()
 
 	if _, exists := r.data[id]; !exists {
-		return errors.New("entity not found")
+		return ErrNotFound
 	}
 
 	delete(r.data, id)
@@ -78,12 +82,30 @@ func (r *MemoryRepository[T]) List() ([]T, error) {
 
 	list := make([]T, 0, len(r.data))
 	for _, entity := range r.data {
-		list = append(list, entity)
+		list = append(list, Clone(entity))
 	}
 
 	return list, nil
 }
 
+// Find avalia a consulta sobre o mapa; campos além do id exigem entidades Queryable.
+func (r *MemoryRepository[T]) Find(q Query) (Page[T], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	page, err := ApplyQuery(r.data, q)
+	for i, entity := range page.Items {
+		page.Items[i] = Clone(entity)
+	}
+	return page, err
+}
+
+// Count conta as entidades que satisfazem os filtros.
+func (r *MemoryRepository[T]) Count(q Query) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return CountQuery(r.data, q)
+}
+
 // ListBy retorna entidades que casam com o filtro fornecido.
 func (r *MemoryRepository[T]) ListBy(filter func(T) bool) ([]T, error) {
 	r.mu.RLock()
@@ -92,7 +114,7 @@ func (r *MemoryRepository[T]) ListBy(filter func(T) bool) ([]T, error) {
 	list := make([]T, 0)
 	for _, entity := range r.data {
 		if filter(entity) {
-			list = append(list, entity)
+			list = append(list, Clone(entity))
 		}
 	}
 
@@ -0,0 +1,116 @@
+package persistence
+
+// Backfill do poder das cartas: cartas e jogadas gravadas antes de as cartas guardarem o poder
+// (migração 0004_card_power) recebem o do seu modelo no catálogo embutido. Cartas sem modelo,
+// ou de modelos de fora dele, ficam com poder 0 e continuam valendo pelo tipo. O SQLite roda
+// o backfill na transação da migração; o Bolt, uma vez por arquivo, na abertura.
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"cod-server/internal/data/catalog"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// cardPowerMigration é a versão da migração que acrescenta o poder às cartas.
+const cardPowerMigration = 4
+
+// cardPowerBackfilled marca, em metaBucket, um arquivo Bolt que já passou pelo backfill.
+var cardPowerBackfilled = []byte("card_power_backfilled")
+
+// templatePowers retorna o poder de cada modelo do catálogo embutido.
+func templatePowers() map[string]int {
+	templates := catalog.Default().Templates
+	powers := make(map[string]int, len(templates))
+	for _, template := range templates {
+		powers[template.ID] = template.Power
+	}
+	return powers
+}
+
+// backfillSQLCardPower preenche cards.power e match_moves.card_power na transação da migração.
+func backfillSQLCardPower(tx *sql.Tx) error {
+	for templateID, power := range templatePowers() {
+		if _, err := tx.Exec("UPDATE cards SET power = ? WHERE template_id = ? AND power = 0", power, templateID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE match_moves SET card_power = ? WHERE card_template_id = ? AND card_power = 0", power, templateID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillBoltCardPower preenche o poder das cartas e das jogadas gravadas no arquivo, se
+// ainda não foi feito. As versões ficam como estão, como no UPDATE do SQLite.
+func backfillBoltCardPower(tx *bolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	if meta.Get(cardPowerBackfilled) != nil {
+		return nil
+	}
+	powers := templatePowers()
+	fill := func(card *cardDTO) bool {
+		power := powers[card.TemplateID]
+		if card.Power != 0 || power == 0 {
+			return false
+		}
+		card.Power = power
+		return true
+	}
+
+	if err := rewrite(tx.Bucket(cardsBucket), func(raw []byte) ([]byte, error) {
+		var card cardDTO
+		if err := json.Unmarshal(raw, &card); err != nil || !fill(&card) {
+			return nil, err
+		}
+		return json.Marshal(card)
+	}); err != nil {
+		return err
+	}
+	if err := rewrite(tx.Bucket(matchesBucket), func(raw []byte) ([]byte, error) {
+		var record matchRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, err
+		}
+		changed := false
+		for _, round := range record.Moves {
+			for playerID, card := range round {
+				if fill(&card) {
+					round[playerID] = card
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			return nil, nil
+		}
+		return json.Marshal(record)
+	}); err != nil {
+		return err
+	}
+	return meta.Put(cardPowerBackfilled, []byte{1})
+}
+
+// rewrite troca cada valor do bucket pelo que update retornar; nil mantém o valor.
+func rewrite(bucket *bolt.Bucket, update func(raw []byte) ([]byte, error)) error {
+	updated := make(map[string][]byte)
+	err := bucket.ForEach(func(key, raw []byte) error {
+		value, err := update(raw)
+		if value != nil {
+			updated[string(key)] = value
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// Gravar durante o ForEach invalidaria o cursor
+	for key, value := range updated {
+		if err := bucket.Put([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,508 @@
+package persistence
+
+// Repositórios sobre BoltDB: um arquivo embutido, sem servidor, com um bucket por coleção e
+// os valores gravados em JSON pelos mesmos DTOs das colunas SQL. Cada operação roda em uma
+// transação do Bolt; BoltUnitOfWork estende uma única transação de escrita a várias entidades.
+// A versão é conferida em Go (data.BumpVersion), já que o Bolt não tem UPDATE condicional;
+// as transações de escrita do Bolt são serializadas, então leitura e gravação não se intercalam.
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	usersBucket     = []byte("users")
+	cardsBucket     = []byte("cards")
+	matchesBucket   = []byte("matches")
+	usernamesBucket = []byte("usernames") // username -> id: unicidade e FindByUsername
+	metaBucket      = []byte("meta")      // Marcas dos backfills já feitos no arquivo
+)
+
+// OpenBolt abre (ou cria) o arquivo e garante os buckets.
+func OpenBolt(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{usersBucket, cardsBucket, matchesBucket, usernamesBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return backfillBoltCardPower(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// boltIndex mantém um bucket chave única -> id, atualizado na mesma transação da entidade.
+type boltIndex[T any] struct {
+	bucket []byte
+	key    func(T) string
+	taken  error // Retornado quando a chave pertence a outro id
+}
+
+// boltCodec converte a entidade no valor gravado e de volta. decode recebe a transação para
+// completar a entidade com dados de outros buckets.
+type boltCodec[T any] struct {
+	bucket []byte
+	encode func(T) ([]byte, error)
+	decode func(tx *bolt.Tx, raw []byte) (T, error)
+	index  *boltIndex[T]
+}
+
+// BoltRepository implementa data.Repository[T] sobre um bucket.
+type BoltRepository[T any] struct {
+	db    *bolt.DB
+	tx    *bolt.Tx // Transação da unidade de trabalho, se houver
+	codec boltCodec[T]
+}
+
+func (r *BoltRepository[T]) view(fn func(tx *bolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.View(fn)
+}
+
+func (r *BoltRepository[T]) update(fn func(tx *bolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.Update(fn)
+}
+
+func (r *BoltRepository[T]) get(tx *bolt.Tx, id string) (T, bool, error) {
+	raw := tx.Bucket(r.codec.bucket).Get([]byte(id))
+	if raw == nil {
+		var zero T
+		return zero, false, nil
+	}
+	entity, err := r.codec.decode(tx, raw)
+	if err != nil {
+		return entity, false, fmt.Errorf("decoding %s %s: %w", r.codec.bucket, id, err)
+	}
+	return entity, true, nil
+}
+
+func (r *BoltRepository[T]) put(tx *bolt.Tx, id string, entity T) error {
+	raw, err := r.codec.encode(entity)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(r.codec.bucket).Put([]byte(id), raw)
+}
+
+// checkIndex recusa a escrita se a chave única de entity pertencer a outro id.
+func (r *BoltRepository[T]) checkIndex(tx *bolt.Tx, id string, entity T) error {
+	index := r.codec.index
+	if index == nil {
+		return nil
+	}
+	owner := tx.Bucket(index.bucket).Get([]byte(index.key(entity)))
+	if owner != nil && string(owner) != id {
+		return index.taken
+	}
+	return nil
+}
+
+// reindex move a chave única de old para entity; old é nil na criação. Chamar após checkIndex.
+func (r *BoltRepository[T]) reindex(tx *bolt.Tx, id string, old *T, entity T) error {
+	index := r.codec.index
+	if index == nil {
+		return nil
+	}
+	bucket := tx.Bucket(index.bucket)
+	key := index.key(entity)
+	if old != nil {
+		if oldKey := index.key(*old); oldKey != key {
+			if err := bucket.Delete([]byte(oldKey)); err != nil {
+				return err
+			}
+		}
+	}
+	return bucket.Put([]byte(key), []byte(id))
+}
+
+func (r *BoltRepository[T]) Create(id string, entity T) error {
+	return r.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(r.codec.bucket).Get([]byte(id)) != nil {
+			return data.ErrAlreadyExists
+		}
+		if err := r.checkIndex(tx, id, entity); err != nil {
+			return err
+		}
+		if err := r.reindex(tx, id, nil, entity); err != nil {
+			return err
+		}
+		return r.put(tx, id, entity)
+	})
+}
+
+func (r *BoltRepository[T]) Read(id string) (T, error) {
+	var entity T
+	err := r.view(func(tx *bolt.Tx) error {
+		stored, found, err := r.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return data.ErrNotFound
+		}
+		entity = stored
+		return nil
+	})
+	return entity, err
+}
+
+func (r *BoltRepository[T]) Update(id string, entity T) error {
+	return r.update(func(tx *bolt.Tx) error {
+		stored, found, err := r.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return data.ErrNotFound
+		}
+		if err := r.checkIndex(tx, id, entity); err != nil {
+			return err
+		}
+		if err := data.BumpVersion(stored, entity); err != nil {
+			return err
+		}
+		if err := r.reindex(tx, id, &stored, entity); err != nil {
+			return err
+		}
+		return r.put(tx, id, entity)
+	})
+}
+
+func (r *BoltRepository[T]) Delete(id string) error {
+	return r.update(func(tx *bolt.Tx) error {
+		stored, found, err := r.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return data.ErrNotFound
+		}
+		if index := r.codec.index; index != nil {
+			if err := tx.Bucket(index.bucket).Delete([]byte(index.key(stored))); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(r.codec.bucket).Delete([]byte(id))
+	})
+}
+
+// all decodifica o bucket inteiro; Find e Count avaliam a consulta sobre o resultado.
+func (r *BoltRepository[T]) all() (map[string]T, error) {
+	entities := make(map[string]T)
+	err := r.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(r.codec.bucket).ForEach(func(key, raw []byte) error {
+			entity, err := r.codec.decode(tx, raw)
+			if err != nil {
+				return fmt.Errorf("decoding %s %s: %w", r.codec.bucket, key, err)
+			}
+			entities[string(key)] = entity
+			return nil
+		})
+	})
+	return entities, err
+}
+
+func (r *BoltRepository[T]) List() ([]T, error) {
+	return r.ListBy(func(T) bool { return true })
+}
+
+func (r *BoltRepository[T]) ListBy(filter func(T) bool) ([]T, error) {
+	entities, err := r.all()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0, len(entities))
+	for _, entity := range entities {
+		if filter(entity) {
+			list = append(list, entity)
+		}
+	}
+	return list, nil
+}
+
+func (r *BoltRepository[T]) Find(q data.Query) (data.Page[T], error) {
+	entities, err := r.all()
+	if err != nil {
+		return data.Page[T]{}, err
+	}
+	return data.ApplyQuery(entities, q)
+}
+
+func (r *BoltRepository[T]) Count(q data.Query) (int, error) {
+	entities, err := r.all()
+	if err != nil {
+		return 0, err
+	}
+	return data.CountQuery(entities, q)
+}
+
+// userRecord é o valor gravado em usersBucket.
+type userRecord struct {
+	ID        string        `json:"id"`
+	Username  string        `json:"username"`
+	Password  string        `json:"password"`
+	Cards     *packDTO      `json:"cards"`
+	Roles     []domain.Role `json:"roles"`
+	CreatedAt time.Time     `json:"created_at"`
+	Version   int64         `json:"version"`
+}
+
+func encodeUserRecord(entity domain.UserInterface) ([]byte, error) {
+	user, ok := entity.(*domain.User)
+	if !ok {
+		user = &domain.User{ID: entity.GetID(), Username: entity.GetUsername(), Roles: entity.GetRoles(), CreatedAt: entity.GetCreatedAt()}
+	}
+	record := userRecord{
+		ID:        user.ID,
+		Username:  user.Username,
+		Password:  user.Password,
+		Roles:     user.Roles,
+		CreatedAt: user.CreatedAt,
+		Version:   user.Version,
+	}
+	if user.Cards != nil {
+		record.Cards = &packDTO{ID: user.Cards.GetID()}
+		if cards := user.Cards.GetCards(); cards != nil {
+			record.Cards.Cards = make([]cardDTO, len(cards))
+			for i, card := range cards {
+				record.Cards.Cards[i] = toCardDTO(card)
+			}
+		}
+	}
+	return json.Marshal(record)
+}
+
+func decodeUserRecord(raw []byte) (*domain.User, error) {
+	var record userRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, err
+	}
+	user := &domain.User{
+		ID:        record.ID,
+		Username:  record.Username,
+		Password:  record.Password,
+		Roles:     record.Roles,
+		CreatedAt: record.CreatedAt,
+		Version:   record.Version,
+	}
+	if record.Cards != nil {
+		pack := &domain.Pack{ID: record.Cards.ID}
+		if record.Cards.Cards != nil {
+			pack.Cards = make([]domain.CardInterface, len(record.Cards.Cards))
+			for i, card := range record.Cards.Cards {
+				pack.Cards[i] = card.toDomain()
+			}
+		}
+		user.Cards = pack
+	}
+	return user, nil
+}
+
+// BoltUserRepository acrescenta a busca pelo índice de usernames (data.UserFinder).
+type BoltUserRepository struct {
+	*BoltRepository[domain.UserInterface]
+}
+
+func NewBoltUserRepository(db *bolt.DB) data.Repository[domain.UserInterface] {
+	return newBoltUserRepository(db, nil)
+}
+
+func newBoltUserRepository(db *bolt.DB, tx *bolt.Tx) *BoltUserRepository {
+	return &BoltUserRepository{&BoltRepository[domain.UserInterface]{db: db, tx: tx, codec: boltCodec[domain.UserInterface]{
+		bucket: usersBucket,
+		encode: encodeUserRecord,
+		decode: func(_ *bolt.Tx, raw []byte) (domain.UserInterface, error) {
+			return decodeUserRecord(raw)
+		},
+		index: &boltIndex[domain.UserInterface]{
+			bucket: usernamesBucket,
+			key:    domain.UserInterface.GetUsername,
+			taken:  domain.ErrUsernameTaken,
+		},
+	}}}
+}
+
+// FindByUsername implementa data.UserFinder; retorna nil, nil quando o username não existe.
+func (r *BoltUserRepository) FindByUsername(username string) (domain.UserInterface, error) {
+	var user domain.UserInterface
+	err := r.view(func(tx *bolt.Tx) error {
+		id := tx.Bucket(usernamesBucket).Get([]byte(username))
+		if id == nil {
+			return nil
+		}
+		stored, found, err := r.get(tx, string(id))
+		if found {
+			user = stored
+		}
+		return err
+	})
+	return user, err
+}
+
+func NewBoltCardRepository(db *bolt.DB) data.Repository[domain.CardInterface] {
+	return newBoltCardRepository(db, nil)
+}
+
+func newBoltCardRepository(db *bolt.DB, tx *bolt.Tx) *BoltRepository[domain.CardInterface] {
+	return &BoltRepository[domain.CardInterface]{db: db, tx: tx, codec: boltCodec[domain.CardInterface]{
+		bucket: cardsBucket,
+		encode: func(card domain.CardInterface) ([]byte, error) {
+			return json.Marshal(toCardDTO(card))
+		},
+		decode: func(_ *bolt.Tx, raw []byte) (domain.CardInterface, error) {
+			var dto cardDTO
+			if err := json.Unmarshal(raw, &dto); err != nil {
+				return nil, err
+			}
+			return dto.toDomain(), nil
+		},
+	}}
+}
+
+// matchRecord é o valor gravado em matchesBucket. Os jogadores são gravados como retrato e,
+// como no SQL, recarregados de usersBucket na leitura enquanto a conta existir.
+type matchRecord struct {
+	ID      string               `json:"id"`
+	Players []playerDTO          `json:"players"`
+	Moves   []map[string]cardDTO `json:"moves"`
+	// RoundWinners são os vencedores decididos quando cada rodada foi jogada.
+	RoundWinners []string       `json:"round_winners"`
+	Scores       map[string]int `json:"scores"`
+	Winner       string         `json:"winner"`
+	Cancelled    bool           `json:"cancelled"`
+	Version      int64          `json:"version"`
+}
+
+func encodeMatchRecord(entity domain.MatchInterface) ([]byte, error) {
+	match, ok := entity.(*domain.Match)
+	if !ok {
+		match = &domain.Match{ID: entity.GetID(), Players: entity.GetPlayers(), Scores: entity.GetScores(), Cancelled: entity.IsCancelled()}
+	}
+	record := matchRecord{
+		ID:           match.ID,
+		RoundWinners: match.RoundWinners,
+		Scores:       match.Scores,
+		Winner:       match.Winner,
+		Cancelled:    match.Cancelled,
+		Version:      match.GetVersion(),
+	}
+	if match.Players != nil {
+		record.Players = make([]playerDTO, len(match.Players))
+		for i, player := range match.Players {
+			record.Players[i] = toPlayerDTO(player)
+		}
+	}
+	if match.Moves != nil {
+		record.Moves = make([]map[string]cardDTO, len(match.Moves))
+		for i, round := range match.Moves {
+			record.Moves[i] = make(map[string]cardDTO, len(round))
+			for playerID, card := range round {
+				record.Moves[i][playerID] = toCardDTO(card)
+			}
+		}
+	}
+	return json.Marshal(record)
+}
+
+func decodeMatchRecord(tx *bolt.Tx, raw []byte) (domain.MatchInterface, error) {
+	var record matchRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, err
+	}
+	users := tx.Bucket(usersBucket)
+	return record.toDomain(func(id string) (*domain.User, error) {
+		raw := users.Get([]byte(id))
+		if raw == nil {
+			return nil, nil
+		}
+		return decodeUserRecord(raw)
+	})
+}
+
+// toDomain monta a partida; user busca a conta atual de cada jogador e retorna nil quando ela
+// não existe mais, caso em que fica o retrato gravado.
+func (record matchRecord) toDomain(user func(id string) (*domain.User, error)) (*domain.Match, error) {
+	match := &domain.Match{
+		ID:           record.ID,
+		RoundWinners: record.RoundWinners,
+		Scores:       record.Scores,
+		Winner:       record.Winner,
+		Cancelled:    record.Cancelled,
+		Version:      record.Version,
+	}
+	if record.Players != nil {
+		match.Players = make([]domain.UserInterface, len(record.Players))
+		for i, player := range record.Players {
+			match.Players[i] = player.toDomain()
+			current, err := user(player.ID)
+			if err != nil {
+				return nil, fmt.Errorf("decoding player %s: %w", player.ID, err)
+			}
+			if current != nil {
+				match.Players[i] = current
+			}
+		}
+	}
+	if record.Moves != nil {
+		match.Moves = make([]map[string]domain.CardInterface, len(record.Moves))
+		for i, round := range record.Moves {
+			match.Moves[i] = make(map[string]domain.CardInterface, len(round))
+			for playerID, card := range round {
+				match.Moves[i][playerID] = card.toDomain()
+			}
+		}
+	}
+	return match, nil
+}
+
+func NewBoltMatchRepository(db *bolt.DB) data.Repository[domain.MatchInterface] {
+	return newBoltMatchRepository(db, nil)
+}
+
+func newBoltMatchRepository(db *bolt.DB, tx *bolt.Tx) *BoltRepository[domain.MatchInterface] {
+	return &BoltRepository[domain.MatchInterface]{db: db, tx: tx, codec: boltCodec[domain.MatchInterface]{
+		bucket: matchesBucket,
+		encode: encodeMatchRecord,
+		decode: decodeMatchRecord,
+	}}
+}
+
+// BoltUnitOfWork roda a unidade de trabalho em uma transação de escrita do Bolt: as escritas
+// valem todas no commit ou nenhuma, e outras escritas esperam até ela terminar.
+type BoltUnitOfWork struct {
+	db *bolt.DB
+}
+
+func NewBoltUnitOfWork(db *bolt.DB) data.UnitOfWork {
+	return &BoltUnitOfWork{db: db}
+}
+
+func (u *BoltUnitOfWork) Do(fn func(tx data.Tx) error) error {
+	return u.db.Update(func(tx *bolt.Tx) error {
+		return fn(data.Tx{
+			Users:   newBoltUserRepository(u.db, tx),
+			Cards:   newBoltCardRepository(u.db, tx),
+			Matches: newBoltMatchRepository(u.db, tx),
+		})
+	})
+}
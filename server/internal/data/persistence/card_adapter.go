@@ -21,6 +21,7 @@ func (a *CardRepoAdapter) Create(id string, entity domain.CardInterface) error {
 			ID:      entity.GetID(),
 			OwnerID: entity.GetOwnerID(),
 			Type:    entity.GetType(),
+			Power:   entity.GetPower(),
 		})
 	}
 	return a.repo.Create(id, card)
@@ -41,6 +42,7 @@ func (a *CardRepoAdapter) Update(id string, entity domain.CardInterface) error {
 			ID:      entity.GetID(),
 			OwnerID: entity.GetOwnerID(),
 			Type:    entity.GetType(),
+			Power:   entity.GetPower(),
 		})
 	}
 	return a.repo.Update(id, card)
@@ -77,3 +79,25 @@ func (a *CardRepoAdapter) ListBy(filter func(domain.CardInterface) bool) ([]doma
 	}
 	return result, nil
 }
+
+// Find delega a consulta ao SQL e calcula o cursor da próxima página a partir do último item.
+func (a *CardRepoAdapter) Find(q data.Query) (data.Page[domain.CardInterface], error) {
+	cards, err := a.repo.Find(q)
+	if err != nil {
+		return data.Page[domain.CardInterface]{}, err
+	}
+	cursor, err := data.NextCursor(cards, q)
+	if err != nil {
+		return data.Page[domain.CardInterface]{}, err
+	}
+
+	items := make([]domain.CardInterface, len(cards))
+	for i, item := range cards {
+		items[i] = item
+	}
+	return data.Page[domain.CardInterface]{Items: items, NextCursor: cursor}, nil
+}
+
+func (a *CardRepoAdapter) Count(q data.Query) (int, error) {
+	return a.repo.Count(q)
+}
@@ -4,53 +4,45 @@ package persistence
 
 import (
 	"database/sql"
-	"fmt"
+	"errors"
 
+	"cod-server/internal/data"
 	"cod-server/internal/domain"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SqlCardRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 func NewSqlCardRepository(db *sql.DB) CardRepository {
-	// Garante que a tabela cards exista na inicialização.
+	// O esquema da tabela cards é criado pelas migrações (ver Migrate).
 	// Create insere uma nova carta.
 	// Read busca carta por id; retorna erro se não encontrar.
-	// Update modifica dono e tipo para o id fornecido.
+	// Update modifica dono, tipo, modelo e poder para o id fornecido.
 	// Delete remove uma carta; retorna erro se nenhuma linha for afetada.
 	// List recupera todas as cartas do banco.
 	// ListBy filtra cartas em memória usando o predicado fornecido.
-	query := `CREATE TABLE IF NOT EXISTS cards (
-		id TEXT PRIMARY KEY,
-		owner_id TEXT NOT NULL,
-		card_type TEXT NOT NULL
-	)`
-	_, err := db.Exec(query)
-	if err != nil {
-		panic(fmt.Sprintf("Falha ao criar tabela cards: %v", err))
-	}
-
+	// Find e Count traduzem data.Query para WHERE sobre colunas indexadas.
 	return &SqlCardRepository{db: db}
 }
 
 func (r *SqlCardRepository) Create(id string, card *domain.Card) error {
-	_, err := r.db.Exec("INSERT INTO cards (id, owner_id, card_type) VALUES (?, ?, ?)",
-		id, card.OwnerID, card.Type)
-	return err
+	_, err := r.db.Exec("INSERT INTO cards (id, owner_id, card_type, template_id, power, version) VALUES (?, ?, ?, ?, ?, ?)",
+		id, card.OwnerID, card.Type, card.TemplateID, card.Power, card.Version)
+	return translateInsertError(err)
 }
 
 func (r *SqlCardRepository) Read(id string) (*domain.Card, error) {
 	var card domain.Card
 
-	err := r.db.QueryRow("SELECT id, owner_id, card_type FROM cards WHERE id = ?", id).
-		Scan(&card.ID, &card.OwnerID, &card.Type)
+	err := r.db.QueryRow("SELECT "+cardSelectColumns+" FROM cards WHERE id = ?", id).
+		Scan(&card.ID, &card.OwnerID, &card.Type, &card.TemplateID, &card.Power, &card.Version)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("card not found")
+			return nil, notFound("card", id)
 		}
 		return nil, err
 	}
@@ -59,21 +51,19 @@ func (r *SqlCardRepository) Read(id string) (*domain.Card, error) {
 }
 
 func (r *SqlCardRepository) Update(id string, card *domain.Card) error {
-	result, err := r.db.Exec("UPDATE cards SET owner_id = ?, card_type = ? WHERE id = ?",
-		card.OwnerID, card.Type, id)
+	result, err := r.db.Exec("UPDATE cards SET owner_id = ?, card_type = ?, template_id = ?, power = ?, version = version + 1 WHERE id = ? AND version = ?",
+		card.OwnerID, card.Type, card.TemplateID, card.Power, id, card.Version)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	if err := checkVersionedUpdate(r.db, "cards", id, result); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return notFound("card", id)
+		}
 		return err
 	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("card not found")
-	}
-
+	card.Version++
 	return nil
 }
 
@@ -89,24 +79,54 @@ func (r *SqlCardRepository) Delete(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("card not found")
+		return notFound("card", id)
 	}
 
 	return nil
 }
 
 func (r *SqlCardRepository) List() ([]*domain.Card, error) {
-	rows, err := r.db.Query("SELECT id, owner_id, card_type FROM cards")
+	rows, err := r.db.Query("SELECT " + cardSelectColumns + " FROM cards")
 	if err != nil {
 		return nil, err
 	}
+	return scanCards(rows)
+}
+
+func (r *SqlCardRepository) Find(q data.Query) ([]*domain.Card, error) {
+	stmt, args, err := cardColumns.selectQuery(cardSelectColumns, "cards", q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanCards(rows)
+}
+
+func (r *SqlCardRepository) Count(q data.Query) (int, error) {
+	stmt, args, err := cardColumns.countQuery("cards", q)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = r.db.QueryRow(stmt, args...).Scan(&count)
+	return count, err
+}
+
+// cardSelectColumns são as colunas lidas por scanCards, na ordem do Scan.
+const cardSelectColumns = "id, owner_id, card_type, template_id, power, version"
+
+// scanCards lê todas as linhas de um SELECT cardSelectColumns e fecha rows.
+func scanCards(rows *sql.Rows) ([]*domain.Card, error) {
 	defer rows.Close()
 
 	var cards []*domain.Card
 	for rows.Next() {
 		var card domain.Card
 
-		err := rows.Scan(&card.ID, &card.OwnerID, &card.Type)
+		err := rows.Scan(&card.ID, &card.OwnerID, &card.Type, &card.TemplateID, &card.Power, &card.Version)
 		if err != nil {
 			return nil, err
 		}
@@ -114,7 +134,7 @@ func (r *SqlCardRepository) List() ([]*domain.Card, error) {
 		cards = append(cards, &card)
 	}
 
-	return cards, nil
+	return cards, rows.Err()
 }
 
 func (r *SqlCardRepository) ListBy(filter func(*domain.Card) bool) ([]*domain.Card, error) {
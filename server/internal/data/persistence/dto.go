@@ -0,0 +1,159 @@
+package persistence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"cod-server/internal/domain"
+)
+
+// DTOs das colunas gravadas a partir de interfaces do domínio (PackInterface, CardInterface,
+// UserInterface), que não podem ser desserializadas diretamente: cada uma é gravada e lida
+// por um tipo concreto e convertida para as implementações do pacote domain.
+
+// cardDTO é uma carta gravada dentro de outra entidade: no pacote de um usuário ou como
+// jogada em match_moves. A jogada guarda a carta como estava, mesmo que ela mude depois.
+type cardDTO struct {
+	ID         string `json:"id"`
+	OwnerID    string `json:"owner_id"`
+	Type       string `json:"type"`
+	TemplateID string `json:"template_id,omitempty"`
+	Power      int    `json:"power"`
+	Version    int64  `json:"version"`
+}
+
+func toCardDTO(card domain.CardInterface) cardDTO {
+	dto := cardDTO{ID: card.GetID(), OwnerID: card.GetOwnerID(), Type: card.GetType(), Power: card.GetPower()}
+	if c, ok := card.(*domain.Card); ok {
+		dto.TemplateID = c.TemplateID
+		dto.Version = c.Version
+	}
+	return dto
+}
+
+func (d cardDTO) toDomain() *domain.Card {
+	return &domain.Card{ID: d.ID, OwnerID: d.OwnerID, Type: d.Type, TemplateID: d.TemplateID, Power: d.Power, Version: d.Version}
+}
+
+type packDTO struct {
+	ID    string    `json:"id"`
+	Cards []cardDTO `json:"cards"`
+}
+
+// playerDTO é o retrato de um jogador gravado em match_players. A senha fica de fora: ao ler,
+// o usuário completo é recarregado da tabela users e o retrato só é usado se a conta não existir mais.
+type playerDTO struct {
+	ID        string
+	Username  string
+	Roles     []domain.Role
+	CreatedAt time.Time
+	Version   int64
+}
+
+func toPlayerDTO(player domain.UserInterface) playerDTO {
+	dto := playerDTO{
+		ID:        player.GetID(),
+		Username:  player.GetUsername(),
+		Roles:     player.GetRoles(),
+		CreatedAt: player.GetCreatedAt(),
+	}
+	if u, ok := player.(*domain.User); ok {
+		dto.Roles = u.Roles
+		dto.Version = u.Version
+	}
+	return dto
+}
+
+func (d playerDTO) toDomain() *domain.User {
+	return &domain.User{ID: d.ID, Username: d.Username, Roles: d.Roles, CreatedAt: d.CreatedAt, Version: d.Version}
+}
+
+// encodeJSON serializa o valor para uma coluna TEXT; nil vira NULL.
+func encodeJSON(value any) (sql.NullString, error) {
+	raw, err := json.Marshal(value)
+	if err != nil || string(raw) == "null" {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(raw), Valid: true}, nil
+}
+
+// decodeJSON lê uma coluna gravada por encodeJSON; NULL e "null" deixam target intacto.
+func decodeJSON(column sql.NullString, target any) error {
+	if !column.Valid || strings.TrimSpace(column.String) == "null" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column.String), target)
+}
+
+func encodePack(pack domain.PackInterface) (sql.NullString, error) {
+	if pack == nil {
+		return sql.NullString{}, nil
+	}
+	dto := &packDTO{ID: pack.GetID()}
+	if cards := pack.GetCards(); cards != nil {
+		dto.Cards = make([]cardDTO, len(cards))
+		for i, card := range cards {
+			dto.Cards[i] = toCardDTO(card)
+		}
+	}
+	return encodeJSON(dto)
+}
+
+func decodePack(column sql.NullString) (domain.PackInterface, error) {
+	var dto *packDTO
+	if err := decodeJSON(column, &dto); err != nil || dto == nil {
+		return nil, err
+	}
+	pack := &domain.Pack{ID: dto.ID}
+	if dto.Cards != nil {
+		pack.Cards = make([]domain.CardInterface, len(dto.Cards))
+		for i, card := range dto.Cards {
+			pack.Cards[i] = card.toDomain()
+		}
+	}
+	return pack, nil
+}
+
+// rowScanner é atendido por *sql.Row e *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const userSelectColumns = "id, username, password, cards, roles, created_at, version"
+
+// scanUser lê uma linha com userSelectColumns.
+func scanUser(row rowScanner) (*domain.User, error) {
+	var user domain.User
+	var cards sql.NullString
+	var roles, createdAt string
+
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &cards, &roles, &createdAt, &user.Version)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = decodeRoles(roles)
+	user.CreatedAt = decodeTime(createdAt)
+	if user.Cards, err = decodePack(cards); err != nil {
+		return nil, fmt.Errorf("decoding cards of user %s: %w", user.ID, err)
+	}
+	return &user, nil
+}
+
+const matchSelectColumns = "id, winner, cancelled, version"
+
+// scanMatch lê uma linha com matchSelectColumns. Jogadores, placar e jogadas ficam em
+// tabelas próprias e são carregados pelo repositório em loadDetails.
+func scanMatch(row rowScanner) (*domain.Match, error) {
+	var match domain.Match
+	var winner sql.NullString
+
+	err := row.Scan(&match.ID, &winner, &match.Cancelled, &match.Version)
+	if err != nil {
+		return nil, err
+	}
+	match.Winner = winner.String
+	return &match, nil
+}
@@ -8,6 +8,7 @@ package persistence
 // NewRepositoryManager constructs a manager for sharing DB connections across repos.
 
 import (
+	"cod-server/internal/data"
 	"cod-server/internal/domain"
 	"database/sql"
 )
@@ -19,6 +20,9 @@ type UserRepository interface {
 	Delete(id string) error
 	List() ([]*domain.User, error)
 	ListBy(filter func(*domain.User) bool) ([]*domain.User, error)
+	Find(q data.Query) ([]*domain.User, error)
+	Count(q data.Query) (int, error)
+	FindByUsername(username string) (*domain.User, error)
 }
 
 type CardRepository interface {
@@ -28,6 +32,8 @@ type CardRepository interface {
 	Delete(id string) error
 	List() ([]*domain.Card, error)
 	ListBy(filter func(*domain.Card) bool) ([]*domain.Card, error)
+	Find(q data.Query) ([]*domain.Card, error)
+	Count(q data.Query) (int, error)
 }
 
 type MatchRepository interface {
@@ -37,6 +43,10 @@ type MatchRepository interface {
 	Delete(id string) error
 	List() ([]*domain.Match, error)
 	ListBy(filter func(*domain.Match) bool) ([]*domain.Match, error)
+	Find(q data.Query) ([]*domain.Match, error)
+	Count(q data.Query) (int, error)
+	MatchesOf(userID string) ([]*domain.Match, error)
+	RoundsOf(matchID string) ([]data.Round, error)
 }
 
 type RepositoryManager struct {
@@ -18,8 +18,9 @@ func (a *MatchRepoAdapter) Create(id string, entity domain.MatchInterface) error
 	match, ok := entity.(*domain.Match)
 	if !ok {
 		return a.repo.Create(id, &domain.Match{
-			ID:      entity.GetID(),
-			Players: entity.GetPlayers(),
+			ID:        entity.GetID(),
+			Players:   entity.GetPlayers(),
+			Cancelled: entity.IsCancelled(),
 			// Obs.: Campos complexos como Moves e Scores são omitidos ao construir via métodos de interface.
 			// Uma implementação completa exigiria getters adicionais na interface.
 		})
@@ -39,8 +40,9 @@ func (a *MatchRepoAdapter) Update(id string, entity domain.MatchInterface) error
 	match, ok := entity.(*domain.Match)
 	if !ok {
 		return a.repo.Update(id, &domain.Match{
-			ID:      entity.GetID(),
-			Players: entity.GetPlayers(),
+			ID:        entity.GetID(),
+			Players:   entity.GetPlayers(),
+			Cancelled: entity.IsCancelled(),
 			// Problema similar com campos complexos exigindo getters adicionais na interface.
 		})
 	}
@@ -78,3 +80,42 @@ func (a *MatchRepoAdapter) ListBy(filter func(domain.MatchInterface) bool) ([]do
 	}
 	return result, nil
 }
+
+// Find delega a consulta ao SQL e calcula o cursor da próxima página a partir do último item.
+func (a *MatchRepoAdapter) Find(q data.Query) (data.Page[domain.MatchInterface], error) {
+	matches, err := a.repo.Find(q)
+	if err != nil {
+		return data.Page[domain.MatchInterface]{}, err
+	}
+	cursor, err := data.NextCursor(matches, q)
+	if err != nil {
+		return data.Page[domain.MatchInterface]{}, err
+	}
+
+	items := make([]domain.MatchInterface, len(matches))
+	for i, item := range matches {
+		items[i] = item
+	}
+	return data.Page[domain.MatchInterface]{Items: items, NextCursor: cursor}, nil
+}
+
+func (a *MatchRepoAdapter) Count(q data.Query) (int, error) {
+	return a.repo.Count(q)
+}
+
+// MatchesOf e RoundsOf implementam data.MatchHistory sobre as tabelas relacionadas do SQL.
+func (a *MatchRepoAdapter) MatchesOf(userID string) ([]domain.MatchInterface, error) {
+	matches, err := a.repo.MatchesOf(userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]domain.MatchInterface, len(matches))
+	for i, match := range matches {
+		result[i] = match
+	}
+	return result, nil
+}
+
+func (a *MatchRepoAdapter) RoundsOf(matchID string) ([]data.Round, error) {
+	return a.repo.RoundsOf(matchID)
+}
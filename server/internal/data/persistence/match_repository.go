@@ -4,154 +4,392 @@ package persistence
 
 import (
 	"database/sql"
-	"encoding/json"
-	"fmt"
+	"errors"
+	"strings"
 
+	"cod-server/internal/data"
 	"cod-server/internal/domain"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SqlMatchRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 func NewSqlMatchRepository(db *sql.DB) MatchRepository {
-	// Garante que a tabela matches exista na inicialização.
-	// Create insere uma nova partida com jogadores, jogadas e pontuações serializadas.
-	// Read busca partida por id; retorna erro se não encontrar. Desserialização complexa omitida.
-	// Update modifica jogadores, jogadas, pontuações e vencedor para o id fornecido.
-	// Delete remove uma partida; retorna erro se nenhuma linha for afetada.
+	// O esquema das tabelas matches, match_players, match_rounds e match_moves é criado pelas migrações (ver Migrate).
+	// Create insere a partida, seus jogadores, rodadas e jogadas em uma transação.
+	// Read busca partida por id; retorna erro se não encontrar. Jogadores voltam da tabela users.
+	// Update modifica vencedor e estado da partida e regrava jogadores, rodadas e jogadas.
+	// Delete remove uma partida e suas linhas relacionadas; retorna erro se nenhuma linha for afetada.
 	// List recupera todas as partidas do banco.
 	// ListBy filtra partidas em memória usando o predicado fornecido.
-	query := `CREATE TABLE IF NOT EXISTS matches (
-		id TEXT PRIMARY KEY,
-		players TEXT,
-		moves TEXT,
-		scores TEXT,
-		winner TEXT
-	)`
-	_, err := db.Exec(query)
-	if err != nil {
-		panic(fmt.Sprintf("Falha ao criar tabela matches: %v", err))
-	}
-
+	// Find e Count traduzem data.Query para WHERE sobre as colunas escalares.
+	// MatchesOf e RoundsOf respondem ao histórico pelas tabelas relacionadas.
 	return &SqlMatchRepository{db: db}
 }
 
 func (r *SqlMatchRepository) Create(id string, match *domain.Match) error {
-	playersJSON, err := json.Marshal(match.Players)
-	if err != nil {
-		return err
-	}
+	return withTx(r.db, func(tx dbtx) error {
+		_, err := tx.Exec("INSERT INTO matches (id, winner, cancelled, version) VALUES (?, ?, ?, ?)",
+			id, match.Winner, match.Cancelled, match.GetVersion())
+		if err != nil {
+			return translateInsertError(err)
+		}
+		return insertMatchDetails(tx, id, match)
+	})
+}
 
-	movesJSON, err := json.Marshal(match.Moves)
-	if err != nil {
-		return err
+// insertMatchDetails grava jogadores com placar, rodadas com vencedor e jogadas da partida.
+func insertMatchDetails(tx dbtx, id string, match *domain.Match) error {
+	for position, player := range match.Players {
+		dto := toPlayerDTO(player)
+		var score any
+		if value, ok := match.Scores[dto.ID]; ok {
+			score = value
+		}
+		_, err := tx.Exec("INSERT INTO match_players (match_id, user_id, position, username, roles, created_at, user_version, score) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			id, dto.ID, position, dto.Username, encodeRoles(dto.Roles), encodeTime(dto.CreatedAt), dto.Version, score)
+		if err != nil {
+			return err
+		}
 	}
 
-	scoresJSON, err := json.Marshal(match.Scores)
-	if err != nil {
-		return err
+	for i, round := range match.Moves {
+		var winner any
+		if i < len(match.RoundWinners) && match.RoundWinners[i] != "" {
+			winner = match.RoundWinners[i]
+		}
+		if _, err := tx.Exec("INSERT INTO match_rounds (match_id, round_number, winner_id) VALUES (?, ?, ?)", id, i+1, winner); err != nil {
+			return err
+		}
+		for playerID, card := range round {
+			dto := toCardDTO(card)
+			_, err := tx.Exec("INSERT INTO match_moves (match_id, round_number, player_id, card_id, card_owner_id, card_type, card_template_id, card_power, card_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				id, i+1, playerID, dto.ID, dto.OwnerID, dto.Type, dto.TemplateID, dto.Power, dto.Version)
+			if err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	_, err = r.db.Exec("INSERT INTO matches (id, players, moves, scores, winner) VALUES (?, ?, ?, ?, ?)",
-		id, string(playersJSON), string(movesJSON), string(scoresJSON), match.Winner)
-	return err
+// deleteMatchDetails apaga as linhas relacionadas à partida. As chaves estrangeiras já
+// cascateiam a exclusão, mas só quando o banco foi aberto com Open.
+func deleteMatchDetails(tx dbtx, id string) error {
+	for _, table := range []string{"match_moves", "match_rounds", "match_players"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE match_id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *SqlMatchRepository) Read(id string) (*domain.Match, error) {
-	var match domain.Match
-	var playersJSON, movesJSON, scoresJSON string
-
-	err := r.db.QueryRow("SELECT id, players, moves, scores, winner FROM matches WHERE id = ?", id).
-		Scan(&match.ID, &playersJSON, &movesJSON, &scoresJSON, &match.Winner)
-
+	match, err := scanMatch(r.db.QueryRow("SELECT "+matchSelectColumns+" FROM matches WHERE id = ?", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("match not found")
+			return nil, notFound("match", id)
 		}
 		return nil, err
 	}
-
-	// Obs.: Desserializar estruturas aninhadas (Players, Moves) exigiria tratamento mais complexo
-
-	return &match, nil
+	if err := r.loadDetails([]*domain.Match{match}); err != nil {
+		return nil, err
+	}
+	return match, nil
 }
 
 func (r *SqlMatchRepository) Update(id string, match *domain.Match) error {
-	playersJSON, err := json.Marshal(match.Players)
+	version := match.GetVersion()
+	err := withTx(r.db, func(tx dbtx) error {
+		result, err := tx.Exec("UPDATE matches SET winner = ?, cancelled = ?, version = version + 1 WHERE id = ? AND version = ?",
+			match.Winner, match.Cancelled, id, version)
+		if err != nil {
+			return err
+		}
+		if err := checkVersionedUpdate(tx, "matches", id, result); err != nil {
+			return err
+		}
+		if err := deleteMatchDetails(tx, id); err != nil {
+			return err
+		}
+		return insertMatchDetails(tx, id, match)
+	})
 	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return notFound("match", id)
+		}
 		return err
 	}
+	match.SetVersion(version + 1)
+	return nil
+}
+
+func (r *SqlMatchRepository) Delete(id string) error {
+	return withTx(r.db, func(tx dbtx) error {
+		if err := deleteMatchDetails(tx, id); err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM matches WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	movesJSON, err := json.Marshal(match.Moves)
+		if rowsAffected == 0 {
+			return notFound("match", id)
+		}
+
+		return nil
+	})
+}
+
+func (r *SqlMatchRepository) List() ([]*domain.Match, error) {
+	rows, err := r.db.Query("SELECT " + matchSelectColumns + " FROM matches")
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return r.scanMatches(rows)
+}
 
-	scoresJSON, err := json.Marshal(match.Scores)
+func (r *SqlMatchRepository) Find(q data.Query) ([]*domain.Match, error) {
+	stmt, args, err := matchColumns.selectQuery(matchSelectColumns, "matches", q)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	rows, err := r.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanMatches(rows)
+}
 
-	result, err := r.db.Exec("UPDATE matches SET players = ?, moves = ?, scores = ?, winner = ? WHERE id = ?",
-		string(playersJSON), string(movesJSON), string(scoresJSON), match.Winner, id)
+func (r *SqlMatchRepository) Count(q data.Query) (int, error) {
+	stmt, args, err := matchColumns.countQuery("matches", q)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	var count int
+	err = r.db.QueryRow(stmt, args...).Scan(&count)
+	return count, err
+}
 
-	rowsAffected, err := result.RowsAffected()
+// MatchesOf lista as partidas das quais o usuário participa, pelo índice de match_players.
+func (r *SqlMatchRepository) MatchesOf(userID string) ([]*domain.Match, error) {
+	rows, err := r.db.Query("SELECT "+matchSelectColumns+" FROM matches WHERE id IN (SELECT match_id FROM match_players WHERE user_id = ?) ORDER BY id", userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return r.scanMatches(rows)
+}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("match not found")
+// RoundsOf retorna as rodadas da partida em ordem, com as jogadas e o vencedor de cada uma.
+func (r *SqlMatchRepository) RoundsOf(matchID string) ([]data.Round, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM matches WHERE id = ?", matchID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, notFound("match", matchID)
 	}
 
-	return nil
+	rounds, err := r.loadRounds([]string{matchID})
+	if err != nil {
+		return nil, err
+	}
+	if rounds[matchID] == nil {
+		return []data.Round{}, nil
+	}
+	return rounds[matchID], nil
 }
 
-func (r *SqlMatchRepository) Delete(id string) error {
-	result, err := r.db.Exec("DELETE FROM matches WHERE id = ?", id)
+// scanMatches lê todas as linhas de um SELECT com as colunas de matches, fecha rows e só
+// então carrega jogadores e rodadas, para não abrir outra consulta com rows ainda aberto.
+func (r *SqlMatchRepository) scanMatches(rows *sql.Rows) ([]*domain.Match, error) {
+	var matches []*domain.Match
+	err := func() error {
+		defer rows.Close()
+		for rows.Next() {
+			match, err := scanMatch(rows)
+			if err != nil {
+				return err
+			}
+			matches = append(matches, match)
+		}
+		return rows.Err()
+	}()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := r.loadDetails(matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// loadDetails preenche jogadores, placar e jogadas das partidas lidas de matches.
+func (r *SqlMatchRepository) loadDetails(matches []*domain.Match) error {
+	byID := make(map[string]*domain.Match, len(matches))
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		match.Players = []domain.UserInterface{}
+		match.Moves = []map[string]domain.CardInterface{}
+		match.RoundWinners = []string{}
+		match.Scores = make(map[string]int)
+		byID[match.ID] = match
+		ids[i] = match.ID
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	err := forEachBatch(ids, func(batch []any, placeholders string) error {
+		rows, err := r.db.Query("SELECT match_id, user_id, username, roles, created_at, user_version, score FROM match_players WHERE match_id IN ("+placeholders+") ORDER BY match_id, position", batch...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var matchID, roles, createdAt string
+			var dto playerDTO
+			var score sql.NullInt64
+			if err := rows.Scan(&matchID, &dto.ID, &dto.Username, &roles, &createdAt, &dto.Version, &score); err != nil {
+				return err
+			}
+			dto.Roles = decodeRoles(roles)
+			dto.CreatedAt = decodeTime(createdAt)
+
+			match := byID[matchID]
+			match.Players = append(match.Players, dto.toDomain())
+			if score.Valid {
+				match.Scores[dto.ID] = int(score.Int64)
+			}
+		}
+		return rows.Err()
+	})
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("match not found")
+	rounds, err := r.loadRounds(ids)
+	if err != nil {
+		return err
+	}
+	for matchID, matchRounds := range rounds {
+		match := byID[matchID]
+		for _, round := range matchRounds {
+			match.Moves = append(match.Moves, round.Moves)
+			match.RoundWinners = append(match.RoundWinners, round.WinnerID)
+		}
 	}
 
-	return nil
+	return r.hydratePlayers(matches)
 }
 
-func (r *SqlMatchRepository) List() ([]*domain.Match, error) {
-	rows, err := r.db.Query("SELECT id, players, moves, scores, winner FROM matches")
-	if err != nil {
-		return nil, err
+// loadRounds lê as rodadas das partidas, em ordem, agrupadas pelo id da partida.
+func (r *SqlMatchRepository) loadRounds(ids []string) (map[string][]data.Round, error) {
+	rounds := make(map[string][]data.Round)
+	err := forEachBatch(ids, func(batch []any, placeholders string) error {
+		rows, err := r.db.Query(`SELECT r.match_id, r.round_number, r.winner_id,
+				m.player_id, m.card_id, m.card_owner_id, m.card_type, m.card_template_id, m.card_power, m.card_version
+			FROM match_rounds r
+			LEFT JOIN match_moves m ON m.match_id = r.match_id AND m.round_number = r.round_number
+			WHERE r.match_id IN (`+placeholders+`)
+			ORDER BY r.match_id, r.round_number`, batch...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var matchID string
+			var number int
+			var winnerID, playerID, cardID, cardOwnerID, cardType, cardTemplateID sql.NullString
+			var cardPower, cardVersion sql.NullInt64
+			if err := rows.Scan(&matchID, &number, &winnerID, &playerID, &cardID, &cardOwnerID, &cardType, &cardTemplateID, &cardPower, &cardVersion); err != nil {
+				return err
+			}
+
+			matchRounds := rounds[matchID]
+			if len(matchRounds) == 0 || matchRounds[len(matchRounds)-1].Number != number {
+				matchRounds = append(matchRounds, data.Round{Number: number, Moves: make(map[string]domain.CardInterface), WinnerID: winnerID.String})
+			}
+			if playerID.Valid {
+				card := cardDTO{ID: cardID.String, OwnerID: cardOwnerID.String, Type: cardType.String, TemplateID: cardTemplateID.String, Power: int(cardPower.Int64), Version: cardVersion.Int64}
+				matchRounds[len(matchRounds)-1].Moves[playerID.String] = card.toDomain()
+			}
+			rounds[matchID] = matchRounds
+		}
+		return rows.Err()
+	})
+	return rounds, err
+}
+
+// batchSize limita os parâmetros de cada SELECT ... IN, abaixo do limite do SQLite.
+const batchSize = 500
+
+// forEachBatch divide os ids em lotes e chama fn com os argumentos e os marcadores "?, ?, ..." de cada lote.
+func forEachBatch(ids []string, fn func(batch []any, placeholders string) error) error {
+	for start := 0; start < len(ids); start += batchSize {
+		end := min(start+batchSize, len(ids))
+		batch := make([]any, 0, end-start)
+		for _, id := range ids[start:end] {
+			batch = append(batch, id)
+		}
+		if err := fn(batch, strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")); err != nil {
+			return err
+		}
 	}
-	defer rows.Close()
+	return nil
+}
 
-	var matches []*domain.Match
-	for rows.Next() {
-		var match domain.Match
-		var playersJSON, movesJSON, scoresJSON string
+// hydratePlayers troca o retrato dos jogadores pelos usuários completos da tabela users.
+// Jogadores cujas contas foram excluídas mantêm o retrato gravado na partida.
+func (r *SqlMatchRepository) hydratePlayers(matches []*domain.Match) error {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		for _, player := range match.Players {
+			if id := player.GetID(); !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
 
-		err := rows.Scan(&match.ID, &playersJSON, &movesJSON, &scoresJSON, &match.Winner)
+	byID := make(map[string]*domain.User, len(ids))
+	err := forEachBatch(ids, func(batch []any, placeholders string) error {
+		rows, err := r.db.Query("SELECT "+userSelectColumns+" FROM users WHERE id IN ("+placeholders+")", batch...)
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		matches = append(matches, &match)
+		users, err := scanUsers(rows)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			byID[user.ID] = user
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return matches, nil
+	for _, match := range matches {
+		for i, player := range match.Players {
+			if user, ok := byID[player.GetID()]; ok {
+				// Cada partida recebe sua cópia, como se tivesse sido lida sozinha
+				match.Players[i] = user.Clone()
+			}
+		}
+	}
+	return nil
 }
 
 func (r *SqlMatchRepository) ListBy(filter func(*domain.Match) bool) ([]*domain.Match, error) {
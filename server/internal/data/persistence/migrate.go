@@ -0,0 +1,268 @@
+package persistence
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migrações ficam em migrations/NNNN_nome.up.sql e NNNN_nome.down.sql, embutidas no binário.
+// Cada uma roda em uma transação junto com o registro em schema_migrations e, se tiver um
+// passo em Go em migrationSteps, com ele logo depois do script.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationSteps completa em Go o up de uma migração, com dados que o SQL não tem (ex.: o
+// catálogo embutido).
+var migrationSteps = map[int]func(tx *sql.Tx) error{
+	cardPowerMigration: backfillSQLCardPower,
+}
+
+// Migration é uma versão do esquema com os scripts para aplicá-la e revertê-la.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus informa se a migração já foi aplicada ao banco e quando.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator aplica e reverte as migrações embutidas, registrando-as em schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Migrate aplica as migrações pendentes; chamado na inicialização do servidor.
+func Migrate(db *sql.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up()
+	return err
+}
+
+// loadMigrations lê os pares up/down e os ordena por versão. Toda migração precisa dos dois.
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(files, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d (%s) needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Status lista todas as migrações conhecidas e se cada uma foi aplicada.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		statuses[i] = MigrationStatus{Migration: migration, Applied: ok, AppliedAt: at}
+	}
+	return statuses, nil
+}
+
+// Up aplica, em ordem, as migrações pendentes e retorna as aplicadas. Para na primeira falha;
+// as anteriores continuam aplicadas.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		if err := adoptLegacySchema(m.db); err != nil {
+			return nil, fmt.Errorf("adopting legacy schema: %w", err)
+		}
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.run(migration.Up, migrationSteps[migration.Version],
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, encodeTime(time.Now()))
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverte as últimas steps migrações aplicadas, da mais recente para a mais antiga.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.run(migration.Down, nil, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// run executa o script, o passo em Go (se houver) e o registro em schema_migrations na
+// mesma transação.
+func (m *Migrator) run(script string, step func(tx *sql.Tx) error, record string, args ...any) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if step != nil {
+		if err := step(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// applied cria schema_migrations se preciso e retorna as versões aplicadas.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = decodeTime(at)
+	}
+	return applied, rows.Err()
+}
+
+// adoptLegacySchema prepara bancos criados antes das migrações, quando cada repositório
+// criava a própria tabela: adiciona as colunas que a migração inicial espera, para que o
+// CREATE TABLE IF NOT EXISTS dela encontre tabelas já completas, e normaliza os usernames,
+// para que o índice único dela possa ser criado.
+func adoptLegacySchema(db *sql.DB) error {
+	legacyColumns := []struct{ table, column, definition string }{
+		{"users", "roles", "TEXT NOT NULL DEFAULT 'player'"},
+		{"users", "created_at", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "version", "INTEGER NOT NULL DEFAULT 0"},
+		{"cards", "version", "INTEGER NOT NULL DEFAULT 0"},
+		{"matches", "cancelled", "INTEGER NOT NULL DEFAULT 0"},
+		{"matches", "version", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, c := range legacyColumns {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", c.table).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			continue
+		}
+		if err := ensureColumn(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	var users int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'users'").Scan(&users); err != nil {
+		return err
+	}
+	if users == 0 {
+		return nil
+	}
+	if _, err := NormalizeLegacyUsernames(db); err != nil {
+		return fmt.Errorf("normalizing usernames: %w", err)
+	}
+	return nil
+}
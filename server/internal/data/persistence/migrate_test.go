@@ -0,0 +1,296 @@
+package persistence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"cod-server/internal/data/catalog"
+	"cod-server/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openEmptyDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func hasColumn(t *testing.T, db *sql.DB, table, column string) bool {
+	t.Helper()
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func appliedVersions(t *testing.T, migrator *Migrator) []int {
+	t.Helper()
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	var versions []int
+	for _, status := range statuses {
+		if status.Applied {
+			if status.AppliedAt.IsZero() {
+				t.Errorf("migration %d applied without a timestamp", status.Version)
+			}
+			versions = append(versions, status.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db := openEmptyDB(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(migrator.migrations)
+	if total < 4 {
+		t.Fatalf("loaded %d migrations, want at least 4", total)
+	}
+	last := migrator.migrations[total-1]
+
+	if applied := appliedVersions(t, migrator); len(applied) != 0 {
+		t.Fatalf("fresh database has applied migrations %v", applied)
+	}
+
+	done, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(done) != total {
+		t.Errorf("Up applied %d migrations, want %d", len(done), total)
+	}
+	if applied := appliedVersions(t, migrator); len(applied) != total {
+		t.Errorf("applied after Up = %v", applied)
+	}
+	if !hasColumn(t, db, "cards", "power") {
+		t.Error("cards.power missing after Up")
+	}
+	if done, err := migrator.Up(); err != nil || len(done) != 0 {
+		t.Errorf("second Up = %v, %v; want nothing to apply", done, err)
+	}
+
+	done, err = migrator.Down(1)
+	if err != nil {
+		t.Fatalf("Down(1): %v", err)
+	}
+	if len(done) != 1 || done[0].Version != last.Version {
+		t.Errorf("Down(1) reverted %v, want only %d", done, last.Version)
+	}
+	if hasColumn(t, db, "cards", "power") {
+		t.Error("cards.power still present after reverting 0004")
+	}
+	if applied := appliedVersions(t, migrator); len(applied) != total-1 {
+		t.Errorf("applied after Down(1) = %v", applied)
+	}
+
+	// Reverter além do aplicado para no início; o esquema some e pode ser recriado
+	if done, err := migrator.Down(total + 5); err != nil || len(done) != total-1 {
+		t.Fatalf("Down(all) = %v, %v; want %d reverted", done, err, total-1)
+	}
+	if hasColumn(t, db, "users", "id") {
+		t.Error("users table still present after reverting every migration")
+	}
+	if done, err := migrator.Down(1); err != nil || len(done) != 0 {
+		t.Errorf("Down on an empty schema = %v, %v", done, err)
+	}
+	if done, err := migrator.Up(); err != nil || len(done) != total {
+		t.Errorf("Up after reverting everything = %d migrations, %v", len(done), err)
+	}
+}
+
+// legacySchema é o esquema criado pelos repositórios antes das migrações.
+const legacySchema = `
+CREATE TABLE users (id TEXT PRIMARY KEY, username TEXT NOT NULL, password TEXT NOT NULL, cards TEXT);
+CREATE TABLE cards (id TEXT PRIMARY KEY, owner_id TEXT NOT NULL, card_type TEXT NOT NULL);
+CREATE TABLE matches (id TEXT PRIMARY KEY, players TEXT, moves TEXT, scores TEXT, winner TEXT);
+INSERT INTO users (id, username, password) VALUES ('u1', 'Alice', 'hash1'), ('u2', 'alice', 'hash2'), ('u3', 'Bob', 'hash3');
+INSERT INTO cards (id, owner_id, card_type) VALUES ('c1', 'u1', 'rock'), ('c2', 'u3', 'scissors');
+INSERT INTO matches (id, players, moves, scores, winner) VALUES ('m1',
+	'[{"id": "u1", "username": "Alice"}, {"id": "u3", "username": "Bob"}]',
+	'[{"u1": {"id": "c1", "owner_id": "u1", "type": "rock"}, "u3": {"id": "c2", "owner_id": "u3", "type": "scissors"}}]',
+	'{"u1": 1}', 'u1');
+`
+
+func TestMigrate_AdoptsLegacySchema(t *testing.T) {
+	db := openEmptyDB(t)
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+
+	// "Alice" e "alice" colidem na forma normalizada; sem a limpeza o índice único falharia
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate on a legacy database: %v", err)
+	}
+
+	users := NewSqlUserRepository(db)
+	for id, want := range map[string]string{"u1": "alice", "u2": "alice-2", "u3": "bob"} {
+		user, err := users.Read(id)
+		if err != nil {
+			t.Fatalf("Read(%s): %v", id, err)
+		}
+		if user.Username != want {
+			t.Errorf("user %s username = %q, want %q", id, user.Username, want)
+		}
+	}
+	if user, err := users.(*SqlUserRepository).FindByUsername("alice"); err != nil || user.ID != "u1" {
+		t.Errorf("FindByUsername(alice) = %v, %v; want the oldest account", user, err)
+	}
+
+	card, err := NewSqlCardRepository(db).Read("c2")
+	if err != nil {
+		t.Fatalf("Read(c2): %v", err)
+	}
+	if card.OwnerID != "u3" || card.Type != "scissors" {
+		t.Errorf("card = %+v, want u3's scissors", card)
+	}
+
+	match, err := NewSqlMatchRepository(db).Read("m1")
+	if err != nil {
+		t.Fatalf("Read(m1): %v", err)
+	}
+	if winner, err := match.GetWinner(); err != nil || winner != "u1" {
+		t.Errorf("winner = %q, %v; want u1", winner, err)
+	}
+	if players := match.GetPlayers(); len(players) != 2 {
+		t.Errorf("players = %d, want 2", len(players))
+	}
+	if score := match.GetScores()["u1"]; score != 1 {
+		t.Errorf("u1 score = %d, want 1", score)
+	}
+}
+
+func TestMigrate_BackfillsCardPower(t *testing.T) {
+	db := openEmptyDB(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	// Volta para antes de 0004_card_power, quando as cartas tinham modelo mas não poder
+	for migrator.migrations[len(appliedVersions(t, migrator))-1].Name != "card_templates" {
+		if _, err := migrator.Down(1); err != nil {
+			t.Fatalf("Down(1): %v", err)
+		}
+	}
+
+	embedded := catalog.Default()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec("INSERT INTO matches (id) VALUES ('m1')")
+	for i, template := range embedded.Templates {
+		exec("INSERT INTO cards (id, owner_id, card_type, template_id) VALUES (?, 'u1', ?, ?)", template.ID, template.Element, template.ID)
+		exec("INSERT INTO match_rounds (match_id, round_number) VALUES ('m1', ?)", i+1)
+		exec("INSERT INTO match_moves (match_id, round_number, player_id, card_id, card_owner_id, card_type, card_template_id) VALUES ('m1', ?, 'u1', ?, 'u1', ?, ?)",
+			i+1, template.ID, template.Element, template.ID)
+	}
+	exec("INSERT INTO cards (id, owner_id, card_type, template_id) VALUES ('old', 'u1', 'rock', '')")
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	for _, template := range embedded.Templates {
+		var power, played int
+		if err := db.QueryRow("SELECT power FROM cards WHERE id = ?", template.ID).Scan(&power); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow("SELECT card_power FROM match_moves WHERE card_id = ?", template.ID).Scan(&played); err != nil {
+			t.Fatal(err)
+		}
+		if power != template.Power || played != template.Power {
+			t.Errorf("%s: card power %d, played card power %d; want %d", template.ID, power, played, template.Power)
+		}
+	}
+	var power int
+	if err := db.QueryRow("SELECT power FROM cards WHERE id = 'old'").Scan(&power); err != nil || power != 0 {
+		t.Errorf("card without a template: power %d (err %v), want 0", power, err)
+	}
+}
+
+func TestOpenBolt_BackfillsCardPower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cards.bolt")
+	embedded := catalog.Default()
+	template := embedded.Templates[0]
+
+	// Arquivo gravado antes de as cartas terem poder: registros sem "power" e sem metaBucket
+	legacy, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := map[string]any{"id": "c1", "owner_id": "u1", "type": template.Element, "template_id": template.ID, "version": 1}
+	match := map[string]any{"id": "m1", "moves": []map[string]any{{"u1": card}}, "scores": map[string]int{}, "version": 1}
+	err = legacy.Update(func(tx *bolt.Tx) error {
+		for bucket, value := range map[string]map[string]any{"cards": card, "matches": match} {
+			b, err := tx.CreateBucket([]byte(bucket))
+			if err != nil {
+				return err
+			}
+			raw, _ := json.Marshal(value)
+			if err := b.Put([]byte(value["id"].(string)), raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	legacy.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenBolt(path)
+	if err != nil {
+		t.Fatalf("OpenBolt: %v", err)
+	}
+	cards := NewBoltCardRepository(db)
+	stored, err := cards.Read("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.GetPower() != template.Power || stored.(*domain.Card).Version != 1 {
+		t.Errorf("card power %d version %d, want power %d and the version kept", stored.GetPower(), stored.(*domain.Card).Version, template.Power)
+	}
+	played, err := NewBoltMatchRepository(db).Read("m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if power := played.(*domain.Match).Moves[0]["u1"].GetPower(); power != template.Power {
+		t.Errorf("played card power %d, want %d", power, template.Power)
+	}
+
+	// O backfill roda uma vez por arquivo: uma carta que perde o poder depois fica como está
+	stored.(*domain.Card).Power = 0
+	if err := cards.Update("c1", stored); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if db, err = OpenBolt(path); err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer db.Close()
+	if stored, err = NewBoltCardRepository(db).Read("c1"); err != nil {
+		t.Fatal(err)
+	}
+	if stored.GetPower() != 0 {
+		t.Errorf("second open backfilled again: power %d, want 0", stored.GetPower())
+	}
+}
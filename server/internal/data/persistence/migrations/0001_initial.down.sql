@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS matches;
+DROP INDEX IF EXISTS idx_cards_owner_id;
+DROP TABLE IF EXISTS cards;
+DROP INDEX IF EXISTS idx_users_username;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,35 @@
+-- Esquema inicial: usuários, cartas e partidas, com os índices usados pelas consultas.
+
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	username TEXT NOT NULL,
+	password TEXT NOT NULL,
+	cards TEXT,
+	roles TEXT NOT NULL DEFAULT 'player',
+	created_at TEXT NOT NULL DEFAULT '',
+	version INTEGER NOT NULL DEFAULT 0
+);
+
+-- Usernames são gravados já normalizados; o índice único garante a unicidade mesmo que
+-- algo escape da verificação do serviço, e atende FindByUsername.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username);
+
+CREATE TABLE IF NOT EXISTS cards (
+	id TEXT PRIMARY KEY,
+	owner_id TEXT NOT NULL,
+	card_type TEXT NOT NULL,
+	version INTEGER NOT NULL DEFAULT 0
+);
+
+-- GetCards e a contagem do perfil consultam as cartas de um dono.
+CREATE INDEX IF NOT EXISTS idx_cards_owner_id ON cards(owner_id);
+
+CREATE TABLE IF NOT EXISTS matches (
+	id TEXT PRIMARY KEY,
+	players TEXT,
+	moves TEXT,
+	scores TEXT,
+	winner TEXT,
+	cancelled INTEGER NOT NULL DEFAULT 0,
+	version INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,40 @@
+-- Volta participantes, rodadas e jogadas para as colunas JSON de matches, no formato lido
+-- antes da normalização, e remove as tabelas.
+
+ALTER TABLE matches ADD COLUMN players TEXT;
+ALTER TABLE matches ADD COLUMN moves TEXT;
+ALTER TABLE matches ADD COLUMN scores TEXT;
+
+UPDATE matches SET
+	players = (
+		SELECT json_group_array(json_object(
+			'id', p.user_id,
+			'username', p.username,
+			'roles', CASE WHEN p.roles = '' THEN json('null') ELSE json('["' || replace(p.roles, ',', '","') || '"]') END,
+			'created_at', CASE WHEN p.created_at = '' THEN '0001-01-01T00:00:00Z' ELSE p.created_at END,
+			'version', p.user_version))
+		FROM (SELECT * FROM match_players WHERE match_id = matches.id ORDER BY position) p
+	),
+	moves = (
+		SELECT json_group_array(json(r.moves))
+		FROM (
+			SELECT (
+				SELECT json_group_object(mv.player_id, json_object(
+					'id', mv.card_id, 'owner_id', mv.card_owner_id, 'type', mv.card_type, 'version', mv.card_version))
+				FROM match_moves mv
+				WHERE mv.match_id = mr.match_id AND mv.round_number = mr.round_number
+			) AS moves
+			FROM match_rounds mr
+			WHERE mr.match_id = matches.id
+			ORDER BY mr.round_number
+		) r
+	),
+	scores = (
+		SELECT json_group_object(user_id, score)
+		FROM match_players
+		WHERE match_id = matches.id AND score IS NOT NULL
+	);
+
+DROP TABLE match_moves;
+DROP TABLE match_rounds;
+DROP TABLE match_players;
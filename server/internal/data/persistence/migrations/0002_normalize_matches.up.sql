@@ -0,0 +1,82 @@
+-- Partidas normalizadas: participantes, rodadas e jogadas saem das colunas JSON de matches
+-- para tabelas próprias, ligadas por chave estrangeira, de modo que o histórico possa ser
+-- consultado em SQL. Os dados existentes são copiados e as colunas JSON removidas.
+
+-- Retrato do jogador na partida. user_id não referencia users: a conta pode ser excluída
+-- e a partida continua no histórico. score é NULL quando o jogador não tem placar.
+CREATE TABLE match_players (
+	match_id TEXT NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	username TEXT NOT NULL,
+	roles TEXT NOT NULL DEFAULT '',
+	created_at TEXT NOT NULL DEFAULT '',
+	user_version INTEGER NOT NULL DEFAULT 0,
+	score INTEGER,
+	PRIMARY KEY (match_id, user_id)
+);
+
+-- "Todas as partidas do usuário X"
+CREATE INDEX idx_match_players_user_id ON match_players(user_id);
+
+-- Rodadas numeradas a partir de 1; winner_id é NULL em empate ou rodada incompleta.
+CREATE TABLE match_rounds (
+	match_id TEXT NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+	round_number INTEGER NOT NULL,
+	winner_id TEXT,
+	PRIMARY KEY (match_id, round_number)
+);
+
+-- Carta jogada por um jogador em uma rodada, gravada como estava no momento da jogada.
+CREATE TABLE match_moves (
+	match_id TEXT NOT NULL,
+	round_number INTEGER NOT NULL,
+	player_id TEXT NOT NULL,
+	card_id TEXT NOT NULL,
+	card_owner_id TEXT NOT NULL,
+	card_type TEXT NOT NULL,
+	card_version INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (match_id, round_number, player_id),
+	FOREIGN KEY (match_id, round_number) REFERENCES match_rounds(match_id, round_number) ON DELETE CASCADE
+);
+
+INSERT INTO match_players (match_id, user_id, position, username, roles, created_at, user_version, score)
+SELECT m.id,
+	json_extract(p.value, '$.id'),
+	p.key,
+	COALESCE(json_extract(p.value, '$.username'), ''),
+	COALESCE((SELECT group_concat(r.value, ',') FROM json_each(p.value, '$.roles') r), ''),
+	COALESCE(json_extract(p.value, '$.created_at'), ''),
+	COALESCE(json_extract(p.value, '$.version'), 0),
+	CASE WHEN json_valid(m.scores) THEN json_extract(m.scores, '$."' || json_extract(p.value, '$.id') || '"') END
+FROM matches m, json_each(CASE WHEN json_valid(m.players) THEN m.players END) p
+WHERE p.type = 'object' AND json_extract(p.value, '$.id') IS NOT NULL;
+
+INSERT INTO match_rounds (match_id, round_number)
+SELECT m.id, r.key + 1
+FROM matches m, json_each(CASE WHEN json_valid(m.moves) THEN m.moves END) r
+WHERE r.type = 'object';
+
+INSERT INTO match_moves (match_id, round_number, player_id, card_id, card_owner_id, card_type, card_version)
+SELECT m.id, r.key + 1, mv.key,
+	COALESCE(json_extract(mv.value, '$.id'), ''),
+	COALESCE(json_extract(mv.value, '$.owner_id'), ''),
+	COALESCE(json_extract(mv.value, '$.type'), ''),
+	COALESCE(json_extract(mv.value, '$.version'), 0)
+FROM matches m, json_each(CASE WHEN json_valid(m.moves) THEN m.moves END) r, json_each(r.value) mv
+WHERE r.type = 'object';
+
+-- Vencedor das rodadas já jogadas, pelas regras de pedra, papel e tesoura de Card.Against
+UPDATE match_rounds SET winner_id = (
+	SELECT a.player_id
+	FROM match_moves a JOIN match_moves b
+		ON b.match_id = a.match_id AND b.round_number = a.round_number AND b.player_id <> a.player_id
+	WHERE a.match_id = match_rounds.match_id AND a.round_number = match_rounds.round_number
+		AND ((a.card_type = 'rock' AND b.card_type = 'scissors')
+			OR (a.card_type = 'scissors' AND b.card_type = 'paper')
+			OR (a.card_type = 'paper' AND b.card_type = 'rock'))
+);
+
+ALTER TABLE matches DROP COLUMN players;
+ALTER TABLE matches DROP COLUMN moves;
+ALTER TABLE matches DROP COLUMN scores;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_cards_template_id;
+ALTER TABLE match_moves DROP COLUMN card_template_id;
+ALTER TABLE cards DROP COLUMN template_id;
@@ -0,0 +1,7 @@
+-- Cartas passam a apontar para um modelo do catálogo. Cartas anteriores ficam sem modelo
+-- e continuam valendo pelo tipo.
+
+ALTER TABLE cards ADD COLUMN template_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE match_moves ADD COLUMN card_template_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_cards_template_id ON cards(template_id);
@@ -0,0 +1,2 @@
+ALTER TABLE match_moves DROP COLUMN card_power;
+ALTER TABLE cards DROP COLUMN power;
@@ -0,0 +1,6 @@
+-- Cartas guardam o poder do modelo, que desempata confrontos do mesmo elemento. O poder das
+-- cartas e jogadas já gravadas vem do catálogo embutido, no passo em Go desta migração
+-- (backfillSQLCardPower).
+
+ALTER TABLE cards ADD COLUMN power INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE match_moves ADD COLUMN card_power INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,149 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+
+	"cod-server/internal/data"
+)
+
+// queryColumn é a coluna de um campo de data.Query e o tipo de valor que ela guarda. O tipo
+// é conferido antes da consulta: o SQLite compararia 1 com '1' sem reclamar.
+type queryColumn struct {
+	name string
+	kind data.FieldKind
+}
+
+// queryColumns mapeia os campos de data.Query para colunas da tabela. Só campos mapeados
+// podem ser filtrados ou ordenados; o id é sempre a chave primária.
+type queryColumns map[string]queryColumn
+
+var (
+	userColumns = queryColumns{
+		"id":       {"id", data.StringField},
+		"username": {"username", data.StringField},
+	}
+	cardColumns = queryColumns{
+		"id":          {"id", data.StringField},
+		"owner_id":    {"owner_id", data.StringField},
+		"type":        {"card_type", data.StringField},
+		"template_id": {"template_id", data.StringField},
+	}
+	matchColumns = queryColumns{
+		"id":        {"id", data.StringField},
+		"winner":    {"winner", data.StringField},
+		"cancelled": {"cancelled", data.BoolField},
+	}
+)
+
+func (c queryColumns) column(field string) (string, error) {
+	column, ok := c[field]
+	if !ok {
+		return "", fmt.Errorf("%w: field %q", data.ErrUnsupportedQuery, field)
+	}
+	return column.name, nil
+}
+
+// value confere o tipo do valor comparado com o campo.
+func (c queryColumns) value(field string, value any) (any, error) {
+	if err := data.CheckValue(field, c[field].kind, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// where traduz filtros e cursor para a cláusula WHERE e seus argumentos.
+func (c queryColumns) where(q data.Query) (string, []any, error) {
+	var conditions []string
+	var args []any
+	for _, f := range q.Filters {
+		column, err := c.column(f.Field)
+		if err != nil {
+			return "", nil, err
+		}
+		value, err := c.value(f.Field, f.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, column+" = ?")
+		args = append(args, value)
+	}
+
+	if q.After != "" {
+		cursor, err := data.DecodeCursor(q)
+		if err != nil {
+			return "", nil, err
+		}
+		column, err := c.column(q.SortField())
+		if err != nil {
+			return "", nil, err
+		}
+		op := ">"
+		if q.Desc {
+			op = "<"
+		}
+		if column == "id" {
+			conditions = append(conditions, "id "+op+" ?")
+			args = append(args, cursor.ID)
+		} else {
+			if _, err := c.value(q.SortField(), cursor.Value); err != nil {
+				return "", nil, fmt.Errorf("%w: %v", data.ErrInvalidCursor, err)
+			}
+			// Paginação por chave: (coluna, id) estritamente após o último item da página
+			conditions = append(conditions, fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, op, column, op))
+			args = append(args, cursor.Value, cursor.Value, cursor.ID)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// selectQuery monta SELECT com WHERE, ORDER BY (desempate pelo id) e LIMIT/OFFSET.
+func (c queryColumns) selectQuery(selectColumns, table string, q data.Query) (string, []any, error) {
+	if err := q.Validate(); err != nil {
+		return "", nil, err
+	}
+	where, args, err := c.where(q)
+	if err != nil {
+		return "", nil, err
+	}
+	column, err := c.column(q.SortField())
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir := "ASC"
+	if q.Desc {
+		dir = "DESC"
+	}
+	order := fmt.Sprintf(" ORDER BY %s %s", column, dir)
+	if column != "id" {
+		order += ", id " + dir
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s%s%s", selectColumns, table, where, order)
+	if q.Limit > 0 || q.Offset > 0 {
+		limit := q.Limit
+		if limit == 0 {
+			limit = -1 // SQLite: sem limite
+		}
+		stmt += " LIMIT ? OFFSET ?"
+		args = append(args, limit, q.Offset)
+	}
+	return stmt, args, nil
+}
+
+// countQuery monta SELECT COUNT(*) só com os filtros; paginação e cursor são ignorados.
+func (c queryColumns) countQuery(table string, q data.Query) (string, []any, error) {
+	if err := q.Validate(); err != nil {
+		return "", nil, err
+	}
+	where, args, err := c.where(data.Query{Filters: q.Filters})
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s%s", table, where), args, nil
+}
@@ -0,0 +1,250 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cada conexão de :memory: é um banco separado
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+var cardTypes = []string{"rock", "paper", "scissors"}
+
+func randomID(r *rand.Rand, prefix string) string {
+	return fmt.Sprintf("%s-%016x", prefix, r.Uint64())
+}
+
+func randomCard(r *rand.Rand, ownerID string) *domain.Card {
+	return &domain.Card{ID: randomID(r, "card"), OwnerID: ownerID, Type: cardTypes[r.Intn(len(cardTypes))], Power: r.Intn(10), Version: r.Int63n(100)}
+}
+
+func randomTime(r *rand.Rand) time.Time {
+	if r.Intn(4) == 0 {
+		return time.Time{}
+	}
+	return time.Unix(r.Int63n(4e9), r.Int63n(1e9)).UTC()
+}
+
+// randomUser gera usuários com todos os campos preenchidos ao acaso. Roles é nil ou não
+// vazio: a coluna não distingue nil de lista vazia, e GetRoles trata os dois da mesma forma.
+type randomUser struct{ *domain.User }
+
+func (randomUser) Generate(r *rand.Rand, size int) reflect.Value {
+	id := randomID(r, "user")
+	user := &domain.User{
+		ID:        id,
+		Username:  "name-" + id,
+		Password:  randomID(r, "hash"),
+		CreatedAt: randomTime(r),
+		Version:   r.Int63n(1000),
+	}
+	for _, role := range []domain.Role{domain.RolePlayer, domain.RoleModerator, domain.RoleAdmin} {
+		if r.Intn(2) == 0 {
+			user.Roles = append(user.Roles, role)
+		}
+	}
+	switch r.Intn(3) {
+	case 1:
+		user.Cards = &domain.Pack{ID: randomID(r, "pack")}
+	case 2:
+		pack := &domain.Pack{ID: randomID(r, "pack"), Cards: []domain.CardInterface{}}
+		for i := r.Intn(size + 1); i > 0; i-- {
+			pack.AddCard(randomCard(r, id))
+		}
+		user.Cards = pack
+	}
+	return reflect.ValueOf(randomUser{user})
+}
+
+// randomMatch gera uma partida e os jogadores que precisam existir na tabela users. Parte dos
+// jogadores fica fora dela, como contas excluídas, e por isso não tem senha nem cartas: só o
+// retrato gravado na partida volta na leitura. Jogadores, jogadas, vencedores das rodadas e
+// placar nunca são nil, como em StartMatch: as tabelas relacionadas não distinguem nil de vazio.
+type randomMatch struct {
+	*domain.Match
+	registered []*domain.User
+}
+
+func (randomMatch) Generate(r *rand.Rand, size int) reflect.Value {
+	match := &domain.Match{ID: randomID(r, "match"), Cancelled: r.Intn(2) == 0, Version: r.Int63n(1000)}
+	var registered []*domain.User
+
+	match.Players = []domain.UserInterface{}
+	for i := r.Intn(3); i > 0; i-- {
+		player := randomUser{}.Generate(r, size).Interface().(randomUser).User
+		if r.Intn(3) == 0 {
+			player.Password = ""
+			player.Cards = nil
+		} else {
+			registered = append(registered, player)
+		}
+		match.Players = append(match.Players, player)
+	}
+
+	match.Scores = make(map[string]int)
+	for _, player := range match.Players {
+		if r.Intn(4) > 0 {
+			match.Scores[player.GetID()] = r.Intn(domain.RoundsToWin + 1)
+		}
+	}
+
+	match.Moves = []map[string]domain.CardInterface{}
+	match.RoundWinners = []string{}
+	for i := r.Intn(size + 1); i > 0; i-- {
+		round := make(map[string]domain.CardInterface)
+		winner := ""
+		for _, player := range match.Players {
+			if r.Intn(4) > 0 {
+				round[player.GetID()] = randomCard(r, player.GetID())
+				if r.Intn(2) == 0 {
+					winner = player.GetID()
+				}
+			}
+		}
+		match.Moves = append(match.Moves, round)
+		match.RoundWinners = append(match.RoundWinners, winner)
+	}
+	if len(match.Players) > 0 && r.Intn(2) == 0 {
+		match.Winner = match.Players[r.Intn(len(match.Players))].GetID()
+	}
+	return reflect.ValueOf(randomMatch{Match: match, registered: registered})
+}
+
+func countPlayer(match *domain.Match, playerID string) int {
+	for _, player := range match.Players {
+		if player.GetID() == playerID {
+			return 1
+		}
+	}
+	return 0
+}
+
+var quickConfig = &quick.Config{MaxCount: 200, MaxCountScale: 0}
+
+func TestSqlUserRepository_RoundTrip(t *testing.T) {
+	repo := NewSqlUserRepository(openTestDB(t))
+
+	property := func(created, updated randomUser) bool {
+		if err := repo.Create(created.ID, created.User); err != nil {
+			t.Logf("create: %v", err)
+			return false
+		}
+		got, err := repo.Read(created.ID)
+		if err != nil || !reflect.DeepEqual(got, created.User) {
+			t.Logf("after create: got %+v, want %+v (err %v)", got, created.User, err)
+			return false
+		}
+
+		updated.ID = created.ID
+		updated.Username = created.Username
+		updated.Version = created.Version
+		if err := repo.Update(created.ID, updated.User); err != nil {
+			t.Logf("update: %v", err)
+			return false
+		}
+		got, err = repo.Read(created.ID)
+		if err != nil || !reflect.DeepEqual(got, updated.User) {
+			t.Logf("after update: got %+v, want %+v (err %v)", got, updated.User, err)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSqlMatchRepository_RoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	users := NewSqlUserRepository(db)
+	repo := NewSqlMatchRepository(db)
+
+	property := func(created, updated randomMatch) bool {
+		for _, player := range append(created.registered, updated.registered...) {
+			if err := users.Create(player.ID, player); err != nil {
+				t.Logf("create player: %v", err)
+				return false
+			}
+		}
+
+		if err := repo.Create(created.ID, created.Match); err != nil {
+			t.Logf("create: %v", err)
+			return false
+		}
+		got, err := repo.Read(created.ID)
+		if err != nil || !reflect.DeepEqual(got, created.Match) {
+			t.Logf("after create: got %+v, want %+v (err %v)", got, created.Match, err)
+			return false
+		}
+
+		updated.ID = created.ID
+		updated.Version = created.Version
+		if err := repo.Update(created.ID, updated.Match); err != nil {
+			t.Logf("update: %v", err)
+			return false
+		}
+		listed, err := repo.List()
+		if err != nil {
+			t.Logf("list: %v", err)
+			return false
+		}
+		found := false
+		for _, match := range listed {
+			if match.ID == created.ID {
+				found = true
+				if !reflect.DeepEqual(match, updated.Match) {
+					t.Logf("after update: got %+v, want %+v", match, updated.Match)
+					return false
+				}
+			}
+		}
+		if !found {
+			t.Logf("match %s missing from List", created.ID)
+			return false
+		}
+
+		// O histórico vem das tabelas relacionadas e precisa concordar com a partida
+		rounds, err := repo.RoundsOf(created.ID)
+		if err != nil || !reflect.DeepEqual(rounds, data.RoundsOfMatch(updated.Match)) {
+			t.Logf("rounds: got %+v, want %+v (err %v)", rounds, data.RoundsOfMatch(updated.Match), err)
+			return false
+		}
+		for _, player := range updated.Players {
+			matches, err := repo.MatchesOf(player.GetID())
+			if err != nil || len(matches) != 1 || matches[0].ID != created.ID {
+				t.Logf("matches of %s: got %d (err %v)", player.GetID(), len(matches), err)
+				return false
+			}
+		}
+		for _, player := range created.Players {
+			if matches, err := repo.MatchesOf(player.GetID()); err != nil || len(matches) != countPlayer(updated.Match, player.GetID()) {
+				t.Logf("matches of replaced player %s: got %d (err %v)", player.GetID(), len(matches), err)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Fatal(err)
+	}
+}
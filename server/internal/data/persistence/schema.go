@@ -0,0 +1,146 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// BusyTimeout é quanto uma conexão espera pelo bloqueio de escrita antes de falhar com
+// "database is locked".
+const BusyTimeout = 5 * time.Second
+
+// Open abre o banco SQLite com as opções que precisam valer em cada conexão do pool:
+//   - chaves estrangeiras ativas; o SQLite as desliga por padrão;
+//   - journal WAL, para que leituras não esperem as escritas nem as bloqueiem;
+//   - busy timeout, para que escritas concorrentes esperem a vez em vez de falhar;
+//   - transações IMMEDIATE, que pegam o bloqueio de escrita já no BEGIN. Uma transação que
+//     lê e depois escreve não consegue esperar o bloqueio no meio do caminho e falharia na hora.
+func Open(path string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	options := fmt.Sprintf("_foreign_keys=on&_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate", BusyTimeout.Milliseconds())
+	return sql.Open("sqlite3", path+sep+options)
+}
+
+// ensureColumn adiciona a coluna à tabela se ela ainda não existir, para que bancos
+// criados antes das migrações ganhem os campos novos sem perder dados.
+func ensureColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// notFound envolve data.ErrNotFound com o tipo e o id da entidade.
+func notFound(entity, id string) error {
+	return fmt.Errorf("%s %s: %w", entity, id, data.ErrNotFound)
+}
+
+// translateInsertError converte a violação da chave primária em data.ErrAlreadyExists.
+func translateInsertError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return data.ErrAlreadyExists
+	}
+	return err
+}
+
+// checkVersionedUpdate interpreta o resultado de um UPDATE ... WHERE id = ? AND version = ?:
+// sem linhas afetadas, distingue id inexistente (data.ErrNotFound) de versão antiga (data.ErrConflict).
+func checkVersionedUpdate(db dbtx, table, id string, result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	exists, err := rowExists(db, table, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return data.ErrNotFound
+	}
+	return data.ErrConflict
+}
+
+// rowExists informa se a tabela tem uma linha com o id.
+func rowExists(db dbtx, table, id string) (bool, error) {
+	var count int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = ?", table), id).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// encodeRoles grava os papéis como lista separada por vírgulas.
+func encodeRoles(roles []domain.Role) string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ",")
+}
+
+func decodeRoles(value string) []domain.Role {
+	var roles []domain.Role
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			roles = append(roles, domain.Role(name))
+		}
+	}
+	return roles
+}
+
+// encodeTime grava instantes em RFC 3339 UTC; o instante zero vira string vazia.
+func encodeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// decodeTime interpreta o formato de encodeTime; valores vazios ou inválidos viram o instante zero.
+func decodeTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,84 @@
+package persistence
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+func TestOpen_ConfiguresEveryConnection(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "pragmas.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pragmas := map[string]string{
+		"journal_mode": "wal",
+		"busy_timeout": fmt.Sprint(BusyTimeout.Milliseconds()),
+		"foreign_keys": "1",
+	}
+	for pragma, want := range pragmas {
+		var got string
+		if err := db.QueryRow("PRAGMA " + pragma).Scan(&got); err != nil {
+			t.Fatalf("PRAGMA %s: %v", pragma, err)
+		}
+		if got != want {
+			t.Errorf("PRAGMA %s = %q, want %q", pragma, got, want)
+		}
+	}
+}
+
+func TestSqlUnitOfWork_ConcurrentWriters(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "writers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(8)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	uow := NewSqlUnitOfWork(db)
+
+	const writers, perWriter = 8, 15
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				id := fmt.Sprintf("user-%d-%d", w, i)
+				// Lê e depois escreve: sem BEGIN IMMEDIATE e busy timeout isto falha com database is locked
+				errs <- uow.Do(func(tx data.Tx) error {
+					if _, err := tx.Users.Count(data.NewQuery()); err != nil {
+						return err
+					}
+					time.Sleep(time.Millisecond) // Dá tempo de outra transação ler antes desta escrever
+					return tx.Users.Create(id, &domain.User{ID: id, Username: id, Password: "hash", CreatedAt: time.Unix(1700000000, 0).UTC()})
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent transaction: %v", err)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != writers*perWriter {
+		t.Errorf("users = %d, want %d", count, writers*perWriter)
+	}
+}
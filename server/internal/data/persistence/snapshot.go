@@ -0,0 +1,124 @@
+package persistence
+
+// Snapshot dos repositórios: o conteúdo de usuários, cartas e partidas serializado com os
+// mesmos registros do Bolt, para qualquer backend. O Raft compacta o log depois de um snapshot,
+// então uma réplica nova (ou um backend em memória reiniciado) só chega ao estado atual
+// restaurando o snapshot; e um backend em disco volta a ele antes de reaplicar o resto do log.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+// snapshotRecords é o conteúdo serializado dos repositórios.
+type snapshotRecords struct {
+	Users   []json.RawMessage `json:"users"`
+	Cards   []cardDTO         `json:"cards"`
+	Matches []json.RawMessage `json:"matches"`
+}
+
+// EncodeSnapshot serializa todo o conteúdo dos repositórios de tx. Rodar dentro de uma
+// unidade de trabalho para ler os três num ponto consistente.
+func EncodeSnapshot(tx data.Tx) ([]byte, error) {
+	var records snapshotRecords
+	users, err := tx.Users.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	for _, user := range users {
+		raw, err := encodeUserRecord(user)
+		if err != nil {
+			return nil, fmt.Errorf("encoding user %s: %w", user.GetID(), err)
+		}
+		records.Users = append(records.Users, raw)
+	}
+	cards, err := tx.Cards.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing cards: %w", err)
+	}
+	for _, card := range cards {
+		records.Cards = append(records.Cards, toCardDTO(card))
+	}
+	matches, err := tx.Matches.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing matches: %w", err)
+	}
+	for _, match := range matches {
+		raw, err := encodeMatchRecord(match)
+		if err != nil {
+			return nil, fmt.Errorf("encoding match %s: %w", match.GetID(), err)
+		}
+		records.Matches = append(records.Matches, raw)
+	}
+	return json.Marshal(records)
+}
+
+// RestoreSnapshot substitui o conteúdo dos repositórios de tx pelo de EncodeSnapshot; nil
+// esvazia os repositórios. As versões gravadas são mantidas. Rodar dentro de uma unidade de
+// trabalho para que a troca valha inteira ou não valha.
+func RestoreSnapshot(tx data.Tx, raw []byte) error {
+	var records snapshotRecords
+	if raw != nil {
+		if err := json.Unmarshal(raw, &records); err != nil {
+			return fmt.Errorf("decoding repositories snapshot: %w", err)
+		}
+	}
+	if err := clearRepository(tx.Matches); err != nil {
+		return fmt.Errorf("clearing matches: %w", err)
+	}
+	if err := clearRepository(tx.Cards); err != nil {
+		return fmt.Errorf("clearing cards: %w", err)
+	}
+	if err := clearRepository(tx.Users); err != nil {
+		return fmt.Errorf("clearing users: %w", err)
+	}
+
+	users := make(map[string]*domain.User, len(records.Users))
+	for _, raw := range records.Users {
+		user, err := decodeUserRecord(raw)
+		if err != nil {
+			return fmt.Errorf("decoding user: %w", err)
+		}
+		if err := tx.Users.Create(user.ID, user); err != nil {
+			return fmt.Errorf("restoring user %s: %w", user.ID, err)
+		}
+		users[user.ID] = user
+	}
+	for _, dto := range records.Cards {
+		if err := tx.Cards.Create(dto.ID, dto.toDomain()); err != nil {
+			return fmt.Errorf("restoring card %s: %w", dto.ID, err)
+		}
+	}
+	for _, raw := range records.Matches {
+		var record matchRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return fmt.Errorf("decoding match: %w", err)
+		}
+		// Os jogadores são completados com os usuários do próprio snapshot
+		match, err := record.toDomain(func(id string) (*domain.User, error) { return users[id], nil })
+		if err != nil {
+			return fmt.Errorf("decoding match: %w", err)
+		}
+		if err := tx.Matches.Create(match.ID, match); err != nil {
+			return fmt.Errorf("restoring match %s: %w", match.ID, err)
+		}
+	}
+	return nil
+}
+
+// clearRepository remove todas as entidades do repositório.
+func clearRepository[T interface{ GetID() string }](repo data.Repository[T]) error {
+	entities, err := repo.List()
+	if err != nil {
+		return err
+	}
+	for _, entity := range entities {
+		if err := repo.Delete(entity.GetID()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
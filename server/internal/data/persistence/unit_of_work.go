@@ -0,0 +1,63 @@
+package persistence
+
+import (
+	"database/sql"
+
+	"cod-server/internal/data"
+)
+
+// dbtx é o subconjunto de *sql.DB e *sql.Tx usado pelos repositórios, para que o mesmo
+// código rode dentro ou fora de uma transação.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// withTx roda fn em uma transação e a confirma se fn retornar nil. Se db já for uma
+// transação, como dentro de uma unidade de trabalho, fn roda nela.
+func withTx(db dbtx, fn func(tx dbtx) error) (err error) {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Desfaz em erro e também em panic, repassando o panic depois
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SqlUnitOfWork executa unidades de trabalho em uma transação SQLite sobre as tabelas
+// criadas pelas migrações.
+type SqlUnitOfWork struct {
+	db *sql.DB
+}
+
+func NewSqlUnitOfWork(db *sql.DB) data.UnitOfWork {
+	return &SqlUnitOfWork{db: db}
+}
+
+func (u *SqlUnitOfWork) Do(fn func(tx data.Tx) error) error {
+	return withTx(u.db, func(tx dbtx) error {
+		return fn(data.Tx{
+			Users:   NewUserRepoAdapter(&SqlUserRepository{db: tx}),
+			Cards:   NewCardRepoAdapter(&SqlCardRepository{db: tx}),
+			Matches: NewMatchRepoAdapter(&SqlMatchRepository{db: tx}),
+		})
+	})
+}
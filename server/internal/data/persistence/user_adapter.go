@@ -21,8 +21,10 @@ func (a *UserRepoAdapter) Create(id string, entity domain.UserInterface) error {
 	if !ok {
 		// Build a struct from interface methods when direct casting is not possible.
 		return a.repo.Create(id, &domain.User{
-			ID:       entity.GetID(),
-			Username: entity.GetUsername(),
+			ID:        entity.GetID(),
+			Username:  entity.GetUsername(),
+			Roles:     entity.GetRoles(),
+			CreatedAt: entity.GetCreatedAt(),
 			// Precisamos de uma forma de extrair o Password e Cards
 			// Isso pode exigir métodos adicionais na interface ou uma abordagem diferente
 			Password: "", // Isso não é ideal
@@ -44,8 +46,10 @@ func (a *UserRepoAdapter) Update(id string, entity domain.UserInterface) error {
 	user, ok := entity.(*domain.User)
 	if !ok {
 		return a.repo.Update(id, &domain.User{
-			ID:       entity.GetID(),
-			Username: entity.GetUsername(),
+			ID:        entity.GetID(),
+			Username:  entity.GetUsername(),
+			Roles:     entity.GetRoles(),
+			CreatedAt: entity.GetCreatedAt(),
 			// Similar conversion logic applies when updating from the interface type.
 			Password: "", // Isso não é ideal
 		})
@@ -57,6 +61,15 @@ func (a *UserRepoAdapter) Delete(id string) error {
 	return a.repo.Delete(id)
 }
 
+// FindByUsername implementa data.UserFinder sobre a consulta indexada do repositório SQL.
+func (a *UserRepoAdapter) FindByUsername(username string) (domain.UserInterface, error) {
+	user, err := a.repo.FindByUsername(username)
+	if err != nil || user == nil {
+		return nil, err // Evita devolver um *domain.User nil dentro de uma interface não nil
+	}
+	return user, nil
+}
+
 func (a *UserRepoAdapter) List() ([]domain.UserInterface, error) {
 	users, err := a.repo.List()
 	if err != nil {
@@ -84,3 +97,25 @@ func (a *UserRepoAdapter) ListBy(filter func(domain.UserInterface) bool) ([]doma
 	}
 	return result, nil
 }
+
+// Find delega a consulta ao SQL e calcula o cursor da próxima página a partir do último item.
+func (a *UserRepoAdapter) Find(q data.Query) (data.Page[domain.UserInterface], error) {
+	users, err := a.repo.Find(q)
+	if err != nil {
+		return data.Page[domain.UserInterface]{}, err
+	}
+	cursor, err := data.NextCursor(users, q)
+	if err != nil {
+		return data.Page[domain.UserInterface]{}, err
+	}
+
+	items := make([]domain.UserInterface, len(users))
+	for i, item := range users {
+		items[i] = item
+	}
+	return data.Page[domain.UserInterface]{Items: items, NextCursor: cursor}, nil
+}
+
+func (a *UserRepoAdapter) Count(q data.Query) (int, error) {
+	return a.repo.Count(q)
+}
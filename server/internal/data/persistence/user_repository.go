@@ -4,92 +4,91 @@ package persistence
 
 import (
 	"database/sql"
-	"encoding/json"
-	"fmt"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
 
+	"cod-server/internal/data"
 	"cod-server/internal/domain"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type SqlUserRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 func NewSqlUserRepository(db *sql.DB) UserRepository {
-	// Garante que a tabela users exista na inicialização.
+	// O esquema da tabela users é criado pelas migrações (ver Migrate).
 	// Create insere um novo usuário com cartas serializadas.
-	// Read busca um usuário por id; retorna erro se não encontrar. Desserialização de cartas está simplificada.
-	// Update modifica username, password e cartas serializadas para o id fornecido.
+	// Read busca um usuário por id; retorna erro se não encontrar. O pacote de cartas volta como *domain.Pack.
+	// Update modifica todos os campos do usuário para o id fornecido.
 	// Delete remove um usuário; retorna erro se nenhuma linha for afetada.
 	// List recupera todos os usuários do banco.
 	// ListBy filtra usuários em memória usando o predicado fornecido.
-	query := `CREATE TABLE IF NOT EXISTS users (
-		id TEXT PRIMARY KEY,
-		username TEXT NOT NULL,
-		password TEXT NOT NULL,
-		cards TEXT
-	)`
-	_, err := db.Exec(query)
-	if err != nil {
-		panic(fmt.Sprintf("Falha ao criar tabela users: %v", err))
-	}
-
+	// Find e Count traduzem data.Query para WHERE sobre colunas indexadas.
 	return &SqlUserRepository{db: db}
 }
 
 func (r *SqlUserRepository) Create(id string, user *domain.User) error {
-	cardsJSON, err := json.Marshal(user.Cards)
+	cards, err := encodePack(user.Cards)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.db.Exec("INSERT INTO users (id, username, password, cards) VALUES (?, ?, ?, ?)",
-		id, user.Username, user.Password, string(cardsJSON))
+	_, err = r.db.Exec("INSERT INTO users (id, username, password, cards, roles, created_at, version) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, user.Username, user.Password, cards, encodeRoles(user.Roles), encodeTime(user.CreatedAt), user.Version)
+	err = translateUserError(err)
+	if errors.Is(err, domain.ErrUsernameTaken) {
+		// O SQLite pode acusar o índice de username antes da chave primária
+		if exists, existsErr := rowExists(r.db, "users", id); existsErr == nil && exists {
+			return data.ErrAlreadyExists
+		}
+	}
 	return err
 }
 
-func (r *SqlUserRepository) Read(id string) (*domain.User, error) {
-	var user domain.User
-	var cardsJSON string
-
-	err := r.db.QueryRow("SELECT id, username, password, cards FROM users WHERE id = ?", id).
-		Scan(&user.ID, &user.Username, &user.Password, &cardsJSON)
+// translateUserError converte a violação do índice único de username em domain.ErrUsernameTaken
+// e a da chave primária em data.ErrAlreadyExists.
+func translateUserError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return domain.ErrUsernameTaken
+	}
+	return translateInsertError(err)
+}
 
+func (r *SqlUserRepository) Read(id string) (*domain.User, error) {
+	user, err := scanUser(r.db.QueryRow("SELECT "+userSelectColumns+" FROM users WHERE id = ?", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, notFound("user", id)
 		}
 		return nil, err
 	}
-
-	// Obs.: o campo Cards deveria ser tratado adequadamente - isto é uma simplificação
-	// Seria necessário implementar serialização adequada para PackInterface
-
-	return &user, nil
+	return user, nil
 }
 
 func (r *SqlUserRepository) Update(id string, user *domain.User) error {
-	cardsJSON, err := json.Marshal(user.Cards)
+	cards, err := encodePack(user.Cards)
 	if err != nil {
 		return err
 	}
 
-	result, err := r.db.Exec("UPDATE users SET username = ?, password = ?, cards = ? WHERE id = ?",
-		user.Username, user.Password, string(cardsJSON), id)
+	result, err := r.db.Exec("UPDATE users SET username = ?, password = ?, cards = ?, roles = ?, created_at = ?, version = version + 1 WHERE id = ? AND version = ?",
+		user.Username, user.Password, cards, encodeRoles(user.Roles), encodeTime(user.CreatedAt), id, user.Version)
 	if err != nil {
-		return err
+		return translateUserError(err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	if err := checkVersionedUpdate(r.db, "users", id, result); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return notFound("user", id)
+		}
 		return err
 	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
-	}
-
+	user.Version++
 	return nil
 }
 
@@ -105,33 +104,68 @@ func (r *SqlUserRepository) Delete(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return notFound("user", id)
 	}
 
 	return nil
 }
 
+// FindByUsername busca pelo índice único de username; retorna nil, nil se não existir.
+func (r *SqlUserRepository) FindByUsername(username string) (*domain.User, error) {
+	user, err := scanUser(r.db.QueryRow("SELECT "+userSelectColumns+" FROM users WHERE username = ?", username))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
 func (r *SqlUserRepository) List() ([]*domain.User, error) {
-	rows, err := r.db.Query("SELECT id, username, password, cards FROM users")
+	rows, err := r.db.Query("SELECT " + userSelectColumns + " FROM users")
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func (r *SqlUserRepository) Find(q data.Query) ([]*domain.User, error) {
+	stmt, args, err := userColumns.selectQuery(userSelectColumns, "users", q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
+	return scanUsers(rows)
+}
+
+func (r *SqlUserRepository) Count(q data.Query) (int, error) {
+	stmt, args, err := userColumns.countQuery("users", q)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = r.db.QueryRow(stmt, args...).Scan(&count)
+	return count, err
+}
+
+// scanUsers lê todas as linhas de um SELECT com as colunas de users e fecha rows.
+func scanUsers(rows *sql.Rows) ([]*domain.User, error) {
 	defer rows.Close()
 
 	var users []*domain.User
 	for rows.Next() {
-		var user domain.User
-		var cardsJSON string
-
-		err := rows.Scan(&user.ID, &user.Username, &user.Password, &cardsJSON)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
 }
 
 func (r *SqlUserRepository) ListBy(filter func(*domain.User) bool) ([]*domain.User, error) {
@@ -149,3 +183,123 @@ func (r *SqlUserRepository) ListBy(filter func(*domain.User) bool) ([]*domain.Us
 
 	return filteredUsers, nil
 }
+
+// UsernameChange registra um username reescrito por NormalizeLegacyUsernames.
+type UsernameChange struct {
+	UserID string
+	From   string
+	To     string
+}
+
+// NormalizeLegacyUsernames reescreve os usernames gravados antes da normalização, para que o
+// índice único da migração inicial possa ser criado. Cada username vira a forma canônica de
+// domain.NormalizeUsername; entre contas que colidem, a mais antiga (menor rowid) fica com o
+// nome e as demais ganham o sufixo "-2", "-3"... Usernames inválidos viram "user-" seguido do
+// início do id. O resultado depende só do conteúdo da tabela, então é o mesmo em toda réplica.
+func NormalizeLegacyUsernames(db *sql.DB) ([]UsernameChange, error) {
+	rows, err := db.Query("SELECT id, username FROM users ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	type legacyUser struct{ id, username, normalized string }
+	var users []legacyUser
+	for rows.Next() {
+		var user legacyUser
+		if err := rows.Scan(&user.id, &user.username); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Primeiro os nomes que ficam: a primeira conta com cada forma canônica válida
+	taken := make(map[string]bool, len(users))
+	var pending []*legacyUser
+	for i := range users {
+		user := &users[i]
+		normalized, err := domain.NormalizeUsername(user.username)
+		if err == nil && !taken[normalized] {
+			user.normalized = normalized
+			taken[normalized] = true
+			continue
+		}
+		if err != nil {
+			normalized = legacyUsernameBase(user.id)
+		}
+		user.normalized = normalized
+		pending = append(pending, user)
+	}
+	// Depois as colisões e os inválidos, com um sufixo que não repita nenhum nome
+	for _, user := range pending {
+		user.normalized = uniqueUsername(user.normalized, taken)
+		taken[user.normalized] = true
+	}
+
+	var changes []UsernameChange
+	for _, user := range users {
+		if user.normalized != user.username {
+			changes = append(changes, UsernameChange{UserID: user.id, From: user.username, To: user.normalized})
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	// Em duas etapas, por um nome temporário único, para não esbarrar em um índice já existente
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, change := range changes {
+		if _, err := tx.Exec("UPDATE users SET username = ? WHERE id = ?", "\x00"+change.UserID, change.UserID); err != nil {
+			return nil, err
+		}
+	}
+	for _, change := range changes {
+		if _, err := tx.Exec("UPDATE users SET username = ? WHERE id = ?", change.To, change.UserID); err != nil {
+			return nil, err
+		}
+	}
+	return changes, tx.Commit()
+}
+
+// legacyUsernameBase é o nome dado a um username legado inválido: "user-" e até 8 letras ou
+// dígitos do id.
+func legacyUsernameBase(userID string) string {
+	var fragment []rune
+	for _, r := range strings.ToLower(userID) {
+		if len(fragment) == 8 {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			fragment = append(fragment, r)
+		}
+	}
+	if len(fragment) == 0 {
+		return "user"
+	}
+	return "user-" + string(fragment)
+}
+
+// uniqueUsername acrescenta "-2", "-3"... a base até achar um nome livre, encurtando a base
+// para respeitar domain.MaxUsernameLength.
+func uniqueUsername(base string, taken map[string]bool) string {
+	if !taken[base] {
+		return base
+	}
+	for n := 2; ; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		runes := []rune(base)
+		if limit := domain.MaxUsernameLength - len(suffix); len(runes) > limit {
+			runes = runes[:limit]
+		}
+		if candidate := string(runes) + suffix; !taken[candidate] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package persistence
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+// openLegacyDB abre um banco com a tabela users de antes das migrações: sem índice único e
+// com usernames gravados como o cliente os enviou.
+func openLegacyDB(t *testing.T, usernames map[string]string, order []string) *sql.DB {
+	t.Helper()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, username TEXT NOT NULL, password TEXT NOT NULL, cards TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range order {
+		if _, err := db.Exec("INSERT INTO users (id, username, password) VALUES (?, ?, 'hash')", id, usernames[id]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func usernamesByID(t *testing.T, db *sql.DB) map[string]string {
+	t.Helper()
+	rows, err := db.Query("SELECT id, username FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	usernames := make(map[string]string)
+	for rows.Next() {
+		var id, username string
+		if err := rows.Scan(&id, &username); err != nil {
+			t.Fatal(err)
+		}
+		usernames[id] = username
+	}
+	return usernames
+}
+
+func TestNormalizeLegacyUsernames(t *testing.T) {
+	order := []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7"}
+	db := openLegacyDB(t, map[string]string{
+		"u1": "Alice",
+		"u2": " alice ",
+		"u3": "ALICE",
+		"u4": "alice-2", // já canônico: fica com o nome, e as colisões de alice pulam o sufixo
+		"u5": "bob",
+		"u6": "x",         // curto demais
+		"u7": "bad name!", // caracteres inválidos
+	}, order)
+
+	changes, err := NormalizeLegacyUsernames(db)
+	if err != nil {
+		t.Fatalf("NormalizeLegacyUsernames: %v", err)
+	}
+
+	want := map[string]string{
+		"u1": "alice",
+		"u2": "alice-3",
+		"u3": "alice-4",
+		"u4": "alice-2",
+		"u5": "bob",
+		"u6": "user-u6",
+		"u7": "user-u7",
+	}
+	if got := usernamesByID(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("usernames = %v, want %v", got, want)
+	}
+	if len(changes) != 5 {
+		t.Errorf("changes = %+v, want 5 renamed accounts", changes)
+	}
+
+	// A migração inicial agora consegue criar o índice único, e rodar de novo não muda nada
+	if _, err := db.Exec("CREATE UNIQUE INDEX idx_users_username ON users(username)"); err != nil {
+		t.Fatalf("unique index after normalization: %v", err)
+	}
+	if changes, err := NormalizeLegacyUsernames(db); err != nil || len(changes) != 0 {
+		t.Errorf("second run = %+v, %v; want no changes", changes, err)
+	}
+}
+
+func TestUniqueUsername_KeepsMaximumLength(t *testing.T) {
+	base := "abcdefghijklmnopqrstuvwxyz012345" // 32 caracteres
+	got := uniqueUsername(base, map[string]bool{base: true})
+	if got != "abcdefghijklmnopqrstuvwxyz0123-2" {
+		t.Errorf("uniqueUsername = %q", got)
+	}
+}
@@ -0,0 +1,378 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnsupportedQuery indica um campo que o repositório não sabe filtrar ou ordenar.
+var ErrUnsupportedQuery = errors.New("unsupported query")
+
+// ErrInvalidCursor indica um cursor malformado ou gerado para outra ordenação.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Queryable expõe campos de uma entidade pelo nome usado nas consultas, para que
+// repositórios em memória avaliem a mesma Query que os repositórios SQL traduzem em WHERE.
+// Todo tipo consultável deve responder ao campo "id".
+type Queryable interface {
+	QueryField(name string) (any, bool)
+}
+
+// Filter exige igualdade entre o campo e o valor. Um valor de tipo diferente do campo é
+// erro (ErrUnsupportedQuery), não uma consulta que não casa com nada.
+type Filter struct {
+	Field string
+	Value any
+}
+
+// Query descreve filtros por igualdade, ordenação e paginação por limit/offset ou cursor.
+// A ordenação usa um campo e desempata pelo id, na mesma direção; sem OrderBy ordena pelo id.
+// After recebe o NextCursor de uma página anterior e não deve ser combinado com Offset.
+type Query struct {
+	Filters []Filter
+	OrderBy string
+	Desc    bool
+	Limit   int // 0 = sem limite
+	Offset  int
+	After   string
+}
+
+// NewQuery cria uma consulta vazia, que casa com todas as entidades.
+func NewQuery() Query {
+	return Query{}
+}
+
+// Where acrescenta um filtro de igualdade.
+func (q Query) Where(field string, value any) Query {
+	q.Filters = append(append([]Filter(nil), q.Filters...), Filter{Field: field, Value: value})
+	return q
+}
+
+// Order define o campo de ordenação.
+func (q Query) Order(field string, desc bool) Query {
+	q.OrderBy = field
+	q.Desc = desc
+	return q
+}
+
+// Page define o tamanho da página e o deslocamento.
+func (q Query) Page(limit, offset int) Query {
+	q.Limit = limit
+	q.Offset = offset
+	return q
+}
+
+// StartAfter continua a partir do cursor de uma página anterior.
+func (q Query) StartAfter(cursor string) Query {
+	q.After = cursor
+	return q
+}
+
+// Validate confere os parâmetros independentes de repositório.
+func (q Query) Validate() error {
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("%w: negative limit or offset", ErrUnsupportedQuery)
+	}
+	if q.After != "" && q.Offset > 0 {
+		return fmt.Errorf("%w: cursor and offset are mutually exclusive", ErrUnsupportedQuery)
+	}
+	return nil
+}
+
+// SortField retorna o campo de ordenação efetivo.
+func (q Query) SortField() string {
+	if q.OrderBy == "" {
+		return "id"
+	}
+	return q.OrderBy
+}
+
+// FieldKind é o tipo de valor de um campo consultável, para repositórios que não têm a
+// entidade em mãos ao conferir os valores de filtros e cursores.
+type FieldKind int
+
+const (
+	StringField FieldKind = iota
+	BoolField
+	NumberField
+)
+
+// CheckValue confere que value pode ser comparado com o campo do tipo informado.
+func CheckValue(field string, kind FieldKind, value any) error {
+	ok := false
+	switch kind {
+	case StringField:
+		_, ok = value.(string)
+	case BoolField:
+		_, ok = value.(bool)
+	case NumberField:
+		_, ok = toFloat(value)
+	}
+	if !ok {
+		return fmt.Errorf("%w: field %q cannot be compared with %T", ErrUnsupportedQuery, field, value)
+	}
+	return nil
+}
+
+// Page é o resultado de Find. NextCursor fica vazio quando não há mais itens.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// Cursor identifica a última entidade de uma página: o valor do campo de ordenação e o id.
+type Cursor struct {
+	Field string `json:"f"`
+	Value any    `json:"v"`
+	ID    string `json:"id"`
+}
+
+// EncodeCursor serializa o cursor em uma string opaca.
+func EncodeCursor(c Cursor) (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor interpreta o cursor e confere se ele foi gerado para a ordenação da consulta.
+func DecodeCursor(q Query) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(q.After)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	if c.Field != q.SortField() {
+		return c, fmt.Errorf("%w: generated for order by %q", ErrInvalidCursor, c.Field)
+	}
+	return c, nil
+}
+
+// NextCursor gera o cursor após o último item quando a página veio cheia.
+func NextCursor[T any](items []T, q Query) (string, error) {
+	if q.Limit == 0 || len(items) < q.Limit {
+		return "", nil
+	}
+	last, ok := any(items[len(items)-1]).(Queryable)
+	if !ok {
+		return "", fmt.Errorf("%w: %T is not queryable", ErrUnsupportedQuery, items[len(items)-1])
+	}
+	field := q.SortField()
+	value, _ := last.QueryField(field)
+	id, _ := last.QueryField("id")
+	return EncodeCursor(Cursor{Field: field, Value: value, ID: fmt.Sprint(id)})
+}
+
+// ApplyQuery avalia a consulta em memória sobre entidades indexadas pelo id, com a mesma
+// semântica que os repositórios SQL. Entidades que não implementam Queryable só aceitam
+// consultas sobre o id.
+func ApplyQuery[T any](entities map[string]T, q Query) (Page[T], error) {
+	matched, err := filterEntities(entities, q)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	field := q.SortField()
+	var sortErr error
+	sort.SliceStable(matched, func(i, j int) bool {
+		c, err := compareEntries(matched[i], matched[j], field)
+		if err != nil {
+			sortErr = err
+		}
+		if q.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	if sortErr != nil {
+		return Page[T]{}, sortErr
+	}
+
+	if q.After != "" {
+		cursor, err := DecodeCursor(q)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		start := len(matched)
+		for i, e := range matched {
+			c, err := compareToCursor(e, field, cursor)
+			if err != nil {
+				return Page[T]{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+			}
+			if (!q.Desc && c > 0) || (q.Desc && c < 0) {
+				start = i
+				break
+			}
+		}
+		matched = matched[start:]
+	}
+
+	if q.Offset > 0 {
+		if q.Offset >= len(matched) {
+			matched = nil
+		} else {
+			matched = matched[q.Offset:]
+		}
+	}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+
+	page := Page[T]{Items: make([]T, len(matched))}
+	for i, e := range matched {
+		page.Items[i] = e.entity
+	}
+	if q.Limit > 0 && len(matched) == q.Limit {
+		last := matched[len(matched)-1]
+		value, err := last.field(field)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		if page.NextCursor, err = EncodeCursor(Cursor{Field: field, Value: value, ID: last.id}); err != nil {
+			return Page[T]{}, err
+		}
+	}
+	return page, nil
+}
+
+// CountQuery conta em memória as entidades que satisfazem os filtros da consulta.
+func CountQuery[T any](entities map[string]T, q Query) (int, error) {
+	matched, err := filterEntities(entities, q)
+	return len(matched), err
+}
+
+type queryEntry[T any] struct {
+	id     string
+	entity T
+}
+
+func (e queryEntry[T]) field(name string) (any, error) {
+	if name == "id" {
+		return e.id, nil
+	}
+	if queryable, ok := any(e.entity).(Queryable); ok {
+		if value, ok := queryable.QueryField(name); ok {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: field %q", ErrUnsupportedQuery, name)
+}
+
+func filterEntities[T any](entities map[string]T, q Query) ([]queryEntry[T], error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	matched := make([]queryEntry[T], 0, len(entities))
+	for id, entity := range entities {
+		e := queryEntry[T]{id: id, entity: entity}
+		ok := true
+		for _, f := range q.Filters {
+			value, err := e.field(f.Field)
+			if err != nil {
+				return nil, err
+			}
+			c, err := compareValues(value, f.Value)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", f.Field, err)
+			}
+			if c != 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+func compareEntries[T any](a, b queryEntry[T], field string) (int, error) {
+	va, err := a.field(field)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := b.field(field)
+	if err != nil {
+		return 0, err
+	}
+	c, err := compareValues(va, vb)
+	if err != nil || c != 0 {
+		return c, err
+	}
+	return compareValues(a.id, b.id)
+}
+
+func compareToCursor[T any](e queryEntry[T], field string, cursor Cursor) (int, error) {
+	value, err := e.field(field)
+	if err != nil {
+		return 0, err
+	}
+	c, err := compareValues(value, cursor.Value)
+	if err != nil || c != 0 {
+		return c, err
+	}
+	return compareValues(e.id, cursor.ID)
+}
+
+// compareValues compara strings, números e booleanos. Números são comparados como float64
+// porque valores vindos de cursores JSON chegam assim.
+func compareValues(a, b any) (int, error) {
+	switch va := a.(type) {
+	case string:
+		vb, ok := b.(string)
+		if !ok {
+			return 0, fmt.Errorf("%w: cannot compare %T with %T", ErrUnsupportedQuery, a, b)
+		}
+		switch {
+		case va < vb:
+			return -1, nil
+		case va > vb:
+			return 1, nil
+		}
+		return 0, nil
+	case bool:
+		vb, ok := b.(bool)
+		if !ok {
+			return 0, fmt.Errorf("%w: cannot compare %T with %T", ErrUnsupportedQuery, a, b)
+		}
+		switch {
+		case va == vb:
+			return 0, nil
+		case !va:
+			return -1, nil
+		}
+		return 1, nil
+	}
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if !okA || !okB {
+		return 0, fmt.Errorf("%w: cannot compare %T with %T", ErrUnsupportedQuery, a, b)
+	}
+	switch {
+	case fa < fb:
+		return -1, nil
+	case fa > fb:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
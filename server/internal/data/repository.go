@@ -1,20 +1,46 @@
 package data
 
-// import "cod-server/internal/utils"
+import (
+	"errors"
+
+	"cod-server/internal/domain"
+)
+
+// Erros padrão dos repositórios. Implementações podem envolvê-los com contexto (fmt.Errorf
+// com %w); quem chama compara com errors.Is.
+var (
+	// ErrNotFound indica que não há entidade com o id em Read, Update ou Delete.
+	ErrNotFound = errors.New("entity not found")
+	// ErrAlreadyExists indica que Create recebeu um id já usado.
+	ErrAlreadyExists = errors.New("entity already exists")
+)
 
 // Repository é uma interface genérica de CRUD para entidades de domínio.
 // Implementações podem ser em memória, com SQL ou adaptadores com cache.
 type Repository[T any] interface {
-	// Create insere uma nova entidade com o id fornecido.
+	// Create insere uma nova entidade com o id fornecido; ErrAlreadyExists se o id já existe.
 	Create(id string, entity T) error
-	// Read busca uma entidade pelo id, retornando erro se não encontrada.
+	// Read busca uma entidade pelo id, retornando ErrNotFound se não encontrada.
 	Read(id string) (T, error)
-	// Update substitui a entidade armazenada sob o id fornecido.
+	// Update substitui a entidade armazenada sob o id fornecido; ErrNotFound se o id não
+	// existe e ErrConflict se a versão não for a gravada.
 	Update(id string, entity T) error
-	// Delete remove a entidade com o id fornecido.
+	// Delete remove a entidade com o id fornecido; ErrNotFound se o id não existe.
 	Delete(id string) error
 	// List retorna todas as entidades no repositório.
 	List() ([]T, error)
 	// ListBy retorna entidades que satisfaçam o predicado de filtro fornecido.
+	// Roda em memória; prefira Find quando o filtro for por igualdade de campos.
 	ListBy(filter func(T) bool) ([]T, error)
+	// Find retorna uma página de entidades conforme a consulta, traduzida para o armazenamento.
+	Find(q Query) (Page[T], error)
+	// Count conta as entidades que satisfazem os filtros da consulta (ignora paginação).
+	Count(q Query) (int, error)
+}
+
+// UserFinder é uma capacidade opcional de repositórios de usuários: busca pelo username
+// normalizado sem varrer a tabela. Serviços a detectam por type assertion e, na ausência,
+// recorrem a ListBy. Retorna nil, nil quando o username não existe.
+type UserFinder interface {
+	FindByUsername(username string) (domain.UserInterface, error)
 }
@@ -0,0 +1,150 @@
+package storage
+
+// Storage escolhe, pela configuração, onde os repositórios guardam usuários, cartas e partidas:
+//   - memory: mapas em memória; rápido para testes, e o estado volta dos snapshots do Raft e
+//     do log aplicado depois deles
+//   - sqlite: banco SQLite no caminho configurado, com as migrações aplicadas na abertura
+//   - bolt: arquivo BoltDB embutido
+// Todos entregam os mesmos data.Repository e uma data.UnitOfWork transacional, e o conteúdo
+// entra nos snapshots do Raft por SnapshotState e RestoreState.
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cod-server/internal/data"
+	"cod-server/internal/data/persistence"
+	"cod-server/internal/domain"
+)
+
+const (
+	Memory = "memory"
+	SQLite = "sqlite"
+	Bolt   = "bolt"
+)
+
+// Config seleciona o backend; Path vazio usa DefaultPath.
+type Config struct {
+	Backend string
+	Path    string
+}
+
+// DefaultPath retorna o arquivo padrão do backend; vazio para memory.
+func DefaultPath(backend string) string {
+	switch backend {
+	case SQLite:
+		return "./game_data.db"
+	case Bolt:
+		return "./game_data.bolt"
+	}
+	return ""
+}
+
+// Storage reúne os repositórios de um backend e a unidade de trabalho sobre eles.
+type Storage struct {
+	data.Tx
+	UnitOfWork data.UnitOfWork
+	close      func() error
+}
+
+// Open abre o backend configurado.
+func Open(config Config) (*Storage, error) {
+	backend := strings.ToLower(strings.TrimSpace(config.Backend))
+	path := config.Path
+	if path == "" {
+		path = DefaultPath(backend)
+	}
+
+	switch backend {
+	case Memory:
+		return openMemory()
+	case SQLite:
+		return openSQLite(path)
+	case Bolt:
+		return openBolt(path)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q (want %s, %s or %s)", config.Backend, Memory, SQLite, Bolt)
+}
+
+// Close libera o arquivo ou a conexão do backend.
+func (s *Storage) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
+
+// SnapshotState serializa usuários, cartas e partidas numa única unidade de trabalho.
+func (s *Storage) SnapshotState() ([]byte, error) {
+	var state []byte
+	err := s.UnitOfWork.Do(func(tx data.Tx) error {
+		var err error
+		state, err = persistence.EncodeSnapshot(tx)
+		return err
+	})
+	return state, err
+}
+
+// RestoreState troca o conteúdo dos repositórios pelo do snapshot, inteiro ou nada. Um
+// snapshot sem os repositórios é de antes de eles entrarem nos snapshots: o conteúdo em disco
+// é mantido em vez de apagado.
+func (s *Storage) RestoreState(state []byte) error {
+	if state == nil {
+		return nil
+	}
+	return s.UnitOfWork.Do(func(tx data.Tx) error {
+		return persistence.RestoreSnapshot(tx, state)
+	})
+}
+
+func openMemory() (*Storage, error) {
+	repos := data.Tx{
+		Users:   data.NewMemoryRepository[domain.UserInterface](),
+		Cards:   data.NewMemoryRepository[domain.CardInterface](),
+		Matches: data.NewMemoryRepository[domain.MatchInterface](),
+	}
+	uow, err := data.NewMemoryUnitOfWork(repos)
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{Tx: repos, UnitOfWork: uow}, nil
+}
+
+func openSQLite(path string) (*Storage, error) {
+	db, err := persistence.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %w", err)
+	}
+
+	// Configura pool de conexões SQLite para acesso concorrente
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(25)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	// Aplica as migrações pendentes antes de qualquer acesso às tabelas
+	if err := persistence.Migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating sqlite database: %w", err)
+	}
+
+	repos := data.Tx{
+		Users:   persistence.NewUserRepoAdapter(persistence.NewSqlUserRepository(db)),
+		Cards:   persistence.NewCardRepoAdapter(persistence.NewSqlCardRepository(db)),
+		Matches: persistence.NewMatchRepoAdapter(persistence.NewSqlMatchRepository(db)),
+	}
+	return &Storage{Tx: repos, UnitOfWork: persistence.NewSqlUnitOfWork(db), close: db.Close}, nil
+}
+
+func openBolt(path string) (*Storage, error) {
+	db, err := persistence.OpenBolt(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt database: %w", err)
+	}
+	repos := data.Tx{
+		Users:   persistence.NewBoltUserRepository(db),
+		Cards:   persistence.NewBoltCardRepository(db),
+		Matches: persistence.NewBoltMatchRepository(db),
+	}
+	return &Storage{Tx: repos, UnitOfWork: persistence.NewBoltUnitOfWork(db), close: db.Close}, nil
+}
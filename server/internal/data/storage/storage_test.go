@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cod-server/internal/data"
+	"cod-server/internal/data/datatest"
+	"cod-server/internal/domain"
+)
+
+// Todos os backends passam pela suíte de conformidade de datatest.
+
+func openBackends(t *testing.T) map[string]*Storage {
+	t.Helper()
+	dir := t.TempDir()
+	backends := make(map[string]*Storage)
+	for _, config := range []Config{
+		{Backend: Memory},
+		{Backend: SQLite, Path: filepath.Join(dir, "conformance.db")},
+		{Backend: Bolt, Path: filepath.Join(dir, "conformance.bolt")},
+	} {
+		store, err := Open(config)
+		if err != nil {
+			t.Fatalf("open %s: %v", config.Backend, err)
+		}
+		t.Cleanup(func() { store.Close() })
+		backends[config.Backend] = store
+	}
+	return backends
+}
+
+func TestStorage_Repositories(t *testing.T) {
+	for name, store := range openBackends(t) {
+		t.Run(name+"/users", func(t *testing.T) { datatest.TestRepository(t, store.Users, datatest.Users) })
+		t.Run(name+"/cards", func(t *testing.T) { datatest.TestRepository(t, store.Cards, datatest.Cards) })
+		t.Run(name+"/matches", func(t *testing.T) { datatest.TestRepository(t, store.Matches, datatest.Matches) })
+	}
+}
+
+func TestStorage_Queries(t *testing.T) {
+	for name, store := range openBackends(t) {
+		t.Run(name+"/cards", func(t *testing.T) { datatest.TestCardQueries(t, store.Cards) })
+		t.Run(name+"/matches", func(t *testing.T) { datatest.TestMatchQueries(t, store.Matches) })
+	}
+}
+
+func TestStorage_UnitOfWork(t *testing.T) {
+	for name, store := range openBackends(t) {
+		t.Run(name, func(t *testing.T) { datatest.TestUnitOfWork(t, store.Tx, store.UnitOfWork) })
+	}
+}
+
+func TestStorage_SnapshotRestoresEveryBackend(t *testing.T) {
+	for source, store := range openBackends(t) {
+		// Um jogador com conta (alterada depois de criada) e outro que já a apagou
+		user := datatest.NewUser("m1-p1")
+		if err := store.Users.Create("m1-p1", user); err != nil {
+			t.Fatal(err)
+		}
+		datatest.Users.Change(user)
+		if err := store.Users.Update("m1-p1", user); err != nil {
+			t.Fatal(err)
+		}
+		card := datatest.Cards.New("c1")
+		if err := store.Cards.Create("c1", card); err != nil {
+			t.Fatal(err)
+		}
+		datatest.Cards.Change(card)
+		if err := store.Cards.Update("c1", card); err != nil {
+			t.Fatal(err)
+		}
+		player, err := store.Users.Read("m1-p1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := datatest.Matches.New("m1").(*domain.Match)
+		match.Players[0] = player
+		if err := store.Matches.Create("m1", match); err != nil {
+			t.Fatal(err)
+		}
+
+		snapshot, err := store.SnapshotState()
+		if err != nil {
+			t.Fatalf("%s: SnapshotState: %v", source, err)
+		}
+		for target, restored := range openBackends(t) {
+			name := source + "->" + target
+			if err := restored.Cards.Create("stale", datatest.Cards.New("stale")); err != nil {
+				t.Fatal(err)
+			}
+			if err := restored.RestoreState(snapshot); err != nil {
+				t.Fatalf("%s: RestoreState: %v", name, err)
+			}
+
+			if _, err := restored.Cards.Read("stale"); !errors.Is(err, data.ErrNotFound) {
+				t.Errorf("%s: entity missing from the snapshot survived the restore: %v", name, err)
+			}
+			sameEntity(t, name, "m1-p1", store.Users, restored.Users)
+			sameEntity(t, name, "c1", store.Cards, restored.Cards)
+			sameEntity(t, name, "m1", store.Matches, restored.Matches)
+
+			// Snapshots de antes dos repositórios entrarem neles não apagam nada
+			if err := restored.RestoreState(nil); err != nil {
+				t.Fatalf("%s: RestoreState(nil): %v", name, err)
+			}
+			sameEntity(t, name+" after nil", "c1", store.Cards, restored.Cards)
+		}
+	}
+}
+
+// sameEntity confere que id foi restaurado igual ao original, versão incluída.
+func sameEntity[T any](t *testing.T, name, id string, want, got data.Repository[T]) {
+	t.Helper()
+	original, err := want.Read(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := got.Read(id)
+	if err != nil {
+		t.Errorf("%s: Read %s: %v", name, id, err)
+		return
+	}
+	if !reflect.DeepEqual(restored, original) {
+		t.Errorf("%s: %s restored as %+v, want %+v", name, id, restored, original)
+	}
+}
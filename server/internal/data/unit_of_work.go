@@ -0,0 +1,247 @@
+package data
+
+import (
+	"errors"
+	"sync"
+
+	"cod-server/internal/domain"
+)
+
+// Tx reúne os repositórios vistos dentro de uma unidade de trabalho. Escritas feitas por
+// eles só valem se a função passada a UnitOfWork.Do retornar nil.
+type Tx struct {
+	Users   Repository[domain.UserInterface]
+	Cards   Repository[domain.CardInterface]
+	Matches Repository[domain.MatchInterface]
+}
+
+// UnitOfWork executa alterações em várias entidades de forma atômica.
+type UnitOfWork interface {
+	// Do executa fn e confirma suas escritas se ela retornar nil; caso contrário, descarta todas.
+	Do(fn func(tx Tx) error) error
+}
+
+// DirectUnitOfWork repassa os repositórios sem transação: cada escrita vale na hora e um
+// erro no meio não desfaz as anteriores. Serve para repositórios sem suporte a transação,
+// como os mocks de teste.
+type DirectUnitOfWork struct {
+	repos Tx
+}
+
+func NewDirectUnitOfWork(repos Tx) UnitOfWork {
+	return &DirectUnitOfWork{repos: repos}
+}
+
+func (u *DirectUnitOfWork) Do(fn func(tx Tx) error) error {
+	return fn(u.repos)
+}
+
+// MemoryUnitOfWork dá transações a MemoryRepository por cópia na escrita: as escritas ficam
+// em uma camada sobre os mapas e só são aplicadas, todas de uma vez, no commit. Unidades de
+// trabalho são serializadas entre si; se uma escrita feita fora delas alterar uma entidade
+// que a transação também escreveu, o commit falha com ErrConflict.
+type MemoryUnitOfWork struct {
+	mu      sync.Mutex
+	users   *MemoryRepository[domain.UserInterface]
+	cards   *MemoryRepository[domain.CardInterface]
+	matches *MemoryRepository[domain.MatchInterface]
+}
+
+// NewMemoryUnitOfWork exige repositórios criados por NewMemoryRepository.
+func NewMemoryUnitOfWork(repos Tx) (UnitOfWork, error) {
+	users, ok1 := repos.Users.(*MemoryRepository[domain.UserInterface])
+	cards, ok2 := repos.Cards.(*MemoryRepository[domain.CardInterface])
+	matches, ok3 := repos.Matches.(*MemoryRepository[domain.MatchInterface])
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errors.New("memory unit of work requires memory repositories")
+	}
+	return &MemoryUnitOfWork{users: users, cards: cards, matches: matches}, nil
+}
+
+func (u *MemoryUnitOfWork) Do(fn func(tx Tx) error) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	users := newMemoryTx(u.users)
+	cards := newMemoryTx(u.cards)
+	matches := newMemoryTx(u.matches)
+	if err := fn(Tx{Users: users, Cards: cards, Matches: matches}); err != nil {
+		return err
+	}
+
+	// Trava os três mapas antes de aplicar para que leitores nunca vejam um commit pela metade
+	u.users.mu.Lock()
+	defer u.users.mu.Unlock()
+	u.cards.mu.Lock()
+	defer u.cards.mu.Unlock()
+	u.matches.mu.Lock()
+	defer u.matches.mu.Unlock()
+
+	for _, err := range []error{users.check(), cards.check(), matches.check()} {
+		if err != nil {
+			return err
+		}
+	}
+	users.apply()
+	cards.apply()
+	matches.apply()
+	return nil
+}
+
+// memoryTx é a camada de escrita de uma transação sobre um MemoryRepository.
+type memoryTx[T any] struct {
+	base    *MemoryRepository[T]
+	staged  map[string]T
+	deleted map[string]bool
+	touched map[string]baseState // Estado no mapa base quando a transação escreveu o id pela primeira vez
+}
+
+type baseState struct {
+	exists  bool
+	version int64
+}
+
+func newMemoryTx[T any](base *MemoryRepository[T]) *memoryTx[T] {
+	return &memoryTx[T]{
+		base:    base,
+		staged:  make(map[string]T),
+		deleted: make(map[string]bool),
+		touched: make(map[string]baseState),
+	}
+}
+
+// touch registra o estado base de id antes da primeira escrita da transação.
+func (t *memoryTx[T]) touch(id string) {
+	if _, ok := t.touched[id]; ok {
+		return
+	}
+	t.base.mu.RLock()
+	defer t.base.mu.RUnlock()
+	entity, exists := t.base.data[id]
+	t.touched[id] = baseState{exists: exists, version: versionOf(entity)}
+}
+
+// check recusa o commit se o mapa base mudou nos ids escritos; chamado com base.mu travado.
+func (t *memoryTx[T]) check() error {
+	for id, state := range t.touched {
+		entity, exists := t.base.data[id]
+		if exists != state.exists || (exists && versionOf(entity) != state.version) {
+			return ErrConflict
+		}
+	}
+	return nil
+}
+
+// apply grava a camada no mapa base; chamado com base.mu travado.
+func (t *memoryTx[T]) apply() {
+	for id := range t.deleted {
+		delete(t.base.data, id)
+	}
+	for id, entity := range t.staged {
+		t.base.data[id] = entity
+	}
+}
+
+func (t *memoryTx[T]) lookup(id string) (T, bool) {
+	if entity, ok := t.staged[id]; ok {
+		return Clone(entity), true
+	}
+	var zero T
+	if t.deleted[id] {
+		return zero, false
+	}
+	t.base.mu.RLock()
+	defer t.base.mu.RUnlock()
+	entity, ok := t.base.data[id]
+	if !ok {
+		return zero, false
+	}
+	return Clone(entity), true
+}
+
+// view monta o estado visível na transação: base mais escritas pendentes.
+func (t *memoryTx[T]) view() map[string]T {
+	t.base.mu.RLock()
+	merged := make(map[string]T, len(t.base.data)+len(t.staged))
+	for id, entity := range t.base.data {
+		if !t.deleted[id] {
+			merged[id] = entity
+		}
+	}
+	t.base.mu.RUnlock()
+	for id, entity := range t.staged {
+		merged[id] = entity
+	}
+	return merged
+}
+
+func (t *memoryTx[T]) Create(id string, entity T) error {
+	if _, exists := t.lookup(id); exists {
+		return ErrAlreadyExists
+	}
+	t.touch(id)
+	t.staged[id] = Clone(entity)
+	return nil
+}
+
+func (t *memoryTx[T]) Read(id string) (T, error) {
+	entity, exists := t.lookup(id)
+	if !exists {
+		return entity, ErrNotFound
+	}
+	return entity, nil
+}
+
+func (t *memoryTx[T]) Update(id string, entity T) error {
+	current, exists := t.lookup(id)
+	if !exists {
+		return ErrNotFound
+	}
+	if err := BumpVersion(current, entity); err != nil {
+		return err
+	}
+	t.touch(id)
+	t.staged[id] = Clone(entity)
+	return nil
+}
+
+func (t *memoryTx[T]) Delete(id string) error {
+	if _, exists := t.lookup(id); !exists {
+		return ErrNotFound
+	}
+	t.touch(id)
+	delete(t.staged, id)
+	t.deleted[id] = true
+	return nil
+}
+
+func (t *memoryTx[T]) List() ([]T, error) {
+	view := t.view()
+	list := make([]T, 0, len(view))
+	for _, entity := range view {
+		list = append(list, Clone(entity))
+	}
+	return list, nil
+}
+
+func (t *memoryTx[T]) ListBy(filter func(T) bool) ([]T, error) {
+	list := make([]T, 0)
+	for _, entity := range t.view() {
+		if filter(entity) {
+			list = append(list, Clone(entity))
+		}
+	}
+	return list, nil
+}
+
+func (t *memoryTx[T]) Find(q Query) (Page[T], error) {
+	page, err := ApplyQuery(t.view(), q)
+	for i, entity := range page.Items {
+		page.Items[i] = Clone(entity)
+	}
+	return page, err
+}
+
+func (t *memoryTx[T]) Count(q Query) (int, error) {
+	return CountQuery(t.view(), q)
+}
@@ -0,0 +1,52 @@
+package data
+
+import "errors"
+
+// ErrConflict indica que a entidade mudou desde a leitura: a versão enviada em Update não é
+// mais a gravada. Releia a entidade e tente de novo.
+var ErrConflict = errors.New("version conflict")
+
+// Versioned é implementado por entidades com controle otimista de concorrência. Update só
+// grava se a versão da entidade for a gravada e, ao gravar, incrementa a versão (inclusive
+// na instância recebida, que pode ser usada em um próximo Update).
+type Versioned interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+// Cloner é implementado por entidades que os repositórios em memória e o cache copiam, para
+// que cada leitura receba sua própria instância e a versão lida não mude por baixo dela.
+type Cloner[T any] interface {
+	Clone() T
+}
+
+// Clone copia a entidade se ela implementar Cloner; caso contrário, a retorna como está.
+func Clone[T any](entity T) T {
+	if cloner, ok := any(entity).(Cloner[T]); ok {
+		return cloner.Clone()
+	}
+	return entity
+}
+
+// BumpVersion confere a versão de entity contra a gravada e a incrementa. Entidades sem
+// versão são aceitas sem verificação. Serve aos repositórios que fazem a verificação em Go,
+// sem um UPDATE condicional no armazenamento.
+func BumpVersion[T any](stored, entity T) error {
+	next, ok := any(entity).(Versioned)
+	if !ok {
+		return nil
+	}
+	if current, ok := any(stored).(Versioned); ok && current.GetVersion() != next.GetVersion() {
+		return ErrConflict
+	}
+	next.SetVersion(next.GetVersion() + 1)
+	return nil
+}
+
+// versionOf retorna a versão da entidade, ou 0 se ela não for versionada.
+func versionOf[T any](entity T) int64 {
+	if v, ok := any(entity).(Versioned); ok {
+		return v.GetVersion()
+	}
+	return 0
+}
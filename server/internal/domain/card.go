@@ -12,7 +12,9 @@ type CardInterface interface {
 	GetID() string
 	GetOwnerID() string
 	GetType() string
-	Against(opponent CardInterface) int
+	GetPower() int
+	// Against resolve o confronto com opponent pelas regras dadas: 1 vitória, 0 empate, -1 derrota.
+	Against(opponent CardInterface, rules CombatRules) int
 }
 
 type PackInterface interface {
@@ -26,6 +28,12 @@ type Card struct {
 	ID      string `json:"id"`
 	OwnerID string `json:"owner_id"`
 	Type    string `json:"type"`
+	// TemplateID aponta para o modelo do catálogo; vazio em cartas anteriores ao catálogo.
+	TemplateID string `json:"template_id,omitempty"`
+	// Power desempata confrontos sem vantagem de elemento; vem do modelo do catálogo.
+	Power int `json:"power"`
+	// Version é incrementada a cada Update; escritas com versão antiga são recusadas.
+	Version int64 `json:"version"`
 }
 
 type Pack struct {
@@ -33,6 +41,35 @@ type Pack struct {
 	Cards []CardInterface `json:"cards"`
 }
 
+// QueryField expõe os campos consultáveis em data.Query: id, owner_id, type e template_id.
+func (c *Card) QueryField(name string) (any, bool) {
+	switch name {
+	case "id":
+		return c.ID, true
+	case "owner_id":
+		return c.OwnerID, true
+	case "type":
+		return c.Type, true
+	case "template_id":
+		return c.TemplateID, true
+	}
+	return nil, false
+}
+
+func (c *Card) GetVersion() int64 {
+	return c.Version
+}
+
+func (c *Card) SetVersion(version int64) {
+	c.Version = version
+}
+
+// Clone copia a carta para que repositórios não compartilhem a instância com quem a lê.
+func (c *Card) Clone() CardInterface {
+	clone := *c
+	return &clone
+}
+
 func (c *Card) GetID() string {
 	return c.ID
 }
@@ -45,16 +82,16 @@ func (c *Card) GetType() string {
 	return c.Type
 }
 
-func (c *Card) Against(opponent CardInterface) int {
-	if c.Type == opponent.GetType() {
-		return 0 // Draw
-	}
-	if (c.Type == "rock" && opponent.GetType() == "scissors") ||
-		(c.Type == "scissors" && opponent.GetType() == "paper") ||
-		(c.Type == "paper" && opponent.GetType() == "rock") {
-		return 1 // Win
+func (c *Card) GetPower() int {
+	return c.Power
+}
+
+// Against delega a rules; sem regras, usa DefaultCombatRules.
+func (c *Card) Against(opponent CardInterface, rules CombatRules) int {
+	if rules == nil {
+		rules = DefaultCombatRules()
 	}
-	return -1 // Lose
+	return rules.Resolve(c, opponent)
 }
 
 func (p *Pack) GetID() string {
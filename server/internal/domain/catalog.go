@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidCatalog  = errors.New("invalid card catalog")
+	ErrUnknownTemplate = errors.New("unknown card template")
+)
+
+// Rarity é a raridade de um modelo de carta; define com que frequência ele sai nos pacotes.
+type Rarity string
+
+const (
+	RarityCommon    Rarity = "common"
+	RarityUncommon  Rarity = "uncommon"
+	RarityRare      Rarity = "rare"
+	RarityEpic      Rarity = "epic"
+	RarityLegendary Rarity = "legendary"
+)
+
+// rarityWeights é quantas vezes cada raridade entra no sorteio de um pacote.
+var rarityWeights = map[Rarity]int{
+	RarityCommon:    8,
+	RarityUncommon:  4,
+	RarityRare:      2,
+	RarityEpic:      1,
+	RarityLegendary: 1,
+}
+
+// Weight retorna o peso da raridade no sorteio dos pacotes; 0 se ela não existe.
+func (r Rarity) Weight() int {
+	return rarityWeights[r]
+}
+
+// CardTemplate é a definição de uma carta do catálogo. As cartas dos jogadores apontam para
+// um modelo por TemplateID e herdam dele elemento e poder.
+type CardTemplate struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Element string `json:"element"`
+	Power   int    `json:"power"`
+	Rarity  Rarity `json:"rarity"`
+	Flavor  string `json:"flavor,omitempty"`
+}
+
+// Catalog é o conjunto versionado de modelos de carta. Version cresce a cada publicação;
+// réplicas só trocam o catálogo por um de versão maior.
+type Catalog struct {
+	Version   int            `json:"version"`
+	Templates []CardTemplate `json:"templates"`
+}
+
+// ParseCatalog lê um catálogo em JSON e o valida.
+func ParseCatalog(raw []byte) (*Catalog, error) {
+	var catalog Catalog
+	if err := json.Unmarshal(raw, &catalog); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCatalog, err)
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+// Validate confere versão positiva, ao menos um modelo, ids únicos e campos obrigatórios.
+func (c *Catalog) Validate() error {
+	if c.Version <= 0 {
+		return fmt.Errorf("%w: version must be positive", ErrInvalidCatalog)
+	}
+	if len(c.Templates) == 0 {
+		return fmt.Errorf("%w: no templates", ErrInvalidCatalog)
+	}
+	seen := make(map[string]bool, len(c.Templates))
+	for _, template := range c.Templates {
+		switch {
+		case template.ID == "":
+			return fmt.Errorf("%w: template without id", ErrInvalidCatalog)
+		case seen[template.ID]:
+			return fmt.Errorf("%w: duplicate template %q", ErrInvalidCatalog, template.ID)
+		case template.Name == "" || template.Element == "":
+			return fmt.Errorf("%w: template %q needs a name and an element", ErrInvalidCatalog, template.ID)
+		case template.Power < 0:
+			return fmt.Errorf("%w: template %q has negative power", ErrInvalidCatalog, template.ID)
+		case template.Rarity.Weight() == 0:
+			return fmt.Errorf("%w: template %q has unknown rarity %q", ErrInvalidCatalog, template.ID, template.Rarity)
+		}
+		seen[template.ID] = true
+	}
+	return nil
+}
+
+// Template busca um modelo pelo id.
+func (c *Catalog) Template(id string) (CardTemplate, bool) {
+	for _, template := range c.Templates {
+		if template.ID == id {
+			return template, true
+		}
+	}
+	return CardTemplate{}, false
+}
+
+// DrawPool lista os modelos repetidos conforme o peso da raridade, na ordem do catálogo.
+// Sortear uma posição do pool respeita as raridades.
+func (c *Catalog) DrawPool() []CardTemplate {
+	var pool []CardTemplate
+	for _, template := range c.Templates {
+		for i := 0; i < template.Rarity.Weight(); i++ {
+			pool = append(pool, template)
+		}
+	}
+	return pool
+}
+
+// NewCard cria uma carta do modelo para o dono. Type e Power guardam elemento e poder,
+// usados no combate.
+func (t CardTemplate) NewCard(id, ownerID string) *Card {
+	return &Card{ID: id, OwnerID: ownerID, Type: t.Element, TemplateID: t.ID, Power: t.Power}
+}
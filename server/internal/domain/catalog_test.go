@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCatalog(t *testing.T) {
+	valid := `{"version": 2, "templates": [
+		{"id": "rock-pebble", "name": "Pebble", "element": "rock", "power": 1, "rarity": "common"},
+		{"id": "paper-scroll", "name": "Scroll", "element": "paper", "power": 0, "rarity": "legendary", "flavor": "Old."}
+	]}`
+	catalog, err := ParseCatalog([]byte(valid))
+	if err != nil {
+		t.Fatalf("ParseCatalog: %v", err)
+	}
+	if template, ok := catalog.Template("paper-scroll"); catalog.Version != 2 || !ok || template.Flavor != "Old." {
+		t.Errorf("parsed catalog = %+v", catalog)
+	}
+
+	template := func(fields string) string {
+		return `{"version": 1, "templates": [{` + fields + `}]}`
+	}
+	invalid := []struct{ name, raw string }{
+		{"not json", `{"version": 1,`},
+		{"wrong field type", `{"version": "1", "templates": []}`},
+		{"zero version", `{"version": 0, "templates": [{"id": "a", "name": "A", "element": "rock", "rarity": "common"}]}`},
+		{"no templates", `{"version": 1, "templates": []}`},
+		{"template without id", template(`"name": "A", "element": "rock", "rarity": "common"`)},
+		{"template without name", template(`"id": "a", "element": "rock", "rarity": "common"`)},
+		{"template without element", template(`"id": "a", "name": "A", "rarity": "common"`)},
+		{"negative power", template(`"id": "a", "name": "A", "element": "rock", "power": -1, "rarity": "common"`)},
+		{"unknown rarity", template(`"id": "a", "name": "A", "element": "rock", "rarity": "mythic"`)},
+		{"duplicate id", `{"version": 1, "templates": [
+			{"id": "a", "name": "A", "element": "rock", "rarity": "common"},
+			{"id": "a", "name": "B", "element": "paper", "rarity": "rare"}]}`},
+	}
+	for _, tt := range invalid {
+		if _, err := ParseCatalog([]byte(tt.raw)); !errors.Is(err, ErrInvalidCatalog) {
+			t.Errorf("%s: got %v, want ErrInvalidCatalog", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package domain
+
+// CombatRules resolve o confronto entre duas cartas: 1 se card vence, -1 se perde e 0 em
+// empate. As regras são passadas a Match.MakeMove por quem conduz a partida (MatchService);
+// outros conjuntos de regras podem ser testados implementando esta interface.
+type CombatRules interface {
+	Resolve(card, opponent CardInterface) int
+}
+
+// PowerModifier ajusta o poder de card no confronto com opponent, recebendo o poder já
+// ajustado pelos modificadores anteriores.
+type PowerModifier func(card, opponent CardInterface, power int) int
+
+// ElementRules resolve primeiro pela vantagem de elemento e, se nenhum dos dois tiver
+// vantagem (mesmo elemento ou elementos sem relação), pelo poder após os modificadores.
+type ElementRules struct {
+	// Beats lista, para cada elemento, os elementos que ele vence.
+	Beats     map[string][]string
+	Modifiers []PowerModifier
+}
+
+// DefaultCombatRules retorna pedra, papel e tesoura com desempate por poder, sem modificadores.
+func DefaultCombatRules() CombatRules {
+	return NewElementRules()
+}
+
+// NewElementRules cria as regras de pedra, papel e tesoura com os modificadores dados.
+func NewElementRules(modifiers ...PowerModifier) *ElementRules {
+	return &ElementRules{
+		Beats: map[string][]string{
+			"rock":     {"scissors"},
+			"scissors": {"paper"},
+			"paper":    {"rock"},
+		},
+		Modifiers: modifiers,
+	}
+}
+
+func (r *ElementRules) Resolve(card, opponent CardInterface) int {
+	switch {
+	case r.beats(card.GetType(), opponent.GetType()):
+		return 1
+	case r.beats(opponent.GetType(), card.GetType()):
+		return -1
+	}
+
+	power, opponentPower := r.power(card, opponent), r.power(opponent, card)
+	switch {
+	case power > opponentPower:
+		return 1
+	case power < opponentPower:
+		return -1
+	}
+	return 0
+}
+
+func (r *ElementRules) beats(element, other string) bool {
+	for _, beaten := range r.Beats[element] {
+		if beaten == other {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ElementRules) power(card, opponent CardInterface) int {
+	power := card.GetPower()
+	for _, modify := range r.Modifiers {
+		power = modify(card, opponent, power)
+	}
+	return power
+}
+
+// ElementBonus soma bonus ao poder das cartas do elemento, por exemplo num evento sazonal.
+func ElementBonus(element string, bonus int) PowerModifier {
+	return func(card, opponent CardInterface, power int) int {
+		if card.GetType() == element {
+			return power + bonus
+		}
+		return power
+	}
+}
@@ -0,0 +1,52 @@
+package domain
+
+import "testing"
+
+func TestElementRules_Resolve(t *testing.T) {
+	card := func(element string, power int) *Card {
+		return &Card{ID: element, Type: element, Power: power}
+	}
+	tests := []struct {
+		name           string
+		rules          CombatRules
+		card, opponent *Card
+		want           int
+	}{
+		{"element advantage beats power", NewElementRules(), card("rock", 1), card("scissors", 9), 1},
+		{"element disadvantage loses to weaker card", NewElementRules(), card("paper", 9), card("scissors", 1), -1},
+		{"same element, higher power wins", NewElementRules(), card("rock", 5), card("rock", 3), 1},
+		{"same element and power draws", NewElementRules(), card("paper", 2), card("paper", 2), 0},
+		{"unknown element falls back to power", NewElementRules(), card("fire", 2), card("rock", 4), -1},
+		{"modifier changes the power comparison", NewElementRules(ElementBonus("fire", 3)), card("fire", 2), card("rock", 4), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Resolve(tt.card, tt.opponent); got != tt.want {
+				t.Errorf("Resolve = %d, want %d", got, tt.want)
+			}
+			if got := tt.rules.Resolve(tt.opponent, tt.card); got != -tt.want {
+				t.Errorf("Resolve reversed = %d, want %d", got, -tt.want)
+			}
+			if got := tt.card.Against(tt.opponent, tt.rules); got != tt.want {
+				t.Errorf("Against = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCard_AgainstWithoutRulesUsesDefault(t *testing.T) {
+	rock, paper := &Card{ID: "r", Type: "rock", Power: 9}, &Card{ID: "p", Type: "paper", Power: 1}
+	if got := rock.Against(paper, nil); got != -1 {
+		t.Errorf("Against = %d, want -1", got)
+	}
+}
+
+func TestRoundWinner_UsesPower(t *testing.T) {
+	round := map[string]CardInterface{
+		"p1": &Card{ID: "c1", Type: "scissors", Power: 2},
+		"p2": &Card{ID: "c2", Type: "scissors", Power: 7},
+	}
+	if got := RoundWinner(round, DefaultCombatRules()); got != "p2" {
+		t.Errorf("RoundWinner = %q, want p2", got)
+	}
+}
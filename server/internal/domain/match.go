@@ -13,18 +13,82 @@ type MatchInterface interface {
 	GetScores() map[string]int
 	AddPlayer(player UserInterface) error
 	RemovePlayer(playerID string) error
-	MakeMove(playerID string, move CardInterface) error
+	MakeMove(playerID string, move CardInterface, rules CombatRules) error
 	Surrender(playerID string) error
 	GetWinner() (string, error)
+	Cancel() error
+	IsCancelled() bool
 }
 
 type Match struct {
 	ID      string                     `json:"id"`
 	Players []UserInterface            `json:"players"`
 	Moves   []map[string]CardInterface `json:"moves"`
-	Scores  map[string]int             `json:"scores"`
-	Winner  string                     `json:"winner"`
-	mu      sync.RWMutex               `json:"-"`
+	// RoundWinners guarda, para cada rodada de Moves, o vencedor decidido quando ela foi
+	// jogada ("" em empate ou enquanto a rodada não termina); o histórico não a resolve de novo.
+	RoundWinners []string       `json:"round_winners"`
+	Scores       map[string]int `json:"scores"`
+	Winner       string         `json:"winner"`
+	// Cancelled marca partidas encerradas por um administrador, sem vencedor.
+	Cancelled bool `json:"cancelled"`
+	// Version é incrementada a cada Update; escritas com versão antiga são recusadas.
+	Version int64        `json:"version"`
+	mu      sync.RWMutex `json:"-"`
+}
+
+func (m *Match) GetVersion() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Version
+}
+
+func (m *Match) SetVersion(version int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Version = version
+}
+
+// Clone copia a partida, incluindo rodadas e placar, para que repositórios não compartilhem
+// a instância com quem a lê. Jogadores e cartas jogadas são compartilhados.
+func (m *Match) Clone() MatchInterface {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clone := &Match{
+		ID:           m.ID,
+		Players:      append([]UserInterface(nil), m.Players...),
+		Moves:        make([]map[string]CardInterface, len(m.Moves)),
+		RoundWinners: append([]string(nil), m.RoundWinners...),
+		Scores:       make(map[string]int, len(m.Scores)),
+		Winner:       m.Winner,
+		Cancelled:    m.Cancelled,
+		Version:      m.Version,
+	}
+	for i, round := range m.Moves {
+		clone.Moves[i] = make(map[string]CardInterface, len(round))
+		for playerID, card := range round {
+			clone.Moves[i][playerID] = card
+		}
+	}
+	for playerID, score := range m.Scores {
+		clone.Scores[playerID] = score
+	}
+	return clone
+}
+
+// QueryField expõe os campos consultáveis em data.Query: id, winner e cancelled.
+func (m *Match) QueryField(name string) (any, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	switch name {
+	case "id":
+		return m.ID, true
+	case "winner":
+		return m.Winner, true
+	case "cancelled":
+		return m.Cancelled, true
+	}
+	return nil, false
 }
 
 func (m *Match) GetID() string {
@@ -50,6 +114,9 @@ func (m *Match) AddPlayer(player UserInterface) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.Cancelled {
+		return errors.New("match was cancelled")
+	}
 	if len(m.Players) >= 2 {
 		return errors.New("match is full")
 	}
@@ -72,11 +139,12 @@ func (m *Match) RemovePlayer(playerID string) error {
 	return errors.New("player not found in match")
 }
 
-func (m *Match) MakeMove(playerID string, move CardInterface) error {
+// MakeMove registra a jogada; quando a rodada se completa, rules decide o vencedor.
+func (m *Match) MakeMove(playerID string, move CardInterface, rules CombatRules) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.Winner != "" {
+	if m.Winner != "" || m.Cancelled {
 		return errors.New("match has already ended")
 	}
 
@@ -87,6 +155,10 @@ func (m *Match) MakeMove(playerID string, move CardInterface) error {
 	if len(m.Moves) == 0 || len(m.Moves[len(m.Moves)-1]) == 2 {
 		m.Moves = append(m.Moves, make(map[string]CardInterface))
 	}
+	// Uma entrada por rodada, inclusive a que está em andamento
+	for len(m.RoundWinners) < len(m.Moves) {
+		m.RoundWinners = append(m.RoundWinners, "")
+	}
 
 	currentRound := m.Moves[len(m.Moves)-1]
 	if _, exists := currentRound[playerID]; exists {
@@ -94,45 +166,54 @@ func (m *Match) MakeMove(playerID string, move CardInterface) error {
 	}
 
 	currentRound[playerID] = move
-	if len(currentRound) == 2 {
-		var player1ID, player2ID string
-		var player1Move, player2Move CardInterface
-		i := 0
-		for pid, mv := range currentRound {
-			if i == 0 {
-				player1ID = pid
-				player1Move = mv
-			} else {
-				player2ID = pid
-				player2Move = mv
-			}
-			i++
+	if len(currentRound) < 2 {
+		return nil
+	}
+	roundWinnerID := RoundWinner(currentRound, rules)
+	m.RoundWinners[len(m.Moves)-1] = roundWinnerID
+	if roundWinnerID != "" {
+		m.Scores[roundWinnerID]++
+		if m.Scores[roundWinnerID] >= RoundsToWin {
+			m.Winner = roundWinnerID
 		}
+	}
+	return nil
+}
 
-		result := player1Move.Against(player2Move)
-
-		var roundWinnerID string
-		if result == 1 {
-			roundWinnerID = player1ID
-		} else if result == -1 {
-			roundWinnerID = player2ID
+// RoundWinner resolve uma rodada com as duas jogadas pelas regras dadas e retorna o id do
+// vencedor; retorna "" em empate ou se a rodada ainda não estiver completa.
+func RoundWinner(round map[string]CardInterface, rules CombatRules) string {
+	if len(round) != 2 {
+		return ""
+	}
+	var player1ID, player2ID string
+	var player1Move, player2Move CardInterface
+	i := 0
+	for pid, mv := range round {
+		if i == 0 {
+			player1ID = pid
+			player1Move = mv
+		} else {
+			player2ID = pid
+			player2Move = mv
 		}
+		i++
+	}
 
-		if roundWinnerID != "" {
-			m.Scores[roundWinnerID]++
-			if m.Scores[roundWinnerID] >= RoundsToWin {
-				m.Winner = roundWinnerID
-			}
-		}
+	switch player1Move.Against(player2Move, rules) {
+	case 1:
+		return player1ID
+	case -1:
+		return player2ID
 	}
-	return nil
+	return ""
 }
 
 func (m *Match) Surrender(playerID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.Winner != "" {
+	if m.Winner != "" || m.Cancelled {
 		return errors.New("match has already ended")
 	}
 
@@ -167,3 +248,20 @@ func (m *Match) GetWinner() (string, error) {
 	return m.Winner, nil
 }
 
+// Cancel encerra a partida sem vencedor; jogadas e desistências passam a ser recusadas.
+func (m *Match) Cancel() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Winner != "" || m.Cancelled {
+		return errors.New("match has already ended")
+	}
+	m.Cancelled = true
+	return nil
+}
+
+func (m *Match) IsCancelled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Cancelled
+}
@@ -1,20 +1,94 @@
 package domain
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+	MinPasswordLength = 8
+	MaxPasswordBytes  = 72 // bcrypt ignora o que passar disso
+)
+
+var (
+	ErrUsernameTaken   = errors.New("username already taken")
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrWeakPassword    = errors.New("password does not meet the policy")
+)
+
+// Role define o papel de um usuário; as permissões de cada papel ficam na política de auth.
+type Role string
+
+const (
+	RolePlayer    Role = "player"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
 )
 
+// ValidRole informa se o papel é um dos conhecidos.
+func ValidRole(role Role) bool {
+	switch role {
+	case RolePlayer, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type UserInterface interface {
 	GetID() string
 	GetUsername() string
+	GetRoles() []Role
+	HasRole(role Role) bool
+	GetCreatedAt() time.Time
 	CheckPassword(password string) bool
 }
 
 type User struct {
-	ID       string        `json:"id"`
-	Username string        `json:"username"`
-	Password string        `json:"password"`
-	Cards    PackInterface `json:"cards"`
+	ID        string        `json:"id"`
+	Username  string        `json:"username"`
+	Password  string        `json:"password"`
+	Roles     []Role        `json:"roles"`
+	CreatedAt time.Time     `json:"created_at"`
+	Cards     PackInterface `json:"cards"`
+	// Version é incrementada a cada Update; escritas com versão antiga são recusadas.
+	Version int64 `json:"version"`
+}
+
+func (u *User) GetVersion() int64 {
+	return u.Version
+}
+
+func (u *User) SetVersion(version int64) {
+	u.Version = version
+}
+
+// Clone copia o usuário para que repositórios não compartilhem a instância com quem a lê.
+// O pacote de cartas é compartilhado.
+func (u *User) Clone() UserInterface {
+	clone := *u
+	clone.Roles = append([]Role(nil), u.Roles...)
+	return &clone
+}
+
+// QueryField expõe os campos consultáveis em data.Query: id e username.
+func (u *User) QueryField(name string) (any, bool) {
+	switch name {
+	case "id":
+		return u.ID, true
+	case "username":
+		return u.Username, true
+	}
+	return nil, false
 }
 
 func (u *User) GetID() string {
@@ -25,7 +99,71 @@ func (u *User) GetUsername() string {
 	return u.Username
 }
 
+func (u *User) GetCreatedAt() time.Time {
+	return u.CreatedAt
+}
+
+// GetRoles retorna os papéis do usuário; contas sem papel gravado são jogadores.
+func (u *User) GetRoles() []Role {
+	if len(u.Roles) == 0 {
+		return []Role{RolePlayer}
+	}
+	return u.Roles
+}
+
+func (u *User) HasRole(role Role) bool {
+	for _, r := range u.GetRoles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
 }
+
+// NormalizeUsername produz a forma canônica do username: NFKC, sem espaços nas pontas e com
+// case folding, de modo que "Alice", "ALICE" e variantes Unicode equivalentes colidam.
+// Aceita letras, dígitos, '_', '-' e '.', com 3 a 32 caracteres.
+func NormalizeUsername(username string) (string, error) {
+	normalized := cases.Fold().String(norm.NFKC.String(strings.TrimSpace(username)))
+	// O folding pode desfazer a composição; normaliza de novo para a forma estável
+	normalized = norm.NFKC.String(normalized)
+
+	length := utf8.RuneCountInString(normalized)
+	if length < MinUsernameLength || length > MaxUsernameLength {
+		return "", fmt.Errorf("%w: must have between %d and %d characters", ErrInvalidUsername, MinUsernameLength, MaxUsernameLength)
+	}
+	for _, r := range normalized {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			return "", fmt.Errorf("%w: character %q is not allowed", ErrInvalidUsername, r)
+		}
+	}
+	return normalized, nil
+}
+
+// ValidatePassword aplica a política de senha: ao menos 8 caracteres, no máximo 72 bytes,
+// ao menos uma letra e um dígito, e diferente do username.
+func ValidatePassword(username, password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return fmt.Errorf("%w: must have at least %d characters", ErrWeakPassword, MinPasswordLength)
+	}
+	if len(password) > MaxPasswordBytes {
+		return fmt.Errorf("%w: must have at most %d bytes", ErrWeakPassword, MaxPasswordBytes)
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("%w: must contain letters and digits", ErrWeakPassword)
+	}
+	if normalized, err := NormalizeUsername(password); err == nil && normalized == username {
+		return fmt.Errorf("%w: must differ from the username", ErrWeakPassword)
+	}
+	return nil
+}
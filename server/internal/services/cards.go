@@ -2,64 +2,146 @@ package services
 
 import (
 	"cod-server/internal/data"
+	"cod-server/internal/data/catalog"
 	"cod-server/internal/domain"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"strconv"
 
 	"github.com/google/uuid"
 )
 
+// ErrNoEntryID indica uma criação sem o entry_id que o coordenador atribui na borda.
+var ErrNoEntryID = errors.New("entry id is required")
+
 type CardsService struct {
 	cardsRepo data.Repository[domain.CardInterface]
 	usersRepo data.Repository[domain.UserInterface]
+	uow       data.UnitOfWork // Compras e trocas atômicas
+	catalog   CatalogServiceInterface
+}
+
+// CardsServiceConfig reúne as dependências de NewCardsServiceWithConfig.
+type CardsServiceConfig struct {
+	Cards data.Repository[domain.CardInterface]
+	Users data.Repository[domain.UserInterface]
+	// UnitOfWork deve abranger os mesmos repositórios, para que pacotes e trocas sejam
+	// gravados por inteiro ou não sejam gravados. Sem ela cada escrita vale isoladamente.
+	UnitOfWork data.UnitOfWork
+	// Catalog fornece os modelos das cartas criadas; sem ele, usa o catálogo embutido.
+	Catalog CatalogServiceInterface
 }
 
+// NewCardsService cria o serviço sem transações: cada escrita vale isoladamente.
 func NewCardsService(cardsRepo data.Repository[domain.CardInterface], usersRepo data.Repository[domain.UserInterface]) CardsServiceInterface {
-	return &CardsService{cardsRepo: cardsRepo, usersRepo: usersRepo}
+	return NewCardsServiceWithConfig(CardsServiceConfig{Cards: cardsRepo, Users: usersRepo})
+}
+
+// NewCardsServiceWithUnitOfWork usa uow, que deve abranger os mesmos repositórios, para
+// que pacotes e trocas sejam gravados por inteiro ou não sejam gravados.
+func NewCardsServiceWithUnitOfWork(cardsRepo data.Repository[domain.CardInterface], usersRepo data.Repository[domain.UserInterface], uow data.UnitOfWork) CardsServiceInterface {
+	return NewCardsServiceWithConfig(CardsServiceConfig{Cards: cardsRepo, Users: usersRepo, UnitOfWork: uow})
+}
+
+func NewCardsServiceWithConfig(config CardsServiceConfig) CardsServiceInterface {
+	uow := config.UnitOfWork
+	if uow == nil {
+		uow = data.NewDirectUnitOfWork(data.Tx{Users: config.Users, Cards: config.Cards})
+	}
+	catalogService := config.Catalog
+	if catalogService == nil {
+		catalogService = NewCatalogService(catalog.Default())
+	}
+	return &CardsService{cardsRepo: config.Cards, usersRepo: config.Users, uow: uow, catalog: catalogService}
 }
 
 func (cs *CardsService) GetCards(userID string) ([]domain.CardInterface, error) {
-	cards, err := cs.cardsRepo.ListBy(func(c domain.CardInterface) bool {
-		return c.GetOwnerID() == userID
-	})
+	page, err := cs.cardsRepo.Find(ownedBy(userID))
 	if err != nil {
 		return nil, err
 	}
-	return cards, nil
+	return page.Items, nil
+}
+
+// ownedBy consulta as cartas de um dono; no SQL vira WHERE owner_id = ? sobre o índice.
+func ownedBy(userID string) data.Query {
+	return data.NewQuery().Where("owner_id", userID)
 }
 
-func (cs *CardsService) BuyPack(userID string) error {
+func (cs *CardsService) BuyPack(userID, entryID string) error {
 	// Verify user exists
-	_, err := cs.usersRepo.Read(userID)
+	_, err := findUser(cs.usersRepo, userID)
 	if err != nil {
 		return err
 	}
 
 	// Create 5 random cards for the user
-	cardTypes := []string{"rock", "paper", "scissors", "rock", "paper"} // Example card types
-	for i := 0; i < 5; i++ {
-		cardID := uuid.New().String()
-		card := &domain.Card{
-			ID:      cardID,
-			OwnerID: userID,
-			Type:    cardTypes[i%len(cardTypes)],
-		}
-		err := cs.cardsRepo.Create(cardID, card)
-		if err != nil {
-			return err
-		}
+	return cs.createCards(userID, 5, entryID)
+}
+
+// GrantCards cria count cartas para o usuário sem cobrança, pelo mesmo sorteio de BuyPack.
+// A permissão de quem concede é verificada antes, no EventHandler.
+func (cs *CardsService) GrantCards(userID string, count int, entryID string) error {
+	if count <= 0 {
+		return errors.New("count must be positive")
+	}
+	_, err := findUser(cs.usersRepo, userID)
+	if err != nil {
+		return err
 	}
+	return cs.createCards(userID, count, entryID)
+}
 
-	return nil
+// createCards cria count cartas para o usuário com modelos do catálogo, sorteados conforme a
+// raridade. Ids e sorteio derivam de entryID, aleatório e atribuído na borda antes do log, para
+// que todas as réplicas gravem as mesmas cartas sem que o cliente preveja o pacote. O pacote é
+// gravado inteiro ou nada é gravado.
+func (cs *CardsService) createCards(userID string, count int, entryID string) error {
+	if entryID == "" {
+		return ErrNoEntryID
+	}
+	catalog := cs.catalog.Catalog()
+	if catalog == nil {
+		return ErrNoCatalog
+	}
+	pool := catalog.DrawPool()
+	draw := entryRand(entryID)
+	return cs.uow.Do(func(tx data.Tx) error {
+		for i := 0; i < count; i++ {
+			cardID := entryCardID(entryID, i)
+			template := pool[draw.IntN(len(pool))]
+			err := tx.Cards.Create(cardID, template.NewCard(cardID, userID))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// entryCardID é o id da i-ésima carta criada pela entrada.
+func entryCardID(entryID string, i int) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("card/"+entryID+"/"+strconv.Itoa(i))).String()
+}
+
+// entryRand é o sorteio da entrada: a mesma sequência em todas as réplicas.
+func entryRand(entryID string) *rand.Rand {
+	sum := sha256.Sum256([]byte(entryID))
+	return rand.New(rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16])))
 }
 
 func (cs *CardsService) OfferTrade(fromUserID, toUserID, cardID string) error {
 	// Verify that the from user exists
-	_, err := cs.usersRepo.Read(fromUserID)
+	_, err := findUser(cs.usersRepo, fromUserID)
 	if err != nil {
 		return err
 	}
 
 	// Verify that the to user exists
-	_, err = cs.usersRepo.Read(toUserID)
+	_, err = findUser(cs.usersRepo, toUserID)
 	if err != nil {
 		return err
 	}
@@ -81,36 +163,34 @@ func (cs *CardsService) OfferTrade(fromUserID, toUserID, cardID string) error {
 	return nil
 }
 
+// AcceptTrade confere e transfere a carta na mesma transação, para que a posse verificada
+// seja a posse substituída.
 func (cs *CardsService) AcceptTrade(fromUserID, toUserID, cardID string) error {
-	// Verify that the to user exists
-	_, err := cs.usersRepo.Read(toUserID)
-	if err != nil {
-		return err
-	}
-
-	// Verify that the card exists and belongs to the from user
-	card, err := cs.cardsRepo.Read(cardID)
-	if err != nil {
-		return err
-	}
-
-	if card.GetOwnerID() != fromUserID {
-		return domain.ErrCardNotOwnedByUser
-	}
+	return transact(cs.uow, func(tx data.Tx) error {
+		// Verify that the to user exists
+		_, err := findUser(tx.Users, toUserID)
+		if err != nil {
+			return err
+		}
 
-	// Perform the trade - update the card's owner
-	updatedCard := &domain.Card{
-		ID:      card.GetID(),
-		OwnerID: toUserID, // Give the card to the user who accepted the trade
-		Type:    card.GetType(),
-	}
+		// Verify that the card exists and belongs to the from user
+		card, err := tx.Cards.Read(cardID)
+		if err != nil {
+			return err
+		}
 
-	// Update the card in the repository
-	err = cs.cardsRepo.Update(cardID, updatedCard)
-	if err != nil {
-		return err
-	}
+		if card.GetOwnerID() != fromUserID {
+			return domain.ErrCardNotOwnedByUser
+		}
 
-	return nil
-}	
+		// Perform the trade - update the card's owner. A cópia lida mantém modelo e versão.
+		updatedCard, ok := card.(*domain.Card)
+		if !ok {
+			return errors.New("unsupported card type")
+		}
+		updatedCard.OwnerID = toUserID // Give the card to the user who accepted the trade
 
+		// Update the card in the repository
+		return tx.Cards.Update(cardID, updatedCard)
+	})
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"cod-server/internal/domain"
+)
+
+var (
+	// ErrStaleCatalog indica um catálogo de versão igual ou menor que a do atual.
+	ErrStaleCatalog = errors.New("catalog version is not newer than the current one")
+	// ErrNoCatalog indica que nenhum catálogo foi aplicado ainda nesta réplica.
+	ErrNoCatalog = errors.New("card catalog not loaded yet")
+)
+
+// CatalogService guarda o catálogo em memória. No servidor começa vazio e recebe o catálogo
+// pelo log do Raft (sync_catalog) ou por um snapshot, como o resto do estado replicado; nil
+// só faz sentido até a primeira entrada sync_catalog do líder ser aplicada.
+type CatalogService struct {
+	mu      sync.RWMutex
+	catalog *domain.Catalog
+}
+
+func NewCatalogService(initial *domain.Catalog) CatalogServiceInterface {
+	return &CatalogService{catalog: initial}
+}
+
+func (cs *CatalogService) Catalog() *domain.Catalog {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.catalog
+}
+
+func (cs *CatalogService) Load(catalog *domain.Catalog) error {
+	if err := catalog.Validate(); err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.catalog != nil && catalog.Version <= cs.catalog.Version {
+		return fmt.Errorf("%w: got %d, have %d", ErrStaleCatalog, catalog.Version, cs.catalog.Version)
+	}
+	cs.catalog = catalog
+	return nil
+}
+
+func (cs *CatalogService) Restore(catalog *domain.Catalog) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.catalog = catalog
+}
+
+func (cs *CatalogService) Template(id string) (domain.CardTemplate, error) {
+	catalog := cs.Catalog()
+	if catalog == nil {
+		return domain.CardTemplate{}, ErrNoCatalog
+	}
+	template, ok := catalog.Template(id)
+	if !ok {
+		return domain.CardTemplate{}, fmt.Errorf("%w: %s", domain.ErrUnknownTemplate, id)
+	}
+	return template, nil
+}
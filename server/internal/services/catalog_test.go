@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"cod-server/internal/data"
+	"cod-server/internal/domain"
+)
+
+func TestCatalogService_Load(t *testing.T) {
+	v1 := &domain.Catalog{Version: 1, Templates: []domain.CardTemplate{{ID: "rock-pebble", Name: "Pebble", Element: "rock", Power: 1, Rarity: domain.RarityCommon}}}
+	v2 := &domain.Catalog{Version: 2, Templates: append(v1.Templates, domain.CardTemplate{ID: "paper-scroll", Name: "Scroll", Element: "paper", Power: 3, Rarity: domain.RarityRare})}
+	catalogService := NewCatalogService(v1)
+
+	if err := catalogService.Load(v1); !errors.Is(err, ErrStaleCatalog) {
+		t.Errorf("Load of the same version: got %v, want ErrStaleCatalog", err)
+	}
+	if err := catalogService.Load(&domain.Catalog{Version: 3}); !errors.Is(err, domain.ErrInvalidCatalog) {
+		t.Errorf("Load of an empty catalog: got %v, want ErrInvalidCatalog", err)
+	}
+	if err := catalogService.Load(v2); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if template, err := catalogService.Template("paper-scroll"); err != nil || template.Power != 3 {
+		t.Errorf("Template: got %+v (err %v)", template, err)
+	}
+	if _, err := catalogService.Template("missing"); !errors.Is(err, domain.ErrUnknownTemplate) {
+		t.Errorf("Template of a missing id: got %v, want ErrUnknownTemplate", err)
+	}
+}
+
+func TestCatalogService_StartsEmptyUntilReplicated(t *testing.T) {
+	users := data.NewMemoryRepository[domain.UserInterface]()
+	cards := data.NewMemoryRepository[domain.CardInterface]()
+	if err := users.Create("alice", &domain.User{ID: "alice", Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	catalogService := NewCatalogService(nil)
+	cardsService := NewCardsServiceWithConfig(CardsServiceConfig{Cards: cards, Users: users, Catalog: catalogService})
+
+	if _, err := catalogService.Template("rock-pebble"); !errors.Is(err, ErrNoCatalog) {
+		t.Errorf("Template without a catalog: got %v, want ErrNoCatalog", err)
+	}
+	if err := cardsService.BuyPack("alice", "entry-1"); !errors.Is(err, ErrNoCatalog) {
+		t.Errorf("BuyPack without a catalog: got %v, want ErrNoCatalog", err)
+	}
+
+	v1 := &domain.Catalog{Version: 1, Templates: []domain.CardTemplate{{ID: "rock-pebble", Name: "Pebble", Element: "rock", Power: 1, Rarity: domain.RarityCommon}}}
+	if err := catalogService.Load(v1); err != nil {
+		t.Fatalf("first Load: %v", err)
+	}
+	if err := cardsService.BuyPack("alice", "entry-1"); err != nil {
+		t.Errorf("BuyPack after the catalog arrived: %v", err)
+	}
+
+	// Restore aceita qualquer versão, inclusive nenhuma
+	catalogService.Restore(nil)
+	if catalogService.Catalog() != nil {
+		t.Error("Restore(nil) kept the catalog")
+	}
+}
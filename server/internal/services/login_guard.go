@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// LoginGuardConfig define quando e por quanto tempo uma chave (conta ou origem) fica bloqueada.
+type LoginGuardConfig struct {
+	MaxFailures int           // Falhas seguidas até o bloqueio
+	BaseLockout time.Duration // Duração do primeiro bloqueio; dobra a cada bloqueio seguinte
+	MaxLockout  time.Duration // Teto do bloqueio
+	ResetAfter  time.Duration // Inatividade após a qual falhas e bloqueios anteriores são esquecidos
+}
+
+// DefaultLoginGuardConfig bloqueia após 5 falhas, por 30s, 1m, 2m... até 1h.
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		MaxFailures: 5,
+		BaseLockout: 30 * time.Second,
+		MaxLockout:  time.Hour,
+		ResetAfter:  15 * time.Minute,
+	}
+}
+
+type attemptRecord struct {
+	Failures    int       `json:"failures"`
+	Lockouts    int       `json:"lockouts"` // Bloqueios consecutivos; define o expoente do próximo
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// LoginGuard conta falhas de login por chave e aplica bloqueio exponencial.
+// Todo instante vem do evento (carimbado pelo coordenador), nunca do relógio local: aplicado
+// pela FSM, o estado é idêntico em todas as réplicas e o limite não é contornável trocando de nó.
+// O estado entra nos snapshots da FSM, para que um bloqueio sobreviva à compactação do log.
+type LoginGuard struct {
+	mu        sync.Mutex
+	config    LoginGuardConfig
+	records   map[string]*attemptRecord
+	lastSweep time.Time
+}
+
+func NewLoginGuard(config LoginGuardConfig) LoginGuardInterface {
+	return &LoginGuard{
+		config:  config,
+		records: make(map[string]*attemptRecord),
+	}
+}
+
+func (g *LoginGuard) Locked(at time.Time, keys ...string) (string, time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, key := range keys {
+		if rec, ok := g.records[key]; ok && at.Before(rec.LockedUntil) {
+			return key, rec.LockedUntil, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+func (g *LoginGuard) RecordFailure(at time.Time, keys ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sweep(at)
+	for _, key := range keys {
+		rec, ok := g.records[key]
+		if !ok {
+			rec = &attemptRecord{}
+			g.records[key] = rec
+		}
+		if g.idle(rec, at) {
+			*rec = attemptRecord{}
+		}
+
+		rec.Failures++
+		rec.LastFailure = at
+		if rec.Failures >= g.config.MaxFailures {
+			rec.Lockouts++
+			rec.Failures = 0
+			rec.LockedUntil = at.Add(g.lockoutFor(rec.Lockouts))
+		}
+	}
+}
+
+func (g *LoginGuard) RecordSuccess(keys ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, key := range keys {
+		delete(g.records, key)
+	}
+}
+
+func (g *LoginGuard) Stats() map[string]any {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Só para observação: o relógio local não influencia o estado replicado
+	now := time.Now()
+	locked := 0
+	for _, rec := range g.records {
+		if now.Before(rec.LockedUntil) {
+			locked++
+		}
+	}
+	return map[string]any{
+		"tracked_keys": len(g.records),
+		"locked_keys":  locked,
+	}
+}
+
+// lockoutFor calcula BaseLockout * 2^(n-1), limitado a MaxLockout.
+func (g *LoginGuard) lockoutFor(lockouts int) time.Duration {
+	lockout := g.config.BaseLockout
+	for i := 1; i < lockouts && lockout < g.config.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > g.config.MaxLockout {
+		lockout = g.config.MaxLockout
+	}
+	return lockout
+}
+
+// idle informa se o registro está fora de bloqueio e sem falhas há mais de ResetAfter.
+func (g *LoginGuard) idle(rec *attemptRecord, at time.Time) bool {
+	return !at.Before(rec.LockedUntil) && at.Sub(rec.LastFailure) > g.config.ResetAfter
+}
+
+// sweep descarta registros ociosos para manter o mapa limitado; chamado com mu travado.
+func (g *LoginGuard) sweep(at time.Time) {
+	if at.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = at
+	for key, rec := range g.records {
+		if g.idle(rec, at) {
+			delete(g.records, key)
+		}
+	}
+}
+
+// loginGuardState é o conteúdo do LoginGuard gravado nos snapshots. lastSweep também entra,
+// porque decide quando os registros ociosos são descartados.
+type loginGuardState struct {
+	Records   map[string]*attemptRecord `json:"records"`
+	LastSweep time.Time                 `json:"last_sweep"`
+}
+
+// SnapshotState serializa falhas e bloqueios de todas as chaves.
+func (g *LoginGuard) SnapshotState() ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return json.Marshal(loginGuardState{Records: g.records, LastSweep: g.lastSweep})
+}
+
+// RestoreState substitui os registros pelos de um snapshot; data vazio esquece todos.
+func (g *LoginGuard) RestoreState(data []byte) error {
+	state := loginGuardState{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+	}
+	if state.Records == nil {
+		state.Records = make(map[string]*attemptRecord)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.records = state.Records
+	g.lastSweep = state.LastSweep
+	return nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+var guardStart = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func testGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{MaxFailures: 3, BaseLockout: 30 * time.Second, MaxLockout: 2 * time.Minute, ResetAfter: 10 * time.Minute}
+}
+
+func failTimes(guard LoginGuardInterface, at time.Time, n int, keys ...string) {
+	for i := 0; i < n; i++ {
+		guard.RecordFailure(at, keys...)
+	}
+}
+
+func TestLoginGuard_LocksAfterMaxFailures(t *testing.T) {
+	guard := NewLoginGuard(testGuardConfig())
+
+	failTimes(guard, guardStart, 2, "a", "s")
+	if _, _, locked := guard.Locked(guardStart, "a", "s"); locked {
+		t.Fatal("locked before MaxFailures")
+	}
+	guard.RecordFailure(guardStart, "a", "s")
+	key, until, locked := guard.Locked(guardStart, "a", "s")
+	if !locked || key != "a" || !until.Equal(guardStart.Add(30*time.Second)) {
+		t.Fatalf("Locked = %q, %v, %v; want a until +30s", key, until, locked)
+	}
+	if _, _, locked := guard.Locked(guardStart, "other"); locked {
+		t.Error("an unrelated key is locked")
+	}
+	if _, _, locked := guard.Locked(until, "a", "s"); locked {
+		t.Error("still locked at the end of the lockout")
+	}
+}
+
+func TestLoginGuard_BackoffDoublesUpToMax(t *testing.T) {
+	guard := NewLoginGuard(testGuardConfig())
+	at := guardStart
+	for _, want := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 2 * time.Minute} {
+		failTimes(guard, at, 3, "a")
+		_, until, locked := guard.Locked(at, "a")
+		if !locked || until.Sub(at) != want {
+			t.Fatalf("lockout = %v (locked %v), want %v", until.Sub(at), locked, want)
+		}
+		at = until
+	}
+
+	// Após ResetAfter sem falhas, o próximo bloqueio volta à duração base
+	at = at.Add(11 * time.Minute)
+	failTimes(guard, at, 3, "a")
+	if _, until, _ := guard.Locked(at, "a"); until.Sub(at) != 30*time.Second {
+		t.Errorf("lockout after idle period = %v, want 30s", until.Sub(at))
+	}
+}
+
+func TestLoginGuard_SuccessResetsOnlyGivenKeys(t *testing.T) {
+	guard := NewLoginGuard(testGuardConfig())
+	failTimes(guard, guardStart, 2, "a", "s")
+	guard.RecordSuccess("a")
+	guard.RecordFailure(guardStart, "a", "s")
+
+	if _, _, locked := guard.Locked(guardStart, "a"); locked {
+		t.Error("success did not reset the account key")
+	}
+	if key, _, locked := guard.Locked(guardStart, "s"); !locked || key != "s" {
+		t.Error("success reset the source key")
+	}
+}
+
+func TestLoginGuard_SnapshotRestore(t *testing.T) {
+	source := NewLoginGuard(testGuardConfig())
+	failTimes(source, guardStart, 3, "a")
+	failTimes(source, guardStart, 2, "b")
+	data, err := source.SnapshotState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewLoginGuard(testGuardConfig())
+	failTimes(restored, guardStart, 3, "stale")
+	if err := restored.RestoreState(data); err != nil {
+		t.Fatalf("RestoreState: %v", err)
+	}
+	if _, until, locked := restored.Locked(guardStart, "a"); !locked || !until.Equal(guardStart.Add(30*time.Second)) {
+		t.Errorf("lockout of a lost in the snapshot: %v, %v", until, locked)
+	}
+	// As falhas pendentes também sobrevivem: uma a mais bloqueia b
+	restored.RecordFailure(guardStart, "b")
+	if _, _, locked := restored.Locked(guardStart, "b"); !locked {
+		t.Error("failure count of b lost in the snapshot")
+	}
+	if _, _, locked := restored.Locked(guardStart, "stale"); locked {
+		t.Error("restore kept a key absent from the snapshot")
+	}
+
+	if err := restored.RestoreState(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, locked := restored.Locked(guardStart, "a"); locked {
+		t.Error("restoring without login guard state kept the lockout")
+	}
+}
@@ -4,41 +4,76 @@ import (
 	"cod-server/internal/data"
 	"cod-server/internal/domain"
 	"errors"
-
-	uuid "github.com/google/uuid"
 )
 
 type MatchService struct {
 	matchRepo data.Repository[domain.MatchInterface]
 	cardsRepo data.Repository[domain.CardInterface]
 	usersRepo data.Repository[domain.UserInterface]
+	uow       data.UnitOfWork    // Jogadas atômicas
+	rules     domain.CombatRules // Decidem as rodadas
+}
+
+// MatchServiceConfig reúne as dependências de NewMatchServiceWithConfig.
+type MatchServiceConfig struct {
+	Matches data.Repository[domain.MatchInterface]
+	Cards   data.Repository[domain.CardInterface]
+	Users   data.Repository[domain.UserInterface]
+	// UnitOfWork deve abranger os mesmos repositórios; sem ela cada escrita vale isoladamente.
+	UnitOfWork data.UnitOfWork
+	// Rules resolvem os confrontos; sem elas, domain.DefaultCombatRules. Todas as réplicas
+	// precisam usar as mesmas, já que as jogadas são aplicadas em cada uma.
+	Rules domain.CombatRules
 }
 
+// NewMatchService cria o serviço sem transações: cada escrita vale isoladamente.
 func NewMatchService(matchRepo data.Repository[domain.MatchInterface], cardsRepo data.Repository[domain.CardInterface], usersRepo data.Repository[domain.UserInterface]) MatchServiceInterface {
+	return NewMatchServiceWithConfig(MatchServiceConfig{Matches: matchRepo, Cards: cardsRepo, Users: usersRepo})
+}
+
+// NewMatchServiceWithUnitOfWork usa uow, que deve abranger os mesmos repositórios.
+func NewMatchServiceWithUnitOfWork(matchRepo data.Repository[domain.MatchInterface], cardsRepo data.Repository[domain.CardInterface], usersRepo data.Repository[domain.UserInterface], uow data.UnitOfWork) MatchServiceInterface {
+	return NewMatchServiceWithConfig(MatchServiceConfig{Matches: matchRepo, Cards: cardsRepo, Users: usersRepo, UnitOfWork: uow})
+}
+
+func NewMatchServiceWithConfig(config MatchServiceConfig) MatchServiceInterface {
+	uow := config.UnitOfWork
+	if uow == nil {
+		uow = data.NewDirectUnitOfWork(data.Tx{Users: config.Users, Cards: config.Cards, Matches: config.Matches})
+	}
+	rules := config.Rules
+	if rules == nil {
+		rules = domain.DefaultCombatRules()
+	}
 	return &MatchService{
-		matchRepo: matchRepo,
-		cardsRepo: cardsRepo,
-		usersRepo: usersRepo,
+		matchRepo: config.Matches,
+		cardsRepo: config.Cards,
+		usersRepo: config.Users,
+		uow:       uow,
+		rules:     rules,
 	}
 }
 
-func (ms *MatchService) StartMatch(userID string) (domain.MatchInterface, error) {
-	user, err := ms.usersRepo.Read(userID)
+func (ms *MatchService) StartMatch(userID, matchID string) (domain.MatchInterface, error) {
+	if matchID == "" {
+		return nil, ErrNoEntryID
+	}
+	user, err := findUser(ms.usersRepo, userID)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, err
 	}
 
-	id := uuid.New().String()
 	match := &domain.Match{
-		ID:      id,
-		Players: []domain.UserInterface{},
-		Moves:   []map[string]domain.CardInterface{},
-		Scores:  make(map[string]int),
-		Winner:  "",
+		ID:           matchID,
+		Players:      []domain.UserInterface{},
+		Moves:        []map[string]domain.CardInterface{},
+		RoundWinners: []string{},
+		Scores:       make(map[string]int),
+		Winner:       "",
 	}
 	match.AddPlayer(user)
 
-	err = ms.matchRepo.Create(id, match)
+	err = ms.matchRepo.Create(matchID, match)
 	if err != nil {
 		return nil, err
 	}
@@ -46,59 +81,84 @@ func (ms *MatchService) StartMatch(userID string) (domain.MatchInterface, error)
 	return imatch, nil
 }
 
+// JoinMatch relê a partida a cada tentativa: com entradas concorrentes, só uma ocupa a vaga.
 func (ms *MatchService) JoinMatch(userID, matchID string) error {
-	match_raw, err := ms.matchRepo.Read(matchID)
+	user, err := findUser(ms.usersRepo, userID)
 	if err != nil {
 		return err
 	}
 
-	user, err := ms.usersRepo.Read(userID)
-	if err != nil {
-		return errors.New("user not found")
-	}
+	return retryOnConflict(func() error {
+		match_raw, err := ms.matchRepo.Read(matchID)
+		if err != nil {
+			return err
+		}
 
-	err = match_raw.AddPlayer(user)
-	if err != nil {
-		return err
-	}
+		err = match_raw.AddPlayer(user)
+		if err != nil {
+			return err
+		}
 
-	return ms.matchRepo.Update(matchID, match_raw)
+		return ms.matchRepo.Update(matchID, match_raw)
+	})
 }
 
 func (ms *MatchService) SurrenderMatch(userID, matchID string) error {
-	match_raw, err := ms.matchRepo.Read(matchID)
-	if err != nil {
-		return err
-	}
-
-	err = match_raw.Surrender(userID)
-	if err != nil {
-		return err
-	}
-
-	return ms.matchRepo.Update(matchID, match_raw)
+	return retryOnConflict(func() error {
+		match_raw, err := ms.matchRepo.Read(matchID)
+		if err != nil {
+			return err
+		}
+
+		err = match_raw.Surrender(userID)
+		if err != nil {
+			return err
+		}
+
+		return ms.matchRepo.Update(matchID, match_raw)
+	})
 }
 
+// MakeMove lê partida e carta e grava a jogada na mesma transação: a posse conferida não
+// muda por uma troca concorrente antes da gravação.
 func (ms *MatchService) MakeMove(userID, matchID string, cardID string) error {
-	match_raw, err := ms.matchRepo.Read(matchID)
-	if err != nil {
-		return err
-	}
-
-	card, err := ms.cardsRepo.Read(cardID)
-	if err != nil {
-		return err
-	}
-
-	// Domain object should check for card ownership
-	if card.GetOwnerID() != userID {
-		return errors.New("player does not own this card")
-	}
-
-	err = match_raw.MakeMove(userID, card)
-	if err != nil {
-		return err
-	}
-	return ms.matchRepo.Update(matchID, match_raw)
+	return transact(ms.uow, func(tx data.Tx) error {
+		match_raw, err := tx.Matches.Read(matchID)
+		if err != nil {
+			return err
+		}
+
+		card, err := tx.Cards.Read(cardID)
+		if err != nil {
+			return err
+		}
+
+		// Domain object should check for card ownership
+		if card.GetOwnerID() != userID {
+			return errors.New("player does not own this card")
+		}
+
+		err = match_raw.MakeMove(userID, card, ms.rules)
+		if err != nil {
+			return err
+		}
+		return tx.Matches.Update(matchID, match_raw)
+	})
 }
 
+// CancelMatch encerra a partida sem vencedor; partidas já terminadas não podem ser canceladas.
+// Relê a partida e tenta de novo se uma jogada concorrente a alterou antes da gravação.
+func (ms *MatchService) CancelMatch(matchID string) error {
+	return retryOnConflict(func() error {
+		match_raw, err := ms.matchRepo.Read(matchID)
+		if err != nil {
+			return err
+		}
+
+		err = match_raw.Cancel()
+		if err != nil {
+			return err
+		}
+		return ms.matchRepo.Update(matchID, match_raw)
+	})
+}
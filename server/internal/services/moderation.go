@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ModerationService guarda silêncios e banimentos por sala em memória.
+// O estado é reconstruído em cada réplica ao aplicar os eventos de moderação do log do Raft
+// e entra nos snapshots da FSM, para sobreviver à compactação do log.
+type ModerationService struct {
+	mu     sync.RWMutex
+	muted  map[string]time.Time // sala|usuário -> fim do silêncio
+	banned map[string]bool      // sala|usuário
+}
+
+func NewModerationService() ModerationServiceInterface {
+	return &ModerationService{
+		muted:  make(map[string]time.Time),
+		banned: make(map[string]bool),
+	}
+}
+
+func moderationKey(roomID, userID string) string {
+	return roomID + "|" + userID
+}
+
+func (ms *ModerationService) Mute(roomID, userID string, until time.Time) error {
+	if roomID == "" || userID == "" {
+		return errors.New("room and user are required")
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.muted[moderationKey(roomID, userID)] = until
+	return nil
+}
+
+func (ms *ModerationService) Ban(roomID, userID string) error {
+	if roomID == "" || userID == "" {
+		return errors.New("room and user are required")
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.banned[moderationKey(roomID, userID)] = true
+	return nil
+}
+
+func (ms *ModerationService) Pardon(roomID, userID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := moderationKey(roomID, userID)
+	_, wasMuted := ms.muted[key]
+	if !wasMuted && !ms.banned[key] {
+		return errors.New("user has no active sanction in this room")
+	}
+	delete(ms.muted, key)
+	delete(ms.banned, key)
+	return nil
+}
+
+func (ms *ModerationService) Status(roomID, userID string, at time.Time) (bool, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	key := moderationKey(roomID, userID)
+	until, ok := ms.muted[key]
+	return ok && at.Before(until), ms.banned[key]
+}
+
+// moderationState é o conteúdo das sanções gravado nos snapshots, com as chaves sala|usuário.
+type moderationState struct {
+	Muted  map[string]time.Time `json:"muted"`
+	Banned map[string]bool      `json:"banned"`
+}
+
+// SnapshotState serializa silêncios e banimentos.
+func (ms *ModerationService) SnapshotState() ([]byte, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return json.Marshal(moderationState{Muted: ms.muted, Banned: ms.banned})
+}
+
+// RestoreState substitui as sanções pelas de um snapshot; data vazio remove todas.
+func (ms *ModerationService) RestoreState(data []byte) error {
+	state := moderationState{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+	}
+	if state.Muted == nil {
+		state.Muted = make(map[string]time.Time)
+	}
+	if state.Banned == nil {
+		state.Banned = make(map[string]bool)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.muted = state.Muted
+	ms.banned = state.Banned
+	return nil
+}
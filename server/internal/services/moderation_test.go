@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModerationService_SnapshotRestore(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := NewModerationService()
+	if err := source.Mute("lobby", "bob-id", now.Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Ban("lobby", "mallory-id"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := source.SnapshotState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewModerationService()
+	if err := restored.Ban("other", "carol-id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.RestoreState(data); err != nil {
+		t.Fatalf("RestoreState: %v", err)
+	}
+	if muted, _ := restored.Status("lobby", "bob-id", now); !muted {
+		t.Error("mute lost in the snapshot")
+	}
+	if muted, _ := restored.Status("lobby", "bob-id", now.Add(10*time.Minute)); muted {
+		t.Error("restored mute does not end at the recorded instant")
+	}
+	if _, banned := restored.Status("lobby", "mallory-id", now); !banned {
+		t.Error("ban lost in the snapshot")
+	}
+	if _, banned := restored.Status("other", "carol-id", now); banned {
+		t.Error("restore kept a sanction absent from the snapshot")
+	}
+
+	if err := restored.RestoreState(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, banned := restored.Status("lobby", "mallory-id", now); banned {
+		t.Error("restoring without moderation state kept the sanctions")
+	}
+}
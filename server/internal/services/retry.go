@@ -0,0 +1,26 @@
+package services
+
+import (
+	"cod-server/internal/data"
+	"errors"
+)
+
+// MaxConflictRetries limita as novas tentativas de uma operação recusada por data.ErrConflict.
+const MaxConflictRetries = 5
+
+// retryOnConflict reexecuta op enquanto ela falhar por versão desatualizada. op deve reler
+// as entidades que altera a cada execução.
+func retryOnConflict(op func() error) error {
+	err := op()
+	for attempt := 0; attempt < MaxConflictRetries && errors.Is(err, data.ErrConflict); attempt++ {
+		err = op()
+	}
+	return err
+}
+
+// transact executa fn na unidade de trabalho, repetindo a transação inteira em conflito.
+func transact(uow data.UnitOfWork, fn func(tx data.Tx) error) error {
+	return retryOnConflict(func() error {
+		return uow.Do(fn)
+	})
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"cod-server/internal/domain"
+	"time"
 )
 
 // UserServiceInterface define métodos para operações de gerenciamento de contas de usuário.
@@ -10,28 +11,133 @@ type UserServiceInterface interface {
 	Register(username, password string) error
 	// Login autentica um usuário e retorna seu objeto de domínio se bem-sucedido.
 	Login(username, password string) (*domain.UserInterface, error)
+	// PrepareRegistration valida username e senha, gera o id e calcula o hash da senha, sem
+	// gravar nada. Roda na borda, para que a senha não entre no log do Raft.
+	PrepareRegistration(username, password string) (*Registration, error)
+	// CreateRegistered grava a conta preparada por PrepareRegistration.
+	CreateRegistered(registration Registration, createdAt time.Time) error
+	// VerifyLogin confere as credenciais e retorna o hash gravado, ou "" se não conferem.
+	VerifyLogin(username, password string) (string, error)
+	// LoginWithHash autentica quem teve a senha conferida na borda por VerifyLogin; retorna
+	// nil se o hash não for mais o gravado.
+	LoginWithHash(username, passwordHash string) (domain.UserInterface, error)
+	// GetUser recupera um usuário pelo id.
+	GetUser(userID string) (domain.UserInterface, error)
+	// SetRoles substitui os papéis de um usuário.
+	SetRoles(userID string, roles []domain.Role) error
+	// BootstrapAdmin promove a admin o usuário com o username informado (primeiro administrador).
+	BootstrapAdmin(username string) (domain.UserInterface, error)
+	// ChangePassword troca a senha após conferir a senha atual.
+	ChangePassword(userID, oldPassword, newPassword string) error
+	// PreparePasswordChange confere a senha atual e valida a nova, retornando o hash gravado e
+	// o hash da nova senha para SetPasswordHash.
+	PreparePasswordChange(userID, oldPassword, newPassword string) (currentHash, newHash string, err error)
+	// SetPasswordHash troca o hash da senha se o gravado ainda for currentHash.
+	SetPasswordHash(userID, currentHash, newHash string) error
+	// VerifyPassword confere a senha do usuário e retorna o hash gravado.
+	VerifyPassword(userID, password string) (string, error)
+	// DeleteAccount remove a conta após conferir a senha, queimando as cartas do usuário
+	// e abandonando suas partidas em aberto.
+	DeleteAccount(userID, password string) error
+	// DeleteAccountWithHash é DeleteAccount com a senha conferida na borda por VerifyPassword.
+	DeleteAccountWithHash(userID, passwordHash string) error
+	// GetProfile retorna os dados públicos e estatísticas do usuário.
+	GetProfile(userID string) (*Profile, error)
+}
+
+// Registration é uma conta validada na borda, pronta para ser gravada pela FSM.
+type Registration struct {
+	UserID       string `json:"user_id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// Profile resume a conta de um usuário.
+type Profile struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+	CardCount int       `json:"card_count"`
+	Wins      int       `json:"wins"`
+	Losses    int       `json:"losses"`
 }
 
 // CardsServiceInterface define métodos para operações de propriedade e troca de cartas.
 type CardsServiceInterface interface {
 	// GetCards recupera todas as cartas pertencentes a um usuário específico.
 	GetCards(userID string) ([]domain.CardInterface, error)
-	// BuyPack permite que um usuário compre um novo pacote de cartas.
-	BuyPack(userID string) error
+	// BuyPack permite que um usuário compre um novo pacote de cartas. entryID é o id aleatório
+	// que o coordenador atribui à entrada do log; ids e sorteio das cartas derivam dele.
+	BuyPack(userID, entryID string) error
 	// OfferTrade inicia uma troca de carta de um usuário para outro.
 	OfferTrade(fromUserID, toUserID, cardID string) error
 	// AcceptTrade completa uma troca de carta oferecida anteriormente.
 	AcceptTrade(fromUserID, toUserID, cardID string) error
+	// GrantCards cria cartas para um usuário sem cobrança (uso administrativo), derivadas de
+	// entryID como em BuyPack.
+	GrantCards(userID string, count int, entryID string) error
 }
 
 // MatchServiceInterface define métodos para gerenciamento de partidas do jogo.
 type MatchServiceInterface interface {
-	// StartMatch cria e inicia uma nova partida de jogo para um usuário.
-	StartMatch(userID string) (domain.MatchInterface, error)
+	// StartMatch cria e inicia uma nova partida de jogo para um usuário, com o id matchID
+	// atribuído na borda, o mesmo em todas as réplicas.
+	StartMatch(userID, matchID string) (domain.MatchInterface, error)
 	// JoinMatch permite que um usuário participe de uma partida existente.
 	JoinMatch(userID, matchID string) error
 	// SurrenderMatch encerra a partida atual com uma derrota para o usuário.
 	SurrenderMatch(userID, matchID string) error
 	// MakeMove joga uma carta durante uma partida ativa.
 	MakeMove(userID, matchID string, cardID string) error
+	// CancelMatch encerra uma partida sem vencedor (uso administrativo).
+	CancelMatch(matchID string) error
+}
+
+// ModerationServiceInterface define as sanções aplicadas por moderadores em salas de chat.
+// Instantes são passados explicitamente para que todas as réplicas cheguem ao mesmo estado.
+type ModerationServiceInterface interface {
+	// Mute silencia o usuário na sala até o instante informado.
+	Mute(roomID, userID string, until time.Time) error
+	// Ban impede o usuário de participar da sala até ser perdoado.
+	Ban(roomID, userID string) error
+	// Pardon remove silêncio e banimento do usuário na sala.
+	Pardon(roomID, userID string) error
+	// Status informa se o usuário está silenciado ou banido na sala no instante informado.
+	Status(roomID, userID string, at time.Time) (muted bool, banned bool)
+	// SnapshotState serializa as sanções para os snapshots da FSM.
+	SnapshotState() ([]byte, error)
+	// RestoreState substitui as sanções pelas de um snapshot; vazio remove todas.
+	RestoreState(data []byte) error
+}
+
+// LoginGuardInterface define o controle de tentativas de login por conta e por origem.
+// Os instantes são passados explicitamente para que todas as réplicas cheguem ao mesmo estado.
+type LoginGuardInterface interface {
+	// Locked informa a primeira chave bloqueada no instante informado e até quando.
+	Locked(at time.Time, keys ...string) (key string, lockedUntil time.Time, locked bool)
+	// RecordFailure conta uma falha para cada chave, bloqueando as que atingirem o limite.
+	RecordFailure(at time.Time, keys ...string)
+	// RecordSuccess zera o histórico das chaves.
+	RecordSuccess(keys ...string)
+	// Stats retorna contadores para o endpoint de métricas.
+	Stats() map[string]any
+	// SnapshotState serializa falhas e bloqueios para os snapshots da FSM.
+	SnapshotState() ([]byte, error)
+	// RestoreState substitui falhas e bloqueios pelos de um snapshot; vazio esquece todos.
+	RestoreState(data []byte) error
+}
+
+// CatalogServiceInterface guarda o catálogo de modelos de carta em uso nesta réplica.
+// O catálogo muda só por eventos do log do Raft, então todas as réplicas usam o mesmo.
+type CatalogServiceInterface interface {
+	// Catalog retorna o catálogo atual, ou nil se nenhum foi aplicado ainda; não deve ser
+	// alterado por quem o recebe.
+	Catalog() *domain.Catalog
+	// Load troca o catálogo por um de versão maior; versões iguais ou menores são recusadas.
+	Load(catalog *domain.Catalog) error
+	// Restore troca o catálogo pelo de um snapshot, qualquer que seja a versão (nil inclusive).
+	Restore(catalog *domain.Catalog)
+	// Template busca um modelo do catálogo atual.
+	Template(id string) (domain.CardTemplate, error)
 }
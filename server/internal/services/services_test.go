@@ -1,7 +1,15 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"cod-server/internal/data"
+	"cod-server/internal/data/datatest"
 	"cod-server/internal/domain"
 )
 
@@ -14,7 +22,10 @@ func (m *MockUserRepository) Create(id string, entity domain.UserInterface) erro
 	if m.users == nil {
 		m.users = make(map[string]domain.UserInterface)
 	}
-	m.users[id] = entity
+	if _, exists := m.users[id]; exists {
+		return data.ErrAlreadyExists
+	}
+	m.users[id] = data.Clone(entity)
 	return nil
 }
 
@@ -24,22 +35,26 @@ func (m *MockUserRepository) Read(id string) (domain.UserInterface, error) {
 	}
 	user, exists := m.users[id]
 	if !exists {
-		return nil, nil // Simples implementação para testes
+		return nil, data.ErrNotFound
 	}
-	return user, nil
+	return data.Clone(user), nil
 }
 
 func (m *MockUserRepository) Update(id string, entity domain.UserInterface) error {
-	if m.users == nil {
-		m.users = make(map[string]domain.UserInterface)
+	stored, exists := m.users[id]
+	if !exists {
+		return data.ErrNotFound
 	}
-	m.users[id] = entity
+	if err := data.BumpVersion(stored, entity); err != nil {
+		return err
+	}
+	m.users[id] = data.Clone(entity)
 	return nil
 }
 
 func (m *MockUserRepository) Delete(id string) error {
-	if m.users == nil {
-		m.users = make(map[string]domain.UserInterface)
+	if _, exists := m.users[id]; !exists {
+		return data.ErrNotFound
 	}
 	delete(m.users, id)
 	return nil
@@ -63,6 +78,14 @@ func (m *MockUserRepository) ListBy(filter func(domain.UserInterface) bool) ([]d
 	return filteredUsers, nil
 }
 
+func (m *MockUserRepository) Find(q data.Query) (data.Page[domain.UserInterface], error) {
+	return data.ApplyQuery(m.users, q)
+}
+
+func (m *MockUserRepository) Count(q data.Query) (int, error) {
+	return data.CountQuery(m.users, q)
+}
+
 // MockCardRepository é um repositório mock para testes de cartas
 type MockCardRepository struct {
 	cards map[string]domain.CardInterface
@@ -72,7 +95,10 @@ func (m *MockCardRepository) Create(id string, entity domain.CardInterface) erro
 	if m.cards == nil {
 		m.cards = make(map[string]domain.CardInterface)
 	}
-	m.cards[id] = entity
+	if _, exists := m.cards[id]; exists {
+		return data.ErrAlreadyExists
+	}
+	m.cards[id] = data.Clone(entity)
 	return nil
 }
 
@@ -82,22 +108,26 @@ func (m *MockCardRepository) Read(id string) (domain.CardInterface, error) {
 	}
 	card, exists := m.cards[id]
 	if !exists {
-		return nil, nil
+		return nil, data.ErrNotFound
 	}
-	return card, nil
+	return data.Clone(card), nil
 }
 
 func (m *MockCardRepository) Update(id string, entity domain.CardInterface) error {
-	if m.cards == nil {
-		m.cards = make(map[string]domain.CardInterface)
+	stored, exists := m.cards[id]
+	if !exists {
+		return data.ErrNotFound
 	}
-	m.cards[id] = entity
+	if err := data.BumpVersion(stored, entity); err != nil {
+		return err
+	}
+	m.cards[id] = data.Clone(entity)
 	return nil
 }
 
 func (m *MockCardRepository) Delete(id string) error {
-	if m.cards == nil {
-		m.cards = make(map[string]domain.CardInterface)
+	if _, exists := m.cards[id]; !exists {
+		return data.ErrNotFound
 	}
 	delete(m.cards, id)
 	return nil
@@ -121,12 +151,26 @@ func (m *MockCardRepository) ListBy(filter func(domain.CardInterface) bool) ([]d
 	return filteredCards, nil
 }
 
+func (m *MockCardRepository) Find(q data.Query) (data.Page[domain.CardInterface], error) {
+	return data.ApplyQuery(m.cards, q)
+}
+
+func (m *MockCardRepository) Count(q data.Query) (int, error) {
+	return data.CountQuery(m.cards, q)
+}
+
+// Os mocks também cumprem o contrato de data.Repository: os serviços dependem dos erros padrão.
+func TestMockRepositories(t *testing.T) {
+	datatest.TestRepository[domain.UserInterface](t, &MockUserRepository{}, datatest.Users)
+	datatest.TestRepository[domain.CardInterface](t, &MockCardRepository{}, datatest.Cards)
+}
+
 func TestUserService_Register(t *testing.T) {
 	mockRepo := &MockUserRepository{}
 	userService := NewUserService(mockRepo)
 
 	username := "testuser"
-	password := "testpass"
+	password := "testpass1"
 	
 	err := userService.Register(username, password)
 	if err != nil {
@@ -151,13 +195,87 @@ func TestUserService_Register(t *testing.T) {
 	}
 }
 
+func TestUserService_RegisterDuplicateUsername(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	userService := NewUserService(mockRepo)
+
+	if err := userService.Register("TestUser", "testpass1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Mesma conta com outra caixa deve colidir após a normalização
+	err := userService.Register("  testuser ", "otherpass2")
+	if !errors.Is(err, domain.ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+
+	users, _ := mockRepo.List()
+	if len(users) != 1 {
+		t.Errorf("Expected 1 user, got %d", len(users))
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	userService := NewUserService(mockRepo)
+
+	if err := userService.Register("testuser", "testpass1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	users, _ := mockRepo.List()
+	userID := users[0].GetID()
+
+	if err := userService.ChangePassword(userID, "wrongpass1", "newpass22"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+	if err := userService.ChangePassword(userID, "testpass1", "short"); !errors.Is(err, domain.ErrWeakPassword) {
+		t.Errorf("Expected ErrWeakPassword, got %v", err)
+	}
+	if err := userService.ChangePassword(userID, "testpass1", "newpass22"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user, _ := userService.Login("testuser", "testpass1"); user != nil {
+		t.Error("Old password should no longer work")
+	}
+	if user, _ := userService.Login("testuser", "newpass22"); user == nil {
+		t.Error("New password should work")
+	}
+}
+
+func TestUserService_BootstrapAdmin(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	userService := NewUserService(mockRepo)
+
+	if _, err := userService.BootstrapAdmin("alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound before registration, got %v", err)
+	}
+	if err := userService.Register("Alice", "testpass1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	users, _ := mockRepo.List()
+	if roles := users[0].GetRoles(); len(roles) != 1 || roles[0] != domain.RolePlayer {
+		t.Fatalf("Expected a new user to be only a player, got %v", roles)
+	}
+
+	for i := 0; i < 2; i++ {
+		user, err := userService.BootstrapAdmin(" ALICE ")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if roles := user.GetRoles(); len(roles) != 2 || roles[0] != domain.RolePlayer || roles[1] != domain.RoleAdmin {
+			t.Errorf("Expected player and admin roles, got %v", roles)
+		}
+	}
+}
+
 func TestUserService_Login(t *testing.T) {
 	mockRepo := &MockUserRepository{}
 	userService := NewUserService(mockRepo)
 
 	// Create a user first
 	username := "testuser"
-	password := "testpass"
+	password := "testpass1"
 	userService.Register(username, password)
 	
 	// Try to login
@@ -232,4 +350,193 @@ func TestCardsService_GetCards(t *testing.T) {
 			t.Errorf("Expected card owner %s, got %s", userID, card.GetOwnerID())
 		}
 	}
-}
\ No newline at end of file
+}
+
+// TestCardsService_PackIsTheSameOnEveryReplica aplica a mesma entrada em dois repositórios,
+// como duas réplicas aplicando o log: ids e modelos das cartas precisam coincidir.
+func TestCardsService_PackIsTheSameOnEveryReplica(t *testing.T) {
+	apply := func(entryID string) map[string]string {
+		t.Helper()
+		users := data.NewMemoryRepository[domain.UserInterface]()
+		cards := data.NewMemoryRepository[domain.CardInterface]()
+		if err := users.Create("alice", &domain.User{ID: "alice", Username: "alice"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewCardsService(cards, users).BuyPack("alice", entryID); err != nil {
+			t.Fatalf("BuyPack: %v", err)
+		}
+		created, err := cards.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pack := make(map[string]string, len(created))
+		for _, card := range created {
+			pack[card.GetID()] = card.(*domain.Card).TemplateID
+		}
+		return pack
+	}
+
+	first, second := apply("entry-1"), apply("entry-1")
+	if len(first) != 5 || !reflect.DeepEqual(first, second) {
+		t.Errorf("replicas disagree on the pack:\n%v\n%v", first, second)
+	}
+	for id := range apply("entry-2") {
+		if _, ok := first[id]; ok {
+			t.Errorf("card id %s reused by another entry", id)
+		}
+	}
+
+	users := data.NewMemoryRepository[domain.UserInterface]()
+	users.Create("alice", &domain.User{ID: "alice", Username: "alice"})
+	if err := NewCardsService(data.NewMemoryRepository[domain.CardInterface](), users).BuyPack("alice", ""); !errors.Is(err, ErrNoEntryID) {
+		t.Errorf("BuyPack without an entry id: got %v, want ErrNoEntryID", err)
+	}
+}
+
+// yieldingRepository cede o processador entre a leitura e a escrita de quem a chama, para
+// que leituras concorrentes da mesma versão aconteçam mesmo com um só núcleo.
+type yieldingRepository[T any] struct {
+	data.Repository[T]
+}
+
+func (r yieldingRepository[T]) Read(id string) (T, error) {
+	entity, err := r.Repository.Read(id)
+	runtime.Gosched()
+	return entity, err
+}
+
+// TestMatchService_ConcurrentJoinAndMoves martela uma partida a partir de várias goroutines.
+// Com versões, só uma entrada ocupa a vaga livre e nenhuma jogada aceita se perde.
+func TestMatchService_ConcurrentJoinAndMoves(t *testing.T) {
+	const contenders = 32
+
+	users := data.NewMemoryRepository[domain.UserInterface]()
+	cards := data.NewMemoryRepository[domain.CardInterface]()
+	matches := data.NewMemoryRepository[domain.MatchInterface]()
+	uow, err := data.NewMemoryUnitOfWork(data.Tx{Users: users, Cards: cards, Matches: matches})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i := 0; i <= contenders; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		users.Create(userID, &domain.User{ID: userID, Username: userID})
+		cards.Create("card-"+userID, &domain.Card{ID: "card-" + userID, OwnerID: userID, Type: "rock"})
+	}
+	matchService := NewMatchServiceWithUnitOfWork(yieldingRepository[domain.MatchInterface]{matches}, cards, users, uow)
+
+	match, err := matchService.StartMatch("user-0", "match-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	matchID := match.GetID()
+
+	var joined atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 1; i <= contenders; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			<-start
+			if matchService.JoinMatch(userID, matchID) == nil {
+				joined.Add(1)
+			}
+		}(fmt.Sprintf("user-%d", i))
+	}
+	close(start)
+	wg.Wait()
+
+	if joined.Load() != 1 {
+		t.Fatalf("Expected exactly 1 successful join, got %d", joined.Load())
+	}
+	stored, _ := matches.Read(matchID)
+	players := stored.GetPlayers()
+	if len(players) != 2 {
+		t.Fatalf("Expected 2 players, got %d", len(players))
+	}
+
+	// Pedra contra pedra empata: a partida não termina e cada rodada completa abre outra
+	moved := make([]atomic.Int32, len(players))
+	start = make(chan struct{})
+	for i := 0; i < contenders; i++ {
+		for p, player := range players {
+			wg.Add(1)
+			go func(p int, userID string) {
+				defer wg.Done()
+				<-start
+				if matchService.MakeMove(userID, matchID, "card-"+userID) == nil {
+					moved[p].Add(1)
+				}
+			}(p, player.GetID())
+		}
+	}
+	close(start)
+	wg.Wait()
+
+	stored, _ = matches.Read(matchID)
+	rounds := stored.(*domain.Match).Moves
+	for p, player := range players {
+		recorded := 0
+		for _, round := range rounds {
+			if _, ok := round[player.GetID()]; ok {
+				recorded++
+			}
+		}
+		if int(moved[p].Load()) != recorded {
+			t.Errorf("Expected %d accepted moves for %s to be recorded, got %d", moved[p].Load(), player.GetID(), recorded)
+		}
+	}
+}
+
+func TestMatchService_UsesInjectedRules(t *testing.T) {
+	users := data.NewMemoryRepository[domain.UserInterface]()
+	cards := data.NewMemoryRepository[domain.CardInterface]()
+	matches := data.NewMemoryRepository[domain.MatchInterface]()
+	for id, power := range map[string]int{"alice": 1, "bob": 5} {
+		users.Create(id, &domain.User{ID: id, Username: id})
+		cards.Create("card-"+id, &domain.Card{ID: "card-" + id, OwnerID: id, Type: "rock", Power: power})
+	}
+	// Pelas regras padrão bob venceria pelo poder; estas dão +10 às cartas de alice
+	favorAlice := func(card, _ domain.CardInterface, power int) int {
+		if card.GetOwnerID() == "alice" {
+			return power + 10
+		}
+		return power
+	}
+	matchService := NewMatchServiceWithConfig(MatchServiceConfig{
+		Matches: matches,
+		Cards:   cards,
+		Users:   users,
+		Rules:   domain.NewElementRules(favorAlice),
+	})
+
+	match, err := matchService.StartMatch("alice", "match-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := matchService.JoinMatch("bob", match.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	for round := 0; round < domain.RoundsToWin; round++ {
+		for _, id := range []string{"alice", "bob"} {
+			if err := matchService.MakeMove(id, match.GetID(), "card-"+id); err != nil {
+				t.Fatalf("round %d, %s: %v", round+1, id, err)
+			}
+		}
+	}
+
+	stored, _ := matches.Read(match.GetID())
+	if winner, _ := stored.GetWinner(); winner != "alice" {
+		t.Errorf("winner = %q, want alice by the injected rules", winner)
+	}
+	// O histórico traz os vencedores decididos na jogada, sem resolver as rodadas de novo
+	rounds, err := data.RoundsOf(matches, match.GetID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, round := range rounds {
+		if round.WinnerID != "alice" {
+			t.Errorf("round %d winner = %q, want alice", round.Number, round.WinnerID)
+		}
+	}
+}
@@ -3,37 +3,176 @@ package services
 import (
 	"cod-server/internal/data"
 	"cod-server/internal/domain"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 
 	uuid "github.com/google/uuid"
 )
 
+// ErrWrongPassword indica que a senha atual informada não confere.
+var ErrWrongPassword = errors.New("wrong password")
+
+// ErrUserNotFound indica que o usuário não existe; envolve data.ErrNotFound.
+var ErrUserNotFound = fmt.Errorf("user not found: %w", data.ErrNotFound)
+
 type UserService struct {
-	userRepo data.Repository[domain.UserInterface]
+	userRepo  data.Repository[domain.UserInterface]
+	cardsRepo data.Repository[domain.CardInterface]  // Cartas queimadas e contadas no perfil
+	matchRepo data.Repository[domain.MatchInterface] // Partidas abandonadas e histórico do perfil
+	uow       data.UnitOfWork                        // Torna DeleteAccount atômico
+}
+
+// UserServiceConfig agrupa as dependências do UserService.
+type UserServiceConfig struct {
+	Users   data.Repository[domain.UserInterface]
+	Cards   data.Repository[domain.CardInterface]
+	Matches data.Repository[domain.MatchInterface]
+	// UnitOfWork abrange os três repositórios acima; sem ela as escritas não são atômicas.
+	UnitOfWork data.UnitOfWork
 }
 
+// NewUserService cria um serviço só com o repositório de usuários; DeleteAccount e
+// GetProfile exigem os repositórios de cartas e partidas de NewUserServiceWithConfig.
 func NewUserService(userRepo data.Repository[domain.UserInterface]) UserServiceInterface {
-	return &UserService{userRepo: userRepo}
+	return NewUserServiceWithConfig(UserServiceConfig{Users: userRepo})
+}
+
+func NewUserServiceWithConfig(config UserServiceConfig) UserServiceInterface {
+	uow := config.UnitOfWork
+	if uow == nil {
+		uow = data.NewDirectUnitOfWork(data.Tx{Users: config.Users, Cards: config.Cards, Matches: config.Matches})
+	}
+	return &UserService{
+		userRepo:  config.Users,
+		cardsRepo: config.Cards,
+		matchRepo: config.Matches,
+		uow:       uow,
+	}
 }
 
+// Register valida username e senha e grava a conta em um passo só, para uso fora do cluster.
+// No cluster a borda chama PrepareRegistration e a FSM, CreateRegistered.
 func (us *UserService) Register(username, password string) error {
-	id := uuid.New().String()
+	registration, err := us.PrepareRegistration(username, password)
+	if err != nil {
+		return err
+	}
+	return us.CreateRegistered(*registration, time.Now().UTC())
+}
+
+// PrepareRegistration normaliza o username, aplica a política de senha e calcula o hash.
+// A checagem de username em uso aqui é só adiantada; quem decide é CreateRegistered.
+func (us *UserService) PrepareRegistration(username, password string) (*Registration, error) {
+	username, err := domain.NormalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.ValidatePassword(username, password); err != nil {
+		return nil, err
+	}
+	existing, err := us.findByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, domain.ErrUsernameTaken
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &Registration{
+		UserID:       uuid.New().String(),
+		Username:     username,
+		PasswordHash: string(hashedPassword),
+	}, nil
+}
+
+// CreateRegistered grava a conta e garante unicidade pelo username normalizado.
+// Roda na FSM, em ordem, em todas as réplicas; o índice único do SQL é a última barreira.
+// Id, hash e createdAt vêm prontos para que todas as réplicas gravem a mesma conta.
+func (us *UserService) CreateRegistered(registration Registration, createdAt time.Time) error {
+	username, err := domain.NormalizeUsername(registration.Username)
 	if err != nil {
 		return err
 	}
+	if username != registration.Username || registration.UserID == "" || registration.PasswordHash == "" {
+		return errors.New("invalid registration")
+	}
+	existing, err := us.findByUsername(username)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return domain.ErrUsernameTaken
+	}
+
 	user := &domain.User{
-		ID:       id,
-		Username: username,
-		Password: string(hashedPassword),
-		Cards:    nil,
+		ID:        registration.UserID,
+		Username:  username,
+		Password:  registration.PasswordHash,
+		Roles:     []domain.Role{domain.RolePlayer},
+		CreatedAt: createdAt,
+		Cards:     nil,
 	}
-	return us.userRepo.Create(id, user)
+	return us.userRepo.Create(user.ID, user)
 }
 
 func (us *UserService) Login(username, password string) (*domain.UserInterface, error) {
+	username, err := domain.NormalizeUsername(username)
+	if err != nil {
+		return nil, nil // Username impossível: mesmas credenciais inválidas, sem revelar o motivo
+	}
+	user, err := us.findByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.CheckPassword(password) {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// VerifyLogin é o Login feito na borda: retorna o hash gravado, que a FSM compara em LoginWithHash.
+func (us *UserService) VerifyLogin(username, password string) (string, error) {
+	user, err := us.Login(username, password)
+	if err != nil || user == nil {
+		return "", err
+	}
+	return passwordHash(*user)
+}
+
+// LoginWithHash confere, na ordem do log, que a senha validada na borda ainda é a da conta:
+// uma troca de senha aplicada antes invalida o login.
+func (us *UserService) LoginWithHash(username, passwordHash string) (domain.UserInterface, error) {
+	username, err := domain.NormalizeUsername(username)
+	if err != nil {
+		return nil, nil
+	}
+	user, err := us.findByUsername(username)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if !sameHash(user, passwordHash) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// findByUsername busca o usuário pelo username já normalizado; retorna nil se não existir.
+// Usa a busca indexada do repositório quando disponível, para que o custo do login não
+// cresça com o número de usuários.
+func (us *UserService) findByUsername(username string) (domain.UserInterface, error) {
+	if finder, ok := us.userRepo.(data.UserFinder); ok {
+		return finder.FindByUsername(username)
+	}
 	users, err := us.userRepo.ListBy(func(u domain.UserInterface) bool {
-		return u.GetUsername() == username && u.CheckPassword(password)
+		return u.GetUsername() == username
 	})
 	if err != nil {
 		return nil, err
@@ -41,5 +180,260 @@ func (us *UserService) Login(username, password string) (*domain.UserInterface,
 	if len(users) == 0 {
 		return nil, nil
 	}
-	return &users[0], nil 
+	return users[0], nil
+}
+
+func (us *UserService) GetUser(userID string) (domain.UserInterface, error) {
+	return findUser(us.userRepo, userID)
+}
+
+func (us *UserService) SetRoles(userID string, roles []domain.Role) error {
+	if len(roles) == 0 {
+		return errors.New("at least one role is required")
+	}
+	for _, role := range roles {
+		if !domain.ValidRole(role) {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	return retryOnConflict(func() error {
+		user, err := us.readUser(userID)
+		if err != nil {
+			return err
+		}
+		updated := *user
+		updated.Roles = append([]domain.Role(nil), roles...)
+		return us.userRepo.Update(userID, &updated)
+	})
+}
+
+// BootstrapAdmin acrescenta o papel admin ao usuário com o username informado. Cria o primeiro
+// administrador, que concede os demais papéis com set_roles; já ser admin não é erro.
+func (us *UserService) BootstrapAdmin(username string) (domain.UserInterface, error) {
+	normalized, err := domain.NormalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	user, err := us.findByUsername(normalized)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	roles := user.GetRoles()
+	for _, role := range roles {
+		if role == domain.RoleAdmin {
+			return user, nil
+		}
+	}
+	roles = append(append([]domain.Role(nil), roles...), domain.RoleAdmin)
+	if err := us.SetRoles(user.GetID(), roles); err != nil {
+		return nil, err
+	}
+	return us.GetUser(user.GetID())
+}
+
+func (us *UserService) ChangePassword(userID, oldPassword, newPassword string) error {
+	currentHash, newHash, err := us.PreparePasswordChange(userID, oldPassword, newPassword)
+	if err != nil {
+		return err
+	}
+	return us.SetPasswordHash(userID, currentHash, newHash)
+}
+
+// PreparePasswordChange roda na borda: confere a senha atual, aplica a política à nova e
+// calcula seu hash, para que nenhuma das duas entre no log.
+func (us *UserService) PreparePasswordChange(userID, oldPassword, newPassword string) (string, string, error) {
+	user, err := us.readUser(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if !user.CheckPassword(oldPassword) {
+		return "", "", ErrWrongPassword
+	}
+	if oldPassword == newPassword {
+		return "", "", fmt.Errorf("%w: must differ from the current password", domain.ErrWeakPassword)
+	}
+	if err := domain.ValidatePassword(user.Username, newPassword); err != nil {
+		return "", "", err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return user.Password, string(hashedPassword), nil
+}
+
+// SetPasswordHash grava newHash se o hash atual ainda for currentHash; se a senha mudou entre a
+// verificação na borda e a aplicação, a troca é recusada como senha errada.
+func (us *UserService) SetPasswordHash(userID, currentHash, newHash string) error {
+	if newHash == "" {
+		return errors.New("missing password hash")
+	}
+	return retryOnConflict(func() error {
+		user, err := us.readUser(userID)
+		if err != nil {
+			return err
+		}
+		if !sameHash(user, currentHash) {
+			return ErrWrongPassword
+		}
+		updated := *user
+		updated.Password = newHash
+		return us.userRepo.Update(userID, &updated)
+	})
+}
+
+// VerifyPassword confere a senha do usuário e retorna o hash gravado.
+func (us *UserService) VerifyPassword(userID, password string) (string, error) {
+	user, err := us.readUser(userID)
+	if err != nil {
+		return "", err
+	}
+	if !user.CheckPassword(password) {
+		return "", ErrWrongPassword
+	}
+	return user.Password, nil
+}
+
+func (us *UserService) DeleteAccount(userID, password string) error {
+	passwordHash, err := us.VerifyPassword(userID, password)
+	if err != nil {
+		return err
+	}
+	return us.DeleteAccountWithHash(userID, passwordHash)
+}
+
+// DeleteAccountWithHash remove a conta se o hash da senha ainda for o conferido na borda.
+func (us *UserService) DeleteAccountWithHash(userID, passwordHash string) error {
+	if us.cardsRepo == nil || us.matchRepo == nil {
+		return errors.New("account deletion is not configured")
+	}
+	user, err := us.readUser(userID)
+	if err != nil {
+		return err
+	}
+	if !sameHash(user, passwordHash) {
+		return ErrWrongPassword
+	}
+
+	// Partidas, cartas e usuário mudam juntos ou nada muda
+	return transact(us.uow, func(tx data.Tx) error {
+		// Partidas em aberto: com adversário, a saída conta como desistência; sozinho, a partida é cancelada
+		matches, err := data.MatchesOf(tx.Matches, userID)
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			if _, err := match.GetWinner(); err == nil || match.IsCancelled() {
+				continue
+			}
+			if len(match.GetPlayers()) >= 2 {
+				err = match.Surrender(userID)
+			} else {
+				err = match.Cancel()
+			}
+			if err != nil {
+				return err
+			}
+			if err := tx.Matches.Update(match.GetID(), match); err != nil {
+				return err
+			}
+		}
+
+		// Cartas são queimadas: não voltam a circular
+		page, err := tx.Cards.Find(ownedBy(userID))
+		if err != nil {
+			return err
+		}
+		for _, card := range page.Items {
+			if err := tx.Cards.Delete(card.GetID()); err != nil {
+				return err
+			}
+		}
+
+		return tx.Users.Delete(userID)
+	})
+}
+
+func (us *UserService) GetProfile(userID string) (*Profile, error) {
+	user, err := findUser(us.userRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	profile := &Profile{
+		UserID:    user.GetID(),
+		Username:  user.GetUsername(),
+		CreatedAt: user.GetCreatedAt(),
+	}
+	for _, role := range user.GetRoles() {
+		profile.Roles = append(profile.Roles, string(role))
+	}
+
+	if us.cardsRepo != nil {
+		count, err := us.cardsRepo.Count(ownedBy(userID))
+		if err != nil {
+			return nil, err
+		}
+		profile.CardCount = count
+	}
+
+	if us.matchRepo != nil {
+		matches, err := data.MatchesOf(us.matchRepo, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			winner, err := match.GetWinner()
+			if err != nil {
+				continue // Em andamento ou cancelada
+			}
+			if winner == userID {
+				profile.Wins++
+			} else {
+				profile.Losses++
+			}
+		}
+	}
+	return profile, nil
+}
+
+// readUser lê o usuário como *domain.User, necessário para gravar uma cópia alterada.
+func (us *UserService) readUser(userID string) (*domain.User, error) {
+	existing, err := findUser(us.userRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	user, ok := existing.(*domain.User)
+	if !ok {
+		return nil, errors.New("unsupported user type")
+	}
+	return user, nil
+}
+
+// findUser lê o usuário, trocando data.ErrNotFound por ErrUserNotFound.
+func findUser(repo data.Repository[domain.UserInterface], userID string) (domain.UserInterface, error) {
+	user, err := repo.Read(userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// passwordHash lê o hash gravado do usuário.
+func passwordHash(user domain.UserInterface) (string, error) {
+	u, ok := user.(*domain.User)
+	if !ok {
+		return "", errors.New("unsupported user type")
+	}
+	return u.Password, nil
+}
+
+// sameHash informa se hash é o hash de senha gravado do usuário.
+func sameHash(user domain.UserInterface, hash string) bool {
+	stored, err := passwordHash(user)
+	return err == nil && hash != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1
 }
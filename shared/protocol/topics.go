@@ -0,0 +1,41 @@
+package protocol
+
+import "strings"
+
+// Pedidos e respostas trafegam em tópicos que começam pelo client id MQTT do cliente:
+// pedidos em requests/{client_id}/<tópico> e respostas em replies/{client_id}/<método>.
+// O broker só deixa cada conexão escrever em requests/ e ler em replies/ sob o próprio
+// client id (ver server/deploy/mosquitto), então o servidor confia no client id do tópico
+// e nenhum cliente lê as respostas (e os tokens) de outro.
+const (
+	RequestTopicRoot = "requests"
+	ReplyTopicRoot   = "replies"
+)
+
+// RequestTopic monta o tópico em que o cliente publica um pedido.
+func RequestTopic(clientID, topic string) string {
+	return RequestTopicRoot + "/" + clientID + "/" + topic
+}
+
+// RequestFilter monta o filtro que o servidor assina para receber o tópico de qualquer cliente.
+func RequestFilter(topic string) string {
+	return RequestTopicRoot + "/+/" + topic
+}
+
+// ClientIDFromTopic extrai o client id de um tópico de pedido.
+func ClientIDFromTopic(topic string) (string, bool) {
+	root, rest, ok := strings.Cut(topic, "/")
+	if !ok || root != RequestTopicRoot {
+		return "", false
+	}
+	clientID, _, ok := strings.Cut(rest, "/")
+	if !ok || clientID == "" {
+		return "", false
+	}
+	return clientID, true
+}
+
+// ReplyTopic monta o tópico privado em que o cliente recebe as respostas de um método.
+func ReplyTopic(clientID, method string) string {
+	return ReplyTopicRoot + "/" + clientID + "/" + method
+}